@@ -2,6 +2,7 @@ package cardatatransfer
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 
@@ -39,18 +40,56 @@ type carDataTransfer struct {
 	dt       datatransfer.Manager
 	supplier BlockStoreSupplier
 	stores   *stores.ReadOnlyBlockstores
+	pricer   RetrievalPricer
+	// payments is nil when retrievals are free.
+	payments *paymentRevalidator
+	policy   *policyEnforcer
+	// selectorBudget is the complexity budget of the selectors that are served.
+	selectorBudget int
+	// stats is nil when transfers are not recorded.
+	stats *RetrievalStats
 }
 
-func StartCarDataTransfer(dt datatransfer.Manager, supplier BlockStoreSupplier) error {
+// StartCarDataTransfer starts serving the content supplied by the given supplier over the given
+// data transfer manager. By default, all retrievals are free; a RetrievalPricer and a
+// PaymentVerifier can be set to serve priced retrievals instead, and a RetrievalPolicy can be set
+// to restrict the retrievals served. Transfers are recorded if RetrievalStats are set.
+//
+// Retrievals select the content to retrieve either with the selector of their proposal or, when
+// unspecified, with a selector that explores the entire DAG. Selectors may interpret nodes as
+// unixfs, which allows subsets of unixfs DAGs to be retrieved, and are rejected if they exceed the
+// complexity budget.
+//
+// See: WithRetrievalPricer, WithPaymentVerifier, WithPaymentDatastore, WithRetrievalPolicy,
+// WithSelectorComplexityBudget, WithRetrievalStats.
+func StartCarDataTransfer(dt datatransfer.Manager, supplier BlockStoreSupplier, o ...Option) error {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return err
+	}
 	cdt := &carDataTransfer{
-		dt:       dt,
-		supplier: supplier,
-		stores:   stores.NewReadOnlyBlockstores(),
+		dt:             dt,
+		supplier:       supplier,
+		stores:         stores.NewReadOnlyBlockstoresWithLimit(opts.policy.MaxOpenBlockstores),
+		pricer:         opts.pricer,
+		policy:         newPolicyEnforcer(opts.policy),
+		selectorBudget: opts.selectorBudget,
+		stats:          opts.stats,
 	}
-	err := dt.RegisterVoucherType(&DealProposal{}, cdt)
+	err = dt.RegisterVoucherType(&DealProposal{}, cdt)
 	if err != nil {
 		return err
 	}
+	if cdt.pricer != nil {
+		cdt.payments = newPaymentRevalidator(opts.verifier, opts.paymentDs)
+	}
+	// Transfers are only revalidated when they are paid for or throttled.
+	if cdt.payments != nil || cdt.policy.maxBytesPerSecond > 0 {
+		err = dt.RegisterRevalidator(&DealPayment{}, cdt)
+		if err != nil {
+			return err
+		}
+	}
 	err = dt.RegisterVoucherResultType(&DealResponse{})
 	if err != nil {
 		return err
@@ -86,7 +125,7 @@ func (cdt *carDataTransfer) ValidatePush(isRestart bool, _ datatransfer.ChannelI
 }
 
 // ValidatePull validates a pull request received from the peer that will receive data
-func (cdt *carDataTransfer) ValidatePull(isRestart bool, _ datatransfer.ChannelID, receiver peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) (datatransfer.VoucherResult, error) {
+func (cdt *carDataTransfer) ValidatePull(isRestart bool, chid datatransfer.ChannelID, receiver peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) (datatransfer.VoucherResult, error) {
 	proposal, ok := voucher.(*DealProposal)
 	if !ok {
 		return nil, errors.New("wrong voucher type")
@@ -112,15 +151,25 @@ func (cdt *carDataTransfer) ValidatePull(isRestart bool, _ datatransfer.ChannelI
 	}
 
 	// If the validation is for a restart request, return nil, which means
-	// the data-transfer should not be explicitly paused or resumed
+	// the data-transfer should not be explicitly paused or resumed, unless
+	// a priced retrieval awaits payment.
 	if isRestart {
+		if cdt.payments != nil {
+			fundsNeeded, err := cdt.payments.restore(chid, proposal)
+			if err != nil {
+				return &DealResponse{ID: proposal.ID, Status: DealStatusErrored, Message: err.Error()}, err
+			}
+			if fundsNeeded != nil {
+				return fundsNeeded, datatransfer.ErrPause
+			}
+		}
 		return nil, nil
 	}
 
 	// attempt to setup the deal
 	providerDealID := ProviderDealID{DealID: proposal.ID, Receiver: receiver}
 
-	status, err := cdt.attemptAcceptDeal(providerDealID, proposal)
+	status, err := cdt.attemptAcceptDeal(chid, providerDealID, proposal, selector)
 
 	response := DealResponse{
 		ID:     proposal.ID,
@@ -131,10 +180,33 @@ func (cdt *carDataTransfer) ValidatePull(isRestart bool, _ datatransfer.ChannelI
 		response.Message = err.Error()
 		return &response, err
 	}
+
+	// Priced retrievals with an unseal price are paused until the unseal price is paid.
+	if cdt.payments != nil {
+		fundsNeeded, err := cdt.payments.track(chid, proposal)
+		if err != nil {
+			response.Status = DealStatusErrored
+			response.Message = err.Error()
+			return &response, err
+		}
+		if fundsNeeded != nil {
+			return fundsNeeded, datatransfer.ErrPause
+		}
+	}
 	return &response, nil
 }
 
-func (cdt *carDataTransfer) attemptAcceptDeal(providerDealID ProviderDealID, proposal *DealProposal) (DealStatus, error) {
+func (cdt *carDataTransfer) attemptAcceptDeal(chid datatransfer.ChannelID, providerDealID ProviderDealID, proposal *DealProposal, selector ipld.Node) (DealStatus, error) {
+	// check the peer is allowed to retrieve
+	if err := cdt.policy.allowed(providerDealID.Receiver); err != nil {
+		return DealStatusRejected, err
+	}
+
+	// check the selector is safe to serve
+	if err := checkSelector(selector, cdt.selectorBudget); err != nil {
+		return DealStatusRejected, err
+	}
+
 	if proposal.PieceCID == nil {
 		return DealStatusErrored, errors.New("must specific piece CID")
 	}
@@ -153,12 +225,49 @@ func (cdt *carDataTransfer) attemptAcceptDeal(providerDealID ProviderDealID, pro
 	}
 	contextID := dmh.Digest
 
+	// check the proposed terms against the ask, if retrievals are priced
+	if cdt.pricer != nil {
+		ask, err := cdt.pricer.GetAsk(context.TODO(), providerDealID.Receiver, contextID)
+		if err != nil {
+			return DealStatusRejected, fmt.Errorf("error getting ask: %w", err)
+		}
+		if err := checkAsk(proposal, ask); err != nil {
+			return DealStatusRejected, err
+		}
+	}
+
+	// check the transfer is within the limits of the retrieval policy
+	if cdt.stores.Full() {
+		return DealStatusRejected, ErrTooManyOpenBlockstores
+	}
+	if err := cdt.policy.start(chid, contextID); err != nil {
+		return DealStatusRejected, err
+	}
+
 	// read blockstore from supplier
 	bs, err := cdt.supplier.ReadOnlyBlockstore(contextID)
 	if err != nil {
+		cdt.policy.finish(chid)
 		return DealStatusErrored, fmt.Errorf("error reading blockstore: %w", err)
 	}
-	cdt.stores.Track(providerDealID.String(), bs)
+	switch err := cdt.stores.TryTrack(providerDealID.String(), bs); err {
+	case nil:
+	case stores.ErrLimitReached:
+		// The limit was reached by concurrent proposals since checked above.
+		cdt.policy.finish(chid)
+		if err := bs.Close(); err != nil {
+			log.Errorf("failed to close blockstore: %s", err)
+		}
+		return DealStatusRejected, ErrTooManyOpenBlockstores
+	default:
+		// The blockstore already tracked for the deal is used instead.
+		if err := bs.Close(); err != nil {
+			log.Errorf("failed to close blockstore: %s", err)
+		}
+	}
+	if cdt.stats != nil {
+		cdt.stats.start(chid, contextID)
+	}
 	return DealStatusAccepted, nil
 }
 
@@ -183,7 +292,118 @@ func (cdt *carDataTransfer) eventListener(event datatransfer.Event, channelState
 		if err != nil {
 			log.Errorf("termination error: %s", err)
 		}
+		if cdt.payments != nil {
+			// Keep the payment state of retrievals that may be restarted.
+			restartable := channelState.Status() != datatransfer.Completed && event.Code != datatransfer.Cancel
+			cdt.payments.untrack(channelState.ChannelID(), restartable)
+		}
+		cdt.policy.finish(channelState.ChannelID())
+		if cdt.stats != nil {
+			err := cdt.stats.finish(context.TODO(), channelState.ChannelID(), channelState.Sent(), retrievalOutcome(event, channelState))
+			if err != nil {
+				log.Errorf("failed to record retrieval: %s", err)
+			}
+		}
+	}
+}
+
+func retrievalOutcome(event datatransfer.Event, channelState datatransfer.ChannelState) RetrievalOutcome {
+	switch {
+	case channelState.Status() == datatransfer.Completed:
+		return RetrievalCompleted
+	case event.Code == datatransfer.Cancel:
+		return RetrievalCancelled
+	default:
+		return RetrievalFailed
+	}
+}
+
+// Revalidate revalidates the payments of priced retrievals. Paid retrievals that are throttled
+// are resumed once the bandwidth cap allows it instead.
+func (cdt *carDataTransfer) Revalidate(chid datatransfer.ChannelID, voucher datatransfer.Voucher) (datatransfer.VoucherResult, error) {
+	if cdt.payments == nil {
+		return nil, errors.New("retrievals are free")
+	}
+	res, err := cdt.payments.Revalidate(chid, voucher)
+	if err == datatransfer.ErrResume && cdt.policy.throttling(chid) {
+		return res, nil
+	}
+	return res, err
+}
+
+// OnPullDataSent throttles transfers to the bandwidth cap of the retrieval policy, and pauses
+// priced retrievals until they are paid for. Priced retrievals whose payments are not tracked,
+// e.g. because their channel was restarted, are charged from their persisted payment state or,
+// failing that, from scratch.
+//
+// Throttled transfers are paused rather than held up, so that the graphsync workers sending them
+// are free to serve other transfers, and are resumed once the bandwidth cap allows it.
+func (cdt *carDataTransfer) OnPullDataSent(chid datatransfer.ChannelID, additionalBytesSent uint64) (bool, datatransfer.VoucherResult, error) {
+	handled, throttled := cdt.policy.throttle(chid, additionalBytesSent, cdt.resumeThrottled)
+	var pause error
+	if throttled {
+		pause = datatransfer.ErrPause
+	}
+	if cdt.payments == nil {
+		return handled, nil, pause
+	}
+	if !cdt.payments.tracked(chid) {
+		proposal, err := cdt.channelProposal(chid)
+		if err != nil {
+			return true, nil, err
+		}
+		if proposal == nil {
+			// Not a retrieval served by this transfer.
+			return handled, nil, pause
+		}
+		fundsNeeded, err := cdt.payments.restore(chid, proposal)
+		if err != nil {
+			return true, nil, err
+		}
+		if fundsNeeded != nil {
+			return true, fundsNeeded, datatransfer.ErrPause
+		}
+	}
+	paid, res, err := cdt.payments.OnPullDataSent(chid, additionalBytesSent)
+	if err == nil && throttled {
+		return true, res, pause
+	}
+	return handled || paid, res, err
+}
+
+// resumeThrottled resumes the given channel once its bandwidth cap allows it, unless it awaits
+// payment, in which case it is resumed once paid for.
+func (cdt *carDataTransfer) resumeThrottled(chid datatransfer.ChannelID) {
+	if cdt.payments != nil && cdt.payments.paused(chid) {
+		return
+	}
+	if err := cdt.dt.ResumeDataTransferChannel(context.TODO(), chid); err != nil {
+		log.Errorw("Failed to resume throttled transfer", "channel", chid, "err", err)
+	}
+}
+
+// channelProposal returns the retrieval proposal of the given channel, or nil if the channel is
+// not a retrieval.
+func (cdt *carDataTransfer) channelProposal(chid datatransfer.ChannelID) (*DealProposal, error) {
+	channelState, err := cdt.dt.ChannelState(context.TODO(), chid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel state: %w", err)
+	}
+	proposal, _ := channelState.Voucher().(*DealProposal)
+	return proposal, nil
+}
+
+// OnPushDataReceived is not handled, since only pull requests are accepted.
+func (cdt *carDataTransfer) OnPushDataReceived(datatransfer.ChannelID, uint64) (bool, datatransfer.VoucherResult, error) {
+	return false, nil, nil
+}
+
+// OnComplete requests the last payment of priced retrievals.
+func (cdt *carDataTransfer) OnComplete(chid datatransfer.ChannelID) (bool, datatransfer.VoucherResult, error) {
+	if cdt.payments == nil {
+		return false, nil, nil
 	}
+	return cdt.payments.OnComplete(chid)
 }
 
 // StoreConfigurableTransport defines the methods needed to
@@ -210,7 +430,7 @@ func (ctd *carDataTransfer) transportConfigurer(channelID datatransfer.ChannelID
 	if store == nil {
 		return
 	}
-	err = gsTransport.UseStore(channelID, storeutil.LinkSystemForBlockstore(store))
+	err = gsTransport.UseStore(channelID, withUnixFSReification(storeutil.LinkSystemForBlockstore(store)))
 	if err != nil {
 		log.Errorf("attempting to configure data store: %s", err)
 	}
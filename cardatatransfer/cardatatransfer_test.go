@@ -10,11 +10,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/mock/gomock"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	dssync "github.com/ipfs/go-datastore/sync"
 	"github.com/ipfs/go-graphsync/storeutil"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-unixfsnode"
 	dagpb "github.com/ipld/go-codec-dagpb"
 	"github.com/ipld/go-ipld-prime"
 	"github.com/ipld/go-ipld-prime/codec/dagcbor"
@@ -33,6 +35,7 @@ import (
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/index-provider/cardatatransfer"
 	"github.com/filecoin-project/index-provider/metadata"
+	mock_provider "github.com/filecoin-project/index-provider/mock"
 	"github.com/filecoin-project/index-provider/supplier"
 	"github.com/filecoin-project/index-provider/testutil"
 )
@@ -57,7 +60,8 @@ func TestCarDataTransfer(t *testing.T) {
 	missingContextID := []byte("notFound")
 
 	supplier := &fakeSupplier{blockstores: make(map[string]supplier.ClosableBlockstore)}
-	supplier.blockstores[string(contextID1)] = rdOnlyBS1
+	// Several retrievals are served from the same blockstore.
+	supplier.blockstores[string(contextID1)] = nopCloser{rdOnlyBS1}
 	supplier.blockstores[string(contextID2)] = rdOnlyBS2
 
 	ssb := builder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
@@ -75,6 +79,40 @@ func TestCarDataTransfer(t *testing.T) {
 	partialBs, partialCount := copySelectorOutputToBlockstore(t, rdOnlyBS2, roots2[0], partialSelector, dagpb.Type.PBNode)
 	require.Equal(t, partialCount, 2)
 
+	// The root of sample-v1-2.car links directly to all its other blocks.
+	rootOnlySelector := cardatatransfer.DepthLimitSelector(3)
+	rootOnlyBs, rootOnlyCount := copySelectorOutputToBlockstore(t, rdOnlyBS1, roots1[0], rootOnlySelector, dagpb.Type.PBNode)
+	require.Equal(t, 1, rootOnlyCount)
+	allBlocksCount := testutil.GetBstoreLen(context.Background(), t, rdOnlyBS1)
+	_, depthCount := copySelectorOutputToBlockstore(t, rdOnlyBS1, roots1[0], cardatatransfer.DepthLimitSelector(4), dagpb.Type.PBNode)
+	require.Equal(t, allBlocksCount, depthCount)
+
+	// The root of sample-v1-2.car is that of a unixfs file, of which the first bytes are held by the
+	// first block it links to.
+	rootBlk, err := rdOnlyBS1.Get(context.Background(), roots1[0])
+	require.NoError(t, err)
+	fileRoot, err := ipld.Decode(rootBlk.RawData(), dagpb.Decode)
+	require.NoError(t, err)
+	byteRangeSelector, err := cardatatransfer.ByteRangeSelector("", fileRoot, 10, 20)
+	require.NoError(t, err)
+	byteRangeBs, byteRangeCount := copySelectorOutputToBlockstore(t, rdOnlyBS1, roots1[0], byteRangeSelector, dagpb.Type.PBNode)
+	require.Equal(t, 2, byteRangeCount)
+
+	// Ranges cost the same regardless of their span.
+	wideRangeSelector := ssb.ExploreFields(func(efsb builder.ExploreFieldsSpecBuilder) {
+		efsb.Insert("Links", ssb.ExploreRange(0, 1<<20, ssb.ExploreFields(func(efsb builder.ExploreFieldsSpecBuilder) {
+			efsb.Insert("Hash", ssb.Matcher())
+		})))
+	}).Node()
+	wideRangeBs, _ := copySelectorOutputToBlockstore(t, rdOnlyBS1, roots1[0], wideRangeSelector, dagpb.Type.PBNode)
+
+	tooComplexSelector := ssb.ExploreFields(func(efsb builder.ExploreFieldsSpecBuilder) {
+		for i := 0; i < cardatatransfer.DefaultSelectorComplexityBudget; i++ {
+			efsb.Insert(fmt.Sprintf("field%d", i), ssb.Matcher())
+		}
+	}).Node()
+	unsupportedADLSelector := ssb.ExploreInterpretAs("fish", ssb.Matcher()).Node()
+
 	pieceCID1 := pieceCIDFromContextID(t, contextID1)
 	pieceCID2 := pieceCIDFromContextID(t, contextID2)
 	missingPieceCID := pieceCIDFromContextID(t, missingContextID)
@@ -118,6 +156,61 @@ func TestCarDataTransfer(t *testing.T) {
 			expectSuccess:            true,
 			expectedBlockstoreResult: partialBs,
 		},
+		"select depth limit": {
+			voucher: &cardatatransfer.DealProposal{
+				PayloadCID: roots1[0],
+				ID:         6,
+				Params:     paramsWithSelector(t, &pieceCID1, rootOnlySelector),
+			},
+			root:                     roots1[0],
+			selector:                 rootOnlySelector,
+			expectSuccess:            true,
+			expectedBlockstoreResult: rootOnlyBs,
+		},
+		"select byte range": {
+			voucher: &cardatatransfer.DealProposal{
+				PayloadCID: roots1[0],
+				ID:         7,
+				Params:     paramsWithSelector(t, &pieceCID1, byteRangeSelector),
+			},
+			root:                     roots1[0],
+			selector:                 byteRangeSelector,
+			expectSuccess:            true,
+			expectedBlockstoreResult: byteRangeBs,
+		},
+		"select wide range": {
+			voucher: &cardatatransfer.DealProposal{
+				PayloadCID: roots1[0],
+				ID:         10,
+				Params:     paramsWithSelector(t, &pieceCID1, wideRangeSelector),
+			},
+			root:                     roots1[0],
+			selector:                 wideRangeSelector,
+			expectSuccess:            true,
+			expectedBlockstoreResult: wideRangeBs,
+		},
+		"selector too complex": {
+			voucher: &cardatatransfer.DealProposal{
+				PayloadCID: roots1[0],
+				ID:         8,
+				Params:     paramsWithSelector(t, &pieceCID1, tooComplexSelector),
+			},
+			root:          roots1[0],
+			selector:      tooComplexSelector,
+			expectSuccess: false,
+			expectMessage: cardatatransfer.ErrSelectorTooComplex.Error(),
+		},
+		"selector with unsupported ADL": {
+			voucher: &cardatatransfer.DealProposal{
+				PayloadCID: roots1[0],
+				ID:         9,
+				Params:     paramsWithSelector(t, &pieceCID1, unsupportedADLSelector),
+			},
+			root:          roots1[0],
+			selector:      unsupportedADLSelector,
+			expectSuccess: false,
+			expectMessage: "selector interprets nodes as unsupported ADL: fish",
+		},
 		"no blockstore for context ID": {
 			voucher: &cardatatransfer.DealProposal{
 				PayloadCID: missingCid,
@@ -172,6 +265,7 @@ func TestCarDataTransfer(t *testing.T) {
 			dstStore := dssync.MutexWrap(datastore.NewMapDatastore())
 			dstBlockstore := bstore.NewBlockstore(dstStore)
 			lsys := storeutil.LinkSystemForBlockstore(dstBlockstore)
+			unixfsnode.AddUnixFSReificationToLinkSystem(&lsys)
 			dstDt := testutil.SetupDataTransferOnHost(t, dstHost, dstStore, lsys)
 			err = mn.LinkAll()
 			require.NoError(t, err)
@@ -238,10 +332,17 @@ func pieceCIDFromContextID(t *testing.T, contextID []byte) cid.Cid {
 	return md.(*metadata.GraphsyncFilecoinV1).PieceCID
 }
 
+func paramsWithSelector(t *testing.T, pieceCID *cid.Cid, selector ipld.Node) cardatatransfer.Params {
+	params := cardatatransfer.Params{PieceCID: pieceCID}
+	require.NoError(t, params.SetSelector(selector))
+	return params
+}
+
 func copySelectorOutputToBlockstore(t *testing.T, sourceBs bstore.Blockstore, root cid.Cid, selectorNode datamodel.Node, np datamodel.NodePrototype) (bstore.Blockstore, int) {
 	bsOutput := bstore.NewBlockstore(datastore.NewMapDatastore())
 	count := 0
 	lsys := cidlink.DefaultLinkSystem()
+	unixfsnode.AddUnixFSReificationToLinkSystem(&lsys)
 	lsys.StorageReadOpener = func(lctx linking.LinkContext, lnk datamodel.Link) (io.Reader, error) {
 		asCidLink, ok := lnk.(cidlink.Link)
 		if !ok {
@@ -275,3 +376,89 @@ func copySelectorOutputToBlockstore(t *testing.T, sourceBs bstore.Blockstore, ro
 
 	return bsOutput, count
 }
+
+func TestCarDataTransfer_BlockstoreSupplier(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	contextID := []byte("flatfs")
+	carBs := testutil.OpenSampleCar(t, "sample-v1-2.car")
+	roots, err := carBs.Roots()
+	require.NoError(t, err)
+	require.Len(t, roots, 1)
+
+	// Copy the CAR content into a non-CAR blockstore.
+	srcBs := bstore.NewBlockstore(datastore.NewMapDatastore())
+	keys, err := carBs.AllKeysChan(ctx)
+	require.NoError(t, err)
+	for k := range keys {
+		blk, err := carBs.Get(ctx, k)
+		require.NoError(t, err)
+		require.NoError(t, srcBs.Put(ctx, blk))
+	}
+
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	mockEng.EXPECT().NotifyPut(gomock.Any(), contextID, gomock.Any()).Return(roots[0], nil)
+	bss := supplier.NewBlockstoreSupplier(mockEng, datastore.NewMapDatastore(), srcBs)
+	tp, err := cardatatransfer.TransportFromContextID(contextID)
+	require.NoError(t, err)
+	_, err = bss.Put(ctx, contextID, roots[0], nil, metadata.New(tp))
+	require.NoError(t, err)
+
+	mn := mocknet.New()
+	srcHost, err := mn.GenPeer()
+	require.NoError(t, err)
+	srcStore := dssync.MutexWrap(datastore.NewMapDatastore())
+	srcDt := testutil.SetupDataTransferOnHost(t, srcHost, srcStore, cidlink.DefaultLinkSystem())
+	require.NoError(t, cardatatransfer.StartCarDataTransfer(srcDt, bss))
+	dstHost, err := mn.GenPeer()
+	require.NoError(t, err)
+	dstStore := dssync.MutexWrap(datastore.NewMapDatastore())
+	dstBlockstore := bstore.NewBlockstore(dstStore)
+	dstDt := testutil.SetupDataTransferOnHost(t, dstHost, dstStore, storeutil.LinkSystemForBlockstore(dstBlockstore))
+	require.NoError(t, mn.LinkAll())
+
+	dstResultChan := make(chan bool, 1)
+	dstDt.SubscribeToEvents(func(event datatransfer.Event, channelState datatransfer.ChannelState) {
+		switch channelState.Status() {
+		case datatransfer.Cancelled, datatransfer.Failed:
+			dstResultChan <- false
+		case datatransfer.Completed:
+			dstResultChan <- true
+		}
+	})
+	require.NoError(t, dstDt.RegisterVoucherResultType(&cardatatransfer.DealResponse{}))
+	require.NoError(t, dstDt.RegisterVoucherType(&cardatatransfer.DealProposal{}, nil))
+	pieceCID := pieceCIDFromContextID(t, contextID)
+	voucher := &cardatatransfer.DealProposal{
+		PayloadCID: roots[0],
+		ID:         1,
+		Params:     cardatatransfer.Params{PieceCID: &pieceCID},
+	}
+	_, err = dstDt.OpenPullDataChannel(ctx, srcHost.ID(), voucher, roots[0], selectorparse.CommonSelector_ExploreAllRecursively)
+	require.NoError(t, err)
+
+	select {
+	case <-ctx.Done():
+		require.FailNow(t, "context closed")
+	case dstResult := <-dstResultChan:
+		require.True(t, dstResult)
+		require.Equal(t, testutil.GetBstoreLen(ctx, t, srcBs), testutil.GetBstoreLen(ctx, t, dstBlockstore))
+	}
+	// Closing the retrieval blockstore must not close the shared source blockstore.
+	has, err := srcBs.Has(ctx, roots[0])
+	require.NoError(t, err)
+	require.True(t, has)
+}
+
+func TestStartCarDataTransfer_NonPositiveSelectorComplexityBudget(t *testing.T) {
+	mn := mocknet.New()
+	h, err := mn.GenPeer()
+	require.NoError(t, err)
+	dt := testutil.SetupDataTransferOnHost(t, h, dssync.MutexWrap(datastore.NewMapDatastore()), cidlink.DefaultLinkSystem())
+	err = cardatatransfer.StartCarDataTransfer(dt, &fakeSupplier{}, cardatatransfer.WithSelectorComplexityBudget(0))
+	require.EqualError(t, err, "selector complexity budget must be greater than zero")
+}
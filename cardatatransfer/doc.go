@@ -1,3 +1,16 @@
 // Package cardatatransfer privdes a datatransfer server that can be used to retrieve multihashes
 // supplied via engine.Engine and supplier.CarSupplier as the provider.MultihashLister.
+// Any supplier that implements BlockStoreSupplier can be served, including
+// supplier.BlockstoreSupplier for DAGs stored in an arbitrary blockstore.
+//
+// Retrievals are free by default. Priced retrievals are served by setting a RetrievalPricer and a
+// PaymentVerifier, in which case transfers are paused at the payment intervals of the deal
+// proposal and resumed once payment vouchers for the amount owed are received.
+//
+// Retrievals may select a subset of the content with the selector of their deal proposal, such as
+// a unixfs path, a byte range of a unixfs file or a depth limit. See: UnixFSPathSelector,
+// ByteRangeSelector, DepthLimitSelector.
+//
+// The transfers served can be recorded in a datastore with RetrievalStats, which aggregates them
+// into counters per context ID.
 package cardatatransfer
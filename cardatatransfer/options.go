@@ -0,0 +1,122 @@
+package cardatatransfer
+
+import (
+	"errors"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+type (
+	// Option sets a configuration parameter for the car data transfer server.
+	Option func(*options) error
+
+	options struct {
+		pricer    RetrievalPricer
+		verifier  PaymentVerifier
+		paymentDs datastore.Datastore
+		policy    RetrievalPolicy
+		// selectorBudget is the complexity budget of the selectors that are served.
+		selectorBudget int
+		stats          *RetrievalStats
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{
+		selectorBudget: DefaultSelectorComplexityBudget,
+	}
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.pricer != nil && opts.verifier == nil {
+		return nil, errors.New("payment verifier must be set when retrieval pricer is set")
+	}
+	if opts.paymentDs == nil {
+		opts.paymentDs = dssync.MutexWrap(datastore.NewMapDatastore())
+	}
+	return opts, nil
+}
+
+// WithRetrievalPricer sets the pricer that determines the minimum terms on which content is
+// retrieved. When a pricer is set, a PaymentVerifier must also be set so that payments for priced
+// retrievals can be received.
+//
+// By default, no pricer is set and all retrievals are free, regardless of the price proposed by
+// the client.
+//
+// See: WithPaymentVerifier.
+func WithRetrievalPricer(p RetrievalPricer) Option {
+	return func(o *options) error {
+		o.pricer = p
+		return nil
+	}
+}
+
+// WithPaymentVerifier sets the verifier of the payment vouchers sent by clients of priced
+// retrievals.
+//
+// See: WithRetrievalPricer.
+func WithPaymentVerifier(v PaymentVerifier) Option {
+	return func(o *options) error {
+		o.verifier = v
+		return nil
+	}
+}
+
+// WithPaymentDatastore sets the datastore in which the payment state of priced retrievals is
+// persisted, so that retrievals are charged for all the data sent even if they are restarted.
+//
+// By default, the payment state is kept in memory, which only survives the restart of retrievals
+// and not of the provider.
+//
+// See: WithRetrievalPricer.
+func WithPaymentDatastore(ds datastore.Datastore) Option {
+	return func(o *options) error {
+		o.paymentDs = ds
+		return nil
+	}
+}
+
+// WithRetrievalPolicy sets the policy that determines which retrievals are served, and limits the
+// resources they use. Retrievals that are not allowed by the policy are rejected with
+// DealStatusRejected.
+//
+// By default, all retrievals are served without limits.
+func WithRetrievalPolicy(p RetrievalPolicy) Option {
+	return func(o *options) error {
+		if p.MaxTransfersPerPeer < 0 || p.MaxOpenBlockstores < 0 {
+			return errors.New("retrieval policy limits must not be negative")
+		}
+		o.policy = p
+		return nil
+	}
+}
+
+// WithSelectorComplexityBudget sets the complexity budget of the selectors that are served.
+// Retrievals with a selector that exceeds the budget are rejected with DealStatusRejected. The
+// complexity of a selector is the number of data model nodes that make it up, plus a constant cost
+// for each range it explores.
+//
+// Defaults to DefaultSelectorComplexityBudget.
+func WithSelectorComplexityBudget(budget int) Option {
+	return func(o *options) error {
+		if budget <= 0 {
+			return errors.New("selector complexity budget must be greater than zero")
+		}
+		o.selectorBudget = budget
+		return nil
+	}
+}
+
+// WithRetrievalStats sets the stats in which the transfers served are recorded.
+//
+// By default, transfers are not recorded.
+func WithRetrievalStats(s *RetrievalStats) Option {
+	return func(o *options) error {
+		o.stats = s
+		return nil
+	}
+}
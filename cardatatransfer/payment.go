@@ -0,0 +1,385 @@
+package cardatatransfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-datastore"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+const (
+	paymentsDatastorePrefix = "car_data_transfer://payments/"
+
+	// verifyPaymentTimeout bounds the verification of each payment voucher.
+	verifyPaymentTimeout = 30 * time.Second
+)
+
+var _ datatransfer.Revalidator = (*paymentRevalidator)(nil)
+
+// Ask is the minimum terms on which the provider serves a retrieval.
+type Ask struct {
+	// PricePerByte is the minimum price per byte of data sent.
+	PricePerByte abi.TokenAmount
+	// UnsealPrice is the minimum price paid upfront, before any data is sent.
+	UnsealPrice abi.TokenAmount
+	// PaymentInterval is the maximum number of bytes sent before payment is requested.
+	PaymentInterval uint64
+	// PaymentIntervalIncrease is the maximum number of bytes by which the payment interval grows
+	// after each payment.
+	PaymentIntervalIncrease uint64
+}
+
+// RetrievalPricer determines the terms on which content is retrieved.
+type RetrievalPricer interface {
+	// GetAsk returns the terms on which the content with the given context ID is retrieved by the
+	// given peer. Returning an error rejects the retrieval.
+	GetAsk(ctx context.Context, receiver peer.ID, contextID []byte) (Ask, error)
+}
+
+// PaymentVerifier verifies the payment vouchers sent by clients of priced retrievals.
+type PaymentVerifier interface {
+	// VerifyPayment verifies the given CBOR encoded payment voucher, sent by the given peer to pay
+	// over the given payment channel for the given amount owed. It returns the amount paid by the
+	// voucher on top of the vouchers previously verified on the same channel, which may be less
+	// than the amount owed.
+	VerifyPayment(ctx context.Context, sender peer.ID, paymentChannel address.Address, voucher *cbg.Deferred, owed abi.TokenAmount) (abi.TokenAmount, error)
+}
+
+// checkAsk checks that the terms of the given proposal satisfy the given ask.
+func checkAsk(proposal *DealProposal, ask Ask) error {
+	if orZero(proposal.PricePerByte).LessThan(orZero(ask.PricePerByte)) {
+		return errors.New("price per byte too low")
+	}
+	if orZero(proposal.UnsealPrice).LessThan(orZero(ask.UnsealPrice)) {
+		return errors.New("unseal price too low")
+	}
+	if proposal.PaymentInterval > ask.PaymentInterval {
+		return errors.New("payment interval too large")
+	}
+	if proposal.PaymentIntervalIncrease > ask.PaymentIntervalIncrease {
+		return errors.New("payment interval increase too large")
+	}
+	if orZero(proposal.PricePerByte).GreaterThan(big.Zero()) && proposal.PaymentInterval == 0 {
+		return errors.New("payment interval must be greater than zero")
+	}
+	return nil
+}
+
+func orZero(a abi.TokenAmount) abi.TokenAmount {
+	if a.Nil() {
+		return big.Zero()
+	}
+	return a
+}
+
+// paidDeal is the payment state of a priced retrieval. It is persisted as JSON, so that the
+// payment of retrievals can be resumed after restarts.
+type paidDeal struct {
+	ID           DealID          `json:"id"`
+	PricePerByte abi.TokenAmount `json:"pricePerByte"`
+	UnsealPrice  abi.TokenAmount `json:"unsealPrice"`
+	// Interval is the current payment interval, which grows by IntervalIncrease after each payment.
+	Interval         uint64 `json:"interval"`
+	IntervalIncrease uint64 `json:"intervalIncrease"`
+	// NextPayment is the number of bytes sent at which the next payment is requested.
+	NextPayment uint64          `json:"nextPayment"`
+	Sent        uint64          `json:"sent"`
+	Received    abi.TokenAmount `json:"received"`
+	// Paused is whether the transfer is paused awaiting payment.
+	Paused bool `json:"paused"`
+	// LastPayment is whether all data has been sent and the last payment is awaited.
+	LastPayment bool `json:"lastPayment"`
+}
+
+func newPaidDeal(proposal *DealProposal) *paidDeal {
+	return &paidDeal{
+		ID:               proposal.ID,
+		PricePerByte:     orZero(proposal.PricePerByte),
+		UnsealPrice:      orZero(proposal.UnsealPrice),
+		Interval:         proposal.PaymentInterval,
+		IntervalIncrease: proposal.PaymentIntervalIncrease,
+		NextPayment:      proposal.PaymentInterval,
+		Received:         big.Zero(),
+	}
+}
+
+// owed returns the amount owed for the data sent so far, including the unseal price.
+func (d *paidDeal) owed() abi.TokenAmount {
+	total := big.Add(big.Mul(d.PricePerByte, big.NewIntUnsigned(d.Sent)), d.UnsealPrice)
+	return big.Sub(total, d.Received)
+}
+
+// fundsNeeded returns the response that requests payment of the amount owed.
+func (d *paidDeal) fundsNeeded(owed abi.TokenAmount) *DealResponse {
+	status := DealStatusFundsNeeded
+	switch {
+	case d.LastPayment:
+		status = DealStatusFundsNeededLastPayment
+	case d.Sent == 0:
+		status = DealStatusFundsNeededUnseal
+	}
+	return &DealResponse{ID: d.ID, Status: status, PaymentOwed: owed}
+}
+
+// paymentRevalidator pauses priced retrievals at payment intervals until they are paid for, and
+// resumes them once payment vouchers for the amount owed are received.
+//
+// The payment state of each retrieval is persisted in a datastore whenever it changes, and is
+// restored when its channel is restarted, so that retrievals are charged for all the data sent
+// regardless of restarts.
+type paymentRevalidator struct {
+	verifier PaymentVerifier
+	ds       datastore.Datastore
+	lk       sync.Mutex
+	deals    map[datatransfer.ChannelID]*paidDeal
+}
+
+func newPaymentRevalidator(verifier PaymentVerifier, ds datastore.Datastore) *paymentRevalidator {
+	return &paymentRevalidator{
+		verifier: verifier,
+		ds:       ds,
+		deals:    make(map[datatransfer.ChannelID]*paidDeal),
+	}
+}
+
+// track starts tracking the payments of the priced retrieval over the given channel. If the
+// retrieval has an unseal price, the response requesting its payment is returned, in which case
+// the transfer must be paused until it is paid for.
+func (r *paymentRevalidator) track(chid datatransfer.ChannelID, proposal *DealProposal) (*DealResponse, error) {
+	d := newPaidDeal(proposal)
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.deals[chid] = d
+	if !d.UnsealPrice.IsZero() {
+		d.Paused = true
+	}
+	if err := r.save(chid, d); err != nil {
+		return nil, err
+	}
+	if !d.Paused {
+		return nil, nil
+	}
+	return d.fundsNeeded(d.owed()), nil
+}
+
+// restore resumes tracking the payments of the priced retrieval over the given channel, e.g. once
+// the channel is restarted, from its persisted payment state. Retrievals without a persisted state
+// are charged from scratch, including the unseal price. If the retrieval awaits payment, the
+// response requesting it is returned, in which case the transfer must be paused until it is paid
+// for.
+func (r *paymentRevalidator) restore(chid datatransfer.ChannelID, proposal *DealProposal) (*DealResponse, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	d, ok := r.deals[chid]
+	if !ok || d.ID != proposal.ID {
+		var err error
+		if d, err = r.load(chid); err != nil {
+			return nil, err
+		}
+		if d == nil || d.ID != proposal.ID {
+			log.Warnw("No payment state found for restored priced retrieval; charging from scratch", "channel", chid)
+			d = newPaidDeal(proposal)
+			if err := r.save(chid, d); err != nil {
+				return nil, err
+			}
+		}
+		r.deals[chid] = d
+	}
+	if !d.Paused {
+		return nil, nil
+	}
+	return d.fundsNeeded(d.owed()), nil
+}
+
+// tracked returns whether the payments of the retrieval over the given channel are tracked.
+func (r *paymentRevalidator) tracked(chid datatransfer.ChannelID) bool {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	_, ok := r.deals[chid]
+	return ok
+}
+
+// paused returns whether the retrieval over the given channel is paused awaiting payment.
+func (r *paymentRevalidator) paused(chid datatransfer.ChannelID) bool {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	d, ok := r.deals[chid]
+	return ok && d.Paused
+}
+
+// untrack stops tracking the payments of the retrieval over the given channel. The persisted
+// payment state is kept if the retrieval may be restarted, and deleted otherwise.
+func (r *paymentRevalidator) untrack(chid datatransfer.ChannelID, restartable bool) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	delete(r.deals, chid)
+	if restartable {
+		return
+	}
+	if err := r.ds.Delete(context.Background(), paidDealKey(chid)); err != nil {
+		log.Errorw("Failed to delete payment state of retrieval", "channel", chid, "err", err)
+	}
+}
+
+// save persists the payment state of the retrieval over the given channel. The caller must hold
+// lk.
+func (r *paymentRevalidator) save(chid datatransfer.ChannelID, d *paidDeal) error {
+	v, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	if err := r.ds.Put(context.Background(), paidDealKey(chid), v); err != nil {
+		return fmt.Errorf("failed to persist payment state: %w", err)
+	}
+	return nil
+}
+
+// load loads the persisted payment state of the retrieval over the given channel, or nil if there
+// is none.
+func (r *paymentRevalidator) load(chid datatransfer.ChannelID) (*paidDeal, error) {
+	v, err := r.ds.Get(context.Background(), paidDealKey(chid))
+	if err != nil {
+		if err == datastore.ErrNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load payment state: %w", err)
+	}
+	var d paidDeal
+	if err := json.Unmarshal(v, &d); err != nil {
+		return nil, fmt.Errorf("failed to decode payment state: %w", err)
+	}
+	return &d, nil
+}
+
+func paidDealKey(chid datatransfer.ChannelID) datastore.Key {
+	return datastore.NewKey(paymentsDatastorePrefix + chid.String())
+}
+
+// Revalidate verifies the payment voucher sent for a priced retrieval, and resumes the transfer
+// once the amount owed is paid. The voucher is verified without holding the lock, so that slow
+// verifications do not hold up other retrievals.
+func (r *paymentRevalidator) Revalidate(chid datatransfer.ChannelID, voucher datatransfer.Voucher) (datatransfer.VoucherResult, error) {
+	payment, ok := voucher.(*DealPayment)
+	if !ok {
+		return nil, errors.New("wrong voucher type")
+	}
+
+	r.lk.Lock()
+	d, ok := r.deals[chid]
+	if !ok || d.ID != payment.ID {
+		r.lk.Unlock()
+		return &DealResponse{ID: payment.ID, Status: DealStatusDealNotFound, Message: "no priced retrieval found for payment"}, errors.New("no priced retrieval found for payment")
+	}
+	owed := d.owed()
+	r.lk.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), verifyPaymentTimeout)
+	defer cancel()
+	received, err := r.verifier.VerifyPayment(ctx, chid.Initiator, payment.PaymentChannel, payment.PaymentVoucher, owed)
+	if err != nil {
+		return &DealResponse{ID: d.ID, Status: DealStatusErrored, Message: err.Error()}, err
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if r.deals[chid] != d {
+		// The retrieval ended while the payment was verified.
+		return &DealResponse{ID: payment.ID, Status: DealStatusDealNotFound, Message: "no priced retrieval found for payment"}, errors.New("no priced retrieval found for payment")
+	}
+	d.Received = big.Add(d.Received, received)
+	res, err := r.afterPayment(d)
+	if saveErr := r.save(chid, d); saveErr != nil {
+		return &DealResponse{ID: d.ID, Status: DealStatusErrored, Message: saveErr.Error()}, saveErr
+	}
+	return res, err
+}
+
+// afterPayment advances the payment state of the given retrieval once a payment is received. The
+// caller must hold lk.
+func (r *paymentRevalidator) afterPayment(d *paidDeal) (datatransfer.VoucherResult, error) {
+	if owed := d.owed(); owed.GreaterThan(big.Zero()) {
+		return d.fundsNeeded(owed), nil
+	}
+	if d.LastPayment {
+		return &DealResponse{ID: d.ID, Status: DealStatusCompleted}, nil
+	}
+	if d.Sent >= d.NextPayment {
+		d.Interval += d.IntervalIncrease
+		d.NextPayment += d.Interval
+	}
+	if !d.Paused {
+		return &DealResponse{ID: d.ID, Status: DealStatusOngoing}, nil
+	}
+	d.Paused = false
+	return &DealResponse{ID: d.ID, Status: DealStatusOngoing}, datatransfer.ErrResume
+}
+
+// OnPullDataSent pauses a priced retrieval once a payment interval worth of data is sent without
+// being paid for. Retrievals that are not tracked are not handled.
+func (r *paymentRevalidator) OnPullDataSent(chid datatransfer.ChannelID, additionalBytesSent uint64) (bool, datatransfer.VoucherResult, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	d, ok := r.deals[chid]
+	if !ok {
+		return false, nil, nil
+	}
+	d.Sent += additionalBytesSent
+	res, err := r.afterDataSent(d)
+	if saveErr := r.save(chid, d); saveErr != nil {
+		return true, nil, saveErr
+	}
+	return true, res, err
+}
+
+// afterDataSent advances the payment state of the given retrieval once data is sent. The caller
+// must hold lk.
+func (r *paymentRevalidator) afterDataSent(d *paidDeal) (datatransfer.VoucherResult, error) {
+	if d.Paused || d.PricePerByte.IsZero() || d.Sent < d.NextPayment {
+		return nil, nil
+	}
+	owed := d.owed()
+	if !owed.GreaterThan(big.Zero()) {
+		// Enough was paid upfront to cover the interval.
+		d.Interval += d.IntervalIncrease
+		d.NextPayment += d.Interval
+		return nil, nil
+	}
+	d.Paused = true
+	return d.fundsNeeded(owed), datatransfer.ErrPause
+}
+
+// OnPushDataReceived is not handled, since only pull requests are accepted.
+func (r *paymentRevalidator) OnPushDataReceived(datatransfer.ChannelID, uint64) (bool, datatransfer.VoucherResult, error) {
+	return false, nil, nil
+}
+
+// OnComplete requests the last payment of a priced retrieval once all data is sent, if any
+// amount is still owed.
+func (r *paymentRevalidator) OnComplete(chid datatransfer.ChannelID) (bool, datatransfer.VoucherResult, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	d, ok := r.deals[chid]
+	if !ok {
+		return false, nil, nil
+	}
+	owed := d.owed()
+	if !owed.GreaterThan(big.Zero()) {
+		return true, &DealResponse{ID: d.ID, Status: DealStatusCompleted}, nil
+	}
+	d.LastPayment = true
+	d.Paused = true
+	if err := r.save(chid, d); err != nil {
+		return true, nil, err
+	}
+	return true, d.fundsNeeded(owed), datatransfer.ErrPause
+}
@@ -0,0 +1,449 @@
+package cardatatransfer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-graphsync/storeutil"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/index-provider/cardatatransfer"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/filecoin-project/index-provider/testutil"
+)
+
+func TestCarDataTransfer_PricedRetrieval(t *testing.T) {
+	contextID := []byte("cheese")
+	rdOnlyBS := testutil.OpenSampleCar(t, "sample-v1-2.car")
+	roots, err := rdOnlyBS.Roots()
+	require.NoError(t, err)
+	require.Len(t, roots, 1)
+	pieceCID := pieceCIDFromContextID(t, contextID)
+
+	ask := cardatatransfer.Ask{
+		PricePerByte:            abi.NewTokenAmount(2),
+		UnsealPrice:             abi.NewTokenAmount(100),
+		PaymentInterval:         1 << 10,
+		PaymentIntervalIncrease: 1 << 9,
+	}
+
+	testCases := map[string]struct {
+		params        cardatatransfer.Params
+		verifierErr   error
+		expectSuccess bool
+		expectMessage string
+	}{
+		"paid at intervals": {
+			params: cardatatransfer.Params{
+				PricePerByte:            abi.NewTokenAmount(2),
+				PaymentInterval:         1 << 10,
+				PaymentIntervalIncrease: 1 << 9,
+				UnsealPrice:             abi.NewTokenAmount(100),
+			},
+			expectSuccess: true,
+		},
+		"price per byte too low": {
+			params: cardatatransfer.Params{
+				PricePerByte:    abi.NewTokenAmount(1),
+				PaymentInterval: 1 << 10,
+				UnsealPrice:     abi.NewTokenAmount(100),
+			},
+			expectMessage: "price per byte too low",
+		},
+		"unseal price too low": {
+			params: cardatatransfer.Params{
+				PricePerByte:    abi.NewTokenAmount(2),
+				PaymentInterval: 1 << 10,
+			},
+			expectMessage: "unseal price too low",
+		},
+		"payment interval too large": {
+			params: cardatatransfer.Params{
+				PricePerByte:    abi.NewTokenAmount(2),
+				PaymentInterval: 1 << 11,
+				UnsealPrice:     abi.NewTokenAmount(100),
+			},
+			expectMessage: "payment interval too large",
+		},
+		"invalid payment": {
+			params: cardatatransfer.Params{
+				PricePerByte:    abi.NewTokenAmount(2),
+				PaymentInterval: 1 << 10,
+				UnsealPrice:     abi.NewTokenAmount(100),
+			},
+			verifierErr:   errors.New("invalid voucher signature"),
+			expectMessage: "invalid voucher signature",
+		},
+	}
+
+	for testCase, data := range testCases {
+		t.Run(testCase, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			supplier := &fakeSupplier{blockstores: map[string]supplier.ClosableBlockstore{string(contextID): rdOnlyBS}}
+			paych := newFakePaymentChannel(data.verifierErr)
+			mn := mocknet.New()
+			srcHost, err := mn.GenPeer()
+			require.NoError(t, err)
+			srcStore := dssync.MutexWrap(datastore.NewMapDatastore())
+			srcDt := testutil.SetupDataTransferOnHost(t, srcHost, srcStore, cidlink.DefaultLinkSystem())
+			err = cardatatransfer.StartCarDataTransfer(srcDt, supplier,
+				cardatatransfer.WithRetrievalPricer(fixedPricer(ask)),
+				cardatatransfer.WithPaymentVerifier(paych))
+			require.NoError(t, err)
+			dstHost, err := mn.GenPeer()
+			require.NoError(t, err)
+			dstStore := dssync.MutexWrap(datastore.NewMapDatastore())
+			dstBlockstore := bstore.NewBlockstore(dstStore)
+			dstDt := testutil.SetupDataTransferOnHost(t, dstHost, dstStore, storeutil.LinkSystemForBlockstore(dstBlockstore))
+			require.NoError(t, mn.LinkAll())
+			require.NoError(t, dstDt.RegisterVoucherResultType(&cardatatransfer.DealResponse{}))
+			require.NoError(t, dstDt.RegisterVoucherType(&cardatatransfer.DealProposal{}, nil))
+			require.NoError(t, dstDt.RegisterVoucherType(&cardatatransfer.DealPayment{}, nil))
+
+			// Pay the amount owed whenever the provider requests funds.
+			payments := make(chan datatransfer.ChannelID, 16)
+			dstResultChan := make(chan bool, 1)
+			var lk sync.Mutex
+			var dstMessage string
+			var statuses []cardatatransfer.DealStatus
+			var paid abi.TokenAmount
+			dstDt.SubscribeToEvents(func(event datatransfer.Event, channelState datatransfer.ChannelState) {
+				if event.Code == datatransfer.NewVoucherResult {
+					if vr, ok := channelState.LastVoucherResult().(*cardatatransfer.DealResponse); ok {
+						lk.Lock()
+						dstMessage = vr.Message
+						statuses = append(statuses, vr.Status)
+						switch vr.Status {
+						case cardatatransfer.DealStatusFundsNeededUnseal, cardatatransfer.DealStatusFundsNeeded, cardatatransfer.DealStatusFundsNeededLastPayment:
+							if paid.Nil() {
+								paid = big.Zero()
+							}
+							paid = big.Add(paid, vr.PaymentOwed)
+							payments <- channelState.ChannelID()
+						}
+						lk.Unlock()
+					}
+				}
+				switch channelState.Status() {
+				case datatransfer.Cancelled, datatransfer.Failed:
+					dstResultChan <- false
+				case datatransfer.Completed:
+					dstResultChan <- true
+				}
+			})
+			go func() {
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case chid := <-payments:
+						lk.Lock()
+						voucher := paych.voucher(t, paid)
+						lk.Unlock()
+						payment := &cardatatransfer.DealPayment{ID: 1, PaymentChannel: paych.addr, PaymentVoucher: voucher}
+						// Payments that arrive after the retrieval completes are rejected.
+						_ = dstDt.SendVoucher(ctx, chid, payment)
+					}
+				}
+			}()
+
+			voucher := &cardatatransfer.DealProposal{PayloadCID: roots[0], ID: 1, Params: data.params}
+			voucher.PieceCID = &pieceCID
+			_, err = dstDt.OpenPullDataChannel(ctx, srcHost.ID(), voucher, roots[0], selectorparse.CommonSelector_ExploreAllRecursively)
+			require.NoError(t, err)
+
+			select {
+			case <-ctx.Done():
+				require.FailNow(t, "context closed")
+			case dstResult := <-dstResultChan:
+				lk.Lock()
+				defer lk.Unlock()
+				require.Equal(t, data.expectSuccess, dstResult)
+				if !data.expectSuccess {
+					require.Equal(t, data.expectMessage, dstMessage)
+					return
+				}
+				require.Equal(t, testutil.GetBstoreLen(ctx, t, rdOnlyBS), testutil.GetBstoreLen(ctx, t, dstBlockstore))
+				require.Equal(t, cardatatransfer.DealStatusFundsNeededUnseal, statuses[0])
+				require.Contains(t, statuses, cardatatransfer.DealStatusFundsNeeded)
+				require.Contains(t, statuses, cardatatransfer.DealStatusFundsNeededLastPayment)
+				require.Equal(t, cardatatransfer.DealStatusCompleted, statuses[len(statuses)-1])
+
+				// All data sent and the unseal price are paid for.
+				sent := big.NewInt(int64(getBstoreSize(ctx, t, rdOnlyBS)))
+				wantPaid := big.Add(big.Mul(ask.PricePerByte, sent), ask.UnsealPrice)
+				require.False(t, paych.total().LessThan(wantPaid), "paid %s, want %s", paych.total(), wantPaid)
+			}
+		})
+	}
+}
+
+func TestStartCarDataTransfer_PricerRequiresVerifier(t *testing.T) {
+	mn := mocknet.New()
+	h, err := mn.GenPeer()
+	require.NoError(t, err)
+	dt := testutil.SetupDataTransferOnHost(t, h, dssync.MutexWrap(datastore.NewMapDatastore()), cidlink.DefaultLinkSystem())
+	err = cardatatransfer.StartCarDataTransfer(dt, &fakeSupplier{}, cardatatransfer.WithRetrievalPricer(fixedPricer(cardatatransfer.Ask{})))
+	require.EqualError(t, err, "payment verifier must be set when retrieval pricer is set")
+}
+
+func TestCarDataTransfer_PaymentStateSurvivesRestarts(t *testing.T) {
+	contextID := []byte("cheese")
+	rdOnlyBS := testutil.OpenSampleCar(t, "sample-v1-2.car")
+	roots, err := rdOnlyBS.Roots()
+	require.NoError(t, err)
+	pieceCID := pieceCIDFromContextID(t, contextID)
+	ask := cardatatransfer.Ask{PricePerByte: abi.NewTokenAmount(1), PaymentInterval: 100}
+	proposal := &cardatatransfer.DealProposal{
+		PayloadCID: roots[0],
+		ID:         1,
+		Params: cardatatransfer.Params{
+			PieceCID:        &pieceCID,
+			PricePerByte:    ask.PricePerByte,
+			PaymentInterval: ask.PaymentInterval,
+		},
+	}
+	client := peer.ID("fish")
+	chid := datatransfer.ChannelID{Initiator: client, Responder: peer.ID("lobster"), ID: 1}
+	paych := newFakePaymentChannel(nil)
+	start := func(ds datastore.Datastore) *fakeDataTransfer {
+		dt := newFakeDataTransfer()
+		supplier := &fakeSupplier{blockstores: map[string]supplier.ClosableBlockstore{string(contextID): rdOnlyBS}}
+		require.NoError(t, cardatatransfer.StartCarDataTransfer(dt, supplier,
+			cardatatransfer.WithRetrievalPricer(fixedPricer(ask)),
+			cardatatransfer.WithPaymentVerifier(paych),
+			cardatatransfer.WithPaymentDatastore(ds)))
+		return dt
+	}
+
+	t.Run("restored from persisted state", func(t *testing.T) {
+		ds := dssync.MutexWrap(datastore.NewMapDatastore())
+		dt := start(ds)
+		_, err := dt.validator.ValidatePull(false, chid, client, proposal, roots[0], selectorparse.CommonSelector_ExploreAllRecursively)
+		require.NoError(t, err)
+		_, res, err := dt.revalidator.OnPullDataSent(chid, 150)
+		require.Equal(t, datatransfer.ErrPause, err)
+		require.Equal(t, abi.NewTokenAmount(150), res.(*cardatatransfer.DealResponse).PaymentOwed)
+
+		// The restarted provider still awaits payment of the data sent before the restart.
+		dt = start(ds)
+		res, err = dt.validator.ValidatePull(true, chid, client, proposal, roots[0], selectorparse.CommonSelector_ExploreAllRecursively)
+		require.Equal(t, datatransfer.ErrPause, err)
+		require.Equal(t, abi.NewTokenAmount(150), res.(*cardatatransfer.DealResponse).PaymentOwed)
+		payment := &cardatatransfer.DealPayment{ID: 1, PaymentChannel: paych.addr, PaymentVoucher: paych.voucher(t, abi.NewTokenAmount(150))}
+		_, err = dt.revalidator.Revalidate(chid, payment)
+		require.Equal(t, datatransfer.ErrResume, err)
+	})
+
+	t.Run("untracked channel is charged", func(t *testing.T) {
+		dt := start(dssync.MutexWrap(datastore.NewMapDatastore()))
+		dt.proposals[chid] = proposal
+		handled, res, err := dt.revalidator.OnPullDataSent(chid, 150)
+		require.True(t, handled)
+		require.Equal(t, datatransfer.ErrPause, err)
+		require.Equal(t, abi.NewTokenAmount(150), res.(*cardatatransfer.DealResponse).PaymentOwed)
+	})
+}
+
+func TestCarDataTransfer_PaymentIsVerifiedWithoutBlockingOtherRetrievals(t *testing.T) {
+	contextID := []byte("cheese")
+	rdOnlyBS := testutil.OpenSampleCar(t, "sample-v1-2.car")
+	roots, err := rdOnlyBS.Roots()
+	require.NoError(t, err)
+	pieceCID := pieceCIDFromContextID(t, contextID)
+	ask := cardatatransfer.Ask{PricePerByte: abi.NewTokenAmount(1), PaymentInterval: 100}
+	verifier := &blockingVerifier{release: make(chan struct{}), verifying: make(chan struct{})}
+	dt := newFakeDataTransfer()
+	supplier := &fakeSupplier{blockstores: map[string]supplier.ClosableBlockstore{string(contextID): rdOnlyBS}}
+	require.NoError(t, cardatatransfer.StartCarDataTransfer(dt, supplier,
+		cardatatransfer.WithRetrievalPricer(fixedPricer(ask)),
+		cardatatransfer.WithPaymentVerifier(verifier)))
+
+	client := peer.ID("fish")
+	var chids []datatransfer.ChannelID
+	for id := 1; id <= 2; id++ {
+		chid := datatransfer.ChannelID{Initiator: client, Responder: peer.ID("lobster"), ID: datatransfer.TransferID(id)}
+		proposal := &cardatatransfer.DealProposal{
+			PayloadCID: roots[0],
+			ID:         cardatatransfer.DealID(id),
+			Params: cardatatransfer.Params{
+				PieceCID:        &pieceCID,
+				PricePerByte:    ask.PricePerByte,
+				PaymentInterval: ask.PaymentInterval,
+			},
+		}
+		_, err := dt.validator.ValidatePull(false, chid, client, proposal, roots[0], selectorparse.CommonSelector_ExploreAllRecursively)
+		require.NoError(t, err)
+		chids = append(chids, chid)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = dt.revalidator.Revalidate(chids[0], &cardatatransfer.DealPayment{ID: 1})
+	}()
+	<-verifier.verifying
+	// Data is sent over the other channel while the payment of the first is being verified.
+	handled, _, err := dt.revalidator.OnPullDataSent(chids[1], 10)
+	require.True(t, handled)
+	require.NoError(t, err)
+	close(verifier.release)
+	<-done
+}
+
+func getBstoreSize(ctx context.Context, t *testing.T, bs bstore.Blockstore) int {
+	keys, err := bs.AllKeysChan(ctx)
+	require.NoError(t, err)
+	var size int
+	for k := range keys {
+		s, err := bs.GetSize(ctx, k)
+		require.NoError(t, err)
+		size += s
+	}
+	return size
+}
+
+// fixedPricer asks the same terms for all retrievals.
+type fixedPricer cardatatransfer.Ask
+
+func (fp fixedPricer) GetAsk(context.Context, peer.ID, []byte) (cardatatransfer.Ask, error) {
+	return cardatatransfer.Ask(fp), nil
+}
+
+// fakePaymentChannel stands in for a payment channel, where vouchers are the CBOR encoded
+// cumulative amount paid over the channel.
+type fakePaymentChannel struct {
+	addr address.Address
+	err  error
+	lk   sync.Mutex
+	paid abi.TokenAmount
+}
+
+func newFakePaymentChannel(err error) *fakePaymentChannel {
+	addr, _ := address.NewIDAddress(1413)
+	return &fakePaymentChannel{addr: addr, err: err, paid: big.Zero()}
+}
+
+func (fpc *fakePaymentChannel) voucher(t *testing.T, amount abi.TokenAmount) *cbg.Deferred {
+	buf := new(bytes.Buffer)
+	require.NoError(t, amount.MarshalCBOR(buf))
+	return &cbg.Deferred{Raw: buf.Bytes()}
+}
+
+func (fpc *fakePaymentChannel) VerifyPayment(_ context.Context, _ peer.ID, paymentChannel address.Address, voucher *cbg.Deferred, _ abi.TokenAmount) (abi.TokenAmount, error) {
+	if fpc.err != nil {
+		return abi.TokenAmount{}, fpc.err
+	}
+	if paymentChannel != fpc.addr {
+		return abi.TokenAmount{}, errors.New("unknown payment channel")
+	}
+	var amount abi.TokenAmount
+	if err := amount.UnmarshalCBOR(bytes.NewReader(voucher.Raw)); err != nil {
+		return abi.TokenAmount{}, err
+	}
+	fpc.lk.Lock()
+	defer fpc.lk.Unlock()
+	received := big.Sub(amount, fpc.paid)
+	if received.LessThan(big.Zero()) {
+		return big.Zero(), nil
+	}
+	fpc.paid = amount
+	return received, nil
+}
+
+func (fpc *fakePaymentChannel) total() abi.TokenAmount {
+	fpc.lk.Lock()
+	defer fpc.lk.Unlock()
+	return fpc.paid
+}
+
+// blockingVerifier blocks the verification of payments until released.
+type blockingVerifier struct {
+	verifying chan struct{}
+	release   chan struct{}
+}
+
+func (bv *blockingVerifier) VerifyPayment(ctx context.Context, _ peer.ID, _ address.Address, _ *cbg.Deferred, _ abi.TokenAmount) (abi.TokenAmount, error) {
+	close(bv.verifying)
+	select {
+	case <-bv.release:
+		return big.Zero(), nil
+	case <-ctx.Done():
+		return abi.TokenAmount{}, ctx.Err()
+	}
+}
+
+// fakeDataTransfer captures the validator and revalidator registered with a data transfer
+// manager, so that they can be called directly.
+type fakeDataTransfer struct {
+	datatransfer.Manager
+	validator   datatransfer.RequestValidator
+	revalidator datatransfer.Revalidator
+	proposals   map[datatransfer.ChannelID]*cardatatransfer.DealProposal
+	resumed     chan datatransfer.ChannelID
+}
+
+func newFakeDataTransfer() *fakeDataTransfer {
+	return &fakeDataTransfer{
+		proposals: make(map[datatransfer.ChannelID]*cardatatransfer.DealProposal),
+		resumed:   make(chan datatransfer.ChannelID, 1),
+	}
+}
+
+func (f *fakeDataTransfer) RegisterVoucherType(_ datatransfer.Voucher, v datatransfer.RequestValidator) error {
+	f.validator = v
+	return nil
+}
+
+func (f *fakeDataTransfer) RegisterRevalidator(_ datatransfer.Voucher, r datatransfer.Revalidator) error {
+	f.revalidator = r
+	return nil
+}
+
+func (f *fakeDataTransfer) RegisterVoucherResultType(datatransfer.VoucherResult) error { return nil }
+
+func (f *fakeDataTransfer) RegisterTransportConfigurer(datatransfer.Voucher, datatransfer.TransportConfigurer) error {
+	return nil
+}
+
+func (f *fakeDataTransfer) SubscribeToEvents(datatransfer.Subscriber) datatransfer.Unsubscribe {
+	return func() {}
+}
+
+func (f *fakeDataTransfer) ChannelState(_ context.Context, chid datatransfer.ChannelID) (datatransfer.ChannelState, error) {
+	proposal, ok := f.proposals[chid]
+	if !ok {
+		return nil, errors.New("channel not found")
+	}
+	return fakeChannelState{voucher: proposal}, nil
+}
+
+func (f *fakeDataTransfer) ResumeDataTransferChannel(_ context.Context, chid datatransfer.ChannelID) error {
+	f.resumed <- chid
+	return nil
+}
+
+type fakeChannelState struct {
+	datatransfer.ChannelState
+	voucher datatransfer.Voucher
+}
+
+func (f fakeChannelState) Voucher() datatransfer.Voucher { return f.voucher }
@@ -0,0 +1,223 @@
+package cardatatransfer
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+var (
+	// ErrPeerNotAllowed signals that a retrieval is rejected since the requesting peer is denied
+	// by the retrieval policy.
+	ErrPeerNotAllowed = errors.New("peer is not allowed to retrieve")
+	// ErrTooManyTransfers signals that a retrieval is rejected since the requesting peer has
+	// reached the limit of concurrent transfers.
+	ErrTooManyTransfers = errors.New("too many concurrent transfers for peer")
+	// ErrTooManyOpenBlockstores signals that a retrieval is rejected since the limit of open
+	// blockstores is reached.
+	ErrTooManyOpenBlockstores = errors.New("too many open blockstores")
+)
+
+// RetrievalPolicy determines which retrievals are served and limits the resources they use.
+// The zero value serves all retrievals without limits.
+type RetrievalPolicy struct {
+	// AllowPeers lists the only peers allowed to retrieve. All peers that are not denied are
+	// allowed if empty.
+	AllowPeers []peer.ID
+	// DenyPeers lists the peers that are not allowed to retrieve, which takes precedence over
+	// AllowPeers.
+	DenyPeers []peer.ID
+	// MaxTransfersPerPeer is the maximum number of transfers served concurrently to the same
+	// peer. Zero means no limit.
+	MaxTransfersPerPeer int
+	// MaxBytesPerSecondPerContextID is the maximum rate in bytes per second at which the content of
+	// the same context ID is sent, across all the transfers that retrieve it. Zero means no limit.
+	MaxBytesPerSecondPerContextID uint64
+	// MaxOpenBlockstores is the maximum number of blockstores open concurrently to serve
+	// transfers. Zero means no limit.
+	MaxOpenBlockstores int
+}
+
+// policyEnforcer enforces a RetrievalPolicy across the transfers in progress.
+type policyEnforcer struct {
+	allow map[peer.ID]struct{}
+	deny  map[peer.ID]struct{}
+	// maxTransfersPerPeer and maxBytesPerSecond are as in RetrievalPolicy.
+	maxTransfersPerPeer int
+	maxBytesPerSecond   uint64
+
+	lk sync.Mutex
+	// transfers maps the channels in progress to the context ID they retrieve.
+	transfers     map[datatransfer.ChannelID]string
+	peerTransfers map[peer.ID]int
+	limiters      map[string]*bandwidthLimiter
+	// throttled maps the channels paused to stay within the bandwidth cap to the timers that
+	// resume them.
+	throttled map[datatransfer.ChannelID]*time.Timer
+}
+
+func newPolicyEnforcer(p RetrievalPolicy) *policyEnforcer {
+	pe := &policyEnforcer{
+		deny:                make(map[peer.ID]struct{}, len(p.DenyPeers)),
+		maxTransfersPerPeer: p.MaxTransfersPerPeer,
+		maxBytesPerSecond:   p.MaxBytesPerSecondPerContextID,
+		transfers:           make(map[datatransfer.ChannelID]string),
+		peerTransfers:       make(map[peer.ID]int),
+		limiters:            make(map[string]*bandwidthLimiter),
+		throttled:           make(map[datatransfer.ChannelID]*time.Timer),
+	}
+	if len(p.AllowPeers) != 0 {
+		pe.allow = make(map[peer.ID]struct{}, len(p.AllowPeers))
+		for _, id := range p.AllowPeers {
+			pe.allow[id] = struct{}{}
+		}
+	}
+	for _, id := range p.DenyPeers {
+		pe.deny[id] = struct{}{}
+	}
+	return pe
+}
+
+// allowed checks whether the given peer is allowed to retrieve.
+func (pe *policyEnforcer) allowed(receiver peer.ID) error {
+	if _, denied := pe.deny[receiver]; denied {
+		return ErrPeerNotAllowed
+	}
+	if pe.allow != nil {
+		if _, ok := pe.allow[receiver]; !ok {
+			return ErrPeerNotAllowed
+		}
+	}
+	return nil
+}
+
+// start records the start of a transfer over the given channel of the content with the given
+// context ID, unless the receiving peer has reached the limit of concurrent transfers.
+func (pe *policyEnforcer) start(chid datatransfer.ChannelID, contextID []byte) error {
+	pe.lk.Lock()
+	defer pe.lk.Unlock()
+	if _, ok := pe.transfers[chid]; ok {
+		return nil
+	}
+	receiver := chid.Initiator
+	if pe.maxTransfersPerPeer > 0 && pe.peerTransfers[receiver] >= pe.maxTransfersPerPeer {
+		return ErrTooManyTransfers
+	}
+	pe.transfers[chid] = string(contextID)
+	pe.peerTransfers[receiver]++
+	if pe.maxBytesPerSecond > 0 {
+		l, ok := pe.limiters[string(contextID)]
+		if !ok {
+			l = newBandwidthLimiter(pe.maxBytesPerSecond)
+			pe.limiters[string(contextID)] = l
+		}
+		l.transfers++
+	}
+	return nil
+}
+
+// finish records the end of the transfer over the given channel, if any.
+func (pe *policyEnforcer) finish(chid datatransfer.ChannelID) {
+	pe.lk.Lock()
+	defer pe.lk.Unlock()
+	contextID, ok := pe.transfers[chid]
+	if !ok {
+		return
+	}
+	delete(pe.transfers, chid)
+	if t, ok := pe.throttled[chid]; ok {
+		t.Stop()
+		delete(pe.throttled, chid)
+	}
+	receiver := chid.Initiator
+	if pe.peerTransfers[receiver]--; pe.peerTransfers[receiver] <= 0 {
+		delete(pe.peerTransfers, receiver)
+	}
+	if l, ok := pe.limiters[contextID]; ok {
+		if l.transfers--; l.transfers <= 0 {
+			delete(pe.limiters, contextID)
+		}
+	}
+}
+
+// throttle keeps the rate at which the content retrieved over the given channel is sent within
+// the bandwidth cap. If sending the given number of bytes exceeds the cap, the returned pause is
+// true, in which case the channel must be paused and is resumed by calling the given resume
+// function once the wait is over. The returned handled is false if the channel is not a transfer
+// in progress.
+func (pe *policyEnforcer) throttle(chid datatransfer.ChannelID, bytesSent uint64, resume func(datatransfer.ChannelID)) (handled bool, pause bool) {
+	pe.lk.Lock()
+	defer pe.lk.Unlock()
+	contextID, ok := pe.transfers[chid]
+	if !ok {
+		return false, false
+	}
+	l := pe.limiters[contextID]
+	if l == nil {
+		return true, false
+	}
+	wait := l.reserve(bytesSent)
+	if wait <= 0 {
+		return true, false
+	}
+	// Data may still be sent while the channel is being paused, in which case the channel is
+	// already paused and its resumption is pushed back instead.
+	t, paused := pe.throttled[chid]
+	if paused && !t.Stop() {
+		// The channel is being resumed, and must be paused again.
+		paused = false
+	}
+	t = time.AfterFunc(wait, func() {
+		pe.lk.Lock()
+		current := pe.throttled[chid] == t
+		if current {
+			delete(pe.throttled, chid)
+		}
+		pe.lk.Unlock()
+		if current {
+			resume(chid)
+		}
+	})
+	pe.throttled[chid] = t
+	return true, !paused
+}
+
+// throttling returns whether the given channel is paused to stay within the bandwidth cap.
+func (pe *policyEnforcer) throttling(chid datatransfer.ChannelID) bool {
+	pe.lk.Lock()
+	defer pe.lk.Unlock()
+	_, ok := pe.throttled[chid]
+	return ok
+}
+
+// bandwidthLimiter spreads the bytes sent by the transfers of the same context ID over time.
+type bandwidthLimiter struct {
+	bytesPerSecond uint64
+	// transfers is the number of transfers in progress that share the limiter.
+	transfers int
+
+	lk sync.Mutex
+	// next is the time at which the bytes sent so far are within the bandwidth cap.
+	next time.Time
+}
+
+func newBandwidthLimiter(bytesPerSecond uint64) *bandwidthLimiter {
+	return &bandwidthLimiter{bytesPerSecond: bytesPerSecond}
+}
+
+// reserve accounts for the given number of bytes to send, and returns how long to wait before
+// sending them.
+func (l *bandwidthLimiter) reserve(n uint64) time.Duration {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.bytesPerSecond) * float64(time.Second)))
+	return wait
+}
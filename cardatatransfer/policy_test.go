@@ -0,0 +1,279 @@
+package cardatatransfer_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-graphsync/storeutil"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/index-provider/cardatatransfer"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/filecoin-project/index-provider/testutil"
+)
+
+func TestCarDataTransfer_RetrievalPolicy(t *testing.T) {
+	contextID := []byte("cheese")
+	rdOnlyBS := testutil.OpenSampleCar(t, "sample-v1-2.car")
+	roots, err := rdOnlyBS.Roots()
+	require.NoError(t, err)
+	require.Len(t, roots, 1)
+	pieceCID := pieceCIDFromContextID(t, contextID)
+	proposal := func(id cardatatransfer.DealID) *cardatatransfer.DealProposal {
+		return &cardatatransfer.DealProposal{
+			PayloadCID: roots[0],
+			ID:         id,
+			Params:     cardatatransfer.Params{PieceCID: &pieceCID},
+		}
+	}
+	// Cap bandwidth such that a transfer takes about a second.
+	bytesPerSecond := uint64(getBstoreSize(context.Background(), t, rdOnlyBS))
+
+	t.Run("denied peer", func(t *testing.T) {
+		rt := newRetrievalTest(t, contextID, rdOnlyBS, func(client peer.ID) cardatatransfer.RetrievalPolicy {
+			return cardatatransfer.RetrievalPolicy{DenyPeers: []peer.ID{client}}
+		})
+		got := <-rt.retrieve(proposal(1), roots[0])
+		require.False(t, got.success)
+		require.Equal(t, cardatatransfer.DealStatusRejected, got.status)
+		require.Equal(t, cardatatransfer.ErrPeerNotAllowed.Error(), got.message)
+	})
+
+	t.Run("peer not in allow list", func(t *testing.T) {
+		rt := newRetrievalTest(t, contextID, rdOnlyBS, func(peer.ID) cardatatransfer.RetrievalPolicy {
+			return cardatatransfer.RetrievalPolicy{AllowPeers: []peer.ID{"fish"}}
+		})
+		got := <-rt.retrieve(proposal(1), roots[0])
+		require.False(t, got.success)
+		require.Equal(t, cardatatransfer.DealStatusRejected, got.status)
+		require.Equal(t, cardatatransfer.ErrPeerNotAllowed.Error(), got.message)
+	})
+
+	t.Run("peer in allow list", func(t *testing.T) {
+		rt := newRetrievalTest(t, contextID, rdOnlyBS, func(client peer.ID) cardatatransfer.RetrievalPolicy {
+			return cardatatransfer.RetrievalPolicy{AllowPeers: []peer.ID{client}}
+		})
+		require.True(t, (<-rt.retrieve(proposal(1), roots[0])).success)
+	})
+
+	t.Run("bandwidth cap and concurrent transfers per peer", func(t *testing.T) {
+		rt := newRetrievalTest(t, contextID, rdOnlyBS, func(peer.ID) cardatatransfer.RetrievalPolicy {
+			return cardatatransfer.RetrievalPolicy{
+				MaxTransfersPerPeer:           1,
+				MaxBytesPerSecondPerContextID: bytesPerSecond,
+			}
+		})
+		start := time.Now()
+		first := rt.retrieve(proposal(1), roots[0])
+		// The first transfer is still in progress due to the bandwidth cap.
+		second := <-rt.retrieve(proposal(2), roots[0])
+		require.False(t, second.success)
+		require.Equal(t, cardatatransfer.DealStatusRejected, second.status)
+		require.Equal(t, cardatatransfer.ErrTooManyTransfers.Error(), second.message)
+
+		require.True(t, (<-first).success)
+		require.Greater(t, time.Since(start), 500*time.Millisecond)
+
+		// Transfers are accepted again once the first one is done.
+		require.Eventually(t, func() bool {
+			return (<-rt.retrieve(proposal(3), roots[0])).success
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("open blockstores", func(t *testing.T) {
+		rt := newRetrievalTest(t, contextID, rdOnlyBS, func(peer.ID) cardatatransfer.RetrievalPolicy {
+			return cardatatransfer.RetrievalPolicy{
+				MaxOpenBlockstores:            1,
+				MaxBytesPerSecondPerContextID: bytesPerSecond,
+			}
+		})
+		first := rt.retrieve(proposal(1), roots[0])
+		second := <-rt.retrieve(proposal(2), roots[0])
+		require.False(t, second.success)
+		require.Equal(t, cardatatransfer.DealStatusRejected, second.status)
+		require.Equal(t, cardatatransfer.ErrTooManyOpenBlockstores.Error(), second.message)
+		require.True(t, (<-first).success)
+	})
+}
+
+func TestCarDataTransfer_ThrottledTransferIsPausedAndResumed(t *testing.T) {
+	contextID := []byte("cheese")
+	rdOnlyBS := testutil.OpenSampleCar(t, "sample-v1-2.car")
+	roots, err := rdOnlyBS.Roots()
+	require.NoError(t, err)
+	pieceCID := pieceCIDFromContextID(t, contextID)
+	dt := newFakeDataTransfer()
+	supplier := &fakeSupplier{blockstores: map[string]supplier.ClosableBlockstore{string(contextID): rdOnlyBS}}
+	require.NoError(t, cardatatransfer.StartCarDataTransfer(dt, supplier,
+		cardatatransfer.WithRetrievalPolicy(cardatatransfer.RetrievalPolicy{MaxBytesPerSecondPerContextID: 1000})))
+
+	client := peer.ID("fish")
+	chid := datatransfer.ChannelID{Initiator: client, Responder: peer.ID("lobster"), ID: 1}
+	proposal := &cardatatransfer.DealProposal{
+		PayloadCID: roots[0],
+		ID:         1,
+		Params:     cardatatransfer.Params{PieceCID: &pieceCID},
+	}
+	_, err = dt.validator.ValidatePull(false, chid, client, proposal, roots[0], selectorparse.CommonSelector_ExploreAllRecursively)
+	require.NoError(t, err)
+
+	// The first second worth of data is sent right away.
+	handled, _, err := dt.revalidator.OnPullDataSent(chid, 1000)
+	require.True(t, handled)
+	require.NoError(t, err)
+
+	// Data beyond the cap pauses the transfer without holding up the sender.
+	start := time.Now()
+	handled, _, err = dt.revalidator.OnPullDataSent(chid, 100)
+	require.True(t, handled)
+	require.Equal(t, datatransfer.ErrPause, err)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// The transfer is resumed once the cap allows it.
+	select {
+	case got := <-dt.resumed:
+		require.Equal(t, chid, got)
+		require.Greater(t, time.Since(start), 500*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		t.Fatal("throttled transfer was not resumed")
+	}
+}
+
+func TestStartCarDataTransfer_NegativePolicyLimits(t *testing.T) {
+	mn := mocknet.New()
+	h, err := mn.GenPeer()
+	require.NoError(t, err)
+	dt := testutil.SetupDataTransferOnHost(t, h, dssync.MutexWrap(datastore.NewMapDatastore()), cidlink.DefaultLinkSystem())
+	err = cardatatransfer.StartCarDataTransfer(dt, &fakeSupplier{}, cardatatransfer.WithRetrievalPolicy(cardatatransfer.RetrievalPolicy{MaxOpenBlockstores: -1}))
+	require.EqualError(t, err, "retrieval policy limits must not be negative")
+}
+
+type retrievalResult struct {
+	success bool
+	status  cardatatransfer.DealStatus
+	message string
+}
+
+// retrievalTest retrieves from a provider that serves a single context ID under a retrieval policy,
+// started with the given additional options.
+type retrievalTest struct {
+	t        *testing.T
+	ctx      context.Context
+	provider host.Host
+	client   datatransfer.Manager
+	clientID peer.ID
+
+	lk        sync.Mutex
+	retrieval map[datatransfer.ChannelID]*retrieval
+}
+
+type retrieval struct {
+	result retrievalResult
+	done   chan struct{}
+}
+
+func newRetrievalTest(t *testing.T, contextID []byte, bs supplier.ClosableBlockstore, policy func(client peer.ID) cardatatransfer.RetrievalPolicy, opts ...cardatatransfer.Option) *retrievalTest {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	mn := mocknet.New()
+	srcHost, err := mn.GenPeer()
+	require.NoError(t, err)
+	dstHost, err := mn.GenPeer()
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+
+	supplier := &fakeSupplier{blockstores: map[string]supplier.ClosableBlockstore{string(contextID): nopCloser{bs}}}
+	srcDt := testutil.SetupDataTransferOnHost(t, srcHost, dssync.MutexWrap(datastore.NewMapDatastore()), cidlink.DefaultLinkSystem())
+	opts = append(opts, cardatatransfer.WithRetrievalPolicy(policy(dstHost.ID())))
+	require.NoError(t, cardatatransfer.StartCarDataTransfer(srcDt, supplier, opts...))
+
+	dstStore := dssync.MutexWrap(datastore.NewMapDatastore())
+	dstDt := testutil.SetupDataTransferOnHost(t, dstHost, dstStore, storeutil.LinkSystemForBlockstore(bstore.NewBlockstore(dstStore)))
+	require.NoError(t, dstDt.RegisterVoucherResultType(&cardatatransfer.DealResponse{}))
+	require.NoError(t, dstDt.RegisterVoucherType(&cardatatransfer.DealProposal{}, nil))
+
+	rt := &retrievalTest{
+		t:         t,
+		ctx:       ctx,
+		provider:  srcHost,
+		client:    dstDt,
+		clientID:  dstHost.ID(),
+		retrieval: make(map[datatransfer.ChannelID]*retrieval),
+	}
+	dstDt.SubscribeToEvents(func(event datatransfer.Event, channelState datatransfer.ChannelState) {
+		rt.lk.Lock()
+		defer rt.lk.Unlock()
+		r := rt.retrievalLocked(channelState.ChannelID())
+		if event.Code == datatransfer.NewVoucherResult {
+			if vr, ok := channelState.LastVoucherResult().(*cardatatransfer.DealResponse); ok {
+				r.result.status = vr.Status
+				r.result.message = vr.Message
+			}
+		}
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+		switch channelState.Status() {
+		case datatransfer.Cancelled, datatransfer.Failed:
+			close(r.done)
+		case datatransfer.Completed:
+			r.result.success = true
+			close(r.done)
+		}
+	})
+	return rt
+}
+
+func (rt *retrievalTest) retrievalLocked(chid datatransfer.ChannelID) *retrieval {
+	r, ok := rt.retrieval[chid]
+	if !ok {
+		r = &retrieval{done: make(chan struct{})}
+		rt.retrieval[chid] = r
+	}
+	return r
+}
+
+// retrieve opens a pull channel for the given proposal, and returns a channel on which the result
+// of the retrieval is sent.
+func (rt *retrievalTest) retrieve(proposal *cardatatransfer.DealProposal, root cid.Cid) <-chan retrievalResult {
+	chid, err := rt.client.OpenPullDataChannel(rt.ctx, rt.provider.ID(), proposal, root, selectorparse.CommonSelector_ExploreAllRecursively)
+	require.NoError(rt.t, err)
+	rt.lk.Lock()
+	r := rt.retrievalLocked(chid)
+	rt.lk.Unlock()
+
+	res := make(chan retrievalResult, 1)
+	go func() {
+		select {
+		case <-rt.ctx.Done():
+			res <- retrievalResult{message: "context closed"}
+		case <-r.done:
+			rt.lk.Lock()
+			res <- r.result
+			rt.lk.Unlock()
+		}
+	}()
+	return res
+}
+
+// nopCloser prevents the blockstore shared by retrievals from being closed once a retrieval is
+// done.
+type nopCloser struct {
+	supplier.ClosableBlockstore
+}
+
+func (nopCloser) Close() error { return nil }
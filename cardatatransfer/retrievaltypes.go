@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 
+	"github.com/filecoin-project/go-address"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/ipfs/go-cid"
@@ -13,7 +14,7 @@ import (
 
 /* This file is copied from go-fil-markets types for retrieval */
 
-//go:generate cbor-gen-for --map-encoding DealProposal DealResponse Params
+//go:generate cbor-gen-for --map-encoding DealProposal DealResponse Params DealPayment
 
 // DealStatus is the status of a retrieval deal returned by a provider
 // in a DealResponse
@@ -215,3 +216,19 @@ func (dr *DealResponse) Type() datatransfer.TypeIdentifier {
 
 // DealResponseUndefined is an undefined deal response
 var DealResponseUndefined = DealResponse{}
+
+// DealPayment is a payment for an in progress retrieval deal.
+// The payment voucher is kept in its CBOR encoded form, which is interpreted by PaymentVerifier.
+type DealPayment struct {
+	ID             DealID
+	PaymentChannel address.Address
+	PaymentVoucher *cbg.Deferred
+}
+
+// Type method makes DealPayment usable as a voucher
+func (dr *DealPayment) Type() datatransfer.TypeIdentifier {
+	return "RetrievalDealPayment/1"
+}
+
+// DealPaymentUndefined is an undefined deal payment
+var DealPaymentUndefined = DealPayment{}
@@ -577,3 +577,142 @@ func (t *Params) UnmarshalCBOR(r io.Reader) error {
 
 	return nil
 }
+func (t *DealPayment) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{163}); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.ID (cardatatransfer.DealID) (uint64)
+	if len("ID") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"ID\" was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len("ID"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("ID")); err != nil {
+		return err
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.ID)); err != nil {
+		return err
+	}
+
+	// t.PaymentChannel (address.Address) (struct)
+	if len("PaymentChannel") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"PaymentChannel\" was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len("PaymentChannel"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("PaymentChannel")); err != nil {
+		return err
+	}
+
+	if err := t.PaymentChannel.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.PaymentVoucher (typegen.Deferred) (struct)
+	if len("PaymentVoucher") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"PaymentVoucher\" was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len("PaymentVoucher"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("PaymentVoucher")); err != nil {
+		return err
+	}
+
+	if err := t.PaymentVoucher.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *DealPayment) UnmarshalCBOR(r io.Reader) error {
+	*t = DealPayment{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("DealPayment: map struct too large (%d)", extra)
+	}
+
+	var name string
+	n := extra
+
+	for i := uint64(0); i < n; i++ {
+
+		{
+			sval, err := cbg.ReadStringBuf(br, scratch)
+			if err != nil {
+				return err
+			}
+
+			name = string(sval)
+		}
+
+		switch name {
+		// t.ID (cardatatransfer.DealID) (uint64)
+		case "ID":
+
+			{
+
+				maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+				if err != nil {
+					return err
+				}
+				if maj != cbg.MajUnsignedInt {
+					return fmt.Errorf("wrong type for uint64 field")
+				}
+				t.ID = DealID(extra)
+
+			}
+			// t.PaymentChannel (address.Address) (struct)
+		case "PaymentChannel":
+
+			{
+
+				if err := t.PaymentChannel.UnmarshalCBOR(br); err != nil {
+					return xerrors.Errorf("unmarshaling t.PaymentChannel: %w", err)
+				}
+
+			}
+			// t.PaymentVoucher (typegen.Deferred) (struct)
+		case "PaymentVoucher":
+
+			{
+
+				t.PaymentVoucher = new(cbg.Deferred)
+
+				if err := t.PaymentVoucher.UnmarshalCBOR(br); err != nil {
+					return xerrors.Errorf("failed to read deferred field: %w", err)
+				}
+			}
+
+		default:
+			// Field doesn't exist on this type, so ignore it
+			cbg.ScanForLinks(r, func(cid.Cid) {})
+		}
+	}
+
+	return nil
+}
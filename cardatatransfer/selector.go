@@ -0,0 +1,321 @@
+package cardatatransfer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-unixfsnode"
+	"github.com/ipfs/go-unixfsnode/data"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+const (
+	// DefaultSelectorComplexityBudget is the default complexity budget of the selectors that are
+	// served.
+	//
+	// See: WithSelectorComplexityBudget.
+	DefaultSelectorComplexityBudget = 1024
+
+	// maxSelectorDepth is the maximum nesting depth of the selectors that are served, regardless of
+	// the complexity budget.
+	maxSelectorDepth = 256
+
+	// exploreRangeCost is the complexity of a range explored by a selector on top of the nodes that
+	// make it up. Ranges cost the same regardless of their span, since only the indices that exist
+	// in the data are explored.
+	exploreRangeCost = 16
+
+	// unixfsADL is the name of the only ADL that selectors may interpret nodes as.
+	unixfsADL = "unixfs"
+)
+
+var (
+	// ErrSelectorTooComplex signals that a retrieval is rejected since its selector exceeds the
+	// complexity budget.
+	ErrSelectorTooComplex = errors.New("selector exceeds complexity budget")
+	// ErrUnsupportedADL signals that a retrieval is rejected since its selector interprets nodes as
+	// an ADL other than unixfs.
+	ErrUnsupportedADL = errors.New("selector interprets nodes as unsupported ADL")
+)
+
+// UnixFSPathSelector returns a selector that retrieves the unixfs file or directory at the given
+// path relative to the root of the retrieval, along with the directories traversed to reach it.
+// The entire file, or the entire directory tree, at the path is retrieved. An empty path retrieves
+// the entire DAG.
+func UnixFSPathSelector(path string) ipld.Node {
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
+	target := ssb.ExploreRecursive(selector.RecursionLimitNone(), ssb.ExploreAll(ssb.ExploreRecursiveEdge()))
+	return unixfsPath(ssb, path, target).Node()
+}
+
+// ByteRangeSelector returns a selector that retrieves the bytes in range [from, to) of the unixfs
+// file at the given path relative to the root of the retrieval, along with the directories
+// traversed to reach it. An empty path selects the root of the retrieval as the file.
+//
+// The given node is the root node of the file, which can be retrieved on its own with
+// DepthLimitSelector(0) from the CID of the file. The blocks that hold the range are determined from the sizes of the
+// subtrees linked from the root of the file: the subtrees that overlap the range are retrieved in
+// their entirety, and the others are not retrieved at all.
+func ByteRangeSelector(path string, file ipld.Node, from, to int64) (ipld.Node, error) {
+	if from < 0 || to <= from {
+		return nil, fmt.Errorf("invalid byte range [%d, %d)", from, to)
+	}
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
+	// Files that fit in a single raw block have no links to explore.
+	if file.Kind() == ipld.Kind_Bytes {
+		return unixfsPath(ssb, path, ssb.Matcher()).Node(), nil
+	}
+	first, last, err := linksInRange(file, from, to)
+	if err != nil {
+		return nil, err
+	}
+	target := ssb.Matcher()
+	if first < last {
+		target = ssb.ExploreFields(func(efsb builder.ExploreFieldsSpecBuilder) {
+			efsb.Insert("Links", ssb.ExploreRange(first, last, ssb.ExploreFields(func(efsb builder.ExploreFieldsSpecBuilder) {
+				efsb.Insert("Hash", ssb.ExploreRecursive(selector.RecursionLimitNone(), ssb.ExploreAll(ssb.ExploreRecursiveEdge())))
+			})))
+		})
+	}
+	return unixfsPath(ssb, path, target).Node(), nil
+}
+
+// linksInRange returns the range [first, last) of the links of the given unixfs file root node to
+// the subtrees that hold bytes in range [from, to) of the file.
+func linksInRange(file ipld.Node, from, to int64) (int64, int64, error) {
+	dataNode, err := file.LookupByString("Data")
+	if err != nil {
+		return 0, 0, fmt.Errorf("not a unixfs file: %w", err)
+	}
+	raw, err := dataNode.AsBytes()
+	if err != nil {
+		return 0, 0, fmt.Errorf("not a unixfs file: %w", err)
+	}
+	ufsData, err := data.DecodeUnixFSData(raw)
+	if err != nil {
+		return 0, 0, fmt.Errorf("not a unixfs file: %w", err)
+	}
+	if dt := ufsData.FieldDataType().Int(); dt != data.Data_File && dt != data.Data_Raw {
+		return 0, 0, fmt.Errorf("not a unixfs file: %s", data.DataTypeNames[dt])
+	}
+	// The subtrees hold the bytes that follow the data inlined in the root, if any.
+	var offset int64
+	if inline := ufsData.FieldData(); inline.Exists() {
+		offset = int64(len(inline.Must().Bytes()))
+	}
+	first, last := int64(-1), int64(0)
+	it := ufsData.FieldBlockSizes().Iterator()
+	for !it.Done() && offset < to {
+		i, size := it.Next()
+		end := offset + size.Int()
+		if end > from {
+			if first < 0 {
+				first = i
+			}
+			last = i + 1
+		}
+		offset = end
+	}
+	if first < 0 {
+		return 0, 0, nil
+	}
+	return first, last, nil
+}
+
+// DepthLimitSelector returns a selector that retrieves the nodes reachable from the root of the
+// retrieval within the given depth. Note that depth is counted in data model nodes rather than
+// blocks; for example, the blocks linked from a dag-pb root are selected from a depth of 4, since
+// they are reached via the Links list of the root, the link itself and its Hash.
+func DepthLimitSelector(depth int64) ipld.Node {
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
+	return ssb.ExploreRecursive(selector.RecursionLimitDepth(depth), ssb.ExploreAll(ssb.ExploreRecursiveEdge())).Node()
+}
+
+// unixfsPath returns a selector that reaches the given unixfs path and selects it with target.
+func unixfsPath(ssb builder.SelectorSpecBuilder, path string, target builder.SelectorSpec) builder.SelectorSpec {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	spec := target
+	for i := len(segments) - 1; i >= 0; i-- {
+		segment, next := segments[i], spec
+		spec = ssb.ExploreInterpretAs(unixfsADL, ssb.ExploreFields(func(efsb builder.ExploreFieldsSpecBuilder) {
+			efsb.Insert(segment, next)
+		}))
+	}
+	return spec
+}
+
+// SetSelector sets the selector of the retrieval to the given selector. The content selected must
+// be requested with the same selector when opening the data transfer channel.
+func (p *Params) SetSelector(sel ipld.Node) error {
+	buf := new(bytes.Buffer)
+	if err := dagcbor.Encode(sel, buf); err != nil {
+		return err
+	}
+	p.Selector = &cbg.Deferred{Raw: buf.Bytes()}
+	return nil
+}
+
+// checkSelector checks that the given selector is valid and within the given complexity budget.
+// The complexity of a selector is the number of data model nodes that make it up, plus a constant
+// cost for each range it explores.
+func checkSelector(sel ipld.Node, budget int) error {
+	sc := &selectorComplexity{budget: budget}
+	if err := sc.add(sel, 0); err != nil {
+		return err
+	}
+	if _, err := selector.ParseSelector(sel); err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	return nil
+}
+
+// selectorComplexity accumulates the complexity of a selector, and errors as soon as it exceeds
+// the budget.
+type selectorComplexity struct {
+	budget int
+	cost   int
+}
+
+func (sc *selectorComplexity) spend(cost int64) error {
+	if cost < 0 || cost > int64(sc.budget-sc.cost) {
+		return ErrSelectorTooComplex
+	}
+	sc.cost += int(cost)
+	return nil
+}
+
+func (sc *selectorComplexity) add(n ipld.Node, depth int) error {
+	if depth > maxSelectorDepth {
+		return ErrSelectorTooComplex
+	}
+	if err := sc.spend(1); err != nil {
+		return err
+	}
+	switch n.Kind() {
+	case ipld.Kind_List:
+		it := n.ListIterator()
+		for !it.Done() {
+			_, v, err := it.Next()
+			if err != nil {
+				return err
+			}
+			if err := sc.add(v, depth+1); err != nil {
+				return err
+			}
+		}
+	case ipld.Kind_Map:
+		it := n.MapIterator()
+		for !it.Done() {
+			k, v, err := it.Next()
+			if err != nil {
+				return err
+			}
+			key, err := k.AsString()
+			if err != nil {
+				return err
+			}
+			switch key {
+			case selector.SelectorKey_Fields:
+				// The keys of explored fields are field names rather than selector keys.
+				if err := sc.addFields(v, depth+1); err != nil {
+					return err
+				}
+				continue
+			case selector.SelectorKey_ExploreRange:
+				if err := sc.addRange(v); err != nil {
+					return err
+				}
+			case selector.SelectorKey_ExploreInterpretAs:
+				if err := checkADL(v); err != nil {
+					return err
+				}
+			}
+			if err := sc.add(v, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (sc *selectorComplexity) addFields(n ipld.Node, depth int) error {
+	if n.Kind() != ipld.Kind_Map {
+		return sc.add(n, depth)
+	}
+	if err := sc.spend(1); err != nil {
+		return err
+	}
+	it := n.MapIterator()
+	for !it.Done() {
+		_, v, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if err := sc.add(v, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addRange checks the given ExploreRange body, and spends the constant cost of exploring a range.
+func (sc *selectorComplexity) addRange(n ipld.Node) error {
+	start, err := lookupInt(n, selector.SelectorKey_Start)
+	if err != nil {
+		return err
+	}
+	end, err := lookupInt(n, selector.SelectorKey_End)
+	if err != nil {
+		return err
+	}
+	if end < start {
+		return fmt.Errorf("invalid selector: range end %d is before start %d", end, start)
+	}
+	return sc.spend(exploreRangeCost)
+}
+
+func checkADL(n ipld.Node) error {
+	as, err := n.LookupByString(selector.SelectorKey_As)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	adl, err := as.AsString()
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	if adl != unixfsADL {
+		return fmt.Errorf("%w: %s", ErrUnsupportedADL, adl)
+	}
+	return nil
+}
+
+func lookupInt(n ipld.Node, key string) (int64, error) {
+	v, err := n.LookupByString(key)
+	if err != nil {
+		return 0, fmt.Errorf("invalid selector: %w", err)
+	}
+	i, err := v.AsInt()
+	if err != nil {
+		return 0, fmt.Errorf("invalid selector: %w", err)
+	}
+	return i, nil
+}
+
+// withUnixFSReification returns the given link system with unixfs reification, so that the
+// selectors that interpret nodes as unixfs can be served.
+func withUnixFSReification(lsys ipld.LinkSystem) ipld.LinkSystem {
+	unixfsnode.AddUnixFSReificationToLinkSystem(&lsys)
+	return lsys
+}
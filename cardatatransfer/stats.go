@@ -0,0 +1,269 @@
+package cardatatransfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+const (
+	retrievalStatsDatastorePrefix = "car_data_transfer://retrieval_stats/"
+	retrievalCountersKeyPrefix    = retrievalStatsDatastorePrefix + "counters/"
+	retrievalRecordsKeyPrefix     = retrievalStatsDatastorePrefix + "records/"
+
+	// maxRetrievalRecordsPerContextID is the number of the most recent transfer records kept per
+	// context ID. Older records are overwritten, whereas counters account for all transfers.
+	maxRetrievalRecordsPerContextID = 100
+)
+
+// ErrNoRetrievals signals that no retrievals are recorded for a context ID.
+var ErrNoRetrievals = errors.New("no retrievals recorded for context ID")
+
+// RetrievalOutcome is the outcome of a transfer.
+type RetrievalOutcome string
+
+const (
+	// RetrievalCompleted signals that all the content selected by a retrieval was sent.
+	RetrievalCompleted RetrievalOutcome = "completed"
+	// RetrievalFailed signals that a transfer ended due to an error, including disconnection.
+	RetrievalFailed RetrievalOutcome = "failed"
+	// RetrievalCancelled signals that a transfer was cancelled by either end.
+	RetrievalCancelled RetrievalOutcome = "cancelled"
+)
+
+// RetrievalRecord records a transfer of the content of a context ID.
+type RetrievalRecord struct {
+	// Peer is the peer to which the content was sent.
+	Peer peer.ID `json:"peer"`
+	// ContextID is the context ID of the content.
+	ContextID []byte `json:"contextID"`
+	// BytesSent is the number of bytes sent over the transfer.
+	BytesSent uint64 `json:"bytesSent"`
+	// Started is the time at which the transfer was accepted.
+	Started time.Time `json:"started"`
+	// Duration is the time from the acceptance of the transfer to its end.
+	Duration time.Duration `json:"duration"`
+	// Outcome is the way in which the transfer ended.
+	Outcome RetrievalOutcome `json:"outcome"`
+}
+
+// RetrievalCounters aggregates the transfers of the content of a context ID.
+type RetrievalCounters struct {
+	// ContextID is the context ID of the content.
+	ContextID []byte `json:"contextID"`
+	// Completed, Failed and Cancelled are the numbers of transfers by outcome.
+	Completed uint64 `json:"completed"`
+	Failed    uint64 `json:"failed"`
+	Cancelled uint64 `json:"cancelled"`
+	// BytesSent is the total number of bytes sent over all transfers.
+	BytesSent uint64 `json:"bytesSent"`
+	// Duration is the total duration of all transfers.
+	Duration time.Duration `json:"duration"`
+	// LastRetrieved is the time at which the latest transfer ended.
+	LastRetrieved time.Time `json:"lastRetrieved"`
+}
+
+// Transfers returns the total number of transfers, regardless of their outcome.
+func (c *RetrievalCounters) Transfers() uint64 {
+	return c.Completed + c.Failed + c.Cancelled
+}
+
+func (c *RetrievalCounters) add(r *RetrievalRecord) {
+	switch r.Outcome {
+	case RetrievalCompleted:
+		c.Completed++
+	case RetrievalCancelled:
+		c.Cancelled++
+	default:
+		c.Failed++
+	}
+	c.BytesSent += r.BytesSent
+	c.Duration += r.Duration
+	c.LastRetrieved = r.Started.Add(r.Duration)
+}
+
+// RetrievalStats persists a record of each transfer served, and aggregates them into counters per
+// context ID. The counters are kept for as long as the datastore is, whereas only the most recent
+// records of each context ID are kept.
+//
+// See: WithRetrievalStats.
+type RetrievalStats struct {
+	ds datastore.Batching
+	// writeLk serializes the updates of counters.
+	writeLk sync.Mutex
+
+	lk sync.Mutex
+	// transfers maps the channels in progress to the start of their transfer.
+	transfers map[datatransfer.ChannelID]transferStart
+}
+
+type transferStart struct {
+	contextID []byte
+	started   time.Time
+}
+
+// NewRetrievalStats instantiates retrieval stats that are persisted in the given datastore.
+func NewRetrievalStats(ds datastore.Batching) *RetrievalStats {
+	return &RetrievalStats{
+		ds:        ds,
+		transfers: make(map[datatransfer.ChannelID]transferStart),
+	}
+}
+
+// start records the start of a transfer over the given channel of the content with the given
+// context ID.
+func (rs *RetrievalStats) start(chid datatransfer.ChannelID, contextID []byte) {
+	rs.lk.Lock()
+	defer rs.lk.Unlock()
+	if _, ok := rs.transfers[chid]; ok {
+		return
+	}
+	rs.transfers[chid] = transferStart{contextID: contextID, started: time.Now()}
+}
+
+// finish records the transfer over the given channel, if its start was recorded.
+func (rs *RetrievalStats) finish(ctx context.Context, chid datatransfer.ChannelID, bytesSent uint64, outcome RetrievalOutcome) error {
+	rs.lk.Lock()
+	ts, ok := rs.transfers[chid]
+	delete(rs.transfers, chid)
+	rs.lk.Unlock()
+	if !ok {
+		return nil
+	}
+	return rs.Record(ctx, &RetrievalRecord{
+		Peer:      chid.Initiator,
+		ContextID: ts.contextID,
+		BytesSent: bytesSent,
+		Started:   ts.started,
+		Duration:  time.Since(ts.started),
+		Outcome:   outcome,
+	})
+}
+
+// Record persists the given record, and accounts for it in the counters of its context ID.
+func (rs *RetrievalStats) Record(ctx context.Context, record *RetrievalRecord) error {
+	rs.writeLk.Lock()
+	defer rs.writeLk.Unlock()
+	counters, err := rs.Get(ctx, record.ContextID)
+	switch err {
+	case nil:
+	case ErrNoRetrievals:
+		counters = &RetrievalCounters{ContextID: record.ContextID}
+	default:
+		return err
+	}
+	// The oldest record is overwritten once the limit of records is reached.
+	index := counters.Transfers() % maxRetrievalRecordsPerContextID
+	counters.add(record)
+
+	batch, err := rs.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	if err := putJSON(ctx, batch, toRetrievalRecordKey(record.ContextID, index), record); err != nil {
+		return err
+	}
+	if err := putJSON(ctx, batch, toRetrievalCountersKey(record.ContextID), counters); err != nil {
+		return err
+	}
+	return batch.Commit(ctx)
+}
+
+// Get returns the counters of the transfers of the content with the given context ID.
+// ErrNoRetrievals is returned if no transfers of the content are recorded.
+func (rs *RetrievalStats) Get(ctx context.Context, contextID []byte) (*RetrievalCounters, error) {
+	v, err := rs.ds.Get(ctx, toRetrievalCountersKey(contextID))
+	if err != nil {
+		if err == datastore.ErrNotFound {
+			return nil, ErrNoRetrievals
+		}
+		return nil, err
+	}
+	var counters RetrievalCounters
+	if err := json.Unmarshal(v, &counters); err != nil {
+		return nil, fmt.Errorf("failed to decode retrieval counters: %w", err)
+	}
+	return &counters, nil
+}
+
+// List returns the counters of all the context IDs of which transfers are recorded, in descending
+// order of number of transfers.
+func (rs *RetrievalStats) List(ctx context.Context) ([]RetrievalCounters, error) {
+	results, err := rs.ds.Query(ctx, query.Query{Prefix: retrievalCountersKeyPrefix})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var list []RetrievalCounters
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var counters RetrievalCounters
+		if err := json.Unmarshal(r.Value, &counters); err != nil {
+			return nil, fmt.Errorf("failed to decode retrieval counters %s: %w", r.Key, err)
+		}
+		list = append(list, counters)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if ti, tj := list[i].Transfers(), list[j].Transfers(); ti != tj {
+			return ti > tj
+		}
+		return bytes.Compare(list[i].ContextID, list[j].ContextID) < 0
+	})
+	return list, nil
+}
+
+// Records returns the most recent records of the transfers of the content with the given context
+// ID, latest first.
+func (rs *RetrievalStats) Records(ctx context.Context, contextID []byte) ([]RetrievalRecord, error) {
+	prefix := retrievalRecordsKeyPrefix + base64.RawURLEncoding.EncodeToString(contextID) + "/"
+	results, err := rs.ds.Query(ctx, query.Query{Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var records []RetrievalRecord
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var record RetrievalRecord
+		if err := json.Unmarshal(r.Value, &record); err != nil {
+			return nil, fmt.Errorf("failed to decode retrieval record %s: %w", r.Key, err)
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Started.After(records[j].Started) })
+	return records, nil
+}
+
+func putJSON(ctx context.Context, w datastore.Write, key datastore.Key, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return w.Put(ctx, key, b)
+}
+
+func toRetrievalCountersKey(contextID []byte) datastore.Key {
+	return datastore.NewKey(retrievalCountersKeyPrefix + base64.RawURLEncoding.EncodeToString(contextID))
+}
+
+func toRetrievalRecordKey(contextID []byte, index uint64) datastore.Key {
+	return datastore.NewKey(retrievalRecordsKeyPrefix + base64.RawURLEncoding.EncodeToString(contextID) + "/" + strconv.FormatUint(index, 10))
+}
@@ -0,0 +1,127 @@
+package cardatatransfer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/index-provider/cardatatransfer"
+	"github.com/filecoin-project/index-provider/testutil"
+)
+
+func TestCarDataTransfer_RetrievalStats(t *testing.T) {
+	ctx := context.Background()
+	contextID := []byte("cheese")
+	rdOnlyBS := testutil.OpenSampleCar(t, "sample-v1-2.car")
+	roots, err := rdOnlyBS.Roots()
+	require.NoError(t, err)
+	require.Len(t, roots, 1)
+	pieceCID := pieceCIDFromContextID(t, contextID)
+	proposal := func(id cardatatransfer.DealID) *cardatatransfer.DealProposal {
+		return &cardatatransfer.DealProposal{
+			PayloadCID: roots[0],
+			ID:         id,
+			Params:     cardatatransfer.Params{PieceCID: &pieceCID},
+		}
+	}
+
+	stats := cardatatransfer.NewRetrievalStats(dssync.MutexWrap(datastore.NewMapDatastore()))
+	_, err = stats.Get(ctx, contextID)
+	require.Equal(t, cardatatransfer.ErrNoRetrievals, err)
+
+	rt := newRetrievalTest(t, contextID, rdOnlyBS, func(peer.ID) cardatatransfer.RetrievalPolicy {
+		return cardatatransfer.RetrievalPolicy{}
+	}, cardatatransfer.WithRetrievalStats(stats))
+	require.True(t, (<-rt.retrieve(proposal(1), roots[0])).success)
+	// Rejected retrievals are not recorded.
+	rejected := rt.retrieve(&cardatatransfer.DealProposal{PayloadCID: roots[0], ID: 2}, roots[0])
+	require.False(t, (<-rejected).success)
+	require.True(t, (<-rt.retrieve(proposal(3), roots[0])).success)
+
+	// Transfers are recorded once the provider observes their end.
+	var counters *cardatatransfer.RetrievalCounters
+	require.Eventually(t, func() bool {
+		counters, err = stats.Get(ctx, contextID)
+		return err == nil && counters.Transfers() == 2
+	}, 5*time.Second, 100*time.Millisecond)
+	size := uint64(getBstoreSize(ctx, t, rdOnlyBS))
+	require.Equal(t, contextID, counters.ContextID)
+	require.Equal(t, uint64(2), counters.Completed)
+	require.Zero(t, counters.Failed)
+	require.Zero(t, counters.Cancelled)
+	require.GreaterOrEqual(t, counters.BytesSent, 2*size)
+	require.False(t, counters.LastRetrieved.IsZero())
+
+	list, err := stats.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []cardatatransfer.RetrievalCounters{*counters}, list)
+
+	records, err := stats.Records(ctx, contextID)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.False(t, records[0].Started.Before(records[1].Started))
+	for _, r := range records {
+		require.Equal(t, rt.clientID, r.Peer)
+		require.Equal(t, contextID, r.ContextID)
+		require.Equal(t, cardatatransfer.RetrievalCompleted, r.Outcome)
+		require.GreaterOrEqual(t, r.BytesSent, size)
+	}
+}
+
+func TestRetrievalStats_Record(t *testing.T) {
+	ctx := context.Background()
+	stats := cardatatransfer.NewRetrievalStats(dssync.MutexWrap(datastore.NewMapDatastore()))
+	client := test.RandPeerIDFatal(t)
+	started := time.Now().UTC().Truncate(time.Second)
+	record := func(contextID string, outcome cardatatransfer.RetrievalOutcome, i int) {
+		require.NoError(t, stats.Record(ctx, &cardatatransfer.RetrievalRecord{
+			Peer:      client,
+			ContextID: []byte(contextID),
+			BytesSent: 10,
+			Started:   started.Add(time.Duration(i) * time.Second),
+			Duration:  time.Second,
+			Outcome:   outcome,
+		}))
+	}
+	for i := 0; i < 150; i++ {
+		record("lobster", cardatatransfer.RetrievalCompleted, i)
+	}
+	record("barreleye", cardatatransfer.RetrievalFailed, 0)
+	record("barreleye", cardatatransfer.RetrievalCancelled, 1)
+
+	lobster, err := stats.Get(ctx, []byte("lobster"))
+	require.NoError(t, err)
+	require.Equal(t, &cardatatransfer.RetrievalCounters{
+		ContextID:     []byte("lobster"),
+		Completed:     150,
+		BytesSent:     1500,
+		Duration:      150 * time.Second,
+		LastRetrieved: started.Add(150 * time.Second),
+	}, lobster)
+
+	list, err := stats.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, []byte("lobster"), list[0].ContextID)
+	require.Equal(t, []byte("barreleye"), list[1].ContextID)
+	require.Equal(t, uint64(1), list[1].Failed)
+	require.Equal(t, uint64(1), list[1].Cancelled)
+
+	// Only the most recent records are kept.
+	records, err := stats.Records(ctx, []byte("lobster"))
+	require.NoError(t, err)
+	require.Len(t, records, 100)
+	require.Equal(t, started.Add(149*time.Second), records[0].Started)
+	require.Equal(t, started.Add(50*time.Second), records[99].Started)
+	require.Equal(t, client, records[0].Peer)
+
+	records, err = stats.Records(ctx, []byte("undadasea"))
+	require.NoError(t, err)
+	require.Empty(t, records)
+}
@@ -11,7 +11,14 @@ import (
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 )
 
-var ErrNotFound = errors.New("not found")
+var (
+	ErrNotFound = errors.New("not found")
+	// ErrLimitReached signals that a blockstore cannot be tracked since the maximum number of open
+	// blockstores are already tracked.
+	ErrLimitReached = errors.New("limit of open blockstores reached")
+	// ErrAlreadyTracked signals that a blockstore is already tracked under the same key.
+	ErrAlreadyTracked = errors.New("blockstore already tracked")
+)
 
 func IsNotFound(err error) bool {
 	return errors.Is(err, ErrNotFound)
@@ -21,24 +28,52 @@ func IsNotFound(err error) bool {
 type ReadOnlyBlockstores struct {
 	mu     sync.RWMutex
 	stores map[string]bstore.Blockstore
+	limit  int
 }
 
 func NewReadOnlyBlockstores() *ReadOnlyBlockstores {
+	return NewReadOnlyBlockstoresWithLimit(0)
+}
+
+// NewReadOnlyBlockstoresWithLimit instantiates ReadOnlyBlockstores that tracks at most the given
+// number of open blockstores. A limit of zero or less means no limit.
+func NewReadOnlyBlockstoresWithLimit(limit int) *ReadOnlyBlockstores {
 	return &ReadOnlyBlockstores{
 		stores: make(map[string]bstore.Blockstore),
+		limit:  limit,
 	}
 }
 
+// Track tracks the given blockstore under the given key, and returns false if it cannot be
+// tracked.
+// See: TryTrack.
 func (r *ReadOnlyBlockstores) Track(key string, bs bstore.Blockstore) bool {
+	return r.TryTrack(key, bs) == nil
+}
+
+// TryTrack tracks the given blockstore under the given key. ErrAlreadyTracked is returned if a
+// blockstore is already tracked under the key, and ErrLimitReached if the limit of open
+// blockstores is reached.
+func (r *ReadOnlyBlockstores) TryTrack(key string, bs bstore.Blockstore) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	if _, ok := r.stores[key]; ok {
-		return false
+		return ErrAlreadyTracked
+	}
+	if r.limit > 0 && len(r.stores) >= r.limit {
+		return ErrLimitReached
 	}
 
 	r.stores[key] = bs
-	return true
+	return nil
+}
+
+// Full checks whether the limit of open blockstores is reached.
+func (r *ReadOnlyBlockstores) Full() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.limit > 0 && len(r.stores) >= r.limit
 }
 
 func (r *ReadOnlyBlockstores) Get(key string) (bstore.Blockstore, error) {
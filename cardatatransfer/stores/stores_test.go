@@ -54,3 +54,23 @@ func TestReadOnlyStoreTracker(t *testing.T) {
 	_, err = tracker.Get(k2)
 	require.True(t, stores.IsNotFound(err))
 }
+
+func TestReadOnlyStoreTracker_Limit(t *testing.T) {
+	rdOnlyBS1 := testutil.OpenSampleCar(t, "sample-v1.car")
+	rdOnlyBS2 := testutil.OpenSampleCar(t, "sample-wrapped-v2-2.car")
+
+	tracker := stores.NewReadOnlyBlockstoresWithLimit(1)
+	require.False(t, tracker.Full())
+	require.NoError(t, tracker.TryTrack("k1", rdOnlyBS1))
+	require.True(t, tracker.Full())
+
+	// Tracking the same key again fails regardless of the limit.
+	require.ErrorIs(t, tracker.TryTrack("k1", rdOnlyBS1), stores.ErrAlreadyTracked)
+
+	// Tracking beyond the limit fails until a blockstore is untracked.
+	require.ErrorIs(t, tracker.TryTrack("k2", rdOnlyBS2), stores.ErrLimitReached)
+	require.False(t, tracker.Track("k2", rdOnlyBS2))
+	require.NoError(t, tracker.Untrack("k1"))
+	require.False(t, tracker.Full())
+	require.True(t, tracker.Track("k2", rdOnlyBS2))
+}
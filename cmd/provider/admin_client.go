@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/filecoin-project/index-provider/cmd/provider/internal/config"
+	adminclient "github.com/filecoin-project/index-provider/server/admin/http/client"
+)
+
+// newAdminClient instantiates the client with which to interact with the admin server at the
+// address set via flags. The admin token flag takes precedence over the read-write token in the
+// local provider config. The TLS settings are read from the local provider config; the config is
+// optional so that the CLI can interact with remote admin servers.
+func newAdminClient() (*adminclient.RPCClient, error) {
+	token := adminTokenFlagValue
+	httpClient := &http.Client{}
+	cfg, err := config.Load("")
+	if err != nil {
+		if !errors.Is(err, config.ErrNotInitialized) {
+			return nil, fmt.Errorf("cannot load config file: %w", err)
+		}
+	} else {
+		if token == "" {
+			token = cfg.AdminServer.ReadWriteToken
+		}
+		tlsConfig, err := cfg.AdminServer.ClientTLSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("cannot load admin server TLS config: %w", err)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		httpClient.Transport = transport
+	}
+	return adminclient.NewRPC(adminAPIFlagValue, adminclient.WithHttpClient(httpClient), adminclient.WithBearerToken(token)), nil
+}
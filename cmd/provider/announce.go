@@ -1,8 +1,6 @@
 package main
 
 import (
-	"net/http"
-
 	"github.com/urfave/cli/v2"
 )
 
@@ -14,21 +12,13 @@ var AnnounceCmd = &cli.Command{
 }
 
 func announceCommand(cctx *cli.Context) error {
-	req, err := http.NewRequestWithContext(cctx.Context, http.MethodPost, adminAPIFlagValue+"/admin/announce", nil)
+	client, err := newAdminClient()
 	if err != nil {
 		return err
 	}
-
-	cl := &http.Client{}
-	resp, err := cl.Do(req)
-	if err != nil {
+	if err := client.Announce(cctx.Context); err != nil {
 		return err
 	}
-	// Handle failed requests
-	if resp.StatusCode != http.StatusOK {
-		return errFromHttpResp(resp)
-	}
-
 	_, err = cctx.App.Writer.Write([]byte("Announced latest advertisement\n"))
 	return err
 }
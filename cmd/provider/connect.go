@@ -1,10 +1,6 @@
 package main
 
 import (
-	"fmt"
-	"net/http"
-
-	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
 	"github.com/urfave/cli/v2"
 )
 
@@ -16,22 +12,15 @@ var ConnectCmd = &cli.Command{
 }
 
 func connectCommand(cctx *cli.Context) error {
-	iaddr := cctx.String("indexermaddr")
-	req := &adminserver.ConnectReq{Maddr: iaddr}
-	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/connect", req)
+	client, err := newAdminClient()
 	if err != nil {
 		return err
 	}
-	// Handle failed requests
-	if resp.StatusCode != http.StatusOK {
-		return errFromHttpResp(resp)
+	if err := client.Connect(cctx.Context, cctx.String("indexermaddr")); err != nil {
+		return err
 	}
 
 	log.Infof("connected to peer successfully")
-	var res adminserver.ConnectRes
-	if _, err := res.ReadFrom(resp.Body); err != nil {
-		return fmt.Errorf("received OK response from server but cannot decode response body: %w", err)
-	}
 	_, err = cctx.App.Writer.Write([]byte("Connected to peer successfully"))
 	return err
 }
@@ -13,7 +13,10 @@ import (
 	"github.com/filecoin-project/index-provider/cardatatransfer"
 	"github.com/filecoin-project/index-provider/cmd/provider/internal/config"
 	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
 	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	bitswapserver "github.com/filecoin-project/index-provider/server/retrieval/bitswap"
+	retrievalserver "github.com/filecoin-project/index-provider/server/retrieval/http"
 	"github.com/filecoin-project/index-provider/supplier"
 	leveldb "github.com/ipfs/go-ds-leveldb"
 	gsimpl "github.com/ipfs/go-graphsync/impl"
@@ -116,14 +119,28 @@ func daemonCommand(cctx *cli.Context) error {
 	}
 
 	// Starting provider core
-	eng, err := engine.New(
+	engOpts := []engine.Option{
 		engine.WithDatastore(ds),
 		engine.WithDataTransfer(dt),
 		engine.WithHost(h),
 		engine.WithEntriesCacheCapacity(cfg.Ingest.LinkCacheSize),
 		engine.WithEntriesChunkSize(cfg.Ingest.LinkedChunkSize),
 		engine.WithTopicName(cfg.Ingest.PubSubTopic),
-		engine.WithPublisherKind(engine.PublisherKind(cfg.Ingest.PublisherKind)))
+		engine.WithPublisherKind(engine.PublisherKind(cfg.Ingest.PublisherKind)),
+		engine.WithReverseIndex(cfg.Ingest.ReverseIndex),
+	}
+	if cfg.HttpRetrieval.ListenMultiaddr != "" {
+		httpRetrievalAddrs, err := cfg.HttpRetrieval.AnnounceAddrs()
+		if err != nil {
+			return fmt.Errorf("bad http retrieval address in config: %s", err)
+		}
+		engOpts = append(engOpts, engine.WithHttpRetrievalAddrs(httpRetrievalAddrs...))
+	}
+	if cfg.Events.WebhookURL != "" {
+		engOpts = append(engOpts, engine.WithEventWebhook(cfg.Events.WebhookURL,
+			cfg.Events.WebhookMaxRetries, time.Duration(cfg.Events.WebhookRetryInterval)))
+	}
+	eng, err := engine.New(engOpts...)
 	if err != nil {
 		return err
 	}
@@ -134,39 +151,145 @@ func daemonCommand(cctx *cli.Context) error {
 	}
 
 	// Instantiate CAR supplier and register it as the multihash lister onto the engine.
-	cs := supplier.NewCarSupplier(eng, ds, car.ZeroLengthSectionAsEOF(carZeroLengthAsEOFFlagValue))
+	csOpts := []supplier.Option{supplier.WithCarReadOptions(car.ZeroLengthSectionAsEOF(carZeroLengthAsEOFFlagValue))}
+	if cfg.CarSupplier.IndexCacheDir != "" {
+		indexCacheDir, err := config.Path("", cfg.CarSupplier.IndexCacheDir)
+		if err != nil {
+			return err
+		}
+		csOpts = append(csOpts, supplier.WithIndexCacheDir(indexCacheDir))
+	}
+	if cfg.CarSupplier.S3Endpoint != "" {
+		csOpts = append(csOpts, supplier.WithS3Endpoint(cfg.CarSupplier.S3Endpoint))
+	}
+	if cfg.CarSupplier.ValidateBlockHashes {
+		csOpts = append(csOpts, supplier.WithBlockHashValidation(true))
+	}
+	cs := supplier.NewCarSupplierWithOptions(eng, ds, csOpts...)
 
-	// Start serving CAR files for retrieval requests
-	err = cardatatransfer.StartCarDataTransfer(dt, cs)
+	// Start serving CAR files for retrieval requests, recording the transfers in the datastore.
+	retrievalStats := cardatatransfer.NewRetrievalStats(ds)
+	allowPeers, err := cfg.GraphsyncRetrieval.AllowPeerIDs()
+	if err != nil {
+		return fmt.Errorf("bad allowed peer ID in GraphsyncRetrieval config: %w", err)
+	}
+	denyPeers, err := cfg.GraphsyncRetrieval.DenyPeerIDs()
+	if err != nil {
+		return fmt.Errorf("bad denied peer ID in GraphsyncRetrieval config: %w", err)
+	}
+	err = cardatatransfer.StartCarDataTransfer(dt, cs, cardatatransfer.WithRetrievalPolicy(cardatatransfer.RetrievalPolicy{
+		AllowPeers:                    allowPeers,
+		DenyPeers:                     denyPeers,
+		MaxTransfersPerPeer:           cfg.GraphsyncRetrieval.MaxTransfersPerPeer,
+		MaxBytesPerSecondPerContextID: cfg.GraphsyncRetrieval.MaxBytesPerSecondPerContextID,
+		MaxOpenBlockstores:            cfg.GraphsyncRetrieval.MaxOpenBlockstores,
+	}), cardatatransfer.WithSelectorComplexityBudget(cfg.GraphsyncRetrieval.MaxSelectorComplexity),
+		cardatatransfer.WithRetrievalStats(retrievalStats))
 	if err != nil {
 		return err
 	}
 
+	// Start serving CAR files over bitswap, if enabled.
+	var bitswapSvr *bitswapserver.Server
+	if cfg.BitswapServer.Enabled {
+		if !cfg.Ingest.ReverseIndex {
+			return errors.New("bitswap server requires reverse index; set Ingest.ReverseIndex in config to enable it")
+		}
+		bitswapSvr, err = bitswapserver.New(h, eng, cs,
+			bitswapserver.WithMaxOpenBlockstores(cfg.BitswapServer.MaxOpenBlockstores))
+		if err != nil {
+			return err
+		}
+		if err = bitswapSvr.Start(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Start serving CAR files over HTTP, if configured.
+	var retrievalSvr *retrievalserver.Server
+	if cfg.HttpRetrieval.ListenMultiaddr != "" {
+		if !cfg.Ingest.ReverseIndex {
+			return errors.New("http retrieval server requires reverse index; set Ingest.ReverseIndex in config to enable it")
+		}
+		retrievalAddr, err := cfg.HttpRetrieval.ListenNetAddr()
+		if err != nil {
+			return err
+		}
+		retrievalSvr, err = retrievalserver.New(eng, cs,
+			retrievalserver.WithListenAddr(retrievalAddr),
+			retrievalserver.WithReadTimeout(time.Duration(cfg.HttpRetrieval.ReadTimeout)),
+			retrievalserver.WithWriteTimeout(time.Duration(cfg.HttpRetrieval.WriteTimeout)))
+		if err != nil {
+			return err
+		}
+		log.Infow("http retrieval server initialized", "address", cfg.HttpRetrieval.ListenMultiaddr)
+	}
+
+	// Watch directories for CAR files to import and remove automatically, if configured.
+	var dirSupplier *supplier.DirectorySupplier
+	if len(cfg.DirectorySupplier.Dirs) != 0 {
+		dirSupplier, err = supplier.NewDirectorySupplier(cs, ds, cfg.DirectorySupplier.Dirs,
+			supplier.WithPollInterval(time.Duration(cfg.DirectorySupplier.PollInterval)),
+			supplier.WithMetadataFunc(func(contextID []byte) (metadata.Metadata, error) {
+				tp, err := cardatatransfer.TransportFromContextID(contextID)
+				if err != nil {
+					return metadata.Metadata{}, err
+				}
+				return metadata.New(tp), nil
+			}))
+		if err != nil {
+			return err
+		}
+		dirSupplier.Start(ctx)
+		log.Infow("watching directories for CAR files", "dirs", cfg.DirectorySupplier.Dirs)
+	}
+
 	// TODO: unclear why the admin config takes multiaddr if it is always converted to net addr; simplify.
 	addr, err := cfg.AdminServer.ListenNetAddr()
 	if err != nil {
 		return err
 	}
 
-	adminSvr, err := adminserver.New(
-		h,
-		eng,
-		cs,
+	adminOpts := []adminserver.Option{
 		adminserver.WithListenAddr(addr),
 		adminserver.WithReadTimeout(time.Duration(cfg.AdminServer.ReadTimeout)),
 		adminserver.WithWriteTimeout(time.Duration(cfg.AdminServer.WriteTimeout)),
-	)
+		adminserver.WithRetrievalStats(retrievalStats),
+	}
+	if cfg.AdminServer.ReadWriteToken != "" {
+		adminOpts = append(adminOpts, adminserver.WithBearerToken(cfg.AdminServer.ReadWriteToken, adminserver.ScopeReadWrite))
+	}
+	if cfg.AdminServer.ReadOnlyToken != "" {
+		adminOpts = append(adminOpts, adminserver.WithBearerToken(cfg.AdminServer.ReadOnlyToken, adminserver.ScopeReadOnly))
+	}
+	if cfg.AdminServer.ReadWriteToken == "" && cfg.AdminServer.ReadOnlyToken == "" {
+		log.Warn("Admin server authentication is disabled; set AdminServer.ReadWriteToken in config to enable it.")
+	}
+	adminTLSConfig, err := cfg.AdminServer.ServerTLSConfig()
+	if err != nil {
+		return err
+	}
+	if adminTLSConfig != nil {
+		adminOpts = append(adminOpts, adminserver.WithTLSConfig(adminTLSConfig))
+	}
+
+	adminSvr, err := adminserver.New(h, eng, cs, adminOpts...)
 
 	if err != nil {
 		return err
 	}
 	log.Infow("admin server initialized", "address", cfg.AdminServer.ListenMultiaddr)
 
-	errChan := make(chan error, 1)
+	errChan := make(chan error, 2)
 	fmt.Fprintf(cctx.App.ErrWriter, "Starting admin server on %s ...", cfg.AdminServer.ListenMultiaddr)
 	go func() {
 		errChan <- adminSvr.Start()
 	}()
+	if retrievalSvr != nil {
+		go func() {
+			errChan <- retrievalSvr.Start()
+		}()
+	}
 
 	// If there are bootstrap peers and bootstrapping is enabled, then try to
 	// connect to the minimum set of peers.
@@ -208,6 +331,33 @@ func daemonCommand(cctx *cli.Context) error {
 		}
 	}()
 
+	// Shut down the admin server first, so that running jobs stop before the engine does.
+	if err = adminSvr.Shutdown(shutdownCtx); err != nil {
+		log.Errorw("Error shutting down admin server: %s", err)
+		finalErr = ErrDaemonStop
+	}
+
+	if bitswapSvr != nil {
+		if err = bitswapSvr.Shutdown(); err != nil {
+			log.Errorw("Error shutting down bitswap server", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
+
+	if retrievalSvr != nil {
+		if err = retrievalSvr.Shutdown(shutdownCtx); err != nil {
+			log.Errorw("Error shutting down http retrieval server", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
+
+	if dirSupplier != nil {
+		if err = dirSupplier.Close(); err != nil {
+			log.Errorf("Error closing directory supplier: %s", err)
+			finalErr = ErrDaemonStop
+		}
+	}
+
 	if err = eng.Shutdown(); err != nil {
 		log.Errorf("Error closing provider core: %s", err)
 		finalErr = ErrDaemonStop
@@ -221,10 +371,6 @@ func daemonCommand(cctx *cli.Context) error {
 	// cancel libp2p server
 	cancelp2p()
 
-	if err = adminSvr.Shutdown(shutdownCtx); err != nil {
-		log.Errorw("Error shutting down admin server: %s", err)
-		finalErr = ErrDaemonStop
-	}
 	log.Infow("node stopped")
 	return finalErr
 }
@@ -47,7 +47,9 @@ over graphsync. It also exposes an administrative HTTP endpoint at "" that
 allows
 
 Additionally, it starts an admin HTTP server at "http://localhost:3102" that enables administrative
-operations using the "provider" CLI tool.
+operations using the "provider" CLI tool. Requests to the admin server are authenticated using the
+bearer tokens generated at initialization and stored in the config. The CLI sends the read-write
+token from the local config automatically; use the "--admin-token" flag to talk to a remote daemon.
 
 To advertise the availability of content by the daemon to indexer nodes, run:
 	provider import car -l http://localhost:3102 -i <path-to-car-file>
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+var DrainCmd = &cli.Command{
+	Name:  "drain",
+	Usage: "Removes all the content advertised by an index-provider daemon, e.g. before decommissioning it.",
+	Description: `Publishes a removal advertisement for every context ID advertised by the daemon, such
+that indexers stop returning the provider for any content. Imported CAR files are forgotten
+once removed, so that the daemon ends up providing no content.
+
+Draining may be interrupted by cancelling its job, and resumed by running drain again.`,
+	Flags:  drainFlags,
+	Before: beforeDrain,
+	Action: doDrain,
+}
+
+func beforeDrain(_ *cli.Context) error {
+	if !drainConfirmFlagValue {
+		return errors.New("drain removes all advertised content; set --yes to confirm")
+	}
+	return nil
+}
+
+func doDrain(cctx *cli.Context) error {
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	job, err := client.Drain(cctx.Context, drainBatchSizeFlagValue, asyncFlagValue)
+	if err != nil {
+		return err
+	}
+	if asyncFlagValue {
+		return printJob(cctx, job)
+	}
+	var removed int
+	if job.Progress != nil {
+		removed = job.Progress.Done
+	}
+	_, err = fmt.Fprintf(cctx.App.Writer, "Drained %d context IDs.\n\t Advertisement ID: %s\n", removed, job.AdvId)
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"fmt"
 
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
 	httpfinderclient "github.com/filecoin-project/storetheindex/api/v0/finder/client/http"
 	"github.com/ipfs/go-cid"
 	"github.com/multiformats/go-multihash"
@@ -12,17 +13,12 @@ import (
 
 var FindCmd = &cli.Command{
 	Name:   "find",
-	Usage:  "Query an indexer for indexed content",
+	Usage:  "Query an indexer, or the local daemon, for indexed content",
 	Flags:  findFlags,
 	Action: findCommand,
 }
 
 func findCommand(cctx *cli.Context) error {
-	cli, err := httpfinderclient.New(cctx.String("indexer"))
-	if err != nil {
-		return err
-	}
-
 	mhArgs := cctx.StringSlice("mh")
 	cidArgs := cctx.StringSlice("cid")
 	mhs := make([]multihash.Multihash, 0, len(mhArgs)+len(cidArgs))
@@ -41,7 +37,18 @@ func findCommand(cctx *cli.Context) error {
 		mhs = append(mhs, c.Hash())
 	}
 
-	resp, err := cli.FindBatch(cctx.Context, mhs)
+	if findLocalFlagValue {
+		return findLocal(cctx, mhs)
+	}
+	if !cctx.IsSet("indexer") {
+		return cli.Exit("Required flag \"indexer\" not set", 1)
+	}
+
+	client, err := httpfinderclient.New(cctx.String("indexer"))
+	if err != nil {
+		return err
+	}
+	resp, err := client.FindBatch(cctx.Context, mhs)
 	if err != nil {
 		return err
 	}
@@ -57,9 +64,34 @@ func findCommand(cctx *cli.Context) error {
 		for _, pr := range resp.MultihashResults[i].ProviderResults {
 			fmt.Println("       Provider:", pr.Provider)
 			fmt.Println("       ContextID:", base64.StdEncoding.EncodeToString(pr.ContextID))
-			fmt.Println("       Metadata:", base64.StdEncoding.EncodeToString(pr.Metadata))
+			fmt.Println("       Metadata:", adminserver.MetadataBytes(pr.Metadata))
 		}
 	}
 
 	return nil
 }
+
+// findLocal looks up the context IDs that contain the given multihashes via the admin server of the
+// local daemon.
+func findLocal(cctx *cli.Context, mhs []multihash.Multihash) error {
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Local context IDs:")
+	for _, mh := range mhs {
+		contextIDs, err := client.FindContextIDs(cctx.Context, mh)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("   Multihash:", mh.B58String())
+		if len(contextIDs) == 0 {
+			fmt.Println("       not advertised")
+		}
+		for _, contextID := range contextIDs {
+			fmt.Println("       ContextID:", base64.StdEncoding.EncodeToString(contextID))
+		}
+	}
+	return nil
+}
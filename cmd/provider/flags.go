@@ -6,6 +6,7 @@ import (
 
 var announceFlags = []cli.Flag{
 	adminAPIFlag,
+	adminTokenFlag,
 }
 
 var daemonFlags = []cli.Flag{
@@ -29,6 +30,7 @@ var connectFlags = []cli.Flag{
 		Required: true,
 	},
 	adminAPIFlag,
+	adminTokenFlag,
 }
 
 var indexerFlag = &cli.StringFlag{
@@ -46,7 +48,18 @@ var addrFlag = &cli.StringSliceFlag{
 }
 
 var findFlags = []cli.Flag{
-	indexerFlag,
+	&cli.StringFlag{
+		Name:    "indexer",
+		Usage:   "Host or host:port of indexer to use. Required unless --local is set.",
+		Aliases: []string{"i"},
+	},
+	&cli.BoolFlag{
+		Name:        "local",
+		Usage:       "Whether to look up the context IDs advertised by the local daemon instead of querying an indexer",
+		Destination: &findLocalFlagValue,
+	},
+	adminAPIFlag,
+	adminTokenFlag,
 	&cli.StringSliceFlag{
 		Name:     "mh",
 		Usage:    "Specify multihash to use as indexer key, multiple OK",
@@ -59,6 +72,8 @@ var findFlags = []cli.Flag{
 	},
 }
 
+var findLocalFlagValue bool
+
 var indexFlags = []cli.Flag{
 	indexerFlag,
 	addrFlag,
@@ -87,22 +102,132 @@ var registerFlags = []cli.Flag{
 
 var importCarFlags = []cli.Flag{
 	adminAPIFlag,
+	adminTokenFlag,
 	carPathFlag,
 	metadataFlag,
 	keyFlag,
+	asyncFlag,
 }
 
+var importManifestFlags = []cli.Flag{
+	adminAPIFlag,
+	adminTokenFlag,
+	&cli.StringFlag{
+		Name:        "input",
+		Aliases:     []string{"i"},
+		Usage:       "Path to the manifest file listing the CARs to import",
+		Destination: &manifestPathFlagValue,
+		Required:    true,
+	},
+	&cli.StringFlag{
+		Name:        "format",
+		Aliases:     []string{"f"},
+		Usage:       "The manifest format, one of json or csv. Inferred from the manifest file extension if unset.",
+		Destination: &manifestFormatFlagValue,
+	},
+	&cli.IntFlag{
+		Name:        "concurrency",
+		Aliases:     []string{"c"},
+		Usage:       "The maximum number of CARs to import concurrently",
+		Value:       4,
+		Destination: &importConcurrencyFlagValue,
+	},
+}
+
+var (
+	manifestPathFlagValue      string
+	manifestFormatFlagValue    string
+	importConcurrencyFlagValue int
+)
+
+var rebuildReverseIndexFlags = []cli.Flag{
+	adminAPIFlag,
+	adminTokenFlag,
+	asyncFlag,
+}
+
+var (
+	statsContextIDFlagValue string
+	retrievalStatsFlags     = []cli.Flag{
+		adminAPIFlag,
+		adminTokenFlag,
+		&cli.StringFlag{
+			Name:        "context-id",
+			Usage:       "Base64 encoded context ID of which to show the stats and most recent transfers. All retrieved context IDs are listed if unset.",
+			Aliases:     []string{"k"},
+			Destination: &statsContextIDFlagValue,
+		},
+	}
+)
+
 var removeCarFlags = []cli.Flag{
 	adminAPIFlag,
+	adminTokenFlag,
 	optionalCarPathFlag,
 	keyFlag,
+	asyncFlag,
 }
 
+var drainFlags = []cli.Flag{
+	adminAPIFlag,
+	adminTokenFlag,
+	&cli.IntFlag{
+		Name:        "batch-size",
+		Usage:       "The number of context IDs to read at a time",
+		Value:       1000,
+		Destination: &drainBatchSizeFlagValue,
+	},
+	&cli.BoolFlag{
+		Name:        "yes",
+		Usage:       "Confirm the removal of all advertised content",
+		Destination: &drainConfirmFlagValue,
+	},
+	asyncFlag,
+}
+
+var (
+	drainBatchSizeFlagValue int
+	drainConfirmFlagValue   bool
+)
+
+var updateMetadataFlags = []cli.Flag{
+	adminAPIFlag,
+	adminTokenFlag,
+	optionalCarPathFlag,
+	keyFlag,
+	&cli.StringFlag{
+		Name:        "metadata",
+		Usage:       `Metadata as JSON, e.g. '{"bitswap": {}}', or as base64 encoded metadata bytes. Required unless removing protocols.`,
+		Aliases:     []string{"m"},
+		Destination: &metadataFlagValue,
+	},
+	&cli.BoolFlag{
+		Name:        "merge",
+		Usage:       "Whether to merge the metadata into the currently advertised metadata, replacing only the protocols it specifies",
+		Destination: &updateMetadataMergeFlagValue,
+	},
+	&cli.StringSliceFlag{
+		Name:  "remove",
+		Usage: "Name of a protocol to remove from the currently advertised metadata, e.g. bitswap, multiple OK. Implies merge.",
+	},
+}
+
+var updateMetadataMergeFlagValue bool
+
+var (
+	asyncFlagValue bool
+	asyncFlag      = &cli.BoolFlag{
+		Name:        "async",
+		Usage:       "Whether to run as a job in the background instead of waiting for completion. See jobs command.",
+		Destination: &asyncFlagValue,
+	}
+)
+
 var (
 	metadataFlagValue string
 	metadataFlag      = &cli.StringFlag{
 		Name:        "metadata",
-		Usage:       "Base64 encoded metadata bytes.",
+		Usage:       `Metadata as JSON, e.g. '{"bitswap": {}}', or as base64 encoded metadata bytes.`,
 		Aliases:     []string{"m"},
 		Required:    false,
 		Destination: &metadataFlagValue,
@@ -153,6 +278,16 @@ var (
 	}
 )
 
+var (
+	adminTokenFlagValue string
+	adminTokenFlag      = &cli.StringFlag{
+		Name:        "admin-token",
+		Usage:       "Bearer token with which to authenticate to the admin HTTP API. If unset, the read-write token in the local provider config is used, if any.",
+		EnvVars:     []string{"PROVIDER_ADMIN_TOKEN"},
+		Destination: &adminTokenFlagValue,
+	}
+)
+
 var (
 	carZeroLengthAsEOFFlagValue bool
 	carZeroLengthAsEOFFlag      = &cli.BoolFlag{
@@ -6,7 +6,9 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/filecoin-project/index-provider/cardatatransfer"
 	"github.com/filecoin-project/index-provider/metadata"
@@ -18,7 +20,7 @@ var ImportCmd = &cli.Command{
 	Name:        "import",
 	Aliases:     []string{"i"},
 	Usage:       "Imports sources of multihashes to the index provider.",
-	Subcommands: []*cli.Command{importCarSubCmd},
+	Subcommands: []*cli.Command{importCarSubCmd, importManifestSubCmd},
 }
 
 var (
@@ -31,7 +33,18 @@ var (
 		Before:  beforeImportCar,
 		Action:  doImportCar,
 	}
-	md metadata.Metadata
+	md                   metadata.Metadata
+	importManifestSubCmd = &cli.Command{
+		Name:    "manifest",
+		Aliases: []string{"m"},
+		Usage:   "Imports the CARs listed in a JSON or CSV manifest",
+		Description: "A JSON manifest is an array of objects with path, and optional base64 encoded key and metadata fields.\n" +
+			"Metadata may also be specified as JSON, e.g. {\"bitswap\": {}}.\n" +
+			"A CSV manifest has a header that names the path, and optional key and metadata columns.\n" +
+			"If unset, keys and metadata are generated the same way as the car subcommand.",
+		Flags:  importManifestFlags,
+		Action: doImportManifest,
+	}
 )
 
 func beforeImportCar(cctx *cli.Context) error {
@@ -47,9 +60,9 @@ func beforeImportCar(cctx *cli.Context) error {
 		importCarKey = h.Sum(nil)
 	}
 	if cctx.IsSet(metadataFlag.Name) {
-		decoded, err := base64.StdEncoding.DecodeString(metadataFlagValue)
+		decoded, err := adminserver.ParseMetadata(metadataFlagValue)
 		if err != nil {
-			return errors.New("metadata is not a valid base64 encoded string")
+			return err
 		}
 		err = md.UnmarshalBinary(decoded)
 		if err != nil {
@@ -75,24 +88,23 @@ func doImportCar(cctx *cli.Context) error {
 		return err
 	}
 
-	req := adminserver.ImportCarReq{
-		Path:     carPathFlagValue,
-		Key:      importCarKey,
-		Metadata: mdBytes,
-	}
-	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/import/car", req)
+	client, err := newAdminClient()
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return errFromHttpResp(resp)
+	if asyncFlagValue {
+		job, err := client.ImportCarAsync(cctx.Context, carPathFlagValue, importCarKey, mdBytes)
+		if err != nil {
+			return err
+		}
+		return printJob(cctx, job)
+	}
+	res, err := client.ImportCar(cctx.Context, carPathFlagValue, importCarKey, mdBytes)
+	if err != nil {
+		return err
 	}
 
 	log.Infof("imported car successfully")
-	var res adminserver.ImportCarRes
-	if _, err := res.ReadFrom(resp.Body); err != nil {
-		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
-	}
 	var b bytes.Buffer
 	b.WriteString("Successfully imported CAR.\n")
 	b.WriteString("\t Advertisement ID: ")
@@ -103,3 +115,74 @@ func doImportCar(cctx *cli.Context) error {
 	_, err = cctx.App.Writer.Write(b.Bytes())
 	return err
 }
+
+func doImportManifest(cctx *cli.Context) error {
+	contentType, err := manifestContentType()
+	if err != nil {
+		return err
+	}
+	manifest, err := os.Open(manifestPathFlagValue)
+	if err != nil {
+		return err
+	}
+	defer manifest.Close()
+	rows, err := adminserver.ReadManifest(manifest, contentType)
+	if err != nil {
+		return fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	// Print the result of each row as it is streamed back.
+	var writeErr error
+	res, err := client.ImportManifest(cctx.Context, rows, importConcurrencyFlagValue, func(res *adminserver.ImportManifestRes) {
+		var b bytes.Buffer
+		if res.Error != "" {
+			fmt.Fprintf(&b, "Failed to import row %d: %s\n", res.Row, res.Path)
+			b.WriteString("\t Error: ")
+			b.WriteString(res.Error)
+		} else {
+			fmt.Fprintf(&b, "Imported row %d: %s\n", res.Row, res.Path)
+			b.WriteString("\t Advertisement ID: ")
+			b.WriteString(res.AdvId.String())
+			b.WriteString("\n\t Context ID: ")
+			b.WriteString(base64.StdEncoding.EncodeToString(res.Key))
+		}
+		b.WriteString("\n")
+		if _, err := cctx.App.Writer.Write(b.Bytes()); err != nil && writeErr == nil {
+			writeErr = err
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if _, err := fmt.Fprintf(cctx.App.Writer, "Imported %d of %d CARs.\n", res.Imported, res.Rows); err != nil {
+		return err
+	}
+	if failed := res.Rows - res.Imported; failed != 0 {
+		return fmt.Errorf("failed to import %d CARs", failed)
+	}
+	return nil
+}
+
+// manifestContentType returns the content type of the manifest, inferring it from the manifest
+// file extension if the format is not specified.
+func manifestContentType() (string, error) {
+	format := manifestFormatFlagValue
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(manifestPathFlagValue)), ".")
+	}
+	switch format {
+	case "json":
+		return adminserver.ManifestContentTypeJson, nil
+	case "csv":
+		return adminserver.ManifestContentTypeCsv, nil
+	default:
+		return "", errors.New("manifest format must be one of json or csv")
+	}
+}
@@ -1,12 +1,12 @@
 package main
 
 import (
-	"encoding/base64"
 	"errors"
 	"fmt"
 
 	"github.com/filecoin-project/index-provider/cmd/provider/internal/config"
 	"github.com/filecoin-project/index-provider/metadata"
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
 	httpc "github.com/filecoin-project/storetheindex/api/v0/ingest/client/http"
 	"github.com/ipfs/go-cid"
 	"github.com/multiformats/go-multihash"
@@ -61,9 +61,9 @@ func indexCommand(cctx *cli.Context) error {
 		return err
 	}
 
-	decoded, err := base64.StdEncoding.DecodeString(metadataFlagValue)
+	decoded, err := adminserver.ParseMetadata(metadataFlagValue)
 	if err != nil {
-		return errors.New("metadata is not a valid base64 encoded string")
+		return err
 	}
 	md = metadata.New()
 	err = md.UnmarshalBinary(decoded)
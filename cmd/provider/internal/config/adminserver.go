@@ -1,6 +1,13 @@
 package config
 
 import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/multiformats/go-multiaddr"
@@ -18,6 +25,24 @@ type AdminServer struct {
 	ListenMultiaddr string
 	ReadTimeout     Duration
 	WriteTimeout    Duration
+	// ReadWriteToken is the bearer token that grants access to all admin API endpoints.
+	// Authentication is disabled if both ReadWriteToken and ReadOnlyToken are empty.
+	ReadWriteToken string
+	// ReadOnlyToken is the bearer token that grants access to admin API endpoints that do not
+	// change the state of the provider.
+	ReadOnlyToken string
+	// TLSCertFile and TLSKeyFile are the paths to the PEM encoded certificate and private key with
+	// which the admin API is served over HTTPS, relative to the config root unless absolute.
+	// The admin API is served over plain HTTP if unset.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile is the path to the PEM encoded certificates of the CAs that sign client
+	// certificates. Clients must present a certificate signed by one of the CAs if set.
+	TLSClientCAFile string
+	// TLSClientCertFile and TLSClientKeyFile are the paths to the PEM encoded certificate and
+	// private key that the CLI presents to the admin API when TLSClientCAFile is set.
+	TLSClientCertFile string
+	TLSClientKeyFile  string
 }
 
 // NewAdminServer instantiates a new AdminServer config with default values.
@@ -29,6 +54,111 @@ func NewAdminServer() AdminServer {
 	}
 }
 
+// NewAdminServerWithTokens instantiates a new AdminServer config with default values and freshly
+// generated bearer tokens.
+func NewAdminServerWithTokens() (AdminServer, error) {
+	as := NewAdminServer()
+	var err error
+	if as.ReadWriteToken, err = newToken(); err != nil {
+		return AdminServer{}, err
+	}
+	if as.ReadOnlyToken, err = newToken(); err != nil {
+		return AdminServer{}, err
+	}
+	return as, nil
+}
+
+func newToken() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
+
+// ServerTLSConfig loads the TLS config with which the admin API is served, or nil if TLS is not
+// configured.
+func (as *AdminServer) ServerTLSConfig() (*tls.Config, error) {
+	if as.TLSCertFile == "" && as.TLSKeyFile == "" {
+		if as.TLSClientCAFile != "" {
+			return nil, errors.New("TLSClientCAFile requires TLSCertFile and TLSKeyFile to be set")
+		}
+		return nil, nil
+	}
+	cert, err := loadKeyPair(as.TLSCertFile, as.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if as.TLSClientCAFile != "" {
+		if c.ClientCAs, err = loadCertPool(as.TLSClientCAFile, false); err != nil {
+			return nil, err
+		}
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return c, nil
+}
+
+// ClientTLSConfig loads the TLS config with which the CLI connects to the admin API. The
+// certificate of the admin API is trusted in addition to the system certificates.
+func (as *AdminServer) ClientTLSConfig() (*tls.Config, error) {
+	c := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	var err error
+	if as.TLSCertFile != "" {
+		if c.RootCAs, err = loadCertPool(as.TLSCertFile, true); err != nil {
+			return nil, err
+		}
+	}
+	if as.TLSClientCertFile != "" || as.TLSClientKeyFile != "" {
+		cert, err := loadKeyPair(as.TLSClientCertFile, as.TLSClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
+
+func loadKeyPair(certFile, keyFile string) (tls.Certificate, error) {
+	certPath, err := Path("", certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPath, err := Path("", keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.LoadX509KeyPair(certPath, keyPath)
+}
+
+// loadCertPool loads the PEM encoded certificates in the given file into a pool, optionally
+// starting from the system certificates.
+func loadCertPool(file string, withSystem bool) (*x509.CertPool, error) {
+	path, err := Path("", file)
+	if err != nil {
+		return nil, err
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if withSystem {
+		if sysPool, err := x509.SystemCertPool(); err == nil {
+			pool = sysPool
+		}
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM encoded certificates found in %s", path)
+	}
+	return pool, nil
+}
+
 func (as *AdminServer) ListenNetAddr() (string, error) {
 	maddr, err := multiaddr.NewMultiaddr(as.ListenMultiaddr)
 	if err != nil {
@@ -0,0 +1,28 @@
+package config
+
+const defaultBitswapServerMaxOpenBlockstores = 64
+
+// BitswapServer configures the bitswap server that serves the blocks of imported CAR files over
+// the libp2p host of the provider.
+type BitswapServer struct {
+	// Enabled tells whether to serve blocks over bitswap. Serving blocks requires Ingest.ReverseIndex
+	// to be enabled, since finding the CAR file that contains a block otherwise requires listing the
+	// multihashes of all imported CAR files.
+	Enabled bool
+	// MaxOpenBlockstores is the maximum number of CAR files kept open to serve subsequent requests.
+	MaxOpenBlockstores int
+}
+
+// NewBitswapServer instantiates a new BitswapServer config with default values.
+func NewBitswapServer() BitswapServer {
+	return BitswapServer{
+		MaxOpenBlockstores: defaultBitswapServerMaxOpenBlockstores,
+	}
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *BitswapServer) PopulateDefaults() {
+	if c.MaxOpenBlockstores == 0 {
+		c.MaxOpenBlockstores = defaultBitswapServerMaxOpenBlockstores
+	}
+}
@@ -0,0 +1,15 @@
+package config
+
+// CarSupplier configures the supplier of multihashes from CAR files.
+type CarSupplier struct {
+	// IndexCacheDir is the directory in which the indexes generated for CAR files are persisted,
+	// relative to the config root unless absolute. Generated indexes are not persisted if unset.
+	IndexCacheDir string
+	// S3Endpoint is the endpoint of an S3-compatible object storage at which CAR files with
+	// s3://<bucket>/<key> paths are read. Such CAR files are read from AWS S3 if unset. Requests
+	// are not signed, so only publicly readable objects can be read.
+	S3Endpoint string
+	// ValidateBlockHashes specifies whether to check the hash of every block in a CAR file before
+	// it is advertised. Only the first block is checked if unset.
+	ValidateBlockHashes bool
+}
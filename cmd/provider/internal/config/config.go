@@ -11,12 +11,18 @@ import (
 
 // Config is used to load config files.
 type Config struct {
-	Identity       Identity
-	Datastore      Datastore
-	Ingest         Ingest
-	ProviderServer ProviderServer
-	AdminServer    AdminServer
-	Bootstrap      Bootstrap
+	Identity           Identity
+	Datastore          Datastore
+	Ingest             Ingest
+	ProviderServer     ProviderServer
+	AdminServer        AdminServer
+	Bootstrap          Bootstrap
+	CarSupplier        CarSupplier
+	DirectorySupplier  DirectorySupplier
+	Events             Events
+	HttpRetrieval      HttpRetrieval
+	BitswapServer      BitswapServer
+	GraphsyncRetrieval GraphsyncRetrieval
 }
 
 const (
@@ -146,4 +152,9 @@ func (c *Config) PopulateDefaults() {
 	c.Datastore.PopulateDefaults()
 	c.Ingest.PopulateDefaults()
 	c.ProviderServer.PopulateDefaults()
+	c.DirectorySupplier.PopulateDefaults()
+	c.Events.PopulateDefaults()
+	c.HttpRetrieval.PopulateDefaults()
+	c.BitswapServer.PopulateDefaults()
+	c.GraphsyncRetrieval.PopulateDefaults()
 }
@@ -0,0 +1,29 @@
+package config
+
+import "time"
+
+const defaultDirectorySupplierPollInterval = Duration(10 * time.Second)
+
+// DirectorySupplier configures the watching of directories for CAR files that are automatically
+// imported when added and removed when deleted.
+type DirectorySupplier struct {
+	// Dirs are the directories to watch for CAR files. Watching is disabled if no directory is
+	// specified.
+	Dirs []string
+	// PollInterval is the interval at which the directories are scanned for changes.
+	PollInterval Duration
+}
+
+// NewDirectorySupplier instantiates a new DirectorySupplier config with default values.
+func NewDirectorySupplier() DirectorySupplier {
+	return DirectorySupplier{
+		PollInterval: defaultDirectorySupplierPollInterval,
+	}
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *DirectorySupplier) PopulateDefaults() {
+	if c.PollInterval == 0 {
+		c.PollInterval = defaultDirectorySupplierPollInterval
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import "time"
+
+const (
+	defaultEventsWebhookMaxRetries    = 3
+	defaultEventsWebhookRetryInterval = Duration(time.Second)
+)
+
+// Events configures the delivery of the events emitted by the provider engine, e.g. published and
+// announced advertisements.
+type Events struct {
+	// WebhookURL is the URL to which events are delivered as HTTP POST requests with JSON body.
+	// Delivery to webhook is disabled if no URL is specified.
+	WebhookURL string
+	// WebhookMaxRetries is the maximum number of times the delivery of an event is retried before
+	// the event is dropped.
+	WebhookMaxRetries int
+	// WebhookRetryInterval is the wait before the first retry of a failed delivery. The wait is
+	// doubled after each retry.
+	WebhookRetryInterval Duration
+}
+
+// NewEvents instantiates a new Events config with default values.
+func NewEvents() Events {
+	return Events{
+		WebhookMaxRetries:    defaultEventsWebhookMaxRetries,
+		WebhookRetryInterval: defaultEventsWebhookRetryInterval,
+	}
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *Events) PopulateDefaults() {
+	if c.WebhookRetryInterval == 0 {
+		c.WebhookRetryInterval = defaultEventsWebhookRetryInterval
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import "github.com/libp2p/go-libp2p-core/peer"
+
+const (
+	defaultGraphsyncRetrievalMaxOpenBlockstores    = 256
+	defaultGraphsyncRetrievalMaxSelectorComplexity = 1024
+)
+
+// GraphsyncRetrieval configures the policy under which the content of imported CAR files is
+// retrieved over graphsync.
+type GraphsyncRetrieval struct {
+	// AllowPeers lists the IDs of the only peers allowed to retrieve. All peers that are not denied
+	// are allowed if empty.
+	AllowPeers []string
+	// DenyPeers lists the IDs of the peers that are not allowed to retrieve, which takes precedence
+	// over AllowPeers.
+	DenyPeers []string
+	// MaxTransfersPerPeer is the maximum number of transfers served concurrently to the same peer.
+	// Zero means no limit.
+	MaxTransfersPerPeer int
+	// MaxBytesPerSecondPerContextID is the maximum rate in bytes per second at which the content of
+	// the same context ID is sent, across all the transfers that retrieve it. Zero means no limit.
+	MaxBytesPerSecondPerContextID uint64
+	// MaxOpenBlockstores is the maximum number of CAR files open concurrently to serve transfers.
+	MaxOpenBlockstores int
+	// MaxSelectorComplexity is the maximum complexity of the selectors with which content is
+	// retrieved, measured as the number of nodes that make up a selector plus a constant cost for
+	// each range it explores.
+	MaxSelectorComplexity int
+}
+
+// NewGraphsyncRetrieval instantiates a new GraphsyncRetrieval config with default values.
+func NewGraphsyncRetrieval() GraphsyncRetrieval {
+	return GraphsyncRetrieval{
+		MaxOpenBlockstores:    defaultGraphsyncRetrievalMaxOpenBlockstores,
+		MaxSelectorComplexity: defaultGraphsyncRetrievalMaxSelectorComplexity,
+	}
+}
+
+// AllowPeerIDs returns the decoded IDs of the peers allowed to retrieve.
+func (c *GraphsyncRetrieval) AllowPeerIDs() ([]peer.ID, error) {
+	return decodePeerIDs(c.AllowPeers)
+}
+
+// DenyPeerIDs returns the decoded IDs of the peers denied from retrieving.
+func (c *GraphsyncRetrieval) DenyPeerIDs() ([]peer.ID, error) {
+	return decodePeerIDs(c.DenyPeers)
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *GraphsyncRetrieval) PopulateDefaults() {
+	if c.MaxOpenBlockstores == 0 {
+		c.MaxOpenBlockstores = defaultGraphsyncRetrievalMaxOpenBlockstores
+	}
+	if c.MaxSelectorComplexity == 0 {
+		c.MaxSelectorComplexity = defaultGraphsyncRetrievalMaxSelectorComplexity
+	}
+}
+
+func decodePeerIDs(ids []string) ([]peer.ID, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	decoded := make([]peer.ID, 0, len(ids))
+	for _, id := range ids {
+		pid, err := peer.Decode(id)
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, pid)
+	}
+	return decoded, nil
+}
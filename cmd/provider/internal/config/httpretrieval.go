@@ -0,0 +1,78 @@
+package config
+
+import (
+	"time"
+
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const (
+	defaultHttpRetrievalReadTimeout  = Duration(30 * time.Second)
+	defaultHttpRetrievalWriteTimeout = Duration(5 * time.Minute)
+)
+
+// HttpRetrieval configures the HTTP server that serves the content of imported CAR files following
+// the IPFS trustless gateway specification.
+type HttpRetrieval struct {
+	// ListenMultiaddr is the address on which the HTTP retrieval server listens, e.g.
+	// "/ip4/0.0.0.0/tcp/3105/http". The server is disabled if unset. Serving content over HTTP
+	// requires Ingest.ReverseIndex to be enabled.
+	ListenMultiaddr string
+	// AnnounceMultiaddrs are the addresses of the HTTP retrieval server included in advertisements.
+	// ListenMultiaddr is advertised if unset.
+	AnnounceMultiaddrs []string
+	ReadTimeout        Duration
+	WriteTimeout       Duration
+}
+
+// NewHttpRetrieval instantiates a new HttpRetrieval config with default values.
+func NewHttpRetrieval() HttpRetrieval {
+	return HttpRetrieval{
+		ReadTimeout:  defaultHttpRetrievalReadTimeout,
+		WriteTimeout: defaultHttpRetrievalWriteTimeout,
+	}
+}
+
+// ListenNetAddr returns the net address on which the HTTP retrieval server listens.
+func (hr *HttpRetrieval) ListenNetAddr() (string, error) {
+	maddr, err := multiaddr.NewMultiaddr(hr.ListenMultiaddr)
+	if err != nil {
+		return "", err
+	}
+	httpMultiaddr, _ := multiaddr.NewMultiaddr("/http")
+	maddr = maddr.Decapsulate(httpMultiaddr)
+
+	netAddr, err := manet.ToNetAddr(maddr)
+	if err != nil {
+		return "", err
+	}
+	return netAddr.String(), nil
+}
+
+// AnnounceAddrs returns the addresses of the HTTP retrieval server to include in advertisements.
+func (hr *HttpRetrieval) AnnounceAddrs() ([]multiaddr.Multiaddr, error) {
+	addrs := hr.AnnounceMultiaddrs
+	if len(addrs) == 0 {
+		addrs = []string{hr.ListenMultiaddr}
+	}
+	maddrs := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, addr := range addrs {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		maddrs = append(maddrs, maddr)
+	}
+	return maddrs, nil
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *HttpRetrieval) PopulateDefaults() {
+	if c.ReadTimeout == 0 {
+		c.ReadTimeout = defaultHttpRetrievalReadTimeout
+	}
+	if c.WriteTimeout == 0 {
+		c.WriteTimeout = defaultHttpRetrievalWriteTimeout
+	}
+}
@@ -36,6 +36,11 @@ type Ingest struct {
 
 	// PublisherKind specifies which legs.Publisher implementation to use.
 	PublisherKind PublisherKind
+
+	// ReverseIndex tells whether to maintain a local index of the context IDs that contain each
+	// advertised multihash. Enabling the index on a provider with previously advertised content
+	// requires a one-off rebuild via "provider reverse-index rebuild".
+	ReverseIndex bool
 }
 
 // NewIngest instantiates a new Ingest configuration with default values.
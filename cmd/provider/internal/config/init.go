@@ -19,13 +19,22 @@ func Init(out io.Writer) (*Config, error) {
 }
 
 func InitWithIdentity(identity Identity) (*Config, error) {
+	adminServer, err := NewAdminServerWithTokens()
+	if err != nil {
+		return nil, err
+	}
 	return &Config{
-		Identity:       identity,
-		Bootstrap:      NewBootstrap(),
-		Datastore:      NewDatastore(),
-		Ingest:         NewIngest(),
-		ProviderServer: NewProviderServer(),
-		AdminServer:    NewAdminServer(),
+		Identity:           identity,
+		Bootstrap:          NewBootstrap(),
+		Datastore:          NewDatastore(),
+		Ingest:             NewIngest(),
+		ProviderServer:     NewProviderServer(),
+		AdminServer:        adminServer,
+		DirectorySupplier:  NewDirectorySupplier(),
+		Events:             NewEvents(),
+		HttpRetrieval:      NewHttpRetrieval(),
+		BitswapServer:      NewBitswapServer(),
+		GraphsyncRetrieval: NewGraphsyncRetrieval(),
 	}, nil
 }
 
@@ -88,3 +88,23 @@ func TestSaveLoad(t *testing.T) {
 		t.Fatal("config data different after being loaded")
 	}
 }
+
+func TestInitGeneratesAdminTokens(t *testing.T) {
+	cfg, err := Init(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AdminServer.ReadWriteToken == "" || cfg.AdminServer.ReadOnlyToken == "" {
+		t.Fatal("admin tokens not generated")
+	}
+	if cfg.AdminServer.ReadWriteToken == cfg.AdminServer.ReadOnlyToken {
+		t.Fatal("admin tokens must differ")
+	}
+	cfg2, err := Init(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AdminServer.ReadWriteToken == cfg2.AdminServer.ReadWriteToken {
+		t.Fatal("admin tokens must be unique across configs")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var JobsCmd = &cli.Command{
+	Name:        "jobs",
+	Usage:       "Manages the import and remove jobs of an index-provider daemon.",
+	Subcommands: []*cli.Command{listJobsSubCmd, getJobSubCmd, cancelJobSubCmd},
+}
+
+var (
+	listJobsSubCmd = &cli.Command{
+		Name:    "list",
+		Aliases: []string{"ls"},
+		Usage:   "Lists jobs in order of creation",
+		Flags:   []cli.Flag{adminAPIFlag, adminTokenFlag},
+		Action:  doListJobs,
+	}
+	getJobSubCmd = &cli.Command{
+		Name:      "get",
+		Usage:     "Shows the status of a job",
+		ArgsUsage: "<job-id>",
+		Flags:     []cli.Flag{adminAPIFlag, adminTokenFlag},
+		Before:    beforeJob,
+		Action:    doGetJob,
+	}
+	cancelJobSubCmd = &cli.Command{
+		Name:      "cancel",
+		Usage:     "Cancels a running job",
+		ArgsUsage: "<job-id>",
+		Flags:     []cli.Flag{adminAPIFlag, adminTokenFlag},
+		Before:    beforeJob,
+		Action:    doCancelJob,
+	}
+	jobID string
+)
+
+func beforeJob(cctx *cli.Context) error {
+	if cctx.NArg() != 1 {
+		return cli.Exit("Exactly one argument <job-id> must be specified.", 1)
+	}
+	jobID = cctx.Args().First()
+	return nil
+}
+
+func doListJobs(cctx *cli.Context) error {
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	jobs, err := client.ListJobs(cctx.Context)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	for _, job := range jobs {
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\n", job.ID, job.Kind, job.Status, job.Created.Format(time.RFC3339))
+	}
+	_, err = cctx.App.Writer.Write(b.Bytes())
+	return err
+}
+
+func doGetJob(cctx *cli.Context) error {
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	job, err := client.GetJob(cctx.Context, jobID)
+	if err != nil {
+		return err
+	}
+	return printJob(cctx, job)
+}
+
+func doCancelJob(cctx *cli.Context) error {
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	job, err := client.CancelJob(cctx.Context, jobID)
+	if err != nil {
+		return err
+	}
+	return printJob(cctx, job)
+}
+
+// printJob prints the given job.
+func printJob(cctx *cli.Context, job *adminserver.JobRes) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Job ID:           %s\n", job.ID)
+	fmt.Fprintf(&b, "Kind:             %s\n", job.Kind)
+	fmt.Fprintf(&b, "Status:           %s\n", job.Status)
+	fmt.Fprintf(&b, "Context ID:       %s\n", base64.StdEncoding.EncodeToString(job.Key))
+	if job.Path != "" {
+		fmt.Fprintf(&b, "Path:             %s\n", job.Path)
+	}
+	if job.AdvId.Defined() {
+		fmt.Fprintf(&b, "Advertisement ID: %s\n", job.AdvId)
+	}
+	if job.Progress != nil {
+		fmt.Fprintf(&b, "Progress:         %d/%d\n", job.Progress.Done, job.Progress.Total)
+	}
+	if job.Error != "" {
+		fmt.Fprintf(&b, "Error:            %s\n", job.Error)
+	}
+	fmt.Fprintf(&b, "Created:          %s\n", job.Created.Format(time.RFC3339))
+	if job.Finished != nil {
+		fmt.Fprintf(&b, "Finished:         %s\n", job.Finished.Format(time.RFC3339))
+	}
+	_, err := cctx.App.Writer.Write(b.Bytes())
+	return err
+}
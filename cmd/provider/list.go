@@ -4,11 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"net/http"
 	"os"
 
 	"github.com/filecoin-project/index-provider/cmd/provider/internal"
-	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipld/go-ipld-prime/traversal/selector"
@@ -70,6 +68,7 @@ var (
 		Action: doListCars,
 		Flags: []cli.Flag{
 			adminAPIFlag,
+			adminTokenFlag,
 		},
 	}
 )
@@ -167,22 +166,16 @@ func doGetAdvertisements(cctx *cli.Context) error {
 }
 
 func doListCars(cctx *cli.Context) error {
-	cl := &http.Client{}
-	resp, err := cl.Get(adminAPIFlagValue + "/admin/list/car")
+	client, err := newAdminClient()
 	if err != nil {
 		return err
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return errFromHttpResp(resp)
-	}
-
-	var res adminserver.ListCarRes
-	if _, err := res.ReadFrom(resp.Body); err != nil {
-		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	paths, err := client.ListCars(cctx.Context)
+	if err != nil {
+		return err
 	}
 	var b bytes.Buffer
-	for _, path := range res.Paths {
+	for _, path := range paths {
 		b.WriteString(path)
 		b.WriteString(fmt.Sprintln())
 	}
@@ -44,13 +44,18 @@ func run() int {
 			AnnounceCmd,
 			ConnectCmd,
 			DaemonCmd,
+			DrainCmd,
 			FindCmd,
 			ImportCmd,
 			IndexCmd,
 			InitCmd,
+			JobsCmd,
 			ListCmd,
 			RegisterCmd,
 			RemoveCmd,
+			ReverseIndexCmd,
+			StatsCmd,
+			UpdateMetadataCmd,
 			VerifyIngestCmd,
 		},
 	}
@@ -6,9 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"net/http"
 
-	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
 	"github.com/urfave/cli/v2"
 )
 
@@ -44,48 +42,54 @@ specified, they key is simply calculated as the SHA_256 hash of the given path.`
 )
 
 func beforeRemoveCar(cctx *cli.Context) error {
+	var err error
+	removeCarKey, err = carKeyFromFlags(cctx)
+	return err
+}
+
+// carKeyFromFlags returns the key of a previously imported CAR file, identified by either the key
+// or the input flag.
+func carKeyFromFlags(cctx *cli.Context) ([]byte, error) {
 	if !cctx.IsSet(keyFlag.Name) {
 		if !cctx.IsSet(optionalCarPathFlag.Name) {
-			return fmt.Errorf("either %s or %s must be set", keyFlag.Name, optionalCarPathFlag.Name)
+			return nil, fmt.Errorf("either %s or %s must be set", keyFlag.Name, optionalCarPathFlag.Name)
 		}
 		h := sha256.New()
 		h.Write([]byte(optionalCarPathFlagValue))
-		removeCarKey = h.Sum(nil)
-		return nil
+		return h.Sum(nil), nil
 	}
 
 	if cctx.IsSet(optionalCarPathFlag.Name) {
-		return fmt.Errorf("only one of %s or %s must be set", keyFlag.Name, optionalCarPathFlag.Name)
+		return nil, fmt.Errorf("only one of %s or %s must be set", keyFlag.Name, optionalCarPathFlag.Name)
 	}
 	decoded, err := base64.StdEncoding.DecodeString(keyFlagValue)
 	if err != nil {
-		return errors.New("key is not a valid base64 encoded string")
+		return nil, errors.New("key is not a valid base64 encoded string")
 	}
-	removeCarKey = decoded
-	return nil
+	return decoded, nil
 }
 
 func doRemoveCar(cctx *cli.Context) error {
-	req := adminserver.RemoveCarReq{
-		Key: removeCarKey,
-	}
-	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/remove/car", req)
+	client, err := newAdminClient()
 	if err != nil {
 		return err
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return errFromHttpResp(resp)
+	if asyncFlagValue {
+		job, err := client.RemoveCarAsync(cctx.Context, removeCarKey)
+		if err != nil {
+			return err
+		}
+		return printJob(cctx, job)
 	}
-
-	var res adminserver.RemoveCarRes
-	if _, err := res.ReadFrom(resp.Body); err != nil {
-		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	advID, err := client.RemoveCar(cctx.Context, removeCarKey)
+	if err != nil {
+		return err
 	}
+
 	var b bytes.Buffer
 	b.WriteString("Successfully removed CAR.\n")
 	b.WriteString("\t Advertisement ID: ")
-	b.WriteString(res.AdvId.String())
+	b.WriteString(advID.String())
 	b.WriteString("\n\t Context ID: ")
 	b.WriteString(base64.StdEncoding.EncodeToString(removeCarKey))
 	b.WriteString("\n")
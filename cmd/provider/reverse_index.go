@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+var ReverseIndexCmd = &cli.Command{
+	Name:        "reverse-index",
+	Usage:       "Manages the local index of context IDs by multihash of an index-provider daemon.",
+	Subcommands: []*cli.Command{rebuildReverseIndexSubCmd},
+}
+
+var rebuildReverseIndexSubCmd = &cli.Command{
+	Name:  "rebuild",
+	Usage: "Rebuilds the reverse index from the context IDs advertised by the daemon",
+	Description: "The reverse index must be enabled in the daemon config via Ingest.ReverseIndex.\n" +
+		"Rebuilding is needed once after enabling the reverse index on a daemon with previously advertised content.",
+	Flags:  rebuildReverseIndexFlags,
+	Action: doRebuildReverseIndex,
+}
+
+func doRebuildReverseIndex(cctx *cli.Context) error {
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	job, err := client.RebuildReverseIndex(cctx.Context, asyncFlagValue)
+	if err != nil {
+		return err
+	}
+	if asyncFlagValue {
+		return printJob(cctx, job)
+	}
+	_, err = cctx.App.Writer.Write([]byte("Rebuilt reverse index\n"))
+	return err
+}
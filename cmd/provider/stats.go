@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var StatsCmd = &cli.Command{
+	Name:        "stats",
+	Usage:       "Shows the stats of an index-provider daemon.",
+	Subcommands: []*cli.Command{retrievalStatsSubCmd},
+}
+
+var retrievalStatsSubCmd = &cli.Command{
+	Name:  "retrievals",
+	Usage: "Shows the graphsync retrieval stats per context ID",
+	Description: "Lists the number of transfers by outcome and the bytes sent per retrieved context ID, " +
+		"in descending order of number of transfers.\n" +
+		"When a context ID is specified, shows its stats along with its most recent transfers.",
+	Flags:  retrievalStatsFlags,
+	Action: doRetrievalStats,
+}
+
+func doRetrievalStats(cctx *cli.Context) error {
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	if statsContextIDFlagValue == "" {
+		stats, err := client.ListRetrievalStats(cctx.Context)
+		if err != nil {
+			return err
+		}
+		var b bytes.Buffer
+		for _, s := range stats {
+			fmt.Fprintf(&b, "%s\t%d completed\t%d failed\t%d cancelled\t%d bytes\t%s\n",
+				base64.StdEncoding.EncodeToString(s.ContextID), s.Completed, s.Failed, s.Cancelled, s.BytesSent,
+				s.LastRetrieved.Format(time.RFC3339))
+		}
+		_, err = cctx.App.Writer.Write(b.Bytes())
+		return err
+	}
+
+	contextID, err := base64.StdEncoding.DecodeString(statsContextIDFlagValue)
+	if err != nil {
+		return fmt.Errorf("context ID must be base64 encoded: %w", err)
+	}
+	stats, err := client.GetRetrievalStats(cctx.Context, contextID)
+	if err != nil {
+		return err
+	}
+	return printRetrievalStats(cctx, stats)
+}
+
+// printRetrievalStats prints the given retrieval stats and transfers.
+func printRetrievalStats(cctx *cli.Context, stats *adminserver.RetrievalStatsRes) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Context ID:     %s\n", base64.StdEncoding.EncodeToString(stats.ContextID))
+	fmt.Fprintf(&b, "Completed:      %d\n", stats.Completed)
+	fmt.Fprintf(&b, "Failed:         %d\n", stats.Failed)
+	fmt.Fprintf(&b, "Cancelled:      %d\n", stats.Cancelled)
+	fmt.Fprintf(&b, "Bytes sent:     %d\n", stats.BytesSent)
+	fmt.Fprintf(&b, "Duration:       %s\n", stats.Duration)
+	fmt.Fprintf(&b, "Last retrieved: %s\n", stats.LastRetrieved.Format(time.RFC3339))
+	if len(stats.Transfers) != 0 {
+		fmt.Fprintf(&b, "Recent transfers:\n")
+	}
+	for _, t := range stats.Transfers {
+		fmt.Fprintf(&b, "  %s\t%s\t%s\t%d bytes\t%s\n", t.Started.Format(time.RFC3339), t.Peer, t.Outcome, t.BytesSent, t.Duration)
+	}
+	_, err := cctx.App.Writer.Write(b.Bytes())
+	return err
+}
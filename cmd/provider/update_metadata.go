@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	updateMetadataKey    []byte
+	updateMetadataMd     []byte
+	updateMetadataRemove []string
+	UpdateMetadataCmd    = &cli.Command{
+		Name:  "update-metadata",
+		Usage: "Updates the metadata of a previously imported CAR file.",
+		Description: `Publishes an advertisement that changes the metadata with which the multihashes
+contained in a CAR file are advertised, for example to change the retrieval protocols.
+
+The CAR file must have previously been imported, and is not read again. See import command.
+
+The CAR file is identified by either:
+  - the key option, the key by which the CAR file was previously imported, or
+  - the input option, the path to the CAR file that was previously imported.
+
+By default the advertised metadata is replaced by the given metadata. With the merge option, only
+the protocols present in the given metadata are replaced, and the other advertised protocols are
+kept. Protocols are removed from the advertised metadata with the remove option, for example:
+
+  provider update-metadata -i my.car --remove bitswap`,
+		Flags:  updateMetadataFlags,
+		Before: beforeUpdateMetadata,
+		Action: doUpdateMetadata,
+	}
+)
+
+func beforeUpdateMetadata(cctx *cli.Context) error {
+	var err error
+	if updateMetadataKey, err = carKeyFromFlags(cctx); err != nil {
+		return err
+	}
+	updateMetadataRemove = cctx.StringSlice("remove")
+	for _, name := range updateMetadataRemove {
+		if _, err := metadata.ProtocolCode(name); err != nil {
+			return err
+		}
+	}
+	if !cctx.IsSet("metadata") {
+		if len(updateMetadataRemove) == 0 {
+			return errors.New("metadata must be set unless removing protocols")
+		}
+		return nil
+	}
+	if updateMetadataMd, err = adminserver.ParseMetadata(metadataFlagValue); err != nil {
+		return err
+	}
+	// Check the metadata locally to fail early.
+	var md metadata.Metadata
+	return md.UnmarshalBinary(updateMetadataMd)
+}
+
+func doUpdateMetadata(cctx *cli.Context) error {
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	var advID cid.Cid
+	if updateMetadataMergeFlagValue || len(updateMetadataRemove) != 0 {
+		advID, err = client.MergeMetadata(cctx.Context, updateMetadataKey, updateMetadataMd, updateMetadataRemove...)
+	} else {
+		advID, err = client.UpdateMetadata(cctx.Context, updateMetadataKey, updateMetadataMd)
+	}
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("Successfully updated metadata.\n")
+	b.WriteString("\t Advertisement ID: ")
+	b.WriteString(advID.String())
+	b.WriteString("\n\t Context ID: ")
+	b.WriteString(base64.StdEncoding.EncodeToString(updateMetadataKey))
+	b.WriteString("\n")
+	_, err = cctx.App.Writer.Write(b.Bytes())
+	return err
+}
@@ -1,10 +1,14 @@
 package provider_test
 
 import (
+	"bytes"
 	"context"
+	"math/rand"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -23,7 +27,10 @@ import (
 	dssync "github.com/ipfs/go-datastore/sync"
 	"github.com/ipfs/go-graphsync/storeutil"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-unixfsnode"
+	unixfsbuilder "github.com/ipfs/go-unixfsnode/data/builder"
 	"github.com/ipld/go-car/v2"
+	dagpb "github.com/ipld/go-codec-dagpb"
 	"github.com/ipld/go-ipld-prime"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
@@ -164,6 +171,80 @@ func testRetrievalRoundTripWithTestCase(t *testing.T, tc testCase) {
 	}
 }
 
+func TestPartialRetrieval(t *testing.T) {
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testPartialRetrievalWithTestCase(t, tc)
+		})
+	}
+}
+
+func testPartialRetrievalWithTestCase(t *testing.T, tc testCase) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	server := newTestServer(t, ctx, tc.serverConfigOpts(t)...)
+
+	// Import a CAR of a unixfs directory, with a file of 1KiB blocks in a sub-directory.
+	dag := newUnixFSTestDAG(t, map[string]int{
+		"hello.txt":   64,
+		"sub/big.bin": 10 << 10,
+		"other.bin":   4 << 10,
+	})
+	carPath := filepath.Join(t.TempDir(), "unixfs.car")
+	err := car.TraverseToFile(ctx, &dag.lsys, dag.root, selectorparse.CommonSelector_ExploreAllRecursively, carPath)
+	require.NoError(t, err)
+
+	contextID := []byte("applesauce")
+	tp, err := cardatatransfer.TransportFromContextID(contextID)
+	require.NoError(t, err)
+	_, err = server.cs.Put(ctx, contextID, carPath, metadata.New(tp))
+	require.NoError(t, err)
+	pieceCID := tp.(*metadata.GraphsyncFilecoinV1).PieceCID
+
+	bigFile := dag.files["sub/big.bin"]
+	bigLeaves := dag.leaves(t, bigFile)
+	require.Len(t, bigLeaves, 10)
+
+	newClient := func(t *testing.T) *testClient {
+		client := newTestClient(t)
+		disseminateNetworkState(server.h, client.h)
+		require.NoError(t, client.dt.RegisterVoucherResultType(&cardatatransfer.DealResponse{}))
+		require.NoError(t, client.dt.RegisterVoucherType(&cardatatransfer.DealProposal{}, nil))
+		return client
+	}
+
+	t.Run("unixfs path", func(t *testing.T) {
+		client := newClient(t)
+		client.retrieve(t, ctx, server.h, pieceCID, dag.root, cardatatransfer.UnixFSPathSelector("sub/big.bin"))
+
+		client.requireHas(t, ctx, true, dag.root, dag.dirs["sub"], bigFile)
+		client.requireHas(t, ctx, true, bigLeaves...)
+		client.requireHas(t, ctx, false, dag.files["hello.txt"], dag.files["other.bin"])
+	})
+
+	t.Run("byte range", func(t *testing.T) {
+		client := newClient(t)
+
+		// Retrieve the root of the file on its own to determine the blocks that hold the range.
+		client.retrieve(t, ctx, server.h, pieceCID, bigFile, cardatatransfer.DepthLimitSelector(0))
+		client.requireHas(t, ctx, true, bigFile)
+		client.requireHas(t, ctx, false, bigLeaves...)
+		fileRoot, err := client.lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: bigFile}, dagpb.Type.PBNode)
+		require.NoError(t, err)
+
+		sel, err := cardatatransfer.ByteRangeSelector("sub/big.bin", fileRoot, 2<<10, 3<<10+1)
+		require.NoError(t, err)
+		client.retrieve(t, ctx, server.h, pieceCID, dag.root, sel)
+
+		client.requireHas(t, ctx, true, dag.root, dag.dirs["sub"])
+		client.requireHas(t, ctx, true, bigLeaves[2:4]...)
+		client.requireHas(t, ctx, false, bigLeaves[:2]...)
+		client.requireHas(t, ctx, false, bigLeaves[4:]...)
+		client.requireHas(t, ctx, false, dag.files["hello.txt"], dag.files["other.bin"])
+	})
+}
+
 func TestReimportCar(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -308,20 +389,145 @@ type testClient struct {
 	dt    datatransfer.Manager
 	lsys  ipld.LinkSystem
 	store datastore.Batching
+	bs    blockstore.Blockstore
+	// nextDealID is the ID of the next deal proposed by retrieve.
+	nextDealID cardatatransfer.DealID
 }
 
 func newTestClient(t *testing.T) *testClient {
 	store := dssync.MutexWrap(datastore.NewMapDatastore())
 	blockStore := blockstore.NewBlockstore(store)
 	lsys := storeutil.LinkSystemForBlockstore(blockStore)
+	// Selectors may interpret unixfs DAGs, in which case they are traversed as such by both ends.
+	unixfsnode.AddUnixFSReificationToLinkSystem(&lsys)
 	h := newHost(t)
 	dt := testutil.SetupDataTransferOnHost(t, h, store, lsys)
 	return &testClient{
-		h:     h,
-		dt:    dt,
+		h:          h,
+		dt:         dt,
+		lsys:       lsys,
+		store:      store,
+		bs:         blockStore,
+		nextDealID: 1,
+	}
+}
+
+// retrieve retrieves the content selected by the given selector from the given root, and requires
+// the retrieval to succeed. The retrieval voucher types must be registered beforehand.
+func (c *testClient) retrieve(t *testing.T, ctx context.Context, server host.Host, pieceCID cid.Cid, root cid.Cid, sel ipld.Node) {
+	proposal := &cardatatransfer.DealProposal{
+		PayloadCID: root,
+		ID:         c.nextDealID,
+		Params:     cardatatransfer.Params{PieceCID: &pieceCID},
+	}
+	c.nextDealID++
+	require.NoError(t, proposal.SetSelector(sel))
+
+	done := make(chan bool, 1)
+	unsub := c.dt.SubscribeToEvents(func(event datatransfer.Event, channelState datatransfer.ChannelState) {
+		switch channelState.Status() {
+		case datatransfer.Failed, datatransfer.Cancelled:
+			done <- false
+		case datatransfer.Completed:
+			done <- true
+		}
+	})
+	defer unsub()
+	_, err := c.dt.OpenPullDataChannel(ctx, server.ID(), proposal, root, sel)
+	require.NoError(t, err)
+
+	select {
+	case <-ctx.Done():
+		require.FailNow(t, "context closed")
+	case result := <-done:
+		require.True(t, result)
+	}
+}
+
+// requireHas requires the blocks with the given CIDs to either all be retrieved or all not be.
+func (c *testClient) requireHas(t *testing.T, ctx context.Context, want bool, cids ...cid.Cid) {
+	for _, k := range cids {
+		has, err := c.bs.Has(ctx, k)
+		require.NoError(t, err)
+		require.Equal(t, want, has, "unexpected presence of %s", k)
+	}
+}
+
+// unixFSTestDAG is a unixfs directory tree stored in memory.
+type unixFSTestDAG struct {
+	lsys ipld.LinkSystem
+	root cid.Cid
+	// files and dirs map the paths of the files and sub-directories in the tree to their CID.
+	files map[string]cid.Cid
+	dirs  map[string]cid.Cid
+}
+
+// newUnixFSTestDAG builds a unixfs directory tree with a file of random bytes at each of the given
+// paths, of the given size and chunked into 1KiB blocks.
+func newUnixFSTestDAG(t *testing.T, sizes map[string]int) *unixFSTestDAG {
+	rng := rand.New(rand.NewSource(1413))
+	store := &cidlink.Memory{}
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageReadOpener = store.OpenRead
+	lsys.StorageWriteOpener = store.OpenWrite
+	dag := &unixFSTestDAG{
 		lsys:  lsys,
-		store: store,
+		files: make(map[string]cid.Cid),
+		dirs:  make(map[string]cid.Cid),
+	}
+
+	// Group the entries of the tree by directory.
+	entries := make(map[string][]dagpb.PBLink)
+	addEntry := func(p string, lnk ipld.Link, size uint64) {
+		entry, err := unixfsbuilder.BuildUnixFSDirectoryEntry(path.Base(p), int64(size), lnk)
+		require.NoError(t, err)
+		dir := path.Dir(p)
+		entries[dir] = append(entries[dir], entry)
+	}
+	for p, size := range sizes {
+		content := make([]byte, size)
+		rng.Read(content)
+		lnk, written, err := unixfsbuilder.BuildUnixFSFile(bytes.NewReader(content), "size-1024", &dag.lsys)
+		require.NoError(t, err)
+		dag.files[p] = lnk.(cidlink.Link).Cid
+		addEntry(p, lnk, written)
+	}
+	// Build the deepest directories first, so that they are linked from their parent.
+	dirs := make([]string, 0, len(entries))
+	for dir := range entries {
+		dirs = append(dirs, dir)
+	}
+	depth := func(dir string) int {
+		if dir == "." {
+			return 0
+		}
+		return strings.Count(dir, "/") + 1
+	}
+	sort.Slice(dirs, func(i, j int) bool { return depth(dirs[i]) > depth(dirs[j]) })
+	for _, dir := range dirs {
+		lnk, err := unixfsbuilder.BuildUnixFSDirectory(entries[dir], &dag.lsys)
+		require.NoError(t, err)
+		if dir == "." {
+			dag.root = lnk.(cidlink.Link).Cid
+			continue
+		}
+		dag.dirs[dir] = lnk.(cidlink.Link).Cid
+		addEntry(dir, lnk, 0)
+	}
+	return dag
+}
+
+// leaves returns the CIDs of the blocks linked from the given file root, in order.
+func (d *unixFSTestDAG) leaves(t *testing.T, file cid.Cid) []cid.Cid {
+	n, err := d.lsys.Load(ipld.LinkContext{}, cidlink.Link{Cid: file}, dagpb.Type.PBNode)
+	require.NoError(t, err)
+	var leaves []cid.Cid
+	links := n.(dagpb.PBNode).FieldLinks().Iterator()
+	for !links.Done() {
+		_, link := links.Next()
+		leaves = append(leaves, link.FieldHash().Link().(cidlink.Link).Cid)
 	}
+	return leaves
 }
 
 func newHost(t *testing.T) host.Host {
@@ -0,0 +1,22 @@
+package engine
+
+import "context"
+
+type deferAnnounceKey struct{}
+
+// WithDeferredAnnounce returns a copy of the given context that instructs the engine to only
+// publish advertisements locally, without announcing them to indexer nodes.
+//
+// This allows a batch of advertisements to be published with a single announcement: indexer
+// nodes sync the entire chain of advertisements from the latest one, so announcing the latest
+// advertisement via Engine.PublishLatest once the batch is complete is sufficient.
+//
+// See: Engine.NotifyPut, Engine.NotifyRemove, Engine.Publish, Engine.PublishLatest.
+func WithDeferredAnnounce(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deferAnnounceKey{}, true)
+}
+
+func isAnnounceDeferred(ctx context.Context) bool {
+	deferred, _ := ctx.Value(deferAnnounceKey{}).(bool)
+	return deferred
+}
@@ -70,6 +70,8 @@ type CachedEntriesChunker struct {
 	// onEvictedCtx is used to set the context to be used during cache eviction by operations
 	// performed via CachedEntriesChunker.performOnCache.
 	onEvictedCtx context.Context
+	// evictionListener, if set, is notified of the root of each chain evicted from the cache.
+	evictionListener func(root ipld.Link)
 	// lock syncronizes the chunking, clearing the cache and reading the number of cached chains.
 	lock sync.Mutex
 }
@@ -193,9 +195,23 @@ func (ls *CachedEntriesChunker) onEvicted(k lru.Key, val interface{}) {
 	if err != nil {
 		log.Errorw("failed to prune persisted cache key after eviction", "err", err)
 		ls.onEvictedErr = err
+		return
+	}
+	if ls.evictionListener != nil {
+		ls.evictionListener(chunkRoot)
 	}
 }
 
+// SetEvictionListener sets the function that is called with the link to the root of each chain
+// evicted from the cache, including chains evicted by CachedEntriesChunker.Clear. The function is
+// called synchronously during eviction and must not call back into the CachedEntriesChunker.
+// Chains evicted while restoring the cache upon instantiation are not notified.
+func (ls *CachedEntriesChunker) SetEvictionListener(fn func(root ipld.Link)) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.evictionListener = fn
+}
+
 func dsKey(l ipld.Link) datastore.Key {
 	return datastore.NewKey(l.(cidlink.Link).Cid.String())
 }
@@ -221,6 +237,11 @@ func (ls *CachedEntriesChunker) Chunk(ctx context.Context, mhi provider.Multihas
 		mhs = append(mhs, mh)
 		mhCount++
 		if len(mhs) >= ls.chunkSize {
+			// Stop chunking as soon as the context is done, since chunking large lists of
+			// multihashes may take a long time.
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			cNode, err := newEntriesChunkNode(mhs, next)
 			if err != nil {
 				return nil, err
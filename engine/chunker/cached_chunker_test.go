@@ -117,6 +117,19 @@ func TestNewCachedEntriesChunker_FailsWhenContextIsCancelled(t *testing.T) {
 	require.ErrorIs(t, err, context.Canceled)
 }
 
+func TestCachedEntriesChunker_ChunkFailsWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	subject, err := chunker.NewCachedEntriesChunker(ctx, datastore.NewMapDatastore(), 10, 1)
+	require.NoError(t, err)
+	defer subject.Close()
+
+	cancel()
+	// Assert context is checked for error while chunking
+	_, err = subject.Chunk(ctx, getRandomMhIterator(t, rand.New(rand.NewSource(1413)), 45))
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 0, subject.Len())
+}
+
 func TestCachedEntriesChunker_NonOverlappingDagIsEvicted(t *testing.T) {
 	rng := rand.New(rand.NewSource(1413))
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -126,6 +139,8 @@ func TestCachedEntriesChunker_NonOverlappingDagIsEvicted(t *testing.T) {
 	subject, err := chunker.NewCachedEntriesChunker(ctx, store, 10, 1)
 	require.NoError(t, err)
 	defer subject.Close()
+	var evicted []ipld.Link
+	subject.SetEvictionListener(func(root ipld.Link) { evicted = append(evicted, root) })
 
 	// Cache a chain of length 5 and assert it is cached.
 	c1Lnk, err := subject.Chunk(ctx, getRandomMhIterator(t, rng, 45))
@@ -145,6 +160,7 @@ func TestCachedEntriesChunker_NonOverlappingDagIsEvicted(t *testing.T) {
 
 	// Assert the first chain is fully evicted
 	requireChunkIsNotCached(t, subject, c1Chain...)
+	require.Equal(t, []ipld.Link{c1Lnk}, evicted)
 }
 
 func TestCachedEntriesChunker_PreviouslyCachedChunksAreRestored(t *testing.T) {
@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/multiformats/go-multihash"
+)
+
+// ContextIDInfo captures the state of a context ID advertised by the engine.
+type ContextIDInfo struct {
+	// ContextID is the advertised context ID.
+	ContextID []byte
+	// Entries is the CID of the advertised chain of multihash entries.
+	Entries cid.Cid
+	// Metadata is the metadata of the latest advertisement for the context ID.
+	Metadata metadata.Metadata
+}
+
+// ListContextIDs lists the context IDs that are currently advertised by the engine, i.e. the
+// context IDs that were put via Engine.NotifyPut and not removed since, in lexicographic order of
+// their datastore keys.
+//
+// The given offset and limit allow paginating through the context IDs. A limit of zero lists all
+// the context IDs from offset onwards.
+func (e *Engine) ListContextIDs(ctx context.Context, offset, limit int) ([][]byte, error) {
+	contextIDs, _, err := e.queryContextIDs(ctx, query.Query{
+		Offset: offset,
+		Limit:  limit,
+	})
+	return contextIDs, err
+}
+
+// listContextIDsAfter lists at most limit context IDs whose datastore keys follow the given key,
+// in lexicographic order of their keys. All context IDs are listed if the given key is empty.
+// The key of the last listed context ID is returned, from which listing may be continued.
+func (e *Engine) listContextIDsAfter(ctx context.Context, afterKey string, limit int) ([][]byte, string, error) {
+	q := query.Query{Limit: limit}
+	if afterKey != "" {
+		q.Filters = []query.Filter{query.FilterKeyCompare{Op: query.GreaterThan, Key: afterKey}}
+	}
+	return e.queryContextIDs(ctx, q)
+}
+
+// queryContextIDs runs the given query over the advertised context IDs ordered by key, and returns
+// the listed context IDs along with the key of the last one.
+func (e *Engine) queryContextIDs(ctx context.Context, q query.Query) ([][]byte, string, error) {
+	q.Prefix = keyToCtxMapPrefix
+	q.Orders = []query.Order{query.OrderByKey{}}
+	results, err := e.ds.Query(ctx, q)
+	if err != nil {
+		return nil, "", err
+	}
+	defer results.Close()
+
+	var contextIDs [][]byte
+	var lastKey string
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, "", r.Error
+		}
+		contextIDs = append(contextIDs, r.Value)
+		lastKey = r.Key
+	}
+	return contextIDs, lastKey, nil
+}
+
+// backfillKeyCtxMap stores the raw context IDs of context IDs that were advertised before raw
+// context IDs were stored separately, so that they are listed. Their context IDs are derived from
+// the keys under which their entries CIDs are stored, which is only accurate for context IDs
+// without slashes. The backfill runs once per datastore.
+func (e *Engine) backfillKeyCtxMap(ctx context.Context) error {
+	done, err := e.ds.Has(ctx, dsKeyCtxMapBackfilledKey)
+	if err != nil || done {
+		return err
+	}
+	results, err := e.ds.Query(ctx, query.Query{
+		Prefix:   keyToCidMapPrefix,
+		KeysOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	keyPrefix := datastore.NewKey(keyToCidMapPrefix).String() + "/"
+	var backfilled int
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		contextID := []byte(strings.TrimPrefix(r.Key, keyPrefix))
+		key := toKeyCtxMapKey(contextID)
+		has, err := e.ds.Has(ctx, key)
+		if err != nil {
+			return err
+		}
+		if has {
+			continue
+		}
+		if err := e.ds.Put(ctx, key, contextID); err != nil {
+			return err
+		}
+		backfilled++
+	}
+	if backfilled != 0 {
+		log.Infow("Backfilled advertised context IDs", "count", backfilled)
+	}
+	return e.ds.Put(ctx, dsKeyCtxMapBackfilledKey, []byte{})
+}
+
+// GetContextID gets the entries CID and metadata currently advertised for the given context ID.
+// If the context ID is not advertised provider.ErrContextIDNotFound is returned.
+func (e *Engine) GetContextID(ctx context.Context, contextID []byte) (*ContextIDInfo, error) {
+	c, err := e.getKeyCidMap(ctx, contextID)
+	if err != nil {
+		if err == datastore.ErrNotFound {
+			return nil, provider.ErrContextIDNotFound
+		}
+		return nil, fmt.Errorf("could not get entries cid by context id: %s", err)
+	}
+	md, err := e.getKeyMetadataMap(ctx, contextID)
+	if err != nil && err != datastore.ErrNotFound {
+		return nil, fmt.Errorf("could not get metadata for context id: %s", err)
+	}
+	return &ContextIDInfo{
+		ContextID: contextID,
+		Entries:   c,
+		Metadata:  md,
+	}, nil
+}
+
+// FindContextIDs finds the advertised context IDs that contain the given multihash.
+//
+// If the reverse index is enabled the context IDs are looked up in the index. Otherwise, the
+// multihashes of every advertised context ID are listed via the registered
+// provider.MultihashLister, which makes the lookup expensive and only suitable for administrative
+// use. Context IDs whose multihashes fail to be listed are skipped.
+//
+// See: WithReverseIndex.
+func (e *Engine) FindContextIDs(ctx context.Context, mh multihash.Multihash) ([][]byte, error) {
+	if e.reverseIndex {
+		return e.lookupReverseIndex(ctx, mh)
+	}
+
+	e.cblk.Lock()
+	mhLister := e.mhLister
+	e.cblk.Unlock()
+	if mhLister == nil {
+		return nil, provider.ErrNoMultihashLister
+	}
+
+	contextIDs, err := e.ListContextIDs(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	var found [][]byte
+	for _, contextID := range contextIDs {
+		contains, err := containsMultihash(ctx, mhLister, contextID, mh)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// Skip context IDs whose multihashes can no longer be listed, e.g. because the
+			// underlying data was deleted, rather than failing the entire lookup.
+			log.Warnw("Failed to list multihashes of context ID", "contextID", base64.StdEncoding.EncodeToString(contextID), "err", err)
+			continue
+		}
+		if contains {
+			found = append(found, contextID)
+		}
+	}
+	return found, nil
+}
+
+func containsMultihash(ctx context.Context, mhLister provider.MultihashLister, contextID []byte, mh multihash.Multihash) (bool, error) {
+	mhIter, err := mhLister(ctx, contextID)
+	if err != nil {
+		return false, err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		next, err := mhIter.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return false, nil
+			}
+			return false, err
+		}
+		if string(next) == string(mh) {
+			return true, nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package engine_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_ListContextIDsPreservesSlashes(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	subject, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: testutil.RandomMultihashes(t, rng, 1)}, nil
+	})
+
+	contextIDs := [][]byte{[]byte("/fish"), []byte("lobster//crab"), []byte("barreleye/")}
+	for _, contextID := range contextIDs {
+		_, err := subject.NotifyPut(ctx, contextID, metadata.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+	listed, err := subject.ListContextIDs(ctx, 0, 0)
+	require.NoError(t, err)
+	require.ElementsMatch(t, contextIDs, listed)
+
+	// Listed context IDs can be removed as advertised.
+	for _, contextID := range listed {
+		_, err := subject.NotifyRemove(ctx, contextID)
+		require.NoError(t, err)
+	}
+	listed, err = subject.ListContextIDs(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Empty(t, listed)
+}
+
+func TestEngine_ListContextIDsAdvertisedBeforeUpgrade(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	// Entries CIDs of context IDs advertised by earlier versions are stored on their own.
+	ds := datastore.NewMapDatastore()
+	entries := testutil.RandomCids(t, rng, 1)[0]
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("map/keyCid/fish"), entries.Bytes()))
+
+	subject, err := engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: testutil.RandomMultihashes(t, rng, 1)}, nil
+	})
+
+	_, err = subject.NotifyPut(ctx, []byte("lobster/"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	listed, err := subject.ListContextIDs(ctx, 0, 0)
+	require.NoError(t, err)
+	require.ElementsMatch(t, [][]byte{[]byte("fish"), []byte("lobster/")}, listed)
+
+	// The entries CIDs are stored as is.
+	info, err := subject.GetContextID(ctx, []byte("fish"))
+	require.NoError(t, err)
+	require.Equal(t, entries, info.Entries)
+	value, err := ds.Get(ctx, datastore.NewKey("map/keyCid/lobster/"))
+	require.NoError(t, err)
+	got, err := cid.Cast(value)
+	require.NoError(t, err)
+	info, err = subject.GetContextID(ctx, []byte("lobster/"))
+	require.NoError(t, err)
+	require.Equal(t, info.Entries, got)
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// DefaultDrainBatchSize is the number of context IDs read at a time by Engine.Drain if no batch
+// size is specified.
+const DefaultDrainBatchSize = 1000
+
+// DrainProgress reports the progress of Engine.Drain after the removal of a context ID.
+type DrainProgress struct {
+	// ContextID is the context ID that was removed.
+	ContextID []byte
+	// AdCid is the CID of the removal advertisement published for the context ID.
+	AdCid cid.Cid
+	// Removed is the number of context IDs removed so far.
+	Removed int
+	// Total is the number of context IDs to remove, counted when draining started. It is raised
+	// to Removed if context IDs are put while draining.
+	Total int
+}
+
+// Drain publishes a removal advertisement for every context ID currently advertised by the
+// engine, such that the latest advertisement reflects that the provider no longer provides any
+// content. The context IDs are read in batches of the given size; a batch size of zero uses
+// DefaultDrainBatchSize. Rather than announcing each removal, the latest advertisement is
+// announced once per batch.
+//
+// The given function, if non-nil, is called after each context ID is removed. Draining stops at
+// the first failed removal or once the given context is done, in which case the context IDs
+// removed so far remain removed and draining may be resumed by calling Drain again.
+//
+// The CID of the last removal advertisement is returned, or cid.Undef if there was nothing to
+// remove.
+func (e *Engine) Drain(ctx context.Context, batchSize int, onRemoved func(DrainProgress)) (cid.Cid, error) {
+	if batchSize < 0 {
+		return cid.Undef, fmt.Errorf("batch size cannot be negative: %d", batchSize)
+	}
+	if batchSize == 0 {
+		batchSize = DefaultDrainBatchSize
+	}
+	total, err := e.countContextIDs(ctx)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("failed to count context IDs: %w", err)
+	}
+	log.Infow("Draining advertised context IDs", "count", total)
+
+	// Removals made since the last announcement are announced even if draining stops early.
+	var unannounced bool
+	defer func() {
+		if unannounced {
+			if err := e.PublishLatest(context.Background()); err != nil {
+				log.Errorw("Failed to announce drained context IDs", "err", err)
+			}
+		}
+	}()
+
+	progress := DrainProgress{Total: total}
+	lastAdCid := cid.Undef
+	removeCtx := WithDeferredAnnounce(ctx)
+	for {
+		// Page through the context IDs after the last listed one, so that context IDs that
+		// cannot be removed are not listed again. Passes are repeated until one removes nothing,
+		// which picks up the context IDs put while draining.
+		var afterKey string
+		var removedInPass int
+		for {
+			batch, lastKey, err := e.listContextIDsAfter(ctx, afterKey, batchSize)
+			if err != nil {
+				return lastAdCid, fmt.Errorf("failed to list context IDs: %w", err)
+			}
+			if len(batch) == 0 {
+				break
+			}
+			afterKey = lastKey
+			for _, contextID := range batch {
+				if err := ctx.Err(); err != nil {
+					return lastAdCid, err
+				}
+				adCid, err := e.NotifyRemove(removeCtx, contextID)
+				if err != nil {
+					if errors.Is(err, provider.ErrContextIDNotFound) {
+						// Removed concurrently; nothing left to do.
+						continue
+					}
+					return lastAdCid, fmt.Errorf("failed to remove context ID %s: %w", base64.StdEncoding.EncodeToString(contextID), err)
+				}
+				unannounced = true
+				lastAdCid = adCid
+				removedInPass++
+				progress.ContextID = contextID
+				progress.AdCid = adCid
+				progress.Removed++
+				if progress.Removed > progress.Total {
+					progress.Total = progress.Removed
+				}
+				if onRemoved != nil {
+					onRemoved(progress)
+				}
+			}
+			if unannounced {
+				if err := e.PublishLatest(ctx); err != nil {
+					return lastAdCid, fmt.Errorf("failed to announce drained context IDs: %w", err)
+				}
+				unannounced = false
+			}
+			log.Infow("Drained batch of context IDs", "removed", progress.Removed, "total", progress.Total)
+		}
+		if removedInPass == 0 {
+			break
+		}
+	}
+	log.Infow("Drained all advertised context IDs", "removed", progress.Removed, "lastAdvertisement", lastAdCid)
+	return lastAdCid, nil
+}
+
+func (e *Engine) countContextIDs(ctx context.Context) (int, error) {
+	results, err := e.ds.Query(ctx, query.Query{
+		Prefix:   keyToCidMapPrefix,
+		KeysOnly: true,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+	var count int
+	for r := range results.Next() {
+		if r.Error != nil {
+			return 0, r.Error
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,145 @@
+package engine_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Drain(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	mhs := make(map[string][]multihash.Multihash)
+	for i := 0; i < 7; i++ {
+		mhs[fmt.Sprintf("fish-%d", i)] = testutil.RandomMultihashes(t, rng, 3)
+	}
+	subject, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: mhs[string(contextID)]}, nil
+	})
+
+	// Draining with nothing advertised is a no-op.
+	adCid, err := subject.Drain(ctx, 0, func(engine.DrainProgress) {
+		t.Fatal("nothing must be removed")
+	})
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, adCid)
+
+	for contextID := range mhs {
+		_, err := subject.NotifyPut(ctx, []byte(contextID), metadata.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+
+	// Drain in batches smaller than the number of context IDs.
+	var progress []engine.DrainProgress
+	adCid, err = subject.Drain(ctx, 3, func(p engine.DrainProgress) {
+		progress = append(progress, p)
+	})
+	require.NoError(t, err)
+	require.Len(t, progress, len(mhs))
+	seen := make(map[string]bool)
+	for i, p := range progress {
+		require.Equal(t, i+1, p.Removed)
+		require.Equal(t, len(mhs), p.Total)
+		require.False(t, seen[string(p.ContextID)])
+		seen[string(p.ContextID)] = true
+	}
+	require.Equal(t, progress[len(progress)-1].AdCid, adCid)
+
+	// The latest advertisement is the last removal, and nothing is advertised anymore.
+	latest, ad, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, adCid, latest)
+	require.True(t, ad.IsRm)
+	contextIDs, err := subject.ListContextIDs(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Empty(t, contextIDs)
+}
+
+func TestEngine_DrainStopsWhenContextIsCancelled(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	subject, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: testutil.RandomMultihashes(t, rng, 1)}, nil
+	})
+	for _, contextID := range []string{"fish", "lobster", "undadasea"} {
+		_, err := subject.NotifyPut(ctx, []byte(contextID), metadata.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+
+	drainCtx, cancel := context.WithCancel(ctx)
+	_, err = subject.Drain(drainCtx, 0, func(engine.DrainProgress) { cancel() })
+	require.ErrorIs(t, err, context.Canceled)
+	contextIDs, err := subject.ListContextIDs(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, contextIDs, 2)
+
+	// Draining again resumes where it stopped.
+	var removed int
+	_, err = subject.Drain(ctx, 0, func(p engine.DrainProgress) {
+		removed = p.Removed
+		require.Equal(t, 2, p.Total)
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+}
+
+func TestEngine_DrainAnnouncesOncePerBatch(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherListenAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: testutil.RandomMultihashes(t, rng, 1)}, nil
+	})
+	for i := 0; i < 7; i++ {
+		_, err := subject.NotifyPut(engine.WithDeferredAnnounce(ctx), []byte(fmt.Sprintf("fish-%d", i)), metadata.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+
+	events, cancel := subject.Subscribe()
+	defer cancel()
+	adCid, err := subject.Drain(ctx, 3, nil)
+	require.NoError(t, err)
+
+	// Expect an announcement per batch of 3, 3 and 1 context IDs, the last of which is the
+	// last removal.
+	var announced []cid.Cid
+	for len(announced) < 3 {
+		if ev, ok := requireNextEvent(t, events).(engine.AdAnnounced); ok {
+			announced = append(announced, ev.AdCid)
+		}
+	}
+	require.Equal(t, adCid, announced[2])
+	for {
+		select {
+		case ev := <-events:
+			require.NotEqual(t, engine.EventTypeAdAnnounced, ev.Type())
+		default:
+			return
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-legs"
 	"github.com/filecoin-project/go-legs/dtsync"
@@ -24,16 +25,19 @@ import (
 
 const (
 	keyToCidMapPrefix      = "map/keyCid/"
+	keyToCtxMapPrefix      = "map/keyCtx/"
 	cidToKeyMapPrefix      = "map/cidKey/"
 	keyToMetadataMapPrefix = "map/keyMD/"
 	latestAdvKey           = "sync/adv/"
+	keyCtxMapBackfilledKey = "sync/keyCtxBackfilled"
 	linksCachePath         = "/cache/links"
 )
 
 var (
 	log = logging.Logger("provider/engine")
 
-	dsLatestAdvKey = datastore.NewKey(latestAdvKey)
+	dsLatestAdvKey           = datastore.NewKey(latestAdvKey)
+	dsKeyCtxMapBackfilledKey = datastore.NewKey(keyCtxMapBackfilledKey)
 )
 
 // Engine is an implementation of the core reference provider interface
@@ -47,6 +51,16 @@ type Engine struct {
 
 	mhLister provider.MultihashLister
 	cblk     sync.Mutex
+
+	events *eventBus
+	// webhookCancel stops the delivery of events to the webhook, if any.
+	webhookCancel context.CancelFunc
+	webhookDone   chan struct{}
+
+	// publishLk serializes the linking of advertisements generated by the engine to the latest
+	// advertisement and their announcement, so that concurrent calls to NotifyPut and NotifyRemove
+	// neither fork the chain nor announce an older advertisement after a newer one.
+	publishLk sync.Mutex
 }
 
 var _ provider.Interface = (*Engine)(nil)
@@ -78,6 +92,7 @@ func New(o ...Option) (*Engine, error) {
 
 	e := &Engine{
 		options: opts,
+		events:  newEventBus(),
 	}
 
 	e.lsys = e.mkLinkSystem()
@@ -106,8 +121,19 @@ func (e *Engine) Start(ctx context.Context) error {
 		}
 	}
 
+	cachedChunker.SetEvictionListener(func(root ipld.Link) {
+		e.emit(CacheEvicted{Time: time.Now(), EntriesCid: root.(cidlink.Link).Cid})
+	})
 	e.entriesChunker = cachedChunker
 
+	if err := e.backfillKeyCtxMap(ctx); err != nil {
+		return fmt.Errorf("could not backfill context ID map: %w", err)
+	}
+
+	if e.webhookURL != "" {
+		e.startWebhook()
+	}
+
 	e.publisher, err = e.newPublisher()
 	if err != nil {
 		log.Errorw("Failed to instantiate legs publisher", "err", err, "kind", e.pubKind)
@@ -119,7 +145,7 @@ func (e *Engine) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("could not get latest advertisement cid: %w", err)
 	}
-	if adCid != cid.Undef {
+	if adCid != cid.Undef && e.publisher != nil {
 		if err = e.publisher.SetRoot(ctx, adCid); err != nil {
 			return err
 		}
@@ -177,6 +203,7 @@ func (e *Engine) PublishLocal(ctx context.Context, adv schema.Advertisement) (ci
 		return cid.Undef, fmt.Errorf("failed to update reference to latest advertisement: %w", err)
 	}
 	log.Info("Updated reference to the latest advertisement successfully")
+	e.emit(AdPublished{Time: time.Now(), AdCid: c, ContextID: adv.ContextID, IsRm: adv.IsRm})
 	return c, nil
 }
 
@@ -192,18 +219,33 @@ func (e *Engine) Publish(ctx context.Context, adv schema.Advertisement) (cid.Cid
 		log.Errorw("Failed to store advertisement locally", "err", err)
 		return cid.Undef, fmt.Errorf("failed to publish advertisement locally: %w", err)
 	}
+	if err := e.announce(ctx, c); err != nil {
+		return cid.Undef, err
+	}
+	return c, nil
+}
 
+// announce signals the change in the latest advertisement to indexer nodes, unless remote
+// announcements are disabled or deferred via the given context.
+//
+// See: WithDeferredAnnounce.
+func (e *Engine) announce(ctx context.Context, c cid.Cid) error {
 	// Only announce the advertisement CID if publisher is configured.
-	if e.publisher != nil {
-		log := log.With("adCid", c)
-		log.Info("Publishing advertisement in pubsub channel")
-		err = e.publisher.UpdateRoot(ctx, c)
-		if err != nil {
-			log.Errorw("Failed to announce advertisement on pubsub channel ", "err", err)
-			return cid.Undef, err
-		}
+	if e.publisher == nil {
+		return nil
 	}
-	return c, nil
+	log := log.With("adCid", c)
+	if isAnnounceDeferred(ctx) {
+		log.Debug("Deferred announcing advertisement")
+		return nil
+	}
+	log.Info("Publishing advertisement in pubsub channel")
+	if err := e.publisher.UpdateRoot(ctx, c); err != nil {
+		log.Errorw("Failed to announce advertisement on pubsub channel ", "err", err)
+		return err
+	}
+	e.emit(AdAnnounced{Time: time.Now(), AdCid: c})
+	return nil
 }
 
 // PublishLatest re-publishes the latest existing advertisement to pubsub.
@@ -214,6 +256,9 @@ func (e *Engine) PublishLatest(ctx context.Context) error {
 		return nil
 	}
 
+	// Hold the publish lock so that an older head is never announced after a newer one.
+	e.publishLk.Lock()
+	defer e.publishLk.Unlock()
 	adCid, err := e.getLatestAdCid(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get latest advertisement cid: %w", err)
@@ -225,7 +270,11 @@ func (e *Engine) PublishLatest(ctx context.Context) error {
 	}
 	log.Infow("Republishing latest advertisement", "cid", adCid)
 
-	return e.publisher.UpdateRoot(ctx, adCid)
+	if err := e.publisher.UpdateRoot(ctx, adCid); err != nil {
+		return err
+	}
+	e.emit(AdAnnounced{Time: time.Now(), AdCid: adCid})
+	return nil
 }
 
 // RegisterMultihashLister registers a provider.MultihashLister that is used to look up the
@@ -248,10 +297,16 @@ func (e *Engine) RegisterMultihashLister(mhl provider.MultihashLister) {
 // given metadata. A provider.MultihashLister is required, and is used to look up the
 // list of multihashes associated to a context ID.
 //
+// The metadata must have at least one known protocol, since metadata of which all protocols are
+// unknown does not tell how to retrieve the content.
+//
 // Note that prior to calling this function a provider.MultihashLister must be registered.
 //
-// See: Engine.RegisterMultihashLister, Engine.Publish.
+// See: Engine.RegisterMultihashLister, Engine.Publish, metadata.Metadata.ValidateKnown.
 func (e *Engine) NotifyPut(ctx context.Context, contextID []byte, md metadata.Metadata) (cid.Cid, error) {
+	if err := md.ValidateKnown(); err != nil {
+		return cid.Undef, fmt.Errorf("invalid metadata: %w", err)
+	}
 	// The multihash lister must have been registered for the linkSystem to know how to
 	// go from contextID to list of CIDs.
 	return e.publishAdvForIndex(ctx, contextID, md, false)
@@ -271,6 +326,8 @@ func (e *Engine) NotifyRemove(ctx context.Context, contextID []byte) (cid.Cid, e
 // The engine is no longer usable after the call to this function.
 func (e *Engine) Shutdown() error {
 	var errs error
+	e.stopWebhook()
+	e.events.close()
 	if e.publisher != nil {
 		if err := e.publisher.Close(); err != nil {
 			errs = multierror.Append(errs, fmt.Errorf("error closing leg publisher: %s", err))
@@ -291,7 +348,7 @@ func (e *Engine) GetAdv(_ context.Context, adCid cid.Cid) (*schema.Advertisement
 	lsys := e.vanillaLinkSystem()
 	n, err := lsys.Load(ipld.LinkContext{}, cidlink.Link{Cid: adCid}, schema.AdvertisementPrototype)
 	if err != nil {
-		return nil, fmt.Errorf("cannot load advertisement from blockstore with vanilla linksystem: %s", err)
+		return nil, fmt.Errorf("cannot load advertisement from blockstore with vanilla linksystem: %w", err)
 	}
 	return schema.UnwrapAdvertisement(n)
 }
@@ -347,12 +404,29 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, contextID []byte, md me
 			if err != nil {
 				return cid.Undef, err
 			}
+			// Index the multihashes as they are chunked if reverse index is enabled.
+			var indexer *indexingMultihashIterator
+			if e.reverseIndex {
+				if indexer, err = e.newIndexingMultihashIterator(ctx, contextID, mhIter); err != nil {
+					return cid.Undef, err
+				}
+				mhIter = indexer
+			}
 			// Generate the linked list ipld.Link that is added to the
 			// advertisement and used for ingestion.
 			lnk, err := e.entriesChunker.Chunk(ctx, mhIter)
+			e.emitEntriesGenerated(contextID, lnk, err)
 			if err != nil {
+				if indexer != nil {
+					indexer.abort()
+				}
 				return cid.Undef, fmt.Errorf("could not generate entries list: %s", err)
 			}
+			if indexer != nil {
+				if err := indexer.commit(); err != nil {
+					return cid.Undef, fmt.Errorf("failed to update reverse index: %s", err)
+				}
+			}
 			cidsLnk = lnk.(cidlink.Link)
 
 			// Store the relationship between contextID and CID of the advertised
@@ -405,6 +479,11 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, contextID []byte, md me
 		if err != nil {
 			return cid.Undef, fmt.Errorf("failed to delete context id to metadata mapping: %s", err)
 		}
+		if e.reverseIndex {
+			if err := e.unindexContextID(ctx, contextID); err != nil {
+				return cid.Undef, fmt.Errorf("failed to remove context id from reverse index: %s", err)
+			}
+		}
 
 		// Create an advertisement to delete content by contextID by specifying
 		// that advertisement has no entries.
@@ -429,6 +508,23 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, contextID []byte, md me
 		IsRm:      isRm,
 	}
 
+	// Announce while holding the lock so that announcements of concurrently published
+	// advertisements are made in the order they are linked into the chain.
+	e.publishLk.Lock()
+	defer e.publishLk.Unlock()
+	c, err = e.linkAndPublishLocal(ctx, adv)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err := e.announce(ctx, c); err != nil {
+		return cid.Undef, err
+	}
+	return c, nil
+}
+
+// linkAndPublishLocal links the given advertisement to the latest advertisement, signs it and
+// publishes it locally. The caller must hold publishLk.
+func (e *Engine) linkAndPublishLocal(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
 	// Get the previous advertisement that was generated
 	prevAdvID, err := e.getLatestAdCid(ctx)
 	if err != nil {
@@ -447,7 +543,12 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, contextID []byte, md me
 	if err := adv.Sign(e.key); err != nil {
 		return cid.Undef, err
 	}
-	return e.Publish(ctx, adv)
+	c, err := e.PublishLocal(ctx, adv)
+	if err != nil {
+		log.Errorw("Failed to store advertisement locally", "err", err)
+		return cid.Undef, fmt.Errorf("failed to publish advertisement locally: %w", err)
+	}
+	return c, nil
 }
 
 func (e *Engine) putKeyCidMap(ctx context.Context, contextID []byte, c cid.Cid) error {
@@ -457,6 +558,11 @@ func (e *Engine) putKeyCidMap(ctx context.Context, contextID []byte, c cid.Cid)
 	if err != nil {
 		return err
 	}
+	// The raw context ID is kept under a key that does not alter it, in order to list advertised
+	// context IDs, since datastore keys are cleaned as paths.
+	if err := e.ds.Put(ctx, toKeyCtxMapKey(contextID), contextID); err != nil {
+		return err
+	}
 	// And the other way around when graphsync ios making a request,
 	// so the lister in the linksystem knows to what contextID we are referring.
 	return e.ds.Put(ctx, datastore.NewKey(cidToKeyMapPrefix+c.String()), contextID)
@@ -472,9 +578,16 @@ func (e *Engine) getKeyCidMap(ctx context.Context, contextID []byte) (cid.Cid, e
 }
 
 func (e *Engine) deleteKeyCidMap(ctx context.Context, contextID []byte) error {
+	if err := e.ds.Delete(ctx, toKeyCtxMapKey(contextID)); err != nil {
+		return err
+	}
 	return e.ds.Delete(ctx, datastore.NewKey(keyToCidMapPrefix+string(contextID)))
 }
 
+func toKeyCtxMapKey(contextID []byte) datastore.Key {
+	return datastore.NewKey(keyToCtxMapPrefix + base64.RawURLEncoding.EncodeToString(contextID))
+}
+
 func (e *Engine) deleteCidKeyMap(ctx context.Context, c cid.Cid) error {
 	return e.ds.Delete(ctx, datastore.NewKey(cidToKeyMapPrefix+c.String()))
 }
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"math/rand"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -28,6 +30,8 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
 	"github.com/stretchr/testify/require"
 )
 
@@ -217,6 +221,33 @@ func TestEngine_PublishWithDataTransferPublisher(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestEngine_RestartWithoutPublisher(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+	ds := datastore.NewMapDatastore()
+	lister := func(context.Context, []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: testutil.RandomMultihashes(t, rng, 1)}, nil
+	}
+
+	subject, err := engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	subject.RegisterMultihashLister(lister)
+	wantCid, err := subject.NotifyPut(ctx, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	require.NoError(t, subject.Shutdown())
+
+	// Restarting with an existing advertisement succeeds even though there is no publisher to
+	// initialize with it.
+	subject, err = engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	gotCid, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, wantCid, gotCid)
+}
+
 func TestEngine_NotifyPutWithoutListerIsError(t *testing.T) {
 	ctx := contextWithTimeout(t)
 	subject, err := engine.New()
@@ -230,6 +261,20 @@ func TestEngine_NotifyPutWithoutListerIsError(t *testing.T) {
 	require.Equal(t, cid.Undef, gotCid)
 }
 
+func TestEngine_NotifyPutWithUnknownMetadataIsError(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	subject, err := engine.New()
+	require.NoError(t, err)
+	err = subject.Start(ctx)
+	require.NoError(t, err)
+	defer subject.Shutdown()
+
+	md := metadata.New(&metadata.Unknown{Code: multicodec.Libp2pRelayRsvp, Payload: []byte("fish")})
+	gotCid, err := subject.NotifyPut(ctx, []byte("fish"), md)
+	require.ErrorIs(t, err, metadata.ErrNoKnownProtocol)
+	require.Equal(t, cid.Undef, gotCid)
+}
+
 func TestEngine_NotifyPutThenNotifyRemove(t *testing.T) {
 	ctx := contextWithTimeout(t)
 	rng := rand.New(rand.NewSource(1413))
@@ -270,6 +315,144 @@ func TestEngine_NotifyPutThenNotifyRemove(t *testing.T) {
 	require.NotEqual(t, gotLatestAfterRmAdCid, gotLatestAdCid)
 }
 
+func TestEngine_ConcurrentNotifyPutLinksAdsInSingleChain(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	subject, err := engine.New()
+	require.NoError(t, err)
+	err = subject.Start(ctx)
+	require.NoError(t, err)
+	defer subject.Shutdown()
+
+	const count = 10
+	mhs := make(map[string][]multihash.Multihash)
+	for i := 0; i < count; i++ {
+		mhs[fmt.Sprintf("fish-%d", i)] = testutil.RandomMultihashes(t, rng, 10)
+	}
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: mhs[string(contextID)]}, nil
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for contextID := range mhs {
+		wg.Add(1)
+		go func(contextID string) {
+			defer wg.Done()
+			_, err := subject.NotifyPut(engine.WithDeferredAnnounce(ctx), []byte(contextID), metadata.New(metadata.Bitswap{}))
+			errs <- err
+		}(contextID)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// Walk the chain from the latest ad and expect to find an ad per context ID.
+	seen := make(map[string]bool)
+	adCid, ad, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	for {
+		require.False(t, seen[string(ad.ContextID)])
+		seen[string(ad.ContextID)] = true
+		if ad.PreviousID == nil {
+			break
+		}
+		adCid = (*ad.PreviousID).(cidlink.Link).Cid
+		ad, err = subject.GetAdv(ctx, adCid)
+		require.NoError(t, err)
+	}
+	require.Len(t, seen, count)
+}
+
+func TestEngine_ConcurrentNotifyPutAnnouncesLatestAdLast(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherListenAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+	err = subject.Start(ctx)
+	require.NoError(t, err)
+	defer subject.Shutdown()
+
+	const count = 10
+	mhs := make(map[string][]multihash.Multihash)
+	for i := 0; i < count; i++ {
+		mhs[fmt.Sprintf("fish-%d", i)] = testutil.RandomMultihashes(t, rng, 10)
+	}
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: mhs[string(contextID)]}, nil
+	})
+
+	events, cancel := subject.Subscribe()
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for contextID := range mhs {
+		wg.Add(1)
+		go func(contextID string) {
+			defer wg.Done()
+			_, err := subject.NotifyPut(ctx, []byte(contextID), metadata.New(metadata.Bitswap{}))
+			errs <- err
+		}(contextID)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// Expect the ads to be announced in the order they were published.
+	var published, announced []cid.Cid
+	for len(announced) < count {
+		switch ev := requireNextEvent(t, events).(type) {
+		case engine.AdPublished:
+			published = append(published, ev.AdCid)
+		case engine.AdAnnounced:
+			announced = append(announced, ev.AdCid)
+		}
+	}
+	require.Equal(t, published, announced)
+	latest, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, latest, announced[count-1])
+}
+
+func TestEngine_HttpRetrievalAddrsAreAdvertisedRegardlessOfMetadata(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	p2pAddr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/3103")
+	require.NoError(t, err)
+	httpAddr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/3105/http")
+	require.NoError(t, err)
+	subject, err := engine.New(engine.WithRetrievalAddrs(p2pAddr), engine.WithHttpRetrievalAddrs(httpAddr))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	mhs := testutil.RandomMultihashes(t, rng, 3)
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: mhs}, nil
+	})
+
+	adCid, err := subject.NotifyPut(ctx, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, []string{p2pAddr.String(), httpAddr.String()}, ad.Addresses)
+
+	adCid, err = subject.NotifyPut(ctx, []byte("fish"), metadata.New(metadata.Bitswap{}, metadata.IpfsGatewayHttp{}))
+	require.NoError(t, err)
+	ad, err = subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, []string{p2pAddr.String(), httpAddr.String()}, ad.Addresses)
+}
+
 func contextWithTimeout(t *testing.T) context.Context {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	t.Cleanup(cancel)
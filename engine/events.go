@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+)
+
+// The types of event emitted by the engine.
+const (
+	// EventTypeAdPublished is the type of AdPublished events.
+	EventTypeAdPublished EventType = "AdPublished"
+	// EventTypeAdAnnounced is the type of AdAnnounced events.
+	EventTypeAdAnnounced EventType = "AdAnnounced"
+	// EventTypeEntriesGenerated is the type of EntriesGenerated events.
+	EventTypeEntriesGenerated EventType = "EntriesGenerated"
+	// EventTypeCacheEvicted is the type of CacheEvicted events.
+	EventTypeCacheEvicted EventType = "CacheEvicted"
+	// EventTypeSyncRequested is the type of SyncRequested events.
+	EventTypeSyncRequested EventType = "SyncRequested"
+)
+
+// eventBufferSize is the number of events buffered for each subscriber. Events are dropped for
+// subscribers that fall further behind.
+const eventBufferSize = 256
+
+type (
+	// EventType represents the type of Event.
+	EventType string
+
+	// Event is an event in the lifecycle of the engine.
+	// See: Engine.Subscribe.
+	Event interface {
+		// Type returns the type of the event.
+		Type() EventType
+		// Timestamp returns the time at which the event occurred.
+		Timestamp() time.Time
+	}
+
+	// AdPublished signals that an advertisement was stored locally and became the latest
+	// advertisement.
+	AdPublished struct {
+		Time time.Time `json:"time"`
+		// AdCid is the CID of the advertisement.
+		AdCid cid.Cid `json:"ad_cid"`
+		// ContextID is the context ID of the advertisement.
+		ContextID []byte `json:"context_id"`
+		// IsRm signals whether the advertisement is a removal advertisement.
+		IsRm bool `json:"is_rm"`
+	}
+
+	// AdAnnounced signals that an advertisement was announced to indexer nodes as the latest
+	// advertisement.
+	AdAnnounced struct {
+		Time time.Time `json:"time"`
+		// AdCid is the CID of the announced advertisement.
+		AdCid cid.Cid `json:"ad_cid"`
+	}
+
+	// EntriesGenerated signals the outcome of generating the chain of entries of a context ID,
+	// either when publishing an advertisement or when regenerating entries evicted from cache
+	// upon sync.
+	EntriesGenerated struct {
+		Time time.Time `json:"time"`
+		// ContextID is the context ID whose multihashes were chunked.
+		ContextID []byte `json:"context_id"`
+		// EntriesCid is the CID of the root of the generated chain, or cid.Undef if generation
+		// failed.
+		EntriesCid cid.Cid `json:"entries_cid"`
+		// Error is the cause of failure, if generation failed.
+		Error string `json:"error,omitempty"`
+	}
+
+	// CacheEvicted signals that a chain of entries was evicted from the entries cache.
+	CacheEvicted struct {
+		Time time.Time `json:"time"`
+		// EntriesCid is the CID of the root of the evicted chain.
+		EntriesCid cid.Cid `json:"entries_cid"`
+	}
+
+	// SyncRequested signals that a peer, typically an indexer node, requested an advertisement
+	// or a chunk of entries from the engine.
+	SyncRequested struct {
+		Time time.Time `json:"time"`
+		// Cid is the CID of the requested advertisement or entries chunk.
+		Cid cid.Cid `json:"cid"`
+		// IsAd signals whether the requested CID is an advertisement.
+		IsAd bool `json:"is_ad"`
+	}
+)
+
+func (e AdPublished) Type() EventType      { return EventTypeAdPublished }
+func (e AdPublished) Timestamp() time.Time { return e.Time }
+
+func (e AdAnnounced) Type() EventType      { return EventTypeAdAnnounced }
+func (e AdAnnounced) Timestamp() time.Time { return e.Time }
+
+func (e EntriesGenerated) Type() EventType      { return EventTypeEntriesGenerated }
+func (e EntriesGenerated) Timestamp() time.Time { return e.Time }
+
+func (e CacheEvicted) Type() EventType      { return EventTypeCacheEvicted }
+func (e CacheEvicted) Timestamp() time.Time { return e.Time }
+
+func (e SyncRequested) Type() EventType      { return EventTypeSyncRequested }
+func (e SyncRequested) Timestamp() time.Time { return e.Time }
+
+// eventBus delivers the events emitted by the engine to subscribers. Events are delivered without
+// blocking the engine; events are dropped for subscribers whose buffer is full.
+type eventBus struct {
+	lk sync.RWMutex
+	// subs maps the channel of each subscriber to its name, used to log dropped events.
+	subs map[chan Event]string
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subs: make(map[chan Event]string),
+	}
+}
+
+func (b *eventBus) subscribe(name string) (<-chan Event, context.CancelFunc) {
+	ch := make(chan Event, eventBufferSize)
+	b.lk.Lock()
+	b.subs[ch] = name
+	b.lk.Unlock()
+	return ch, func() {
+		b.lk.Lock()
+		defer b.lk.Unlock()
+		// The channel is already closed if the bus is closed or the subscription cancelled.
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *eventBus) emit(ev Event) {
+	b.lk.RLock()
+	defer b.lk.RUnlock()
+	for ch, name := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			log.Warnw("Dropped event for slow subscriber; buffer is full", "subscriber", name, "type", ev.Type(), "bufferSize", eventBufferSize)
+		}
+	}
+}
+
+// close closes the channels of all subscribers.
+func (b *eventBus) close() {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Subscribe subscribes to the events emitted by the engine. Events are delivered on the returned
+// channel in the order in which they occurred, until the returned function is called or the
+// engine is shut down, at which point the channel is closed.
+//
+// Events are never delivered in a way that blocks the engine: the channel is buffered, and events
+// are dropped if the subscriber falls too far behind.
+func (e *Engine) Subscribe() (<-chan Event, context.CancelFunc) {
+	return e.events.subscribe("subscriber")
+}
+
+func (e *Engine) emit(ev Event) {
+	e.events.emit(ev)
+}
+
+// emitEntriesGenerated emits an EntriesGenerated event for the outcome of chunking the
+// multihashes of the given context ID.
+func (e *Engine) emitEntriesGenerated(contextID []byte, lnk ipld.Link, err error) {
+	ev := EntriesGenerated{Time: time.Now(), ContextID: contextID, EntriesCid: cid.Undef}
+	if err != nil {
+		ev.Error = err.Error()
+	} else {
+		ev.EntriesCid = lnk.(cidlink.Link).Cid
+	}
+	e.emit(ev)
+}
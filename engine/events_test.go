@@ -0,0 +1,194 @@
+package engine_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_SubscribeReceivesEvents(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	mhs := map[string][]multihash.Multihash{
+		"fish":    testutil.RandomMultihashes(t, rng, 3),
+		"lobster": testutil.RandomMultihashes(t, rng, 3),
+	}
+	// Cache a single chain of entries so that advertising a second context ID evicts the first.
+	subject, err := engine.New(engine.WithEntriesCacheCapacity(1))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: mhs[string(contextID)]}, nil
+	})
+
+	events, cancel := subject.Subscribe()
+	defer cancel()
+
+	adCid, err := subject.NotifyPut(ctx, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	generated := requireNextEvent(t, events).(engine.EntriesGenerated)
+	require.Equal(t, []byte("fish"), generated.ContextID)
+	require.NotEqual(t, cid.Undef, generated.EntriesCid)
+	require.Empty(t, generated.Error)
+	require.False(t, generated.Time.IsZero())
+
+	published := requireNextEvent(t, events).(engine.AdPublished)
+	require.Equal(t, adCid, published.AdCid)
+	require.Equal(t, []byte("fish"), published.ContextID)
+	require.False(t, published.IsRm)
+
+	_, err = subject.NotifyPut(ctx, []byte("lobster"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	// The chain of the first context ID is evicted while the second one is generated.
+	evicted := requireNextEvent(t, events).(engine.CacheEvicted)
+	require.Equal(t, generated.EntriesCid, evicted.EntriesCid)
+	require.Equal(t, engine.EventTypeEntriesGenerated, requireNextEvent(t, events).Type())
+	require.Equal(t, engine.EventTypeAdPublished, requireNextEvent(t, events).Type())
+
+	// No events are delivered once the subscription is cancelled.
+	cancel()
+	_, open := <-events
+	require.False(t, open)
+}
+
+func TestEngine_SyncRequestedIsEmittedOnLoad(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	subject, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	mhs := testutil.RandomMultihashes(t, rng, 3)
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: mhs}, nil
+	})
+	adCid, err := subject.NotifyPut(ctx, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	events, cancel := subject.Subscribe()
+	defer cancel()
+
+	n, err := subject.LinkSystem().Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: adCid}, schema.AdvertisementPrototype)
+	require.NoError(t, err)
+	requested := requireNextEvent(t, events).(engine.SyncRequested)
+	require.Equal(t, adCid, requested.Cid)
+	require.True(t, requested.IsAd)
+
+	ad, err := schema.UnwrapAdvertisement(n)
+	require.NoError(t, err)
+	_, err = subject.LinkSystem().Load(ipld.LinkContext{Ctx: ctx}, ad.Entries, schema.EntryChunkPrototype)
+	require.NoError(t, err)
+	requested = requireNextEvent(t, events).(engine.SyncRequested)
+	require.Equal(t, ad.Entries.(cidlink.Link).Cid, requested.Cid)
+	require.False(t, requested.IsAd)
+}
+
+func TestEngine_ShutdownClosesSubscriptions(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	subject, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+
+	events, cancel := subject.Subscribe()
+	require.NoError(t, subject.Shutdown())
+	_, open := <-events
+	require.False(t, open)
+	// Cancelling after shutdown is a no-op.
+	cancel()
+}
+
+func TestEngine_EventWebhookRetriesFailedDeliveries(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	var (
+		lk       sync.Mutex
+		attempts int
+		bodies   = make(chan []byte, 10)
+	)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lk.Lock()
+		attempts++
+		fail := attempts <= 2
+		lk.Unlock()
+		if fail {
+			http.Error(w, "try again later", http.StatusServiceUnavailable)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		bodies <- body
+	}))
+	defer webhook.Close()
+
+	subject, err := engine.New(engine.WithEventWebhook(webhook.URL, 2, 10*time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	mhs := testutil.RandomMultihashes(t, rng, 3)
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: mhs}, nil
+	})
+
+	_, err = subject.NotifyPut(ctx, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	// The first event is delivered on the third attempt, i.e. after two retries.
+	var got struct {
+		Type  engine.EventType `json:"type"`
+		Event json.RawMessage  `json:"event"`
+	}
+	select {
+	case body := <-bodies:
+		require.NoError(t, json.Unmarshal(body, &got))
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for webhook delivery")
+	}
+	require.Equal(t, engine.EventTypeEntriesGenerated, got.Type)
+	var generated engine.EntriesGenerated
+	require.NoError(t, json.Unmarshal(got.Event, &generated))
+	require.Equal(t, []byte("fish"), generated.ContextID)
+	lk.Lock()
+	require.Equal(t, 3, attempts)
+	lk.Unlock()
+}
+
+func TestEventWebhookRejectsInvalidRetries(t *testing.T) {
+	_, err := engine.New(engine.WithEventWebhook("http://localhost", -1, time.Second))
+	require.Error(t, err)
+	_, err = engine.New(engine.WithEventWebhook("http://localhost", 1, 0))
+	require.Error(t, err)
+}
+
+func requireNextEvent(t *testing.T, events <-chan engine.Event) engine.Event {
+	select {
+	case ev, ok := <-events:
+		require.True(t, ok)
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return nil
+	}
+}
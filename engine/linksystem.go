@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"time"
 
 	provider "github.com/filecoin-project/index-provider"
 	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
@@ -48,6 +49,7 @@ func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 			// If this was an advertisement, then return it.
 			if isAdvertisement(n) {
 				log.Infow("Retrieved advertisement from datastore", "cid", c, "size", len(val))
+				e.emit(SyncRequested{Time: time.Now(), Cid: c, IsAd: true})
 				return bytes.NewBuffer(val), nil
 			}
 			log.Infow("Retrieved non-advertisement object from datastore", "cid", c, "size", len(val))
@@ -103,7 +105,8 @@ func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 			// Store the linked list entries in cache as we generate them.  We
 			// use the cache linksystem that stores entries in an in-memory
 			// datastore.
-			_, err = e.entriesChunker.Chunk(ctx, mhIter)
+			lnk, err := e.entriesChunker.Chunk(ctx, mhIter)
+			e.emitEntriesGenerated(key, lnk, err)
 			if err != nil {
 				log.Errorf("Error generating linked list from multihash lister: %s", err)
 				return nil, err
@@ -125,6 +128,7 @@ func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 			log.Errorf("No object found in linksystem for CID (%s)", c)
 			return nil, datastore.ErrNotFound
 		}
+		e.emit(SyncRequested{Time: time.Now(), Cid: c})
 
 		return bytes.NewBuffer(val), nil
 	}
@@ -2,6 +2,8 @@ package engine
 
 import (
 	"fmt"
+	"net/http"
+	"time"
 
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/ipfs/go-datastore"
@@ -43,8 +45,9 @@ type (
 		// Setting an explicit identity must not be exposed unless it is tightly coupled with the
 		// host identity. Otherwise, the signature of advertisement will not match the libp2p host
 		// ID.
-		key            crypto.PrivKey
-		retrievalAddrs []multiaddr.Multiaddr
+		key                crypto.PrivKey
+		retrievalAddrs     []multiaddr.Multiaddr
+		httpRetrievalAddrs []multiaddr.Multiaddr
 
 		pubKind            PublisherKind
 		pubDT              datatransfer.Manager
@@ -56,6 +59,13 @@ type (
 		entCacheCap  int
 		entChunkSize int
 		purgeCache   bool
+
+		reverseIndex bool
+
+		webhookURL           string
+		webhookClient        *http.Client
+		webhookMaxRetries    int
+		webhookRetryInterval time.Duration
 	}
 )
 
@@ -69,6 +79,10 @@ func newOptions(o ...Option) (*options, error) {
 		// Multihashes are 128 bytes so 16384 results in 0.25MiB chunk when full.
 		entChunkSize: 16384,
 		purgeCache:   false,
+
+		webhookClient:        &http.Client{Timeout: defaultWebhookTimeout},
+		webhookMaxRetries:    3,
+		webhookRetryInterval: time.Second,
 	}
 
 	for _, apply := range o {
@@ -110,6 +124,11 @@ func (o *options) retrievalAddrsAsString() []string {
 	for _, ra := range o.retrievalAddrs {
 		ras = append(ras, ra.String())
 	}
+	// Advertise the HTTP retrieval addresses regardless of metadata, since indexers use the
+	// addresses of the latest advertisement for all content of the provider.
+	for _, ha := range o.httpRetrievalAddrs {
+		ras = append(ras, ha.String())
+	}
 	return ras
 }
 
@@ -124,6 +143,21 @@ func WithPurgeCacheOnStart(p bool) Option {
 	}
 }
 
+// WithReverseIndex sets whether to maintain a local index that maps each advertised multihash to
+// the context IDs that contain it. The index is updated by Engine.NotifyPut and Engine.NotifyRemove
+// and allows Engine.FindContextIDs to look up context IDs without listing the multihashes of
+// every advertised context ID.
+//
+// Enabling the index on a datastore with previously advertised context IDs requires a one-off
+// Engine.RebuildReverseIndex for those context IDs to be found.
+// If unset, no reverse index is maintained.
+func WithReverseIndex(enable bool) Option {
+	return func(o *options) error {
+		o.reverseIndex = enable
+		return nil
+	}
+}
+
 // WithEntriesChunkSize sets the maximum number of multihashes to include in a single entries chunk.
 // If unset, the default size of 16384 is used.
 //
@@ -243,6 +277,20 @@ func WithRetrievalAddrs(addr ...multiaddr.Multiaddr) Option {
 	}
 }
 
+// WithHttpRetrievalAddrs sets the addresses of the HTTP server from which content is retrievable
+// via the IPFS trustless gateway protocol, e.g. "/ip4/1.2.3.4/tcp/3105/http". The addresses are
+// advertised in addition to the retrieval addresses in every advertisement, since indexers use the
+// addresses of the latest advertisement for all content of the provider. Content is retrievable
+// from them if its metadata contains metadata.IpfsGatewayHttp.
+// If unset, no HTTP retrieval addresses are advertised.
+// See: WithRetrievalAddrs.
+func WithHttpRetrievalAddrs(addr ...multiaddr.Multiaddr) Option {
+	return func(o *options) error {
+		o.httpRetrievalAddrs = addr
+		return nil
+	}
+}
+
 // WithExtraGossipData supplies extra data to include in the pubsub announcement.
 // Note that this option only takes effect if the PublisherKind is set to DataTransferPublisher.
 // See: WithPublisherKind.
@@ -256,3 +304,38 @@ func WithExtraGossipData(extraData []byte) Option {
 		return nil
 	}
 }
+
+// WithEventWebhook sets the URL to which the events emitted by the engine are delivered as HTTP
+// POST requests. Each request body is a JSON object with the event type and the event itself,
+// e.g. {"type":"AdPublished","event":{...}}.
+//
+// Failed deliveries, i.e. requests that fail or respond with a non-2xx status, are retried up to
+// maxRetries times, doubling the wait between attempts starting from retryInterval. Events that
+// cannot be delivered after all retries are dropped, as are events emitted while delivery lags
+// behind by more than 256 events; dropped events are logged.
+// If unset, events are not delivered to any webhook.
+// See: Engine.Subscribe.
+func WithEventWebhook(url string, maxRetries int, retryInterval time.Duration) Option {
+	return func(o *options) error {
+		if maxRetries < 0 {
+			return fmt.Errorf("webhook max retries must not be negative; got %d", maxRetries)
+		}
+		if retryInterval <= 0 {
+			return fmt.Errorf("webhook retry interval must be positive; got %s", retryInterval)
+		}
+		o.webhookURL = url
+		o.webhookMaxRetries = maxRetries
+		o.webhookRetryInterval = retryInterval
+		return nil
+	}
+}
+
+// WithEventWebhookClient sets the HTTP client used to deliver events to the webhook.
+// If unset, a client with a request timeout of 10 seconds is used.
+// See: WithEventWebhook.
+func WithEventWebhookClient(c *http.Client) Option {
+	return func(o *options) error {
+		o.webhookClient = c
+		return nil
+	}
+}
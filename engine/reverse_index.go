@@ -0,0 +1,281 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	// mhToKeyIndexPrefix is the prefix of the reverse index keys, which map a multihash to the
+	// context IDs that contain it: map/mhKey/<multihash>/<context-id> -> context ID.
+	mhToKeyIndexPrefix = "map/mhKey/"
+	// keyToMhIndexPrefix is the prefix of the keys that list the indexed multihashes of each
+	// context ID, used to remove them from the reverse index: map/keyMh/<context-id>/<multihash>.
+	keyToMhIndexPrefix = "map/keyMh/"
+
+	// reverseIndexBatchSize is the maximum number of reverse index writes per datastore batch.
+	reverseIndexBatchSize = 4096
+)
+
+// ErrReverseIndexDisabled signals that the engine does not maintain a reverse index.
+// See: WithReverseIndex.
+var ErrReverseIndexDisabled = errors.New("reverse index is disabled")
+
+var _ provider.MultihashIterator = (*indexingMultihashIterator)(nil)
+
+// indexingMultihashIterator adds the multihashes returned by the wrapped iterator to the reverse
+// index as they are iterated over. The writes are batched; commit must be called once iteration
+// completes successfully, and abort otherwise.
+type indexingMultihashIterator struct {
+	provider.MultihashIterator
+	e         *Engine
+	ctx       context.Context
+	contextID []byte
+	batch     datastore.Batch
+	pending   int
+}
+
+func (e *Engine) newIndexingMultihashIterator(ctx context.Context, contextID []byte, mhIter provider.MultihashIterator) (*indexingMultihashIterator, error) {
+	batch, err := e.ds.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &indexingMultihashIterator{
+		MultihashIterator: mhIter,
+		e:                 e,
+		ctx:               ctx,
+		contextID:         contextID,
+		batch:             batch,
+	}, nil
+}
+
+func (i *indexingMultihashIterator) Next() (multihash.Multihash, error) {
+	mh, err := i.MultihashIterator.Next()
+	if err != nil {
+		return nil, err
+	}
+	if err := putReverseIndex(i.ctx, i.batch, i.contextID, mh); err != nil {
+		return nil, fmt.Errorf("failed to update reverse index: %w", err)
+	}
+	i.pending++
+	if i.pending >= reverseIndexBatchSize {
+		if err := i.commit(); err != nil {
+			return nil, fmt.Errorf("failed to update reverse index: %w", err)
+		}
+		if i.batch, err = i.e.ds.Batch(i.ctx); err != nil {
+			return nil, err
+		}
+	}
+	return mh, nil
+}
+
+func (i *indexingMultihashIterator) commit() error {
+	i.pending = 0
+	return i.batch.Commit(i.ctx)
+}
+
+// abort removes the multihashes indexed so far.
+func (i *indexingMultihashIterator) abort() {
+	if err := i.e.unindexContextID(i.ctx, i.contextID); err != nil {
+		log.Errorw("Failed to remove partially indexed context ID from reverse index", "err", err)
+	}
+}
+
+func putReverseIndex(ctx context.Context, w datastore.Write, contextID []byte, mh multihash.Multihash) error {
+	encContextID := base64.RawURLEncoding.EncodeToString(contextID)
+	encMh := mh.B58String()
+	if err := w.Put(ctx, datastore.NewKey(mhToKeyIndexPrefix+encMh+"/"+encContextID), contextID); err != nil {
+		return err
+	}
+	return w.Put(ctx, datastore.NewKey(keyToMhIndexPrefix+encContextID+"/"+encMh), []byte{})
+}
+
+// unindexContextID removes the multihashes of the given context ID from the reverse index.
+func (e *Engine) unindexContextID(ctx context.Context, contextID []byte) error {
+	encContextID := base64.RawURLEncoding.EncodeToString(contextID)
+	prefix := datastore.NewKey(keyToMhIndexPrefix+encContextID).String() + "/"
+	results, err := e.ds.Query(ctx, query.Query{
+		Prefix:   prefix,
+		KeysOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	var encMhs []string
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return r.Error
+		}
+		encMhs = append(encMhs, strings.TrimPrefix(r.Key, prefix))
+	}
+	results.Close()
+
+	batch, err := e.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for i, encMh := range encMhs {
+		if err := batch.Delete(ctx, datastore.NewKey(mhToKeyIndexPrefix+encMh+"/"+encContextID)); err != nil {
+			return err
+		}
+		if err := batch.Delete(ctx, datastore.NewKey(prefix+encMh)); err != nil {
+			return err
+		}
+		if (i+1)%reverseIndexBatchSize == 0 {
+			if err := batch.Commit(ctx); err != nil {
+				return err
+			}
+			if batch, err = e.ds.Batch(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return batch.Commit(ctx)
+}
+
+// ReverseIndexEnabled checks whether the engine maintains a reverse index, in which case
+// FindContextIDs looks up the index instead of listing the multihashes of every context ID.
+//
+// See: WithReverseIndex.
+func (e *Engine) ReverseIndexEnabled() bool {
+	return e.reverseIndex
+}
+
+// lookupReverseIndex looks up the context IDs that contain the given multihash in the reverse
+// index.
+func (e *Engine) lookupReverseIndex(ctx context.Context, mh multihash.Multihash) ([][]byte, error) {
+	results, err := e.ds.Query(ctx, query.Query{
+		Prefix: mhToKeyIndexPrefix + mh.B58String() + "/",
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var contextIDs [][]byte
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		contextIDs = append(contextIDs, r.Value)
+	}
+	return contextIDs, nil
+}
+
+// RebuildReverseIndex rebuilds the reverse index from scratch by listing the multihashes of every
+// advertised context ID via the registered provider.MultihashLister. Context IDs whose multihashes
+// fail to be listed are skipped.
+//
+// The rebuild is needed when the reverse index is enabled on a datastore with previously
+// advertised context IDs. ErrReverseIndexDisabled is returned if the reverse index is not enabled.
+//
+// See: WithReverseIndex.
+func (e *Engine) RebuildReverseIndex(ctx context.Context) error {
+	if !e.reverseIndex {
+		return ErrReverseIndexDisabled
+	}
+	e.cblk.Lock()
+	mhLister := e.mhLister
+	e.cblk.Unlock()
+	if mhLister == nil {
+		return provider.ErrNoMultihashLister
+	}
+
+	for _, prefix := range []string{mhToKeyIndexPrefix, keyToMhIndexPrefix} {
+		if err := e.deletePrefix(ctx, prefix); err != nil {
+			return fmt.Errorf("failed to clear reverse index: %w", err)
+		}
+	}
+
+	contextIDs, err := e.ListContextIDs(ctx, 0, 0)
+	if err != nil {
+		return err
+	}
+	log.Infow("Rebuilding reverse index", "contextIDs", len(contextIDs))
+	for _, contextID := range contextIDs {
+		if err := e.indexContextID(ctx, mhLister, contextID); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Warnw("Failed to index context ID", "contextID", base64.StdEncoding.EncodeToString(contextID), "err", err)
+			continue
+		}
+		// Undo the indexing if the context ID was removed while its multihashes were listed.
+		if _, err := e.getKeyCidMap(ctx, contextID); err == datastore.ErrNotFound {
+			if err := e.unindexContextID(ctx, contextID); err != nil {
+				return err
+			}
+		}
+	}
+	log.Info("Rebuilt reverse index")
+	return nil
+}
+
+func (e *Engine) indexContextID(ctx context.Context, mhLister provider.MultihashLister, contextID []byte) error {
+	mhIter, err := mhLister(ctx, contextID)
+	if err != nil {
+		return err
+	}
+	indexer, err := e.newIndexingMultihashIterator(ctx, contextID, mhIter)
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := indexer.Next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return indexer.commit()
+			}
+			indexer.abort()
+			return err
+		}
+	}
+}
+
+func (e *Engine) deletePrefix(ctx context.Context, prefix string) error {
+	results, err := e.ds.Query(ctx, query.Query{
+		Prefix:   prefix,
+		KeysOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return r.Error
+		}
+		keys = append(keys, r.Key)
+	}
+	results.Close()
+
+	batch, err := e.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if err := batch.Delete(ctx, datastore.NewKey(key)); err != nil {
+			return err
+		}
+		if (i+1)%reverseIndexBatchSize == 0 {
+			if err := batch.Commit(ctx); err != nil {
+				return err
+			}
+			if batch, err = e.ds.Batch(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return batch.Commit(ctx)
+}
@@ -0,0 +1,101 @@
+package engine_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_ReverseIndexIsMaintainedOnPutAndRemove(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	shared := testutil.RandomMultihashes(t, rng, 1)[0]
+	mhs := map[string][]multihash.Multihash{
+		"fish":    append(testutil.RandomMultihashes(t, rng, 10), shared),
+		"lobster": append(testutil.RandomMultihashes(t, rng, 10), shared),
+	}
+	subject, err := engine.New(engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: mhs[string(contextID)]}, nil
+	})
+
+	for contextID := range mhs {
+		_, err := subject.NotifyPut(ctx, []byte(contextID), metadata.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+	requireContextIDs(t, subject, mhs["fish"][0], "fish")
+	requireContextIDs(t, subject, mhs["lobster"][0], "lobster")
+	requireContextIDs(t, subject, shared, "fish", "lobster")
+
+	// Listing multihashes is no longer needed once indexed.
+	subject.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		t.Fatal("multihashes must be looked up in reverse index")
+		return nil, nil
+	})
+	_, err = subject.NotifyRemove(ctx, []byte("fish"))
+	require.NoError(t, err)
+	requireContextIDs(t, subject, mhs["fish"][0])
+	requireContextIDs(t, subject, mhs["lobster"][0], "lobster")
+	requireContextIDs(t, subject, shared, "lobster")
+}
+
+func TestEngine_RebuildReverseIndex(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	mhs := map[string][]multihash.Multihash{
+		"fish":    testutil.RandomMultihashes(t, rng, 10),
+		"lobster": testutil.RandomMultihashes(t, rng, 10),
+	}
+	lister := func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		return &sliceMhIterator{mhs: mhs[string(contextID)]}, nil
+	}
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	// Advertise with reverse index disabled.
+	withoutIndex, err := engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, withoutIndex.Start(ctx))
+	withoutIndex.RegisterMultihashLister(lister)
+	for contextID := range mhs {
+		_, err := withoutIndex.NotifyPut(ctx, []byte(contextID), metadata.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+	require.ErrorIs(t, withoutIndex.RebuildReverseIndex(ctx), engine.ErrReverseIndexDisabled)
+	require.NoError(t, withoutIndex.Shutdown())
+
+	subject, err := engine.New(engine.WithDatastore(ds), engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(lister)
+
+	// Previously advertised context IDs are not indexed until rebuilt.
+	requireContextIDs(t, subject, mhs["fish"][0])
+	require.NoError(t, subject.RebuildReverseIndex(ctx))
+	requireContextIDs(t, subject, mhs["fish"][0], "fish")
+	requireContextIDs(t, subject, mhs["lobster"][9], "lobster")
+}
+
+func requireContextIDs(t *testing.T, e *engine.Engine, mh multihash.Multihash, want ...string) {
+	got, err := e.FindContextIDs(context.Background(), mh)
+	require.NoError(t, err)
+	var gotStrs []string
+	for _, contextID := range got {
+		gotStrs = append(gotStrs, string(contextID))
+	}
+	require.ElementsMatch(t, want, gotStrs)
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// defaultWebhookTimeout is the timeout of requests made to the events webhook by the default
+// client.
+const defaultWebhookTimeout = 10 * time.Second
+
+// webhookEnvelope is the body of requests made to the events webhook.
+type webhookEnvelope struct {
+	Type  EventType `json:"type"`
+	Event Event     `json:"event"`
+}
+
+// startWebhook subscribes to the engine events and delivers them to the configured webhook in the
+// background until stopWebhook is called or the engine is shut down. Events emitted while the
+// delivery falls behind by more than the event buffer size are dropped and logged.
+func (e *Engine) startWebhook() {
+	events, cancelSub := e.events.subscribe("webhook")
+	ctx, cancel := context.WithCancel(context.Background())
+	e.webhookCancel = cancel
+	e.webhookDone = make(chan struct{})
+	go func() {
+		defer close(e.webhookDone)
+		defer cancelSub()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := e.deliverToWebhook(ctx, ev); err != nil && ctx.Err() == nil {
+					log.Errorw("Failed to deliver event to webhook; dropping event", "type", ev.Type(), "err", err)
+				}
+			}
+		}
+	}()
+	log.Infow("Delivering events to webhook", "url", e.webhookURL)
+}
+
+func (e *Engine) stopWebhook() {
+	if e.webhookCancel == nil {
+		return
+	}
+	e.webhookCancel()
+	<-e.webhookDone
+	e.webhookCancel = nil
+}
+
+// deliverToWebhook posts the given event to the webhook, retrying with exponential backoff on
+// failure.
+func (e *Engine) deliverToWebhook(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(webhookEnvelope{Type: ev.Type(), Event: ev})
+	if err != nil {
+		return err
+	}
+	wait := e.webhookRetryInterval
+	for attempt := 0; ; attempt++ {
+		err = e.postToWebhook(ctx, body)
+		if err == nil || attempt >= e.webhookMaxRetries {
+			return err
+		}
+		log.Warnw("Failed to deliver event to webhook; retrying", "type", ev.Type(), "attempt", attempt+1, "wait", wait, "err", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+func (e *Engine) postToWebhook(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so that the connection can be reused.
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -4,6 +4,10 @@
 // multihashes advertised by a provider. It is represented as an array of bytes in the indexer
 // protocol, starting with a varint ProtocolID that defines how to decode the remaining bytes.
 //
-// Two metadata types are currently represented here: Bitswap and
-// GraphsyncFilecoinV1.
+// Three metadata types are currently represented here: Bitswap, GraphsyncFilecoinV1 and
+// IpfsGatewayHttp. Applications may add their own types via Register. Types that are not
+// registered are decoded as Unknown, which preserves their bytes.
+//
+// Metadata also has a human-readable JSON representation, keyed by protocol name.
+// See: Metadata.MarshalJSON.
 package metadata
@@ -0,0 +1,49 @@
+//go:build go1.18
+// +build go1.18
+
+package metadata_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/multiformats/go-varint"
+	"github.com/stretchr/testify/require"
+)
+
+// FuzzMetadata_UnmarshalBinary checks that decoding arbitrary bytes terminates, and that decoded
+// metadata re-encodes to metadata that decodes identically.
+//
+// The seed corpus is in testdata/fuzz/FuzzMetadata_UnmarshalBinary, in addition to the seeds
+// added below.
+func FuzzMetadata_UnmarshalBinary(f *testing.F) {
+	rng := rand.New(rand.NewSource(1413))
+	pieceCid := testutil.RandomPieceCids(f, rng, 1)[0]
+	for _, seed := range []metadata.Metadata{
+		metadata.New(&metadata.Bitswap{}),
+		metadata.New(&metadata.IpfsGatewayHttp{}),
+		metadata.New(&metadata.GraphsyncFilecoinV1{PieceCID: pieceCid, FastRetrieval: true}),
+		metadata.New(&metadata.Bitswap{}, &metadata.GraphsyncFilecoinV1{PieceCID: pieceCid}, &metadata.IpfsGatewayHttp{}),
+	} {
+		data, err := seed.MarshalBinary()
+		require.NoError(f, err)
+		f.Add(data)
+	}
+	f.Add([]byte{})
+	f.Add(varint.ToUvarint(123456))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var subject metadata.Metadata
+		if err := subject.UnmarshalBinary(data); err != nil {
+			return
+		}
+
+		reencoded, err := subject.MarshalBinary()
+		require.NoError(t, err)
+		var again metadata.Metadata
+		require.NoError(t, again.UnmarshalBinary(reencoded))
+		require.True(t, subject.Equal(again))
+	})
+}
@@ -0,0 +1,26 @@
+//go:build go1.18
+// +build go1.18
+
+package metadata
+
+// Find returns the first protocol of type T in the given Metadata, for example:
+//
+//	gs, ok := metadata.Find[*metadata.GraphsyncFilecoinV1](md)
+//
+// The returned bool is false if the Metadata contains no protocol of type T.
+func Find[T Protocol](m Metadata) (T, bool) {
+	for _, p := range m.protocols {
+		if t, ok := p.(T); ok {
+			return t, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Contains checks whether the given Metadata contains a protocol of type T.
+// See: Find.
+func Contains[T Protocol](m Metadata) bool {
+	_, ok := Find[T](m)
+	return ok
+}
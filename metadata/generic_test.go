@@ -0,0 +1,29 @@
+//go:build go1.18
+// +build go1.18
+
+package metadata_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	pieceCid := testutil.RandomPieceCids(t, rand.New(rand.NewSource(1413)), 1)[0]
+	gs := &metadata.GraphsyncFilecoinV1{PieceCID: pieceCid, FastRetrieval: true}
+	subject := metadata.New(&metadata.Bitswap{}, gs)
+
+	gotGs, ok := metadata.Find[*metadata.GraphsyncFilecoinV1](subject)
+	require.True(t, ok)
+	require.Same(t, gs, gotGs)
+	require.True(t, metadata.Contains[*metadata.Bitswap](subject))
+
+	gotHttp, ok := metadata.Find[*metadata.IpfsGatewayHttp](subject)
+	require.False(t, ok)
+	require.Nil(t, gotHttp)
+	require.False(t, metadata.Contains[*metadata.IpfsGatewayHttp](subject))
+}
@@ -6,7 +6,9 @@ import (
 
 	"github.com/filecoin-project/index-provider/metadata"
 	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-cid"
 	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
 	"github.com/multiformats/go-varint"
 	"github.com/stretchr/testify/require"
 )
@@ -54,3 +56,56 @@ func TestGraphsyncFilecoinV1Metadata_FromIndexerMetadataErr(t *testing.T) {
 	err := dst.UnmarshalBinary(varint.ToUvarint(uint64(multicodec.TransportBitswap)))
 	require.Errorf(t, err, "invalid transport ID: transport-bitswap")
 }
+
+func TestGraphsyncFilecoinV1_Validate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	contextIDPieceCid, err := cid.Prefix{
+		Version:  1,
+		Codec:    uint64(multicodec.TransportGraphsyncFilecoinv1),
+		MhType:   multihash.IDENTITY,
+		MhLength: -1,
+	}.Sum([]byte("fish"))
+	require.NoError(t, err)
+	sha256Mh, err := multihash.Sum([]byte("fish"), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		pieceCid cid.Cid
+		wantErr  string
+	}{
+		{
+			name:     "Piece commitment is valid",
+			pieceCid: testutil.RandomPieceCids(t, rng, 1)[0],
+		},
+		{
+			name:     "Context ID is valid",
+			pieceCid: contextIDPieceCid,
+		},
+		{
+			name:    "Undefined is invalid",
+			wantErr: "transport-graphsync-filecoinv1: piece CID must be specified",
+		},
+		{
+			name:     "Piece commitment with other multihash is invalid",
+			pieceCid: cid.NewCidV1(uint64(multicodec.FilCommitmentUnsealed), sha256Mh),
+			wantErr:  "transport-graphsync-filecoinv1: piece CID multihash must be sha2-256-trunc254-padded; got sha2-256",
+		},
+		{
+			name:     "Context ID with other multihash is invalid",
+			pieceCid: cid.NewCidV1(uint64(multicodec.TransportGraphsyncFilecoinv1), sha256Mh),
+			wantErr:  "transport-graphsync-filecoinv1: context ID piece CID multihash must be identity; got sha2-256",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject := &metadata.GraphsyncFilecoinV1{PieceCID: test.pieceCid}
+			err := subject.Validate()
+			if test.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-varint"
+)
+
+// TransportIpfsGatewayHttp is the multicodec code of the HTTP transport that serves content
+// following the IPFS trustless gateway specification.
+//
+// The code is defined here because it is not yet part of the multicodec table of the
+// go-multicodec version in use.
+const TransportIpfsGatewayHttp = multicodec.Code(0x0920)
+
+var (
+	ipfsGatewayHttpBytes          = varint.ToUvarint(uint64(TransportIpfsGatewayHttp))
+	_                    Protocol = (*IpfsGatewayHttp)(nil)
+)
+
+// IpfsGatewayHttp represents the indexing metadata that uses TransportIpfsGatewayHttp, i.e.
+// content that is retrievable as blocks or CAR files over HTTP at the trustless gateway paths of
+// the provider's HTTP retrieval addresses.
+// See: https://specs.ipfs.tech/http-gateways/trustless-gateway/
+type IpfsGatewayHttp struct {
+}
+
+func (g IpfsGatewayHttp) ID() multicodec.Code {
+	return TransportIpfsGatewayHttp
+}
+
+func (g IpfsGatewayHttp) MarshalBinary() ([]byte, error) {
+	return ipfsGatewayHttpBytes, nil
+}
+
+func (g IpfsGatewayHttp) UnmarshalBinary(data []byte) error {
+	if !bytes.Equal(data, ipfsGatewayHttpBytes) {
+		return fmt.Errorf("transport ID does not match %s", TransportIpfsGatewayHttp)
+	}
+	return nil
+}
+
+func (g IpfsGatewayHttp) ReadFrom(r io.Reader) (n int64, err error) {
+	wantLen := len(ipfsGatewayHttpBytes)
+	buf := make([]byte, wantLen)
+	read, err := io.ReadFull(r, buf)
+	bRead := int64(read)
+	if err != nil {
+		return bRead, err
+	}
+	if !bytes.Equal(ipfsGatewayHttpBytes, buf) {
+		return bRead, fmt.Errorf("transport ID does not match %s", TransportIpfsGatewayHttp)
+	}
+	return bRead, nil
+}
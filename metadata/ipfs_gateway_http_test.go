@@ -0,0 +1,42 @@
+package metadata_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-varint"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIpfsGatewayHttp(t *testing.T) {
+	wantBytes := varint.ToUvarint(uint64(metadata.TransportIpfsGatewayHttp))
+
+	var subject metadata.IpfsGatewayHttp
+	require.Equal(t, metadata.TransportIpfsGatewayHttp, subject.ID())
+
+	gotBytes, err := subject.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, wantBytes, gotBytes)
+	require.NoError(t, subject.UnmarshalBinary(gotBytes))
+
+	read, err := subject.ReadFrom(bytes.NewReader(gotBytes))
+	require.NoError(t, err)
+	require.Equal(t, int64(len(wantBytes)), read)
+
+	err = subject.UnmarshalBinary(varint.ToUvarint(uint64(multicodec.TransportBitswap)))
+	require.EqualError(t, err, "transport ID does not match Code(2336)")
+}
+
+func TestIpfsGatewayHttpIsRegistered(t *testing.T) {
+	require.True(t, metadata.IsRegistered(metadata.TransportIpfsGatewayHttp))
+
+	subject := metadata.New(&metadata.IpfsGatewayHttp{}, &metadata.Bitswap{})
+	data, err := subject.MarshalBinary()
+	require.NoError(t, err)
+
+	var got metadata.Metadata
+	require.NoError(t, got.UnmarshalBinary(data))
+	require.Equal(t, subject, got)
+}
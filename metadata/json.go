@@ -0,0 +1,236 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multicodec"
+)
+
+var (
+	_ json.Marshaler   = (*Metadata)(nil)
+	_ json.Unmarshaler = (*Metadata)(nil)
+	_ json.Marshaler   = (*Bitswap)(nil)
+	_ json.Unmarshaler = (*Bitswap)(nil)
+	_ json.Marshaler   = (*GraphsyncFilecoinV1)(nil)
+	_ json.Unmarshaler = (*GraphsyncFilecoinV1)(nil)
+	_ json.Marshaler   = (*IpfsGatewayHttp)(nil)
+	_ json.Unmarshaler = (*IpfsGatewayHttp)(nil)
+	_ json.Marshaler   = (*Unknown)(nil)
+	_ json.Unmarshaler = (*Unknown)(nil)
+)
+
+// protocolNames holds the names of protocols whose codes are not yet part of the multicodec table
+// of the go-multicodec version in use.
+var protocolNames = map[multicodec.Code]string{
+	TransportIpfsGatewayHttp: "ipfs-gateway-http",
+}
+
+// ProtocolName returns the name by which the protocol with the given code is identified in the
+// JSON representation of Metadata.
+//
+// The name of a protocol is its multicodec name without the "transport-" prefix, e.g.
+// "graphsync-filecoinv1". Protocols with codes that are not in the multicodec table are named by
+// their hexadecimal code, e.g. "0x3f4e5a".
+func ProtocolName(code multicodec.Code) string {
+	if name, ok := protocolNames[code]; ok {
+		return name
+	}
+	name := code.String()
+	// The multicodec stringer falls back on Code(<decimal code>) for unknown codes.
+	if name == "Code("+strconv.FormatInt(int64(code), 10)+")" {
+		return fmt.Sprintf("0x%x", uint64(code))
+	}
+	return strings.TrimPrefix(name, "transport-")
+}
+
+// ProtocolCode returns the code of the protocol with the given name.
+// In addition to the names returned by ProtocolName, full multicodec names and numeric codes in
+// any base accepted by strconv.ParseUint are accepted.
+// See: ProtocolName.
+func ProtocolCode(name string) (multicodec.Code, error) {
+	if v, err := strconv.ParseUint(name, 0, 64); err == nil {
+		return multicodec.Code(v), nil
+	}
+	for code, n := range protocolNames {
+		if n == name {
+			return code, nil
+		}
+	}
+	var code multicodec.Code
+	if err := code.Set("transport-" + name); err == nil {
+		return code, nil
+	}
+	if err := code.Set(name); err == nil {
+		return code, nil
+	}
+	return 0, fmt.Errorf("unknown protocol: %s", name)
+}
+
+// MarshalJSON implements json.Marshaler.
+//
+// Metadata is represented as a JSON object with one field per protocol, named by ProtocolName
+// and valued by the JSON representation of the protocol, e.g.:
+//
+//	{"bitswap": {}, "graphsync-filecoinv1": {"PieceCID": "baga...", "VerifiedDeal": false, "FastRetrieval": true}}
+//
+// MarshalJSON has a value receiver so that Metadata is represented the same way when it is a
+// non-pointer field of other structs.
+func (m Metadata) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, p := range m.protocols {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(ProtocolName(p.ID()))
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal protocol %s: %w", p.ID(), err)
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// Each field is decoded into the protocol registered with the code that corresponds to the field
+// name, or into an Unknown protocol if no such protocol is registered. Any transports previously
+// held by this Metadata are replaced. As with Metadata.UnmarshalBinary, the fields of transports
+// are not validated.
+// See: Metadata.MarshalJSON, Metadata.Validate.
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	m.protocols = nil
+	for name, value := range fields {
+		code, err := ProtocolCode(name)
+		if err != nil {
+			return err
+		}
+		p := newProtocol(code)
+		if u, ok := p.(*Unknown); ok {
+			u.Code = code
+		}
+		if err := json.Unmarshal(value, p); err != nil {
+			return fmt.Errorf("failed to unmarshal protocol %s: %w", name, err)
+		}
+		m.protocols = append(m.protocols, p)
+	}
+	sort.Sort(m)
+	return m.validateStructure()
+}
+
+// MarshalJSON implements json.Marshaler. Bitswap has no fields and is represented as an empty
+// JSON object.
+func (b Bitswap) MarshalJSON() ([]byte, error) {
+	return []byte("{}"), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b Bitswap) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONStrict(data, &struct{}{})
+}
+
+// MarshalJSON implements json.Marshaler. IpfsGatewayHttp has no fields and is represented as an
+// empty JSON object.
+func (g IpfsGatewayHttp) MarshalJSON() ([]byte, error) {
+	return []byte("{}"), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (g IpfsGatewayHttp) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONStrict(data, &struct{}{})
+}
+
+// graphsyncFilecoinV1JSON is the JSON representation of GraphsyncFilecoinV1, where PieceCID is
+// represented as a string, or an empty string if undefined.
+type graphsyncFilecoinV1JSON struct {
+	PieceCID      string
+	VerifiedDeal  bool
+	FastRetrieval bool
+}
+
+// MarshalJSON implements json.Marshaler.
+func (dtm *GraphsyncFilecoinV1) MarshalJSON() ([]byte, error) {
+	v := graphsyncFilecoinV1JSON{
+		VerifiedDeal:  dtm.VerifiedDeal,
+		FastRetrieval: dtm.FastRetrieval,
+	}
+	if dtm.PieceCID.Defined() {
+		v.PieceCID = dtm.PieceCID.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (dtm *GraphsyncFilecoinV1) UnmarshalJSON(data []byte) error {
+	var v graphsyncFilecoinV1JSON
+	if err := unmarshalJSONStrict(data, &v); err != nil {
+		return err
+	}
+	pieceCid := cid.Undef
+	if v.PieceCID != "" {
+		var err error
+		if pieceCid, err = cid.Decode(v.PieceCID); err != nil {
+			return fmt.Errorf("invalid piece CID: %w", err)
+		}
+	}
+	dtm.PieceCID = pieceCid
+	dtm.VerifiedDeal = v.VerifiedDeal
+	dtm.FastRetrieval = v.FastRetrieval
+	return nil
+}
+
+// unknownJSON is the JSON representation of Unknown. The protocol code is omitted since it is
+// represented by the name of the Metadata field that holds the protocol.
+type unknownJSON struct {
+	Payload []byte
+}
+
+// MarshalJSON implements json.Marshaler. The payload is represented as a base64 encoded string.
+func (u *Unknown) MarshalJSON() ([]byte, error) {
+	return json.Marshal(unknownJSON{Payload: u.Payload})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Only the payload is decoded; the protocol code is
+// left unchanged.
+func (u *Unknown) UnmarshalJSON(data []byte) error {
+	var v unknownJSON
+	if err := unmarshalJSONStrict(data, &v); err != nil {
+		return err
+	}
+	u.Payload = v.Payload
+	return nil
+}
+
+// unmarshalJSONStrict unmarshals data into v, rejecting unknown fields so that misspelled
+// fields are not silently ignored.
+func unmarshalJSONStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after JSON value")
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package metadata_test
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-varint"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata_JSONRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	pieceCid := testutil.RandomPieceCids(t, rng, 1)[0]
+	subject := metadata.New(
+		&metadata.IpfsGatewayHttp{},
+		&metadata.GraphsyncFilecoinV1{PieceCID: pieceCid, FastRetrieval: true},
+		&metadata.Bitswap{},
+	)
+
+	data, err := json.Marshal(subject)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"bitswap": {},
+		"graphsync-filecoinv1": {"PieceCID": "`+pieceCid.String()+`", "VerifiedDeal": false, "FastRetrieval": true},
+		"ipfs-gateway-http": {}
+	}`, string(data))
+
+	var got metadata.Metadata
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.True(t, subject.Equal(got))
+
+	// Metadata is represented the same way when it is a field of another struct.
+	wrapped, err := json.Marshal(struct{ Metadata metadata.Metadata }{subject})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Metadata":`+string(data)+`}`, string(wrapped))
+}
+
+func TestMetadata_UnmarshalJSON(t *testing.T) {
+	pieceCid := testutil.RandomPieceCids(t, rand.New(rand.NewSource(1413)), 1)[0]
+	tests := []struct {
+		name    string
+		given   string
+		want    metadata.Metadata
+		wantErr string
+	}{
+		{
+			name:  "Full multicodec names",
+			given: `{"transport-bitswap": {}, "transport-graphsync-filecoinv1": {"PieceCID": "` + pieceCid.String() + `"}}`,
+			want:  metadata.New(&metadata.Bitswap{}, &metadata.GraphsyncFilecoinV1{PieceCID: pieceCid}),
+		},
+		{
+			name:  "Numeric codes",
+			given: `{"0x900": {}, "2336": {}}`,
+			want:  metadata.New(&metadata.Bitswap{}, &metadata.IpfsGatewayHttp{}),
+		},
+		{
+			name:  "Unregistered protocol",
+			given: `{"0x3f4e5b": {"Payload": "ZmlzaA=="}}`,
+			want:  metadata.New(&metadata.Unknown{Code: 0x3f4e5b, Payload: []byte("fish")}),
+		},
+		{
+			name:    "Unknown protocol name",
+			given:   `{"fish": {}}`,
+			wantErr: "unknown protocol: fish",
+		},
+		{
+			name:    "No protocols",
+			given:   `{}`,
+			wantErr: "at least one transport must be specified",
+		},
+		{
+			name:    "Repeated protocols",
+			given:   `{"bitswap": {}, "transport-bitswap": {}}`,
+			wantErr: "duplicate transport: transport-bitswap",
+		},
+		{
+			name:    "Misspelled field",
+			given:   `{"graphsync-filecoinv1": {"PieceCID": "` + pieceCid.String() + `", "FastRetreival": true}}`,
+			wantErr: `failed to unmarshal protocol graphsync-filecoinv1: json: unknown field "FastRetreival"`,
+		},
+		{
+			name:    "Invalid piece CID",
+			given:   `{"graphsync-filecoinv1": {"PieceCID": "fish"}}`,
+			wantErr: "failed to unmarshal protocol graphsync-filecoinv1: invalid piece CID",
+		},
+		{
+			// Fields are checked by Metadata.Validate rather than when decoding.
+			name:  "Undefined piece CID",
+			given: `{"graphsync-filecoinv1": {"FastRetrieval": true}}`,
+			want:  metadata.New(&metadata.GraphsyncFilecoinV1{FastRetrieval: true}),
+		},
+		{
+			name:    "Not an object",
+			given:   `["bitswap"]`,
+			wantErr: "cannot unmarshal array",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got metadata.Metadata
+			err := json.Unmarshal([]byte(test.given), &got)
+			if test.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, test.want.Equal(got), "got: %v", got)
+		})
+	}
+}
+
+func TestMetadata_JSONPreservesUnknownProtocols(t *testing.T) {
+	code := multicodec.Code(0x3f4e5b)
+	data := append(varint.ToUvarint(uint64(code)), []byte("fish")...)
+	var subject metadata.Metadata
+	require.NoError(t, subject.UnmarshalBinary(data))
+
+	j, err := json.Marshal(subject)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"0x3f4e5b": {"Payload": "ZmlzaA=="}}`, string(j))
+
+	var got metadata.Metadata
+	require.NoError(t, json.Unmarshal(j, &got))
+	gotData, err := got.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, data, gotData)
+}
+
+func TestProtocolName(t *testing.T) {
+	require.Equal(t, "bitswap", metadata.ProtocolName(multicodec.TransportBitswap))
+	require.Equal(t, "graphsync-filecoinv1", metadata.ProtocolName(multicodec.TransportGraphsyncFilecoinv1))
+	require.Equal(t, "ipfs-gateway-http", metadata.ProtocolName(metadata.TransportIpfsGatewayHttp))
+	require.Equal(t, "0x3f4e5b", metadata.ProtocolName(0x3f4e5b))
+
+	for _, name := range []string{"bitswap", "transport-bitswap", "0x900", "2304"} {
+		code, err := metadata.ProtocolCode(name)
+		require.NoError(t, err)
+		require.Equal(t, multicodec.TransportBitswap, code)
+	}
+	code, err := metadata.ProtocolCode("ipfs-gateway-http")
+	require.NoError(t, err)
+	require.Equal(t, metadata.TransportIpfsGatewayHttp, code)
+	_, err = metadata.ProtocolCode("fish")
+	require.EqualError(t, err, "unknown protocol: fish")
+}
@@ -20,6 +20,9 @@ func (e ErrInvalidMetadata) Error() string {
 	return fmt.Sprintf("storetheindex: invalid metadata: %v", e.Message)
 }
 
+// ErrNoKnownProtocol signals that metadata only has protocols that are not registered.
+var ErrNoKnownProtocol = errors.New("metadata has no known protocol")
+
 var (
 	_ sort.Interface             = (*Metadata)(nil)
 	_ encoding.BinaryMarshaler   = (*Metadata)(nil)
@@ -53,6 +56,80 @@ func New(t ...Protocol) Metadata {
 	return metadata
 }
 
+// Protocols returns the retrieval protocols in this Metadata, sorted by ID.
+// The returned slice is a copy; modifying it does not modify this Metadata.
+func (m Metadata) Protocols() []Protocol {
+	protocols := make([]Protocol, len(m.protocols))
+	copy(protocols, m.protocols)
+	return protocols
+}
+
+// Get returns the protocol in this Metadata with the given ID, or nil if there is no such
+// protocol.
+func (m Metadata) Get(id multicodec.Code) Protocol {
+	if i := indexOf(m.protocols, id); i >= 0 {
+		return m.protocols[i]
+	}
+	return nil
+}
+
+// Has checks whether this Metadata contains a protocol with the given ID.
+func (m Metadata) Has(id multicodec.Code) bool {
+	return m.Get(id) != nil
+}
+
+// With returns a copy of this Metadata with the given protocols added. Any protocol in this
+// Metadata with the same ID as one of the given protocols is replaced by it.
+//
+// This Metadata is left unchanged. Note that the protocols themselves are not copied, and are
+// shared between the two Metadata.
+func (m Metadata) With(p ...Protocol) Metadata {
+	protocols := make([]Protocol, len(m.protocols), len(m.protocols)+len(p))
+	copy(protocols, m.protocols)
+	for _, added := range p {
+		if i := indexOf(protocols, added.ID()); i >= 0 {
+			protocols[i] = added
+		} else {
+			protocols = append(protocols, added)
+		}
+	}
+	return New(protocols...)
+}
+
+// Without returns a copy of this Metadata without the protocols with the given IDs.
+//
+// This Metadata is left unchanged. Note that the returned Metadata may have no protocols, which
+// is invalid.
+func (m Metadata) Without(ids ...multicodec.Code) Metadata {
+	protocols := make([]Protocol, 0, len(m.protocols))
+	for _, p := range m.protocols {
+		if !containsID(ids, p.ID()) {
+			protocols = append(protocols, p)
+		}
+	}
+	return New(protocols...)
+}
+
+func containsID(ids []multicodec.Code, id multicodec.Code) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// indexOf returns the index of the protocol with the given ID, or -1 if there is no such
+// protocol.
+func indexOf(protocols []Protocol, id multicodec.Code) int {
+	for i, p := range protocols {
+		if p.ID() == id {
+			return i
+		}
+	}
+	return -1
+}
+
 func (m *Metadata) Len() int {
 	return len(m.protocols)
 }
@@ -65,24 +142,59 @@ func (m *Metadata) Swap(one, other int) {
 	m.protocols[one], m.protocols[other] = m.protocols[other], m.protocols[one]
 }
 
-// Validate checks whether this Metadata is valid.
+// Validate checks whether this Metadata is valid, i.e. that it has at least one transport, its
+// transports are sorted by ID without any repeated ID, and the fields of every transport that
+// implements Validator are valid.
 func (m *Metadata) Validate() error {
+	if err := m.validateStructure(); err != nil {
+		return err
+	}
+	for _, transport := range m.protocols {
+		if v, ok := transport.(Validator); ok {
+			if err := v.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateStructure checks that this Metadata has at least one transport, and that its transports
+// are sorted by ID without any repeated ID. Unlike Validate, the fields of transports are not
+// checked, so that metadata which was persisted or advertised before its fields were validated can
+// still be decoded.
+func (m *Metadata) validateStructure() error {
 	if len(m.protocols) == 0 {
 		return errors.New("at least one transport must be specified")
 	}
-
-	var lastID multicodec.Code
-	for _, transport := range m.protocols {
-		if lastID > transport.ID() {
+	for i := 1; i < len(m.protocols); i++ {
+		lastID, id := m.protocols[i-1].ID(), m.protocols[i].ID()
+		if lastID == id {
+			return fmt.Errorf("duplicate transport: %s", id)
+		}
+		if lastID > id {
 			return errors.New("metadata transports must be sorted by ID")
 		}
 	}
-
-	//TODO: Assert all IDs are known and transports are unique once we discuss what's techincally valid?
-	//      For example, is it valid to have repeated graphsync metadata with different values?
 	return nil
 }
 
+// ValidateKnown checks whether this Metadata is valid and has at least one protocol that is not
+// Unknown. Metadata of which all protocols are unknown is decoded for round-tripping, but does not
+// tell how to retrieve the content, and so must not be advertised.
+// See: Metadata.Validate, Register.
+func (m *Metadata) ValidateKnown() error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	for _, p := range m.protocols {
+		if _, unknown := p.(*Unknown); !unknown {
+			return nil
+		}
+	}
+	return ErrNoKnownProtocol
+}
+
 // MarshalBinary implements encoding.BinaryMarshaler.
 func (m *Metadata) MarshalBinary() ([]byte, error) {
 	sort.Sort(m)
@@ -100,29 +212,31 @@ func (m *Metadata) MarshalBinary() ([]byte, error) {
 }
 
 // UnmarshalBinary implements encoding.BinaryUnmarshaler.
+// Any transports previously held by this Metadata are replaced. The decoded Metadata must have at
+// least one transport, sorted by ID without any repeated ID. The fields of transports are not
+// validated; use Metadata.Validate to do so before advertising decoded Metadata.
 func (m *Metadata) UnmarshalBinary(data []byte) error {
-	var read int64
-	for read < int64(len(data)) {
-		data = data[read:]
+	m.protocols = nil
+	for len(data) != 0 {
 		v, _, err := varint.FromUvarint(data)
 		if err != nil {
 			return err
 		}
-		id := multicodec.Code(v)
-		t, err := newTransport(id)
-		if err != nil {
-			return err
-		}
+		t := newProtocol(multicodec.Code(v))
 
-		buf := bytes.NewBuffer(data)
-		tLen, err := t.ReadFrom(buf)
+		tLen, err := t.ReadFrom(bytes.NewReader(data))
 		if err != nil {
 			return err
 		}
+		// Guard against transports that report reading no bytes, which would otherwise loop
+		// forever, or more bytes than there are.
+		if tLen <= 0 || tLen > int64(len(data)) {
+			return fmt.Errorf("transport %s read invalid number of bytes: %d", t.ID(), tLen)
+		}
 		m.protocols = append(m.protocols, t)
-		read += tLen
+		data = data[tLen:]
 	}
-	return m.Validate()
+	return m.validateStructure()
 }
 
 // Equal checks whether this Metadata is equal with the other Metadata.
@@ -155,14 +269,3 @@ func protocolEqual(one, other Protocol) bool {
 	}
 	return bytes.Equal(oneBytes, otherBytes)
 }
-
-func newTransport(id multicodec.Code) (Protocol, error) {
-	switch id {
-	case multicodec.TransportBitswap:
-		return &Bitswap{}, nil
-	case multicodec.TransportGraphsyncFilecoinv1:
-		return &GraphsyncFilecoinV1{}, nil
-	default:
-		return nil, fmt.Errorf("unknwon transport id: %s", id.String())
-	}
-}
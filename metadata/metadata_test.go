@@ -13,7 +13,7 @@ import (
 
 func TestMetadata(t *testing.T) {
 	rng := rand.New(rand.NewSource(1413))
-	cids := testutil.RandomCids(t, rng, 4)
+	cids := testutil.RandomPieceCids(t, rng, 4)
 	tests := []struct {
 		name            string
 		givenTransports []metadata.Protocol
@@ -30,10 +30,39 @@ func TestMetadata(t *testing.T) {
 				&metadata.Bitswap{},
 			},
 		},
+		{
+			name: "All known transports",
+			givenTransports: []metadata.Protocol{
+				&metadata.IpfsGatewayHttp{},
+				&metadata.GraphsyncFilecoinV1{
+					PieceCID:      cids[1],
+					VerifiedDeal:  true,
+					FastRetrieval: true,
+				},
+				&metadata.Bitswap{},
+			},
+		},
 		{
 			name:            "No transports is invalid",
 			wantValidateErr: "at least one transport must be specified",
 		},
+		{
+			name:            "Repeated transports is invalid",
+			givenTransports: []metadata.Protocol{&metadata.Bitswap{}, &metadata.Bitswap{}},
+			wantValidateErr: "duplicate transport: transport-bitswap",
+		},
+		{
+			name:            "Undefined piece CID is invalid",
+			givenTransports: []metadata.Protocol{&metadata.GraphsyncFilecoinV1{}},
+			wantValidateErr: "transport-graphsync-filecoinv1: piece CID must be specified",
+		},
+		{
+			name: "Piece CID that is not a piece commitment is invalid",
+			givenTransports: []metadata.Protocol{
+				&metadata.GraphsyncFilecoinV1{PieceCID: testutil.RandomCids(t, rng, 1)[0]},
+			},
+			wantValidateErr: "transport-graphsync-filecoinv1: piece CID codec must be fil-commitment-unsealed; got dag-json",
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -73,6 +102,29 @@ func TestMetadata(t *testing.T) {
 	}
 }
 
+func TestMetadata_UnmarshalBinaryReplacesTransports(t *testing.T) {
+	subject := metadata.New(&metadata.Bitswap{})
+	gateway := metadata.New(&metadata.IpfsGatewayHttp{})
+	data, err := gateway.MarshalBinary()
+	require.NoError(t, err)
+
+	require.NoError(t, subject.UnmarshalBinary(data))
+	require.Equal(t, gateway, subject)
+}
+
+func TestMetadata_UnmarshalBinaryDoesNotValidateFields(t *testing.T) {
+	// Metadata advertised before piece CIDs were validated may have any piece CID.
+	rng := rand.New(rand.NewSource(1413))
+	gs := &metadata.GraphsyncFilecoinV1{PieceCID: testutil.RandomCids(t, rng, 1)[0]}
+	data, err := gs.MarshalBinary()
+	require.NoError(t, err)
+
+	var subject metadata.Metadata
+	require.NoError(t, subject.UnmarshalBinary(data))
+	require.True(t, metadata.New(gs).Equal(subject))
+	require.EqualError(t, subject.Validate(), "transport-graphsync-filecoinv1: piece CID codec must be fil-commitment-unsealed; got dag-json")
+}
+
 func TestMetadata_UnmarshalBinary(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -85,9 +137,24 @@ func TestMetadata_UnmarshalBinary(t *testing.T) {
 			wantErr: "at least one transport must be specified",
 		},
 		{
-			name:       "Unknown transport ID is error",
-			givenBytes: varint.ToUvarint(uint64(multicodec.Libp2pRelayRsvp)),
-			wantErr:    "unknwon transport id: libp2p-relay-rsvp",
+			name:         "Unknown transport ID is preserved",
+			givenBytes:   varint.ToUvarint(uint64(multicodec.Libp2pRelayRsvp)),
+			wantMetadata: metadata.New(&metadata.Unknown{Code: multicodec.Libp2pRelayRsvp, Payload: []byte{}}),
+		},
+		{
+			name:       "Unsorted transports is error",
+			givenBytes: append(varint.ToUvarint(uint64(metadata.TransportIpfsGatewayHttp)), varint.ToUvarint(uint64(multicodec.TransportBitswap))...),
+			wantErr:    "metadata transports must be sorted by ID",
+		},
+		{
+			name:       "Repeated transports is error",
+			givenBytes: append(varint.ToUvarint(uint64(multicodec.TransportBitswap)), varint.ToUvarint(uint64(multicodec.TransportBitswap))...),
+			wantErr:    "duplicate transport: transport-bitswap",
+		},
+		{
+			name:       "Truncated transport ID is error",
+			givenBytes: []byte{0x80},
+			wantErr:    "varints malformed, could not reach the end",
 		},
 		{
 			name:         "Known transport ID is not error",
@@ -96,9 +163,12 @@ func TestMetadata_UnmarshalBinary(t *testing.T) {
 		},
 
 		{
-			name:       "Known transport ID mixed with unknown ID is not error",
+			name:       "Unknown transport ID consumes the remaining bytes",
 			givenBytes: append(varint.ToUvarint(uint64(123456)), varint.ToUvarint(uint64(multicodec.TransportBitswap))...),
-			wantErr:    "unknwon transport id: Code(123456)",
+			wantMetadata: metadata.New(&metadata.Unknown{
+				Code:    123456,
+				Payload: varint.ToUvarint(uint64(multicodec.TransportBitswap)),
+			}),
 		},
 	}
 	for _, test := range tests {
@@ -109,9 +179,70 @@ func TestMetadata_UnmarshalBinary(t *testing.T) {
 				require.NoError(t, err)
 				require.Equal(t, subject, test.wantMetadata)
 				require.NoError(t, subject.Validate())
+
+				// Metadata is re-encoded unchanged, whether its protocols are known or not.
+				gotBytes, err := subject.MarshalBinary()
+				require.NoError(t, err)
+				require.Equal(t, test.givenBytes, gotBytes)
 			} else {
 				require.EqualError(t, err, test.wantErr)
 			}
 		})
 	}
 }
+
+func TestMetadata_ValidateKnown(t *testing.T) {
+	unknown := &metadata.Unknown{Code: multicodec.Libp2pRelayRsvp, Payload: []byte("fish")}
+
+	subject := metadata.New(unknown)
+	require.NoError(t, subject.Validate())
+	require.Equal(t, metadata.ErrNoKnownProtocol, subject.ValidateKnown())
+
+	subject = metadata.New(&metadata.Bitswap{}, unknown)
+	require.NoError(t, subject.ValidateKnown())
+
+	subject = metadata.New()
+	require.EqualError(t, subject.ValidateKnown(), "at least one transport must be specified")
+}
+
+func TestMetadata_Accessors(t *testing.T) {
+	pieceCid := testutil.RandomPieceCids(t, rand.New(rand.NewSource(1413)), 1)[0]
+	gs := &metadata.GraphsyncFilecoinV1{PieceCID: pieceCid}
+	subject := metadata.New(gs, &metadata.Bitswap{})
+
+	require.True(t, subject.Has(multicodec.TransportBitswap))
+	require.True(t, subject.Has(multicodec.TransportGraphsyncFilecoinv1))
+	require.False(t, subject.Has(metadata.TransportIpfsGatewayHttp))
+	require.Same(t, gs, subject.Get(multicodec.TransportGraphsyncFilecoinv1))
+	require.Nil(t, subject.Get(metadata.TransportIpfsGatewayHttp))
+
+	// Modifying the returned protocols does not modify the metadata.
+	protocols := subject.Protocols()
+	require.Len(t, protocols, 2)
+	protocols[0] = &metadata.IpfsGatewayHttp{}
+	require.True(t, subject.Has(multicodec.TransportBitswap))
+}
+
+func TestMetadata_WithAndWithout(t *testing.T) {
+	cids := testutil.RandomPieceCids(t, rand.New(rand.NewSource(1413)), 2)
+	original := metadata.New(&metadata.GraphsyncFilecoinV1{PieceCID: cids[0]}, &metadata.Bitswap{})
+	originalBytes, err := original.MarshalBinary()
+	require.NoError(t, err)
+
+	replacement := &metadata.GraphsyncFilecoinV1{PieceCID: cids[1], FastRetrieval: true}
+	with := original.With(&metadata.IpfsGatewayHttp{}, replacement)
+	require.NoError(t, with.Validate())
+	require.True(t, with.Equal(metadata.New(&metadata.Bitswap{}, replacement, &metadata.IpfsGatewayHttp{})))
+
+	without := with.Without(multicodec.TransportBitswap, metadata.TransportIpfsGatewayHttp)
+	require.NoError(t, without.Validate())
+	require.True(t, without.Equal(metadata.New(replacement)))
+	empty := without.Without(multicodec.TransportGraphsyncFilecoinv1)
+	require.Error(t, empty.Validate())
+
+	// The original metadata is left unchanged.
+	gotBytes, err := original.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, originalBytes, gotBytes)
+	require.Len(t, with.Protocols(), 3)
+}
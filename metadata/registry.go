@@ -0,0 +1,70 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/multiformats/go-multicodec"
+)
+
+// ErrAlreadyRegistered signals that a protocol is already registered with the same code.
+var ErrAlreadyRegistered = errors.New("protocol already registered")
+
+// ProtocolFactory instantiates an empty Protocol into which its binary representation is decoded.
+type ProtocolFactory func() Protocol
+
+var registry = struct {
+	lk        sync.RWMutex
+	factories map[multicodec.Code]ProtocolFactory
+}{
+	factories: map[multicodec.Code]ProtocolFactory{
+		multicodec.TransportBitswap:             func() Protocol { return &Bitswap{} },
+		multicodec.TransportGraphsyncFilecoinv1: func() Protocol { return &GraphsyncFilecoinV1{} },
+		TransportIpfsGatewayHttp:                func() Protocol { return &IpfsGatewayHttp{} },
+	},
+}
+
+// Register registers the factory of the Protocol identified by the given code, so that metadata
+// containing the protocol is decoded using the Protocol implementation returned by the factory.
+// Protocols are typically registered during the initialization of the package that implements
+// them.
+//
+// Bitswap, GraphsyncFilecoinV1 and IpfsGatewayHttp are registered by default.
+// ErrAlreadyRegistered is returned if a protocol is already registered with the given code.
+//
+// Metadata containing protocols that are not registered is decoded into Unknown protocols, which
+// preserve the undecoded bytes.
+func Register(code multicodec.Code, factory ProtocolFactory) error {
+	if factory == nil {
+		return errors.New("protocol factory must not be nil")
+	}
+	registry.lk.Lock()
+	defer registry.lk.Unlock()
+	if _, exists := registry.factories[code]; exists {
+		return fmt.Errorf("%w: %s", ErrAlreadyRegistered, code)
+	}
+	registry.factories[code] = factory
+	return nil
+}
+
+// IsRegistered checks whether a protocol is registered with the given code.
+// See: Register.
+func IsRegistered(code multicodec.Code) bool {
+	registry.lk.RLock()
+	defer registry.lk.RUnlock()
+	_, ok := registry.factories[code]
+	return ok
+}
+
+// newProtocol instantiates the registered Protocol with the given code, or an Unknown protocol if
+// no such protocol is registered.
+func newProtocol(code multicodec.Code) Protocol {
+	registry.lk.RLock()
+	factory, ok := registry.factories[code]
+	registry.lk.RUnlock()
+	if !ok {
+		return &Unknown{}
+	}
+	return factory()
+}
@@ -0,0 +1,72 @@
+package metadata_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-varint"
+	"github.com/stretchr/testify/require"
+)
+
+// testProtocolCode is the code of testProtocol, chosen from the range of codes that are not
+// assigned in the multicodec table.
+const testProtocolCode = multicodec.Code(0x3f4e5a)
+
+var _ metadata.Protocol = (*testProtocol)(nil)
+
+// testProtocol is a protocol whose payload is a single byte.
+type testProtocol struct {
+	value byte
+}
+
+func (p *testProtocol) ID() multicodec.Code {
+	return testProtocolCode
+}
+
+func (p *testProtocol) MarshalBinary() ([]byte, error) {
+	return append(varint.ToUvarint(uint64(testProtocolCode)), p.value), nil
+}
+
+func (p *testProtocol) UnmarshalBinary(data []byte) error {
+	_, err := p.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+func (p *testProtocol) ReadFrom(r io.Reader) (int64, error) {
+	prefix := varint.ToUvarint(uint64(testProtocolCode))
+	buf := make([]byte, len(prefix)+1)
+	n, err := io.ReadFull(r, buf)
+	if err != nil {
+		return int64(n), err
+	}
+	if !bytes.Equal(prefix, buf[:len(prefix)]) {
+		return int64(n), errors.New("protocol code does not match")
+	}
+	p.value = buf[len(prefix)]
+	return int64(n), nil
+}
+
+func TestRegister(t *testing.T) {
+	require.False(t, metadata.IsRegistered(testProtocolCode))
+	require.NoError(t, metadata.Register(testProtocolCode, func() metadata.Protocol { return &testProtocol{} }))
+	require.True(t, metadata.IsRegistered(testProtocolCode))
+
+	err := metadata.Register(testProtocolCode, func() metadata.Protocol { return &testProtocol{} })
+	require.True(t, errors.Is(err, metadata.ErrAlreadyRegistered))
+	err = metadata.Register(multicodec.TransportBitswap, func() metadata.Protocol { return &testProtocol{} })
+	require.True(t, errors.Is(err, metadata.ErrAlreadyRegistered))
+
+	// The registered protocol is decoded, and is followed by the registered default protocols.
+	subject := metadata.New(&metadata.Bitswap{}, &testProtocol{value: 42})
+	data, err := subject.MarshalBinary()
+	require.NoError(t, err)
+
+	var got metadata.Metadata
+	require.NoError(t, got.UnmarshalBinary(data))
+	require.Equal(t, subject, got)
+	require.Equal(t, &testProtocol{value: 42}, got.Protocols()[1])
+}
@@ -0,0 +1,60 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-varint"
+)
+
+var _ Protocol = (*Unknown)(nil)
+
+// Unknown represents a protocol that is not registered, preserving its undecoded bytes so that
+// metadata containing it can be re-encoded unchanged.
+//
+// Because the length of an unknown protocol cannot be determined, an Unknown protocol consumes
+// all the bytes that follow its code, including those of any protocols listed after it.
+// See: Register.
+type Unknown struct {
+	// Code is the multicodec code that identifies the protocol.
+	Code multicodec.Code
+	// Payload is the undecoded bytes that follow the protocol code.
+	Payload []byte
+}
+
+// ID returns the code of the unknown protocol.
+func (u *Unknown) ID() multicodec.Code {
+	return u.Code
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (u *Unknown) MarshalBinary() ([]byte, error) {
+	buf := bytes.NewBuffer(varint.ToUvarint(uint64(u.Code)))
+	buf.Write(u.Payload)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (u *Unknown) UnmarshalBinary(data []byte) error {
+	_, err := u.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+// ReadFrom reads the protocol code followed by all the remaining bytes of r as payload.
+func (u *Unknown) ReadFrom(r io.Reader) (n int64, err error) {
+	cr := &countingReader{r: r}
+	v, err := varint.ReadUvarint(cr)
+	if err != nil {
+		return cr.readCount, err
+	}
+	payload, err := ioutil.ReadAll(cr)
+	if err != nil {
+		return cr.readCount, fmt.Errorf("failed to read payload of protocol %s: %w", multicodec.Code(v), err)
+	}
+	u.Code = multicodec.Code(v)
+	u.Payload = payload
+	return cr.readCount, nil
+}
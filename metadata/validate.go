@@ -0,0 +1,51 @@
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+)
+
+// Validator is optionally implemented by a Protocol in order to check that its fields are
+// well-formed. Metadata.Validate validates every protocol that implements Validator.
+type Validator interface {
+	// Validate checks whether the fields of the protocol are well-formed.
+	Validate() error
+}
+
+var (
+	_ Validator = (*GraphsyncFilecoinV1)(nil)
+)
+
+// Validate checks that PieceCID is a valid piece commitment, i.e. a CID with
+// multicodec.FilCommitmentUnsealed codec and a 32 byte multicodec.Sha2_256Trunc254Padded
+// multihash.
+//
+// PieceCIDs that wrap a context ID in an identity multihash with
+// multicodec.TransportGraphsyncFilecoinv1 codec are also valid, as generated by the
+// cardatatransfer package of the reference provider in order to retrieve content by context ID.
+func (dtm *GraphsyncFilecoinV1) Validate() error {
+	if dtm.PieceCID == cid.Undef {
+		return fmt.Errorf("%s: piece CID must be specified", dtm.ID())
+	}
+	prefix := dtm.PieceCID.Prefix()
+	switch multicodec.Code(prefix.Codec) {
+	case multicodec.FilCommitmentUnsealed:
+		if prefix.MhType != uint64(multicodec.Sha2_256Trunc254Padded) {
+			return fmt.Errorf("%s: piece CID multihash must be %s; got %s", dtm.ID(), multicodec.Sha2_256Trunc254Padded, multicodec.Code(prefix.MhType))
+		}
+		if prefix.MhLength != 32 {
+			return fmt.Errorf("%s: piece CID digest must be 32 bytes long; got %d", dtm.ID(), prefix.MhLength)
+		}
+		return nil
+	case multicodec.TransportGraphsyncFilecoinv1:
+		if prefix.MhType != multihash.IDENTITY {
+			return fmt.Errorf("%s: context ID piece CID multihash must be %s; got %s", dtm.ID(), multicodec.Identity, multicodec.Code(prefix.MhType))
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: piece CID codec must be %s; got %s", dtm.ID(), multicodec.FilCommitmentUnsealed, multicodec.Code(prefix.Codec))
+	}
+}
@@ -0,0 +1,148 @@
+package adminserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	"github.com/gorilla/mux"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+)
+
+const (
+	defaultListAdsLimit = 10
+	maxListAdsLimit     = 100
+)
+
+type adHandler struct {
+	e *engine.Engine
+}
+
+// handleGetHead responds with the latest advertisement published by the provider.
+func (h *adHandler) handleGetHead(w http.ResponseWriter, r *http.Request) {
+	c, ad, err := h.e.GetLatestAdv(r.Context())
+	if err != nil {
+		msg := fmt.Sprintf("failed to get latest advertisement: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if c == cid.Undef {
+		http.Error(w, "no advertisements published", http.StatusNotFound)
+		return
+	}
+	resp := toAdRes(c, ad)
+	respond(w, http.StatusOK, &resp)
+}
+
+// handleGet responds with the advertisement with the CID in the request path.
+func (h *adHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	c, err := cid.Decode(mux.Vars(r)["cid"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid advertisement CID: %v", err), http.StatusBadRequest)
+		return
+	}
+	ad, ok := h.getAdv(w, r, c)
+	if !ok {
+		return
+	}
+	resp := toAdRes(c, ad)
+	respond(w, http.StatusOK, &resp)
+}
+
+// handleList walks the advertisement chain backwards, starting from the advertisement with the
+// CID in the start query parameter, or the latest advertisement if unset. At most limit
+// advertisements are listed; the response specifies the CID at which the next page starts.
+func (h *adHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, err := limitParam(query.Get("limit"), defaultListAdsLimit, maxListAdsLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var next cid.Cid
+	if start := query.Get("start"); start != "" {
+		if next, err = cid.Decode(start); err != nil {
+			http.Error(w, fmt.Sprintf("invalid start CID: %v", err), http.StatusBadRequest)
+			return
+		}
+	} else if next, _, err = h.e.GetLatestAdv(r.Context()); err != nil {
+		msg := fmt.Sprintf("failed to get latest advertisement: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resp := &ListAdsRes{
+		Ads: []AdRes{},
+	}
+	for len(resp.Ads) < limit && next != cid.Undef {
+		ad, ok := h.getAdv(w, r, next)
+		if !ok {
+			return
+		}
+		res := toAdRes(next, ad)
+		resp.Ads = append(resp.Ads, res)
+		next = res.PreviousID
+	}
+	resp.Next = next
+	respond(w, http.StatusOK, resp)
+}
+
+func (h *adHandler) getAdv(w http.ResponseWriter, r *http.Request, c cid.Cid) (*schema.Advertisement, bool) {
+	ad, err := h.e.GetAdv(r.Context(), c)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			http.Error(w, fmt.Sprintf("no advertisement found with CID %s", c), http.StatusNotFound)
+			return nil, false
+		}
+		msg := fmt.Sprintf("failed to get advertisement: %v", err)
+		log.Errorw(msg, "err", err, "cid", c)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return nil, false
+	}
+	return ad, true
+}
+
+func toAdRes(c cid.Cid, ad *schema.Advertisement) AdRes {
+	res := AdRes{
+		ID:        c,
+		Provider:  ad.Provider,
+		Addresses: ad.Addresses,
+		Entries:   linkCid(ad.Entries),
+		ContextID: ad.ContextID,
+		Metadata:  ad.Metadata,
+		IsRm:      ad.IsRm,
+	}
+	res.DecodedMetadata = MetadataBytes(ad.Metadata).decode()
+	if ad.PreviousID != nil {
+		res.PreviousID = linkCid(*ad.PreviousID)
+	}
+	return res
+}
+
+func linkCid(l ipld.Link) cid.Cid {
+	if cl, ok := l.(cidlink.Link); ok {
+		return cl.Cid
+	}
+	return cid.Undef
+}
+
+// limitParam parses the given limit query parameter value, returning the default limit if unset.
+func limitParam(v string, defaultLimit, maxLimit int) (int, error) {
+	if v == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > maxLimit {
+		return 0, fmt.Errorf("limit must be an integer between 1 and %d", maxLimit)
+	}
+	return limit, nil
+}
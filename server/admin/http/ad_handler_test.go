@@ -0,0 +1,110 @@
+package adminserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_adHandler(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	baseURL, _, cs := startTestEngineServer(t)
+
+	// No advertisements are published yet.
+	resp := httpGet(t, baseURL+"/admin/ads/head")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	var list ListAdsRes
+	requireGetOk(t, baseURL+"/admin/ads", &list)
+	require.Empty(t, list.Ads)
+	require.Equal(t, cid.Undef, list.Next)
+
+	md := metadata.New(metadata.Bitswap{})
+	wantMd, err := md.MarshalBinary()
+	require.NoError(t, err)
+	testdata := filepath.Join(testutil.ThisDir(t), "../../../testdata")
+	putCid, err := cs.Put(ctx, []byte("fish"), filepath.Join(testdata, "sample-v1.car"), md)
+	require.NoError(t, err)
+	put2Cid, err := cs.Put(ctx, []byte("lobster"), filepath.Join(testdata, "sample-v1-2.car"), md)
+	require.NoError(t, err)
+	rmCid, err := cs.Remove(ctx, []byte("fish"))
+	require.NoError(t, err)
+
+	var head AdRes
+	requireGetOk(t, baseURL+"/admin/ads/head", &head)
+	require.Equal(t, rmCid, head.ID)
+	require.Equal(t, put2Cid, head.PreviousID)
+	require.Equal(t, []byte("fish"), head.ContextID)
+	require.True(t, head.IsRm)
+
+	var ad AdRes
+	requireGetOk(t, baseURL+"/admin/ads/"+putCid.String(), &ad)
+	require.Equal(t, putCid, ad.ID)
+	require.Equal(t, cid.Undef, ad.PreviousID)
+	require.Equal(t, []byte("fish"), ad.ContextID)
+	require.Equal(t, MetadataBytes(wantMd), ad.Metadata)
+	require.NotNil(t, ad.DecodedMetadata)
+	require.Equal(t, metadata.New(&metadata.Bitswap{}), *ad.DecodedMetadata)
+	require.True(t, ad.Entries.Defined())
+	require.False(t, ad.IsRm)
+
+	// Walk the chain in pages of two.
+	list = ListAdsRes{}
+	requireGetOk(t, baseURL+"/admin/ads?limit=2", &list)
+	require.Len(t, list.Ads, 2)
+	require.Equal(t, rmCid, list.Ads[0].ID)
+	require.Equal(t, put2Cid, list.Ads[1].ID)
+	require.Equal(t, putCid, list.Next)
+	var lastPage ListAdsRes
+	requireGetOk(t, baseURL+"/admin/ads?limit=2&start="+list.Next.String(), &lastPage)
+	require.Len(t, lastPage.Ads, 1)
+	require.Equal(t, putCid, lastPage.Ads[0].ID)
+	require.Equal(t, cid.Undef, lastPage.Next)
+
+	resp = httpGet(t, baseURL+"/admin/ads/"+ad.Entries.String()+"fish")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = httpGet(t, baseURL+"/admin/ads/bafkqaaa")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = httpGet(t, baseURL+"/admin/ads?limit=0")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// startTestEngineServer starts a Server backed by a started engine with the given options and a
+// CarSupplier, and returns the base URL of the server.
+func startTestEngineServer(t *testing.T, o ...engine.Option) (string, *engine.Engine, *supplier.CarSupplier) {
+	eng, err := engine.New(o...)
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(context.Background()))
+	t.Cleanup(func() { eng.Shutdown() })
+	cs := supplier.NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	subject, err := New(nil, eng, cs, WithListenAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+	go subject.Start()
+	t.Cleanup(func() { subject.Shutdown(contextWithTimeout(t)) })
+	return "http://" + subject.l.Addr().String(), eng, cs
+}
+
+func httpGet(t *testing.T, url string) *http.Response {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func requireGetOk(t *testing.T, url string, res io.ReaderFrom) {
+	resp := httpGet(t, url)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_, err := res.ReadFrom(resp.Body)
+	require.NoError(t, err)
+}
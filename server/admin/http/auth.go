@@ -0,0 +1,75 @@
+package adminserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ScopeReadOnly grants access to the admin endpoints that do not change the state of the
+	// provider, such as listing CARs and jobs.
+	ScopeReadOnly Scope = "read-only"
+	// ScopeReadWrite grants access to all admin endpoints.
+	ScopeReadWrite Scope = "read-write"
+)
+
+// Scope represents the level of access granted by a bearer token.
+type Scope string
+
+// allows checks whether this scope grants access to endpoints that require the given scope.
+func (s Scope) allows(required Scope) bool {
+	return s == ScopeReadWrite || s == required
+}
+
+type bearerToken struct {
+	token []byte
+	scope Scope
+}
+
+// authorize wraps the given handler to require a bearer token with the given scope.
+// Requests are not authenticated if no bearer tokens are configured.
+func (s *Server) authorize(required Scope, next http.HandlerFunc) http.HandlerFunc {
+	if len(s.tokens) == 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		scope, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="index-provider"`)
+			http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		if !scope.allows(required) {
+			http.Error(w, "bearer token does not grant "+string(required)+" access", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authenticate returns the scope granted by the bearer token of the given request.
+func (s *Server) authenticate(r *http.Request) (Scope, bool) {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	given := []byte(strings.TrimSpace(auth[len(prefix):]))
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(given, t.token) == 1 {
+			return t.scope, true
+		}
+	}
+	return "", false
+}
+
+// scopeOf returns the scope granted to the given request. All requests are granted read-write
+// scope if no bearer tokens are configured.
+func (s *Server) scopeOf(r *http.Request) Scope {
+	if len(s.tokens) == 0 {
+		return ScopeReadWrite
+	}
+	scope, _ := s.authenticate(r)
+	return scope
+}
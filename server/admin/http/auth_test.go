@@ -0,0 +1,182 @@
+package adminserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	mock_provider "github.com/filecoin-project/index-provider/mock"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_BearerTokenScopes(t *testing.T) {
+	const (
+		rwToken = "fish"
+		roToken = "lobster"
+	)
+	subject := startTestServer(t,
+		WithBearerToken(rwToken, ScopeReadWrite),
+		WithBearerToken(roToken, ScopeReadOnly))
+	baseURL := "http://" + subject.l.Addr().String()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{
+			name:       "read without token",
+			method:     http.MethodGet,
+			path:       "/admin/list/car",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "read with unknown token",
+			method:     http.MethodGet,
+			path:       "/admin/list/car",
+			token:      "barreleye",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "read with read-only token",
+			method:     http.MethodGet,
+			path:       "/admin/list/car",
+			token:      roToken,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "read with read-write token",
+			method:     http.MethodGet,
+			path:       "/admin/jobs",
+			token:      rwToken,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "write with read-only token",
+			method:     http.MethodPost,
+			path:       "/admin/remove/car",
+			token:      roToken,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "write with read-write token",
+			method:     http.MethodPost,
+			path:       "/admin/remove/car",
+			token:      rwToken,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body string
+			if tt.method == http.MethodPost {
+				body = `{"key":"ZmlzaA=="}`
+			}
+			req, err := http.NewRequest(tt.method, baseURL+tt.path, strings.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	serverCert, serverPool := generateTestCert(t, "127.0.0.1")
+	clientCert, clientPool := generateTestCert(t, "fish")
+	subject := startTestServer(t, WithTLSConfig(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientPool,
+	}))
+	url := "https://" + subject.l.Addr().String() + "/admin/list/car"
+
+	withoutCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: serverPool}}}
+	_, err := withoutCert.Get(url)
+	require.Error(t, err)
+
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      serverPool,
+		Certificates: []tls.Certificate{clientCert},
+	}}}
+	resp, err := withCert.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestWithBearerToken_RejectsInvalidTokens(t *testing.T) {
+	_, err := newOptions(WithBearerToken("", ScopeReadWrite))
+	require.Error(t, err)
+	_, err = newOptions(WithBearerToken("fish", "lobster"))
+	require.Error(t, err)
+}
+
+func startTestServer(t *testing.T, o ...Option) *Server {
+	mc := gomock.NewController(t)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	cs := supplier.NewCarSupplier(mockEng, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	subject, err := New(nil, nil, cs, append(o, WithListenAddr("127.0.0.1:0"))...)
+	require.NoError(t, err)
+	go subject.Start()
+	t.Cleanup(func() { subject.Shutdown(contextWithTimeout(t)) })
+	return subject
+}
+
+// generateTestCert generates a self-signed certificate for the given host, and a pool that
+// trusts it.
+func generateTestCert(t *testing.T, host string) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1413),
+		Subject:               pkix.Name{CommonName: host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else {
+		tmpl.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func contextWithTimeout(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
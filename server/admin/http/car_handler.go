@@ -3,22 +3,39 @@ package adminserver
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
+	"sync"
 
 	"github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/engine"
 	"github.com/filecoin-project/index-provider/metadata"
 	"github.com/filecoin-project/index-provider/supplier"
 	"github.com/ipfs/go-cid"
 )
 
 type carHandler struct {
-	cs *supplier.CarSupplier
+	cs   *supplier.CarSupplier
+	jobs *jobManager
+	// e is the engine from which the currently advertised metadata is read when merging metadata
+	// updates.
+	e *engine.Engine
+	// updateLk serializes metadata updates, such that each merge reads the metadata advertised by
+	// the previous update rather than racing with it.
+	updateLk sync.Mutex
 }
 
 func (h *carHandler) handleImport(w http.ResponseWriter, r *http.Request) {
 	log.Info("received import CAR request")
 
+	async, err := isAsync(r)
+	if err != nil {
+		http.Error(w, "async must be a boolean", http.StatusBadRequest)
+		return
+	}
+
 	// Decode request.
 	var req ImportCarReq
 	if _, err := req.ReadFrom(r.Body); err != nil {
@@ -28,11 +45,6 @@ func (h *carHandler) handleImport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Supply CAR.
-	var advID cid.Cid
-	var err error
-	ctx := context.Background()
-
 	var md metadata.Metadata
 	if err := md.UnmarshalBinary(req.Metadata); err != nil {
 		msg := fmt.Sprintf("failed to unmarshal metadata: %v", err)
@@ -40,9 +52,30 @@ func (h *carHandler) handleImport(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
+	if err := md.ValidateKnown(); err != nil {
+		msg := fmt.Sprintf("invalid metadata: %v", err)
+		log.Infow(msg, "path", req.Path)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
 
+	// Supply CAR as a job.
 	log.Info("importing CAR")
-	advID, err = h.cs.Put(ctx, req.Key, req.Path, md)
+	j, err := h.jobs.start(JobKindImportCar, req.Key, req.Path, func(ctx context.Context) (cid.Cid, error) {
+		return h.cs.Put(ctx, req.Key, req.Path, md)
+	})
+	if err != nil {
+		msg := fmt.Sprintf("failed to start import job: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if async {
+		respondJobAccepted(w, j)
+		return
+	}
+	// Wait for the import to complete, cancelling it if the request is cancelled.
+	advID, err := j.wait(r.Context())
 
 	// Respond with cause of failure.
 	if err != nil {
@@ -52,6 +85,12 @@ func (h *carHandler) handleImport(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, msg, http.StatusConflict)
 			return
 		}
+		if errors.Is(err, supplier.ErrInvalidCar) || errors.Is(err, fs.ErrNotExist) {
+			msg := fmt.Sprintf("cannot import CAR: %v", err)
+			log.Infow(msg, "path", req.Path)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
 		msg := fmt.Sprintf("failed to import CAR: %v", err)
 		log.Errorw(msg, "err", err, "path", req.Path)
 		http.Error(w, msg, http.StatusInternalServerError)
@@ -68,6 +107,12 @@ func (h *carHandler) handleImport(w http.ResponseWriter, r *http.Request) {
 func (h *carHandler) handleRemove(w http.ResponseWriter, r *http.Request) {
 	log.Info("Received remove CAR request")
 
+	async, err := isAsync(r)
+	if err != nil {
+		http.Error(w, "async must be a boolean", http.StatusBadRequest)
+		return
+	}
+
 	// Decode request.
 	var req RemoveCarReq
 	if _, err := req.ReadFrom(r.Body); err != nil {
@@ -85,7 +130,21 @@ func (h *carHandler) handleRemove(w http.ResponseWriter, r *http.Request) {
 	b64Key := base64.StdEncoding.EncodeToString(req.Key)
 	// Remove CAR.
 	log.Infow("Removing CAR by key", "key", b64Key)
-	advID, err := h.cs.Remove(context.Background(), req.Key)
+	j, err := h.jobs.start(JobKindRemoveCar, req.Key, "", func(ctx context.Context) (cid.Cid, error) {
+		return h.cs.Remove(ctx, req.Key)
+	})
+	if err != nil {
+		msg := fmt.Sprintf("failed to start remove job: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if async {
+		respondJobAccepted(w, j)
+		return
+	}
+	// Wait for the removal to complete, cancelling it if the request is cancelled.
+	advID, err := j.wait(r.Context())
 
 	// Respond with cause of failure.
 	if err != nil {
@@ -108,6 +167,75 @@ func (h *carHandler) handleRemove(w http.ResponseWriter, r *http.Request) {
 	respond(w, http.StatusOK, resp)
 }
 
+func (h *carHandler) handleUpdateMetadata(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received update metadata request")
+
+	// Decode request.
+	var req UpdateMetadataReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if len(req.Key) == 0 {
+		http.Error(w, "key must be specified", http.StatusBadRequest)
+		return
+	}
+	update, err := newMetadataUpdate(&req)
+	if err != nil {
+		msg := err.Error()
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	b64Key := base64.StdEncoding.EncodeToString(req.Key)
+	h.updateLk.Lock()
+	defer h.updateLk.Unlock()
+	md, err := update.apply(r.Context(), h.e, req.Key)
+	if err != nil {
+		if errors.Is(err, provider.ErrContextIDNotFound) {
+			msg := fmt.Sprintf("provider has no metadata advertised for key %s", b64Key)
+			log.Info(msg)
+			http.Error(w, msg, http.StatusNotFound)
+			return
+		}
+		msg := fmt.Sprintf("failed to merge metadata: %v", err)
+		log.Errorw(msg, "err", err, "key", b64Key)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if err := md.ValidateKnown(); err != nil {
+		msg := fmt.Sprintf("invalid metadata: %v", err)
+		log.Infow(msg, "key", b64Key)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	log.Infow("Updating metadata by key", "key", b64Key)
+	advID, err := h.cs.UpdateMetadata(r.Context(), req.Key, md)
+	if err != nil {
+		switch err {
+		case supplier.ErrNotFound:
+			msg := fmt.Sprintf("provider has no car file for key %s", b64Key)
+			log.Info(msg)
+			http.Error(w, msg, http.StatusNotFound)
+		case provider.ErrAlreadyAdvertised:
+			msg := "CAR already advertised with the given metadata"
+			log.Infow(msg, "key", b64Key)
+			http.Error(w, msg, http.StatusConflict)
+		default:
+			log.Errorw("Failed to update metadata", "err", err, "key", b64Key)
+			http.Error(w, fmt.Sprintf("error updating metadata: %s", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Infow("Updated metadata successfully", "contextID", b64Key, "advertisement", advID)
+	respond(w, http.StatusOK, &UpdateMetadataRes{req.Key, advID})
+}
+
 func (h *carHandler) handleList(w http.ResponseWriter, _ *http.Request) {
 	paths, err := h.cs.List(context.Background())
 	if err != nil {
@@ -10,6 +10,8 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	provider "github.com/filecoin-project/index-provider"
@@ -38,7 +40,7 @@ func Test_importCarHandler(t *testing.T) {
 	require.NoError(t, err)
 
 	icReq := &ImportCarReq{
-		Path:     "fish",
+		Path:     testCarPath(t),
 		Key:      wantKey,
 		Metadata: mdBytes,
 	}
@@ -55,7 +57,7 @@ func Test_importCarHandler(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs, jobs: newJobManager()}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleImport)
@@ -90,7 +92,7 @@ func Test_importCarHandlerFail(t *testing.T) {
 	mdBytes, err := wantMetadata.MarshalBinary()
 	require.NoError(t, err)
 	icReq := &ImportCarReq{
-		Path:     "fish",
+		Path:     testCarPath(t),
 		Key:      wantKey,
 		Metadata: mdBytes,
 	}
@@ -107,7 +109,7 @@ func Test_importCarHandlerFail(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs, jobs: newJobManager()}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleImport)
@@ -134,7 +136,7 @@ func Test_importCarAlreadyAdvertised(t *testing.T) {
 	mdBytes, err := wantMetadata.MarshalBinary()
 	require.NoError(t, err)
 	icReq := &ImportCarReq{
-		Path:     "fish",
+		Path:     testCarPath(t),
 		Key:      wantKey,
 		Metadata: mdBytes,
 	}
@@ -151,7 +153,7 @@ func Test_importCarAlreadyAdvertised(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs, jobs: newJobManager()}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleImport)
@@ -170,6 +172,67 @@ func Test_importCarAlreadyAdvertised(t *testing.T) {
 	require.Equal(t, "CAR already advertised\n", string(respBytes))
 }
 
+func Test_importCarHandler_MissingCarIsBadRequest(t *testing.T) {
+	wantKey := []byte("lobster")
+	wantTp, err := cardatatransfer.TransportFromContextID(wantKey)
+	require.NoError(t, err)
+	wantMetadata := metadata.New(wantTp)
+	mdBytes, err := wantMetadata.MarshalBinary()
+	require.NoError(t, err)
+	icReq := &ImportCarReq{
+		Path:     filepath.Join(t.TempDir(), "fish.car"),
+		Key:      wantKey,
+		Metadata: mdBytes,
+	}
+
+	jsonReq, err := json.Marshal(icReq)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, "/admin/import/car", bytes.NewReader(jsonReq))
+	require.NoError(t, err)
+
+	mc := gomock.NewController(t)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	cs := supplier.NewCarSupplier(mockEng, ds)
+
+	subject := carHandler{cs: cs, jobs: newJobManager()}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(subject.handleImport)
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func Test_importCarHandler_UnknownMetadataIsBadRequest(t *testing.T) {
+	icReq := &ImportCarReq{
+		Path:     filepath.Join(t.TempDir(), "fish.car"),
+		Key:      []byte("lobster"),
+		Metadata: []byte("fish"),
+	}
+	jsonReq, err := json.Marshal(icReq)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/admin/import/car", bytes.NewReader(jsonReq))
+	require.NoError(t, err)
+
+	mc := gomock.NewController(t)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	cs := supplier.NewCarSupplier(mockEng, ds)
+
+	subject := carHandler{cs: cs, jobs: newJobManager()}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(subject.handleImport).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	respBytes, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, "invalid metadata: metadata has no known protocol\n", string(respBytes))
+}
+
 func Test_removeCarHandler(t *testing.T) {
 	rng := rand.New(rand.NewSource(1413))
 	wantKey := []byte("lobster")
@@ -181,7 +244,7 @@ func Test_removeCarHandler(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs, jobs: newJobManager()}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -217,7 +280,7 @@ func Test_removeCarHandlerFail(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs, jobs: newJobManager()}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -247,7 +310,7 @@ func Test_removeCarHandler_NonExistingCarIsNotFound(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs, jobs: newJobManager()}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -265,7 +328,7 @@ func Test_removeCarHandler_UnspecifiedKeyIsBadRequest(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs, jobs: newJobManager()}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -283,7 +346,7 @@ func Test_removeCarHandler_InvalidJsonIsBadRequest(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs, jobs: newJobManager()}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -292,6 +355,95 @@ func Test_removeCarHandler_InvalidJsonIsBadRequest(t *testing.T) {
 	require.Equal(t, http.StatusBadRequest, rr.Code)
 }
 
+func Test_updateMetadataHandler(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	wantKey := []byte("lobster")
+	newMd := metadata.New(&metadata.Bitswap{})
+	newMdBytes, err := newMd.MarshalBinary()
+	require.NoError(t, err)
+
+	mc := gomock.NewController(t)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	cs := supplier.NewCarSupplier(mockEng, ds)
+	subject := carHandler{cs: cs, jobs: newJobManager()}
+	handler := http.HandlerFunc(subject.handleUpdateMetadata)
+
+	update := func(key, md []byte) *httptest.ResponseRecorder {
+		jsonReq, err := json.Marshal(&UpdateMetadataReq{Key: key, Metadata: md})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "/admin/update/metadata", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Unknown keys are not found.
+	require.Equal(t, http.StatusNotFound, update(wantKey, newMdBytes).Code)
+	// Key and valid metadata must be specified.
+	require.Equal(t, http.StatusBadRequest, update(nil, newMdBytes).Code)
+	require.Equal(t, http.StatusBadRequest, update(wantKey, []byte("fish")).Code)
+
+	requireMockPut(t, mockEng, wantKey, cs, rng)
+	wantCid := testutil.RandomCids(t, rng, 1)[0]
+	mockEng.
+		EXPECT().
+		NotifyPut(gomock.Any(), gomock.Eq(wantKey), newMd).
+		Return(wantCid, nil)
+	rr := update(wantKey, newMdBytes)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp UpdateMetadataRes
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, wantKey, resp.Key)
+	require.Equal(t, wantCid, resp.AdvId)
+
+	// Updating to the same metadata is a conflict.
+	mockEng.
+		EXPECT().
+		NotifyPut(gomock.Any(), gomock.Eq(wantKey), newMd).
+		Return(cid.Undef, provider.ErrAlreadyAdvertised)
+	require.Equal(t, http.StatusConflict, update(wantKey, newMdBytes).Code)
+}
+
+func Test_updateMetadataHandler_ConcurrentMergesAreSerialized(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	baseURL, eng, cs := startTestEngineServer(t)
+	key := []byte("fish")
+	gsMd := metadata.New(&metadata.GraphsyncFilecoinV1{PieceCID: testutil.RandomPieceCids(t, rand.New(rand.NewSource(1413)), 1)[0]})
+	_, err := cs.Put(ctx, key, testCarPath(t), gsMd)
+	require.NoError(t, err)
+
+	// Merge each protocol concurrently; every merge must be reflected in the advertised metadata.
+	protocols := []metadata.Protocol{&metadata.Bitswap{}, &metadata.IpfsGatewayHttp{}}
+	var wg sync.WaitGroup
+	codes := make([]int, len(protocols))
+	for i, p := range protocols {
+		md := metadata.New(p)
+		mdBytes, err := md.MarshalBinary()
+		require.NoError(t, err)
+		jsonReq, err := json.Marshal(&UpdateMetadataReq{Key: key, Metadata: mdBytes, Merge: true})
+		require.NoError(t, err)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := http.Post(baseURL+"/admin/update/metadata", "application/json", bytes.NewReader(jsonReq))
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+			codes[i] = resp.StatusCode
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, []int{http.StatusOK, http.StatusOK}, codes)
+
+	info, err := eng.GetContextID(ctx, key)
+	require.NoError(t, err)
+	require.True(t, gsMd.With(protocols...).Equal(info.Metadata), "got %v", info.Metadata.Protocols())
+}
+
 func requireRemoveCarHttpRequestFromKey(t *testing.T, key []byte) *http.Request {
 	jsonReq, err := json.Marshal(&RemoveCarReq{Key: key})
 	require.NoError(t, err)
@@ -306,6 +458,10 @@ func requireRemoveCarHttpRequest(t *testing.T, body io.Reader) *http.Request {
 	return req
 }
 
+func testCarPath(t *testing.T) string {
+	return filepath.Join(testutil.ThisDir(t), "../../../testdata/sample-v1.car")
+}
+
 func requireMockPut(t *testing.T, mockEng *mock_provider.MockInterface, key []byte, cs *supplier.CarSupplier, rng *rand.Rand) {
 	wantTp, err := cardatatransfer.TransportFromContextID(key)
 	require.NoError(t, err)
@@ -315,13 +471,13 @@ func requireMockPut(t *testing.T, mockEng *mock_provider.MockInterface, key []by
 		EXPECT().
 		NotifyPut(gomock.Any(), gomock.Eq(key), wantMetadata).
 		Return(wantCid, nil)
-	_, err = cs.Put(context.Background(), key, "/fish/in/da/sea", wantMetadata)
+	_, err = cs.Put(context.Background(), key, testCarPath(t), wantMetadata)
 	require.NoError(t, err)
 }
 
 func Test_ListCarHandler(t *testing.T) {
 	rng := rand.New(rand.NewSource(1413))
-	wantPath := "fish"
+	wantPath := testCarPath(t)
 	wantKey := []byte("lobster")
 	wantTp, err := cardatatransfer.TransportFromContextID(wantKey)
 	require.NoError(t, err)
@@ -335,7 +491,7 @@ func Test_ListCarHandler(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs, jobs: newJobManager()}
 
 	req, err := http.NewRequest(http.MethodGet, "/admin/list/car", nil)
 	require.NoError(t, err)
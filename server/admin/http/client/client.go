@@ -0,0 +1,260 @@
+// Package adminclient provides clients of the admin server served by adminserver.Server: Client
+// for the HTTP API, and RPCClient for the RPC service.
+package adminclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	provider "github.com/filecoin-project/index-provider"
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/ipfs/go-cid"
+)
+
+const (
+	// jobPollInterval is the interval at which the status of awaited jobs is polled.
+	jobPollInterval = 500 * time.Millisecond
+	// jobCancelTimeout bounds the cancellation of awaited jobs once the caller gives up on them.
+	jobCancelTimeout = 10 * time.Second
+)
+
+// Client sends requests to the admin HTTP API.
+//
+// Responses with status 404 Not Found are returned as errors that wrap supplier.ErrNotFound, and
+// responses with status 409 Conflict as errors that wrap provider.ErrAlreadyAdvertised.
+type Client struct {
+	addr string
+	*options
+}
+
+// New instantiates a new client of the admin server listening at the given address, e.g.
+// http://localhost:3102.
+func New(addr string, o ...Option) *Client {
+	return &Client{
+		addr:    strings.TrimSuffix(addr, "/"),
+		options: newOptions(o...),
+	}
+}
+
+// ImportCar imports the CAR at the given path with the given key and metadata, and returns the
+// ID of the resulting advertisement once the import has completed. The metadata must be
+// marshalled as binary.
+func (c *Client) ImportCar(ctx context.Context, path string, key, md []byte) (*adminserver.ImportCarRes, error) {
+	req := &adminserver.ImportCarReq{Path: path, Key: key, Metadata: md}
+	var res adminserver.ImportCarRes
+	if err := c.do(ctx, http.MethodPost, "/admin/import/car", req, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ImportCarAsync starts importing the CAR at the given path as a job, and returns the started job.
+func (c *Client) ImportCarAsync(ctx context.Context, path string, key, md []byte) (*adminserver.JobRes, error) {
+	req := &adminserver.ImportCarReq{Path: path, Key: key, Metadata: md}
+	var res adminserver.JobRes
+	if err := c.do(ctx, http.MethodPost, "/admin/import/car?async=true", req, http.StatusAccepted, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RemoveCar removes the CAR with the given key, and returns the ID of the resulting removal
+// advertisement once the removal has completed.
+func (c *Client) RemoveCar(ctx context.Context, key []byte) (cid.Cid, error) {
+	var res adminserver.RemoveCarRes
+	if err := c.do(ctx, http.MethodPost, "/admin/remove/car", &adminserver.RemoveCarReq{Key: key}, http.StatusOK, &res); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// RemoveCarAsync starts removing the CAR with the given key as a job, and returns the started job.
+func (c *Client) RemoveCarAsync(ctx context.Context, key []byte) (*adminserver.JobRes, error) {
+	var res adminserver.JobRes
+	if err := c.do(ctx, http.MethodPost, "/admin/remove/car?async=true", &adminserver.RemoveCarReq{Key: key}, http.StatusAccepted, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateMetadata changes the metadata of the CAR imported with the given key, and returns the ID
+// of the resulting advertisement. The metadata must be marshalled as binary.
+func (c *Client) UpdateMetadata(ctx context.Context, key, md []byte) (cid.Cid, error) {
+	var res adminserver.UpdateMetadataRes
+	req := &adminserver.UpdateMetadataReq{Key: key, Metadata: md}
+	if err := c.do(ctx, http.MethodPost, "/admin/update/metadata", req, http.StatusOK, &res); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// MergeMetadata merges the given metadata into the metadata currently advertised for the CAR
+// imported with the given key, and returns the ID of the resulting advertisement. Only the
+// protocols present in the given metadata are replaced, after which the protocols with the given
+// names are removed, e.g. bitswap. The metadata must be marshalled as binary, and may be empty
+// when only removing protocols.
+func (c *Client) MergeMetadata(ctx context.Context, key, md []byte, remove ...string) (cid.Cid, error) {
+	var res adminserver.UpdateMetadataRes
+	req := &adminserver.UpdateMetadataReq{Key: key, Metadata: md, Merge: true, Remove: remove}
+	if err := c.do(ctx, http.MethodPost, "/admin/update/metadata", req, http.StatusOK, &res); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// ListCars lists the paths of the imported CARs.
+func (c *Client) ListCars(ctx context.Context) ([]string, error) {
+	var res adminserver.ListCarRes
+	if err := c.do(ctx, http.MethodGet, "/admin/list/car", nil, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+	return res.Paths, nil
+}
+
+// Announce announces the latest advertisement.
+func (c *Client) Announce(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/admin/announce", nil, http.StatusOK, nil)
+}
+
+// Connect connects the provider to the peer at the given multiaddr, which must include the peer
+// ID.
+func (c *Client) Connect(ctx context.Context, maddr string) error {
+	var res adminserver.ConnectRes
+	return c.do(ctx, http.MethodPost, "/admin/connect", &adminserver.ConnectReq{Maddr: maddr}, http.StatusOK, &res)
+}
+
+// Drain removes all the content advertised by the provider, reading the given number of context
+// IDs at a time, and returns the drain job once it has completed. A batch size of zero uses the
+// server default. The drain job is cancelled if the given context is done before it completes.
+func (c *Client) Drain(ctx context.Context, batchSize int) (*adminserver.JobRes, error) {
+	path := "/admin/drain"
+	if batchSize != 0 {
+		path += "?batch=" + strconv.Itoa(batchSize)
+	}
+	var res adminserver.JobRes
+	if err := c.do(ctx, http.MethodPost, path, nil, http.StatusAccepted, &res); err != nil {
+		return nil, err
+	}
+	return awaitJob(ctx, c, &res)
+}
+
+// GetJob gets the job with the given ID.
+func (c *Client) GetJob(ctx context.Context, id string) (*adminserver.JobRes, error) {
+	var res adminserver.JobRes
+	if err := c.do(ctx, http.MethodGet, "/admin/jobs/"+id, nil, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CancelJob requests the cancellation of the job with the given ID.
+func (c *Client) CancelJob(ctx context.Context, id string) (*adminserver.JobRes, error) {
+	var res adminserver.JobRes
+	if err := c.do(ctx, http.MethodPost, "/admin/jobs/"+id+"/cancel", nil, http.StatusAccepted, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// jobClient gets and cancels jobs; it is implemented by both Client and RPCClient.
+type jobClient interface {
+	GetJob(ctx context.Context, id string) (*adminserver.JobRes, error)
+	CancelJob(ctx context.Context, id string) (*adminserver.JobRes, error)
+}
+
+// awaitJob polls the given job until it finishes, and returns an error if it did not succeed. The
+// job is cancelled if the given context is done first.
+func awaitJob(ctx context.Context, c jobClient, job *adminserver.JobRes) (*adminserver.JobRes, error) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for job.Finished == nil {
+		select {
+		case <-ctx.Done():
+			cctx, cancel := context.WithTimeout(context.Background(), jobCancelTimeout)
+			defer cancel()
+			if _, err := c.CancelJob(cctx, job.ID); err != nil {
+				return nil, fmt.Errorf("failed to cancel job %s: %w", job.ID, err)
+			}
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+		var err error
+		if job, err = c.GetJob(ctx, job.ID); err != nil {
+			return nil, err
+		}
+	}
+	if job.Status != adminserver.JobStatusSucceeded {
+		return nil, fmt.Errorf("job %s %s: %s", job.ID, job.Status, job.Error)
+	}
+	return job, nil
+}
+
+// do sends a request with the given method and JSON body to the given path, and reads the
+// response body into the given result if the response has the expected status.
+func (c *Client) do(ctx context.Context, method, path string, body io.WriterTo, wantStatus int, result io.ReaderFrom) error {
+	var reqBody io.Reader
+	if body != nil {
+		var buf bytes.Buffer
+		if _, err := body.WriteTo(&buf); err != nil {
+			return err
+		}
+		reqBody = &buf
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.addr+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		return errFromResponse(resp)
+	}
+	if result == nil {
+		return nil
+	}
+	if _, err := result.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received %s response but cannot decode response body: %w", resp.Status, err)
+	}
+	return nil
+}
+
+// errFromResponse constructs an error from the given unexpected response.
+// See: errFromStatus.
+func errFromResponse(resp *http.Response) error {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", resp.Status, err)
+	}
+	return errFromStatus(resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// errFromStatus constructs the error of a request that failed with the given HTTP status and
+// message, mapping the status to the corresponding error when there is one. Errors of both Client
+// and RPCClient are constructed this way, so that failures are reported alike by either.
+func errFromStatus(status int, msg string) error {
+	switch status {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", supplier.ErrNotFound, msg)
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s", provider.ErrAlreadyAdvertised, msg)
+	default:
+		return fmt.Errorf("%s: %s", http.StatusText(status), msg)
+	}
+}
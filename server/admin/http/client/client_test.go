@@ -0,0 +1,164 @@
+package adminclient_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	adminclient "github.com/filecoin-project/index-provider/server/admin/http/client"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+// testToken is the bearer token with which the test admin server authenticates requests.
+const testToken = "fish"
+
+// startTestServer starts an admin server backed by an engine instantiated with the given options,
+// and returns its address along with the engine.
+func startTestServer(t *testing.T, engineOpts []engine.Option, serverOpts ...adminserver.Option) (string, *engine.Engine) {
+	ctx := contextWithTimeout(t)
+	eng, err := engine.New(engineOpts...)
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	cs := supplier.NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+	serverOpts = append([]adminserver.Option{
+		adminserver.WithListenAddr("127.0.0.1:0"),
+		adminserver.WithBearerToken(testToken, adminserver.ScopeReadWrite),
+	}, serverOpts...)
+	server, err := adminserver.New(nil, eng, cs, serverOpts...)
+	require.NoError(t, err)
+	go server.Start()
+	t.Cleanup(func() { server.Shutdown(ctx) })
+	return "http://" + server.Addr().String(), eng
+}
+
+func contextWithTimeout(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestClient_RoundTrip(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	addr, eng := startTestServer(t, nil)
+
+	testdata := filepath.Join(testutil.ThisDir(t), "../../../../testdata")
+	v1Path := filepath.Join(testdata, "sample-v1.car")
+	v2Path := filepath.Join(testdata, "sample-wrapped-v2.car")
+	bitswapMd := metadata.New(metadata.Bitswap{})
+	md, err := bitswapMd.MarshalBinary()
+	require.NoError(t, err)
+
+	// Requests without the bearer token are rejected.
+	_, err = adminclient.New(addr).ListCars(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Unauthorized")
+
+	subject := adminclient.New(addr, adminclient.WithBearerToken(testToken))
+
+	paths, err := subject.ListCars(ctx)
+	require.NoError(t, err)
+	require.Empty(t, paths)
+
+	imported, err := subject.ImportCar(ctx, v1Path, []byte("fish"), md)
+	require.NoError(t, err)
+	require.Equal(t, []byte("fish"), imported.Key)
+	require.True(t, imported.AdvId.Defined())
+
+	// Importing the same CAR again is a conflict.
+	_, err = subject.ImportCar(ctx, v1Path, []byte("fish"), md)
+	require.True(t, errors.Is(err, provider.ErrAlreadyAdvertised), "unexpected error: %v", err)
+
+	// The metadata of imported CARs can be updated, but not to the same metadata.
+	pieceCid := testutil.RandomPieceCids(t, rand.New(rand.NewSource(1413)), 1)[0]
+	gsMd := metadata.New(&metadata.GraphsyncFilecoinV1{PieceCID: pieceCid, FastRetrieval: true})
+	newMd, err := gsMd.MarshalBinary()
+	require.NoError(t, err)
+	updatedAd, err := subject.UpdateMetadata(ctx, []byte("fish"), newMd)
+	require.NoError(t, err)
+	require.NotEqual(t, imported.AdvId, updatedAd)
+	_, err = subject.UpdateMetadata(ctx, []byte("fish"), newMd)
+	require.True(t, errors.Is(err, provider.ErrAlreadyAdvertised), "unexpected error: %v", err)
+	_, err = subject.UpdateMetadata(ctx, []byte("undadasea"), newMd)
+	require.True(t, errors.Is(err, supplier.ErrNotFound), "unexpected error: %v", err)
+
+	// Protocols can be merged into, and removed from, the advertised metadata.
+	_, err = subject.MergeMetadata(ctx, []byte("fish"), md)
+	require.NoError(t, err)
+	info, err := eng.GetContextID(ctx, []byte("fish"))
+	require.NoError(t, err)
+	require.True(t, gsMd.With(metadata.Bitswap{}).Equal(info.Metadata))
+	_, err = subject.MergeMetadata(ctx, []byte("fish"), nil, "graphsync-filecoinv1")
+	require.NoError(t, err)
+	info, err = eng.GetContextID(ctx, []byte("fish"))
+	require.NoError(t, err)
+	require.True(t, bitswapMd.Equal(info.Metadata))
+	_, err = subject.MergeMetadata(ctx, []byte("fish"), nil, "bitswap")
+	require.EqualError(t, err, "Bad Request: invalid metadata: at least one transport must be specified")
+	_, err = subject.MergeMetadata(ctx, []byte("fish"), nil, "fish")
+	require.EqualError(t, err, "Bad Request: unknown protocol: fish")
+	_, err = subject.MergeMetadata(ctx, []byte("undadasea"), md)
+	require.True(t, errors.Is(err, supplier.ErrNotFound), "unexpected error: %v", err)
+
+	job, err := subject.ImportCarAsync(ctx, v2Path, []byte("lobster"), md)
+	require.NoError(t, err)
+	require.Equal(t, adminserver.JobKindImportCar, job.Kind)
+	require.Equal(t, v2Path, job.Path)
+	require.Eventually(t, func() bool {
+		paths, err := subject.ListCars(ctx)
+		require.NoError(t, err)
+		return len(paths) == 2
+	}, 10*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		got, err := subject.GetJob(ctx, job.ID)
+		require.NoError(t, err)
+		return got.Status == adminserver.JobStatusSucceeded
+	}, 10*time.Second, 10*time.Millisecond)
+	_, err = subject.CancelJob(ctx, "undadasea")
+	require.True(t, errors.Is(err, supplier.ErrNotFound), "unexpected error: %v", err)
+
+	removedAd, err := subject.RemoveCar(ctx, []byte("fish"))
+	require.NoError(t, err)
+	require.True(t, removedAd.Defined())
+
+	// Removing a CAR that is no longer advertised is not found.
+	_, err = subject.RemoveCar(ctx, []byte("fish"))
+	require.True(t, errors.Is(err, supplier.ErrNotFound), "unexpected error: %v", err)
+	job, err = subject.RemoveCarAsync(ctx, []byte("lobster"))
+	require.NoError(t, err)
+	require.Equal(t, adminserver.JobKindRemoveCar, job.Kind)
+
+	require.NoError(t, subject.Announce(ctx))
+
+	// Draining removes all CARs.
+	require.Eventually(t, func() bool {
+		paths, err := subject.ListCars(ctx)
+		require.NoError(t, err)
+		return len(paths) == 0
+	}, 10*time.Second, 10*time.Millisecond)
+	_, err = subject.ImportCar(ctx, filepath.Join(testdata, "sample-v1-2.car"), []byte("crab"), md)
+	require.NoError(t, err)
+	drain, err := subject.Drain(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, adminserver.JobStatusSucceeded, drain.Status)
+	require.Equal(t, &adminserver.JobProgress{Done: 1, Total: 1}, drain.Progress)
+	paths, err = subject.ListCars(ctx)
+	require.NoError(t, err)
+	require.Empty(t, paths)
+
+	// Connecting requires a valid multiaddr.
+	err = subject.Connect(ctx, "fish")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Bad Request")
+}
@@ -0,0 +1,40 @@
+package adminclient
+
+import "net/http"
+
+type (
+	// Option captures a configurable parameter of Client.
+	Option func(*options)
+
+	options struct {
+		httpClient *http.Client
+		token      string
+	}
+)
+
+func newOptions(o ...Option) *options {
+	opts := &options{
+		httpClient: http.DefaultClient,
+	}
+	for _, apply := range o {
+		apply(opts)
+	}
+	return opts
+}
+
+// WithHttpClient sets the HTTP client used to send requests to the admin server, for example to
+// configure TLS.
+// If unset, http.DefaultClient is used.
+func WithHttpClient(c *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = c
+	}
+}
+
+// WithBearerToken sets the bearer token with which requests are authenticated.
+// If unset, requests are sent without authentication.
+func WithBearerToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
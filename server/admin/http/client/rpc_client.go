@@ -0,0 +1,306 @@
+package adminclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+// rpcCodeStatuses maps the codes of errors returned by the RPC service to the HTTP statuses with
+// which the equivalent REST endpoints respond. Codes that are not mapped correspond to
+// http.StatusInternalServerError.
+var rpcCodeStatuses = map[int]int{
+	adminserver.RPCCodeParseError:     http.StatusBadRequest,
+	adminserver.RPCCodeInvalidRequest: http.StatusBadRequest,
+	adminserver.RPCCodeMethodNotFound: http.StatusNotImplemented,
+	adminserver.RPCCodeInvalidParams:  http.StatusBadRequest,
+	adminserver.RPCCodeNotFound:       http.StatusNotFound,
+	adminserver.RPCCodeConflict:       http.StatusConflict,
+	adminserver.RPCCodeForbidden:      http.StatusForbidden,
+}
+
+// RPCClient calls the methods of the admin RPC service served at /admin/rpc.
+//
+// Errors returned by the service are reported in the same way as by Client, i.e. errors of
+// unknown CARs wrap supplier.ErrNotFound and conflicting imports wrap
+// provider.ErrAlreadyAdvertised.
+type RPCClient struct {
+	url    string
+	nextID uint64
+	*options
+}
+
+// NewRPC instantiates a new client of the RPC service of the admin server listening at the given
+// address, e.g. http://localhost:3102.
+func NewRPC(addr string, o ...Option) *RPCClient {
+	return &RPCClient{
+		url:     strings.TrimSuffix(addr, "/") + "/admin/rpc",
+		options: newOptions(o...),
+	}
+}
+
+// Announce announces the latest advertisement.
+func (c *RPCClient) Announce(ctx context.Context) error {
+	return c.call(ctx, adminserver.RPCMethodAnnounce, nil, &adminserver.AnnounceRes{}, nil)
+}
+
+// Connect connects the provider to the peer at the given multiaddr, which must include the peer
+// ID.
+func (c *RPCClient) Connect(ctx context.Context, maddr string) error {
+	return c.call(ctx, adminserver.RPCMethodConnect, &adminserver.ConnectReq{Maddr: maddr}, &adminserver.ConnectRes{}, nil)
+}
+
+// ImportCar imports the CAR at the given path with the given key and metadata, and returns the
+// ID of the resulting advertisement once the import has completed. The metadata must be
+// marshalled as binary.
+func (c *RPCClient) ImportCar(ctx context.Context, path string, key, md []byte) (*adminserver.ImportCarRes, error) {
+	var res adminserver.ImportCarRes
+	req := &adminserver.ImportCarReq{Path: path, Key: key, Metadata: md}
+	if err := c.call(ctx, adminserver.RPCMethodImportCar, req, &res, nil); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ImportCarAsync starts importing the CAR at the given path as a job, and returns the started job.
+func (c *RPCClient) ImportCarAsync(ctx context.Context, path string, key, md []byte) (*adminserver.JobRes, error) {
+	var res adminserver.JobRes
+	req := &adminserver.ImportCarReq{Path: path, Key: key, Metadata: md}
+	if err := c.call(ctx, adminserver.RPCMethodImportCarAsync, req, &res, nil); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ImportManifest imports the CARs listed in the given manifest rows, importing up to the given
+// number of CARs concurrently. The given progress function, if non-nil, is called with the result
+// of each row as soon as it is imported.
+func (c *RPCClient) ImportManifest(ctx context.Context, rows []adminserver.ImportCarReq, concurrency int, progress func(*adminserver.ImportManifestRes)) (*adminserver.ImportManifestSummaryRes, error) {
+	var res adminserver.ImportManifestSummaryRes
+	req := &adminserver.ImportManifestReq{Rows: rows, Concurrency: concurrency}
+	onNotify := func(msg *adminserver.RPCMessage) error {
+		if msg.Method != adminserver.RPCMethodImportProgress || progress == nil {
+			return nil
+		}
+		var rowRes adminserver.ImportManifestRes
+		if err := json.Unmarshal(msg.Params, &rowRes); err != nil {
+			return fmt.Errorf("failed to unmarshal import progress: %w", err)
+		}
+		progress(&rowRes)
+		return nil
+	}
+	if err := c.call(ctx, adminserver.RPCMethodImportManifest, req, &res, onNotify); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RemoveCar removes the CAR with the given key, and returns the ID of the resulting removal
+// advertisement once the removal has completed.
+func (c *RPCClient) RemoveCar(ctx context.Context, key []byte) (cid.Cid, error) {
+	var res adminserver.RemoveCarRes
+	if err := c.call(ctx, adminserver.RPCMethodRemoveCar, &adminserver.RemoveCarReq{Key: key}, &res, nil); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// RemoveCarAsync starts removing the CAR with the given key as a job, and returns the started job.
+func (c *RPCClient) RemoveCarAsync(ctx context.Context, key []byte) (*adminserver.JobRes, error) {
+	var res adminserver.JobRes
+	if err := c.call(ctx, adminserver.RPCMethodRemoveCarAsync, &adminserver.RemoveCarReq{Key: key}, &res, nil); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateMetadata changes the metadata of the CAR imported with the given key, and returns the ID
+// of the resulting advertisement. The metadata must be marshalled as binary.
+func (c *RPCClient) UpdateMetadata(ctx context.Context, key, md []byte) (cid.Cid, error) {
+	var res adminserver.UpdateMetadataRes
+	req := &adminserver.UpdateMetadataReq{Key: key, Metadata: md}
+	if err := c.call(ctx, adminserver.RPCMethodUpdateMetadata, req, &res, nil); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// MergeMetadata merges the given metadata into the metadata currently advertised for the CAR
+// imported with the given key, and returns the ID of the resulting advertisement. Only the
+// protocols present in the given metadata are replaced, after which the protocols with the given
+// names are removed, e.g. bitswap. The metadata must be marshalled as binary, and may be empty
+// when only removing protocols.
+func (c *RPCClient) MergeMetadata(ctx context.Context, key, md []byte, remove ...string) (cid.Cid, error) {
+	var res adminserver.UpdateMetadataRes
+	req := &adminserver.UpdateMetadataReq{Key: key, Metadata: md, Merge: true, Remove: remove}
+	if err := c.call(ctx, adminserver.RPCMethodUpdateMetadata, req, &res, nil); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// ListCars lists the paths of the imported CARs.
+func (c *RPCClient) ListCars(ctx context.Context) ([]string, error) {
+	var res adminserver.ListCarRes
+	if err := c.call(ctx, adminserver.RPCMethodListCars, nil, &res, nil); err != nil {
+		return nil, err
+	}
+	return res.Paths, nil
+}
+
+// ListJobs lists the import and removal jobs known to the admin server.
+func (c *RPCClient) ListJobs(ctx context.Context) ([]adminserver.JobRes, error) {
+	var res adminserver.ListJobsRes
+	if err := c.call(ctx, adminserver.RPCMethodListJobs, nil, &res, nil); err != nil {
+		return nil, err
+	}
+	return res.Jobs, nil
+}
+
+// GetJob gets the job with the given ID.
+func (c *RPCClient) GetJob(ctx context.Context, id string) (*adminserver.JobRes, error) {
+	var res adminserver.JobRes
+	if err := c.call(ctx, adminserver.RPCMethodGetJob, &adminserver.JobReq{ID: id}, &res, nil); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CancelJob requests the cancellation of the job with the given ID.
+func (c *RPCClient) CancelJob(ctx context.Context, id string) (*adminserver.JobRes, error) {
+	var res adminserver.JobRes
+	if err := c.call(ctx, adminserver.RPCMethodCancelJob, &adminserver.JobReq{ID: id}, &res, nil); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// FindContextIDs finds the advertised context IDs that contain the given multihash.
+func (c *RPCClient) FindContextIDs(ctx context.Context, mh multihash.Multihash) ([][]byte, error) {
+	var res adminserver.FindContextIDsRes
+	if err := c.call(ctx, adminserver.RPCMethodFindContextIDs, &adminserver.FindContextIDsReq{Multihash: mh}, &res, nil); err != nil {
+		return nil, err
+	}
+	return res.ContextIDs, nil
+}
+
+// RebuildReverseIndex rebuilds the reverse index of the provider engine, and returns the rebuild
+// job. Unless async is set, the job is returned once it has completed.
+func (c *RPCClient) RebuildReverseIndex(ctx context.Context, async bool) (*adminserver.JobRes, error) {
+	var res adminserver.JobRes
+	req := &adminserver.RebuildReverseIndexReq{Async: async}
+	if err := c.call(ctx, adminserver.RPCMethodRebuildReverseIndex, req, &res, nil); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Drain removes all the content advertised by the provider, reading the given number of context
+// IDs at a time, and returns the drain job. A batch size of zero uses the server default. Unless
+// async is set, the job is returned once it has completed, and is cancelled if the given context
+// is done first.
+func (c *RPCClient) Drain(ctx context.Context, batchSize int, async bool) (*adminserver.JobRes, error) {
+	var res adminserver.JobRes
+	req := &adminserver.DrainReq{BatchSize: batchSize}
+	if err := c.call(ctx, adminserver.RPCMethodDrain, req, &res, nil); err != nil {
+		return nil, err
+	}
+	if async {
+		return &res, nil
+	}
+	return awaitJob(ctx, c, &res)
+}
+
+// ListRetrievalStats lists the retrieval stats of all retrieved context IDs, in descending order of
+// number of transfers.
+func (c *RPCClient) ListRetrievalStats(ctx context.Context) ([]adminserver.RetrievalStatsRes, error) {
+	var res adminserver.ListRetrievalStatsRes
+	if err := c.call(ctx, adminserver.RPCMethodListRetrievalStats, nil, &res, nil); err != nil {
+		return nil, err
+	}
+	return res.Stats, nil
+}
+
+// GetRetrievalStats gets the retrieval stats of the given context ID, along with its most recent
+// transfers.
+func (c *RPCClient) GetRetrievalStats(ctx context.Context, contextID []byte) (*adminserver.RetrievalStatsRes, error) {
+	var res adminserver.RetrievalStatsRes
+	if err := c.call(ctx, adminserver.RPCMethodGetRetrievalStats, &adminserver.RetrievalStatsReq{ContextID: contextID}, &res, nil); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// call calls the given method with the given params, and unmarshals its result into the given
+// result. Any notifications sent before the response are passed to the given onNotify function.
+func (c *RPCClient) call(ctx context.Context, method string, params, result interface{}, onNotify func(*adminserver.RPCMessage) error) error {
+	id := atomic.AddUint64(&c.nextID, 1)
+	req := adminserver.RPCRequest{
+		JsonRPC: adminserver.RPCVersion,
+		ID:      json.RawMessage(fmt.Sprint(id)),
+		Method:  method,
+	}
+	if params != nil {
+		var err error
+		if req.Params, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+	body, err := json.Marshal(&req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// Errors raised before the request reaches the RPC service, e.g. authentication errors,
+		// are reported as plain HTTP errors.
+		return errFromResponse(resp)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg adminserver.RPCMessage
+		if err := dec.Decode(&msg); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		if msg.Method != "" {
+			if onNotify != nil {
+				if err := onNotify(&msg); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if msg.Error != nil {
+			status, ok := rpcCodeStatuses[msg.Error.Code]
+			if !ok {
+				status = http.StatusInternalServerError
+			}
+			return errFromStatus(status, msg.Error.Message)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	}
+}
@@ -0,0 +1,167 @@
+package adminclient_test
+
+import (
+	"errors"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/cardatatransfer"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	adminclient "github.com/filecoin-project/index-provider/server/admin/http/client"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-car/v2/blockstore"
+	"github.com/libp2p/go-libp2p-core/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPCClient_RoundTrip(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	stats := cardatatransfer.NewRetrievalStats(dssync.MutexWrap(datastore.NewMapDatastore()))
+	addr, eng := startTestServer(t, []engine.Option{engine.WithReverseIndex(true)}, adminserver.WithRetrievalStats(stats))
+
+	testdata := filepath.Join(testutil.ThisDir(t), "../../../../testdata")
+	v1Path := filepath.Join(testdata, "sample-v1.car")
+	v1Path2 := filepath.Join(testdata, "sample-v1-2.car")
+	v2Path := filepath.Join(testdata, "sample-wrapped-v2.car")
+	bitswapMd := metadata.New(metadata.Bitswap{})
+	md, err := bitswapMd.MarshalBinary()
+	require.NoError(t, err)
+
+	// Requests without the bearer token are rejected.
+	_, err = adminclient.NewRPC(addr).ListCars(ctx)
+	require.Contains(t, err.Error(), "Unauthorized")
+
+	subject := adminclient.NewRPC(addr, adminclient.WithBearerToken(testToken))
+
+	imported, err := subject.ImportCar(ctx, v1Path, []byte("fish"), md)
+	require.NoError(t, err)
+	require.Equal(t, []byte("fish"), imported.Key)
+	require.True(t, imported.AdvId.Defined())
+
+	// Importing the same CAR again conflicts with the existing advertisement.
+	_, err = subject.ImportCar(ctx, v1Path, []byte("fish"), md)
+	require.True(t, errors.Is(err, provider.ErrAlreadyAdvertised), "unexpected error: %v", err)
+
+	pieceCid := testutil.RandomPieceCids(t, rand.New(rand.NewSource(1413)), 1)[0]
+	gsMd := metadata.New(&metadata.GraphsyncFilecoinV1{PieceCID: pieceCid, FastRetrieval: true})
+	newMd, err := gsMd.MarshalBinary()
+	require.NoError(t, err)
+	updatedAd, err := subject.UpdateMetadata(ctx, []byte("fish"), newMd)
+	require.NoError(t, err)
+	require.NotEqual(t, imported.AdvId, updatedAd)
+	_, err = subject.UpdateMetadata(ctx, []byte("undadasea"), newMd)
+	require.True(t, errors.Is(err, supplier.ErrNotFound), "unexpected error: %v", err)
+
+	// Protocols can be merged into, and removed from, the advertised metadata.
+	_, err = subject.MergeMetadata(ctx, []byte("fish"), md)
+	require.NoError(t, err)
+	info, err := eng.GetContextID(ctx, []byte("fish"))
+	require.NoError(t, err)
+	require.True(t, gsMd.With(metadata.Bitswap{}).Equal(info.Metadata))
+	_, err = subject.MergeMetadata(ctx, []byte("fish"), nil, "graphsync-filecoinv1")
+	require.NoError(t, err)
+	info, err = eng.GetContextID(ctx, []byte("fish"))
+	require.NoError(t, err)
+	require.True(t, bitswapMd.Equal(info.Metadata))
+	_, err = subject.MergeMetadata(ctx, []byte("fish"), nil, "bitswap")
+	require.Contains(t, err.Error(), "Bad Request")
+	_, err = subject.MergeMetadata(ctx, []byte("undadasea"), md)
+	require.True(t, errors.Is(err, supplier.ErrNotFound), "unexpected error: %v", err)
+
+	var progress []*adminserver.ImportManifestRes
+	summary, err := subject.ImportManifest(ctx, []adminserver.ImportCarReq{
+		{Path: v1Path2, Key: []byte("lobster")},
+		{Path: filepath.Join(t.TempDir(), "missing.car")},
+	}, 2, func(res *adminserver.ImportManifestRes) {
+		progress = append(progress, res)
+	})
+	require.NoError(t, err)
+	require.Equal(t, &adminserver.ImportManifestSummaryRes{Rows: 2, Imported: 1}, summary)
+	require.Len(t, progress, 2)
+	sort.Slice(progress, func(i, j int) bool { return progress[i].Row < progress[j].Row })
+	require.Empty(t, progress[0].Error)
+	require.True(t, progress[0].AdvId.Defined())
+	require.NotEmpty(t, progress[1].Error)
+
+	job, err := subject.ImportCarAsync(ctx, v2Path, []byte("crab"), md)
+	require.NoError(t, err)
+	require.Equal(t, adminserver.JobKindImportCar, job.Kind)
+	require.Eventually(t, func() bool {
+		got, err := subject.GetJob(ctx, job.ID)
+		require.NoError(t, err)
+		return got.Status == adminserver.JobStatusSucceeded
+	}, 10*time.Second, 10*time.Millisecond)
+	jobs, err := subject.ListJobs(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, jobs)
+	_, err = subject.CancelJob(ctx, "undadasea")
+	require.True(t, errors.Is(err, supplier.ErrNotFound), "unexpected error: %v", err)
+
+	paths, err := subject.ListCars(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{v1Path, v1Path2, v2Path}, paths)
+
+	bs, err := blockstore.OpenReadOnly(v1Path2)
+	require.NoError(t, err)
+	roots, err := bs.Roots()
+	require.NoError(t, err)
+	require.NoError(t, bs.Close())
+	contextIDs, err := subject.FindContextIDs(ctx, roots[0].Hash())
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("lobster")}, contextIDs)
+
+	retrievalStats, err := subject.ListRetrievalStats(ctx)
+	require.NoError(t, err)
+	require.Empty(t, retrievalStats)
+	_, err = subject.GetRetrievalStats(ctx, []byte("lobster"))
+	require.True(t, errors.Is(err, supplier.ErrNotFound), "unexpected error: %v", err)
+	client := test.RandPeerIDFatal(t)
+	require.NoError(t, stats.Record(ctx, &cardatatransfer.RetrievalRecord{
+		Peer:      client,
+		ContextID: []byte("lobster"),
+		BytesSent: 1413,
+		Started:   time.Now(),
+		Duration:  time.Second,
+		Outcome:   cardatatransfer.RetrievalCompleted,
+	}))
+	retrievalStats, err = subject.ListRetrievalStats(ctx)
+	require.NoError(t, err)
+	require.Len(t, retrievalStats, 1)
+	require.Equal(t, []byte("lobster"), retrievalStats[0].ContextID)
+	lobsterStats, err := subject.GetRetrievalStats(ctx, []byte("lobster"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), lobsterStats.Completed)
+	require.Equal(t, uint64(1413), lobsterStats.BytesSent)
+	require.Len(t, lobsterStats.Transfers, 1)
+	require.Equal(t, client.String(), lobsterStats.Transfers[0].Peer)
+
+	rebuild, err := subject.RebuildReverseIndex(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, adminserver.JobStatusSucceeded, rebuild.Status)
+
+	removedAd, err := subject.RemoveCar(ctx, []byte("fish"))
+	require.NoError(t, err)
+	require.NotEqual(t, cid.Undef, removedAd)
+	_, err = subject.RemoveCar(ctx, []byte("fish"))
+	require.True(t, errors.Is(err, supplier.ErrNotFound), "unexpected error: %v", err)
+
+	require.NoError(t, subject.Announce(ctx))
+
+	drain, err := subject.Drain(ctx, 1, false)
+	require.NoError(t, err)
+	require.Equal(t, adminserver.JobStatusSucceeded, drain.Status)
+	require.Equal(t, &adminserver.JobProgress{Done: 2, Total: 2}, drain.Progress)
+	paths, err = subject.ListCars(ctx)
+	require.NoError(t, err)
+	require.Empty(t, paths)
+}
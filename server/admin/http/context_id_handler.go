@@ -0,0 +1,133 @@
+package adminserver
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/gorilla/mux"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	defaultListContextIDsLimit = 100
+	maxListContextIDsLimit     = 1000
+)
+
+type contextIDHandler struct {
+	e *engine.Engine
+}
+
+// handleList lists a page of the advertised context IDs, starting at the offset query parameter
+// and listing at most limit context IDs.
+func (h *contextIDHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, err := limitParam(query.Get("limit"), defaultListContextIDsLimit, maxListContextIDsLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var offset int
+	if v := query.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// List one more context ID than the limit to find out whether there is a next page.
+	contextIDs, err := h.e.ListContextIDs(r.Context(), offset, limit+1)
+	if err != nil {
+		msg := fmt.Sprintf("failed to list context IDs: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	resp := &ListContextIDsRes{
+		ContextIDs: [][]byte{},
+	}
+	if len(contextIDs) > limit {
+		contextIDs = contextIDs[:limit]
+		resp.NextOffset = offset + limit
+	}
+	resp.ContextIDs = append(resp.ContextIDs, contextIDs...)
+	respond(w, http.StatusOK, resp)
+}
+
+// handleGet responds with the entries CID and decoded metadata of the context ID in the request
+// path, encoded as URL-safe base64.
+func (h *contextIDHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	contextID, err := decodeContextID(mux.Vars(r)["contextid"])
+	if err != nil {
+		http.Error(w, "context ID must be URL-safe base64 encoded", http.StatusBadRequest)
+		return
+	}
+	info, err := h.e.GetContextID(r.Context(), contextID)
+	if err != nil {
+		if errors.Is(err, provider.ErrContextIDNotFound) {
+			http.Error(w, "context ID not found", http.StatusNotFound)
+			return
+		}
+		msg := fmt.Sprintf("failed to get context ID: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resp := &ContextIDRes{
+		ContextID: info.ContextID,
+		Entries:   info.Entries,
+	}
+	if info.Metadata.Len() != 0 {
+		if resp.Metadata, err = info.Metadata.MarshalBinary(); err != nil {
+			msg := fmt.Sprintf("failed to marshal metadata: %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		resp.DecodedMetadata = &info.Metadata
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+// handleFind responds with the advertised context IDs that contain the multihash in the request
+// path. The multihash may be specified as a base58 encoded multihash or as a CID.
+func (h *contextIDHandler) handleFind(w http.ResponseWriter, r *http.Request) {
+	mh, err := decodeMultihash(mux.Vars(r)["multihash"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid multihash: %v", err), http.StatusBadRequest)
+		return
+	}
+	contextIDs, err := h.e.FindContextIDs(r.Context(), mh)
+	if err != nil {
+		msg := fmt.Sprintf("failed to find context IDs: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	resp := &FindContextIDsRes{
+		ContextIDs: [][]byte{},
+	}
+	resp.ContextIDs = append(resp.ContextIDs, contextIDs...)
+	respond(w, http.StatusOK, resp)
+}
+
+// decodeContextID decodes the given URL-safe base64 encoded context ID, with or without padding.
+func decodeContextID(v string) ([]byte, error) {
+	if decoded, err := base64.RawURLEncoding.DecodeString(v); err == nil {
+		return decoded, nil
+	}
+	return base64.URLEncoding.DecodeString(v)
+}
+
+func decodeMultihash(v string) (multihash.Multihash, error) {
+	if c, err := cid.Decode(v); err == nil {
+		return c.Hash(), nil
+	}
+	return multihash.FromB58String(v)
+}
@@ -0,0 +1,100 @@
+package adminserver
+
+import (
+	"encoding/base64"
+	"math/rand"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car/v2/blockstore"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_contextIDHandler(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	baseURL, _, cs := startTestEngineServer(t)
+
+	testdata := filepath.Join(testutil.ThisDir(t), "../../../testdata")
+	fishPath := filepath.Join(testdata, "sample-v1.car")
+	lobsterPath := filepath.Join(testdata, "sample-v1-2.car")
+	pieceCid, err := cid.Decode("baga6ea4seaqjtovkwk4myyzj56eztkh5pzsk5upksan6f5outesy62bsvl4dsha")
+	require.NoError(t, err)
+	fishMd := metadata.New(&metadata.GraphsyncFilecoinV1{
+		PieceCID:     pieceCid,
+		VerifiedDeal: true,
+	})
+	_, err = cs.Put(ctx, []byte("fish"), fishPath, fishMd)
+	require.NoError(t, err)
+	_, err = cs.Put(ctx, []byte("lobster"), lobsterPath, metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	var list ListContextIDsRes
+	requireGetOk(t, baseURL+"/admin/contextids", &list)
+	require.Equal(t, [][]byte{[]byte("fish"), []byte("lobster")}, list.ContextIDs)
+	require.Zero(t, list.NextOffset)
+	list = ListContextIDsRes{}
+	requireGetOk(t, baseURL+"/admin/contextids?limit=1", &list)
+	require.Equal(t, [][]byte{[]byte("fish")}, list.ContextIDs)
+	require.Equal(t, 1, list.NextOffset)
+	list = ListContextIDsRes{}
+	requireGetOk(t, baseURL+"/admin/contextids?limit=1&offset=1", &list)
+	require.Equal(t, [][]byte{[]byte("lobster")}, list.ContextIDs)
+	require.Zero(t, list.NextOffset)
+
+	var got ContextIDRes
+	requireGetOk(t, baseURL+"/admin/contextids/"+base64.RawURLEncoding.EncodeToString([]byte("fish")), &got)
+	require.Equal(t, []byte("fish"), got.ContextID)
+	require.True(t, got.Entries.Defined())
+	wantMd, err := fishMd.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, MetadataBytes(wantMd), got.Metadata)
+	require.NotNil(t, got.DecodedMetadata)
+	require.True(t, fishMd.Equal(*got.DecodedMetadata))
+	gotGs, ok := got.DecodedMetadata.Get(multicodec.TransportGraphsyncFilecoinv1).(*metadata.GraphsyncFilecoinV1)
+	require.True(t, ok)
+	require.Equal(t, pieceCid, gotGs.PieceCID)
+	require.True(t, gotGs.VerifiedDeal)
+
+	resp := httpGet(t, baseURL+"/admin/contextids/"+base64.RawURLEncoding.EncodeToString([]byte("undadasea")))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = httpGet(t, baseURL+"/admin/contextids/!fish!")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Find the context ID that contains the root of each CAR, by CID and by multihash.
+	fishRoot := carRoot(t, fishPath)
+	lobsterRoot := carRoot(t, lobsterPath)
+	var found FindContextIDsRes
+	requireGetOk(t, baseURL+"/admin/multihashes/"+fishRoot.String()+"/contextids", &found)
+	require.Equal(t, [][]byte{[]byte("fish")}, found.ContextIDs)
+	found = FindContextIDsRes{}
+	requireGetOk(t, baseURL+"/admin/multihashes/"+lobsterRoot.Hash().B58String()+"/contextids", &found)
+	require.Equal(t, [][]byte{[]byte("lobster")}, found.ContextIDs)
+
+	rng := rand.New(rand.NewSource(1413))
+	data := make([]byte, 32)
+	rng.Read(data)
+	absent, err := multihash.Sum(data, multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	found = FindContextIDsRes{}
+	requireGetOk(t, baseURL+"/admin/multihashes/"+absent.B58String()+"/contextids", &found)
+	require.Empty(t, found.ContextIDs)
+
+	resp = httpGet(t, baseURL+"/admin/multihashes/fish/contextids")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func carRoot(t *testing.T, path string) cid.Cid {
+	bs, err := blockstore.OpenReadOnly(path)
+	require.NoError(t, err)
+	defer bs.Close()
+	roots, err := bs.Roots()
+	require.NoError(t, err)
+	require.NotEmpty(t, roots)
+	return roots[0]
+}
@@ -1,2 +1,9 @@
 // Package adminserver provides a HTTP server that allows to perform administrative operations.
+//
+// In addition to the REST endpoints, the server exposes the same operations as a JSON-RPC 2.0
+// service at /admin/rpc, which streams the progress of manifest imports as notifications. See the
+// client package for clients of both.
+//
+// The events emitted by the engine, e.g. published and announced advertisements, are streamed as
+// Server-Sent Events at /admin/events.
 package adminserver
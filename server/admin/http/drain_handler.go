@@ -0,0 +1,69 @@
+package adminserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/ipfs/go-cid"
+)
+
+// maxDrainBatchSize is the maximum number of context IDs read at a time while draining.
+const maxDrainBatchSize = 100_000
+
+type drainHandler struct {
+	e    *engine.Engine
+	cs   *supplier.CarSupplier
+	jobs *jobManager
+}
+
+// handleDrain starts a job that removes all the content advertised by the engine, and responds
+// with the job once started. Draining always runs in the background, since it may take longer than
+// the server write timeout; its progress and outcome are available via the jobs endpoints.
+func (h *drainHandler) handleDrain(w http.ResponseWriter, r *http.Request) {
+	log.Info("received drain request")
+
+	batchSize, err := drainBatchSizeParam(r.URL.Query().Get("batch"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	j, err := h.start(batchSize)
+	if err != nil {
+		msg := fmt.Sprintf("failed to start drain job: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	respondJobAccepted(w, j)
+}
+
+// start starts a job that publishes a removal advertisement for every context ID advertised by
+// the engine, and forgets the CARs of removed context IDs. The progress of the job is reported as
+// the number of context IDs removed.
+func (h *drainHandler) start(batchSize int) (*job, error) {
+	return h.jobs.start(JobKindDrain, nil, "", func(ctx context.Context) (cid.Cid, error) {
+		return h.e.Drain(ctx, batchSize, func(p engine.DrainProgress) {
+			// Context IDs may have been advertised by means other than importing CARs.
+			if err := h.cs.Forget(ctx, p.ContextID); err != nil && err != supplier.ErrNotFound {
+				log.Warnw("Failed to forget CAR of drained context ID", "err", err)
+			}
+			setJobProgress(ctx, p.Removed, p.Total)
+		})
+	})
+}
+
+func drainBatchSizeParam(v string) (int, error) {
+	if v == "" {
+		return engine.DefaultDrainBatchSize, nil
+	}
+	batchSize, err := strconv.Atoi(v)
+	if err != nil || batchSize < 1 || batchSize > maxDrainBatchSize {
+		return 0, fmt.Errorf("batch must be an integer between 1 and %d", maxDrainBatchSize)
+	}
+	return batchSize, nil
+}
@@ -0,0 +1,78 @@
+package adminserver
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_drainHandler(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	baseURL, eng, cs := startTestEngineServer(t)
+
+	testdata := filepath.Join(testutil.ThisDir(t), "../../../testdata")
+	md := metadata.New(metadata.Bitswap{})
+	for key, path := range map[string]string{
+		"fish":    "sample-v1.car",
+		"lobster": "sample-v1-2.car",
+		"crab":    "sample-wrapped-v2.car",
+	} {
+		_, err := cs.Put(ctx, []byte(key), filepath.Join(testdata, path), md)
+		require.NoError(t, err)
+	}
+
+	resp, err := http.Post(baseURL+"/admin/drain?batch=0", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Draining runs in the background.
+	resp, err = http.Post(baseURL+"/admin/drain?batch=2", "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var job JobRes
+	_, err = job.ReadFrom(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, JobKindDrain, job.Kind)
+	require.Equal(t, "/admin/jobs/"+job.ID, resp.Header.Get("Location"))
+	jobID := job.ID
+	require.Eventually(t, func() bool {
+		job = JobRes{}
+		requireGetOk(t, baseURL+"/admin/jobs/"+jobID, &job)
+		return job.Status == JobStatusSucceeded
+	}, 10*time.Second, 10*time.Millisecond)
+	require.Equal(t, &JobProgress{Done: 3, Total: 3}, job.Progress)
+
+	// Nothing is advertised anymore, the latest advertisement is the last removal, and the CARs
+	// are forgotten.
+	contextIDs, err := eng.ListContextIDs(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Empty(t, contextIDs)
+	latest, ad, err := eng.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, job.AdvId, latest)
+	require.True(t, ad.IsRm)
+	paths, err := cs.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, paths)
+
+	// Draining with nothing to remove succeeds.
+	resp, err = http.Post(baseURL+"/admin/drain", "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	job = JobRes{}
+	_, err = job.ReadFrom(resp.Body)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		var got JobRes
+		requireGetOk(t, baseURL+"/admin/jobs/"+job.ID, &got)
+		return got.Status == JobStatusSucceeded
+	}, 10*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,151 @@
+package adminserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/index-provider/engine"
+)
+
+// eventsKeepAliveInterval is the interval at which comments are written to event streams, so that
+// idle streams are not closed by proxies.
+const eventsKeepAliveInterval = 15 * time.Second
+
+var eventTypes = map[engine.EventType]struct{}{
+	engine.EventTypeAdPublished:      {},
+	engine.EventTypeAdAnnounced:      {},
+	engine.EventTypeEntriesGenerated: {},
+	engine.EventTypeCacheEvicted:     {},
+	engine.EventTypeSyncRequested:    {},
+}
+
+type eventsHandler struct {
+	e *engine.Engine
+	// closing is closed when the server shuts down, which ends all event streams.
+	closing <-chan struct{}
+	// writeTimeout bounds each write to an event stream, in place of the server write timeout
+	// which would otherwise end the stream once elapsed. Zero means no timeout.
+	writeTimeout time.Duration
+}
+
+// connContextKey is the request context key under which the underlying connection is stored.
+type connContextKey struct{}
+
+// withConn stores the given connection in the context of requests served over it, so that
+// handlers of long-lived requests can manage the connection write deadline.
+// See: http.Server.ConnContext.
+func withConn(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// handleStream streams the events emitted by the engine as Server-Sent Events, until the client
+// disconnects or the server shuts down. Each event is named after its type, with its JSON
+// encoding as data. The streamed event types may be restricted via the comma separated types
+// query parameter.
+//
+// Streams are not bound by the server write timeout; instead, each write to the stream must
+// complete within it, so that streams to unresponsive clients are ended.
+func (h *eventsHandler) handleStream(w http.ResponseWriter, r *http.Request) {
+	wanted, err := eventTypesParam(r.URL.Query().Get("types"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, ok := r.Context().Value(connContextKey{}).(net.Conn)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, cancel := h.e.Subscribe()
+	defer cancel()
+	log.Info("streaming events")
+
+	// Replace the deadline set by the server for the whole response with one per write.
+	if err := h.extendWriteDeadline(conn); err != nil {
+		log.Errorw("failed to set write deadline", "err", err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.closing:
+			return
+		case <-keepAlive.C:
+			if err := h.extendWriteDeadline(conn); err != nil {
+				return
+			}
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case ev, ok := <-events:
+			if !ok {
+				// The engine has shut down.
+				return
+			}
+			if _, ok := wanted[ev.Type()]; len(wanted) != 0 && !ok {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				log.Errorw("failed to encode event", "type", ev.Type(), "err", err)
+				continue
+			}
+			if err := h.extendWriteDeadline(conn); err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type(), data); err != nil {
+				log.Debugw("failed to write event; ending stream", "err", err)
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// extendWriteDeadline sets the write deadline of the given connection to writeTimeout from now,
+// or clears it if there is no write timeout.
+func (h *eventsHandler) extendWriteDeadline(conn net.Conn) error {
+	var deadline time.Time
+	if h.writeTimeout > 0 {
+		deadline = time.Now().Add(h.writeTimeout)
+	}
+	return conn.SetWriteDeadline(deadline)
+}
+
+// eventTypesParam parses the comma separated list of event types. An empty set is returned if no
+// types are specified.
+func eventTypesParam(v string) (map[engine.EventType]struct{}, error) {
+	wanted := make(map[engine.EventType]struct{})
+	if v == "" {
+		return wanted, nil
+	}
+	for _, t := range strings.Split(v, ",") {
+		et := engine.EventType(strings.TrimSpace(t))
+		if _, ok := eventTypes[et]; !ok {
+			return nil, fmt.Errorf("unknown event type: %s", t)
+		}
+		wanted[et] = struct{}{}
+	}
+	return wanted, nil
+}
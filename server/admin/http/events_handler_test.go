@@ -0,0 +1,96 @@
+package adminserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_eventsHandler(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	baseURL, _, cs := startTestEngineServer(t)
+
+	resp, err := http.Get(baseURL + "/admin/events?types=AdPublished,Unknown")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/admin/events?types=AdPublished", nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// The stream is established once headers are received; events emitted from now on are
+	// streamed.
+	testdata := filepath.Join(testutil.ThisDir(t), "../../../testdata")
+	adCid, err := cs.Put(ctx, []byte("fish"), filepath.Join(testdata, "sample-v1.car"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	// Only the filtered event type is streamed, i.e. the first event is the published ad rather
+	// than the generated entries that precede it.
+	scanner := bufio.NewScanner(resp.Body)
+	var lines []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, lines, 2)
+	require.Equal(t, "event: AdPublished", lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "data: "))
+	var got engine.AdPublished
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &got))
+	require.Equal(t, adCid, got.AdCid)
+	require.Equal(t, []byte("fish"), got.ContextID)
+	require.False(t, got.IsRm)
+}
+
+func Test_eventsHandler_StreamOutlivesWriteTimeout(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	eng, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	cs := supplier.NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	const writeTimeout = 100 * time.Millisecond
+	subject, err := New(nil, eng, cs, WithListenAddr("127.0.0.1:0"), WithWriteTimeout(writeTimeout))
+	require.NoError(t, err)
+	go subject.Start()
+	t.Cleanup(func() { subject.Shutdown(contextWithTimeout(t)) })
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+subject.l.Addr().String()+"/admin/events?types=AdPublished", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Emit an event well after the server write timeout has elapsed.
+	time.Sleep(5 * writeTimeout)
+	testdata := filepath.Join(testutil.ThisDir(t), "../../../testdata")
+	_, err = cs.Put(ctx, []byte("fish"), filepath.Join(testdata, "sample-v1.car"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	scanner := bufio.NewScanner(resp.Body)
+	require.True(t, scanner.Scan(), "stream ended: %v", scanner.Err())
+	require.Equal(t, "event: AdPublished", scanner.Text())
+}
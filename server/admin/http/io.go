@@ -10,17 +10,41 @@ import (
 var (
 	_ io.ReaderFrom = (*ImportCarReq)(nil)
 	_ io.ReaderFrom = (*ImportCarRes)(nil)
+	_ io.ReaderFrom = (*ImportManifestRes)(nil)
 	_ io.ReaderFrom = (*RemoveCarReq)(nil)
 	_ io.ReaderFrom = (*RemoveCarRes)(nil)
+	_ io.ReaderFrom = (*UpdateMetadataReq)(nil)
+	_ io.ReaderFrom = (*UpdateMetadataRes)(nil)
 	_ io.ReaderFrom = (*ConnectReq)(nil)
 	_ io.ReaderFrom = (*ConnectRes)(nil)
+	_ io.ReaderFrom = (*JobRes)(nil)
+	_ io.ReaderFrom = (*ListJobsRes)(nil)
+	_ io.ReaderFrom = (*AdRes)(nil)
+	_ io.ReaderFrom = (*ListAdsRes)(nil)
+	_ io.ReaderFrom = (*ListContextIDsRes)(nil)
+	_ io.ReaderFrom = (*ContextIDRes)(nil)
+	_ io.ReaderFrom = (*FindContextIDsRes)(nil)
+	_ io.ReaderFrom = (*RetrievalStatsRes)(nil)
+	_ io.ReaderFrom = (*ListRetrievalStatsRes)(nil)
 
 	_ io.WriterTo = (*ImportCarReq)(nil)
 	_ io.WriterTo = (*ImportCarRes)(nil)
+	_ io.WriterTo = (*ImportManifestRes)(nil)
 	_ io.WriterTo = (*RemoveCarReq)(nil)
 	_ io.WriterTo = (*RemoveCarRes)(nil)
+	_ io.WriterTo = (*UpdateMetadataReq)(nil)
+	_ io.WriterTo = (*UpdateMetadataRes)(nil)
 	_ io.WriterTo = (*ConnectReq)(nil)
 	_ io.WriterTo = (*ConnectRes)(nil)
+	_ io.WriterTo = (*JobRes)(nil)
+	_ io.WriterTo = (*ListJobsRes)(nil)
+	_ io.WriterTo = (*AdRes)(nil)
+	_ io.WriterTo = (*ListAdsRes)(nil)
+	_ io.WriterTo = (*ListContextIDsRes)(nil)
+	_ io.WriterTo = (*ContextIDRes)(nil)
+	_ io.WriterTo = (*FindContextIDsRes)(nil)
+	_ io.WriterTo = (*RetrievalStatsRes)(nil)
+	_ io.WriterTo = (*ListRetrievalStatsRes)(nil)
 )
 
 func (er *ImportCarReq) WriteTo(w io.Writer) (int64, error) {
@@ -39,6 +63,14 @@ func (er *ImportCarRes) ReadFrom(r io.Reader) (int64, error) {
 	return unmarshalAsJson(r, er)
 }
 
+func (er *ImportManifestRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ImportManifestRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
 func (er *RemoveCarReq) WriteTo(w io.Writer) (int64, error) {
 	return marshalToJson(w, er)
 }
@@ -55,6 +87,22 @@ func (er *RemoveCarRes) ReadFrom(r io.Reader) (int64, error) {
 	return unmarshalAsJson(r, er)
 }
 
+func (er *UpdateMetadataReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *UpdateMetadataReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *UpdateMetadataRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *UpdateMetadataRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
 func (er *ListCarRes) WriteTo(w io.Writer) (int64, error) {
 	return marshalToJson(w, er)
 }
@@ -79,6 +127,78 @@ func (er *ConnectRes) ReadFrom(r io.Reader) (int64, error) {
 	return unmarshalAsJson(r, er)
 }
 
+func (er *JobRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *JobRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ListJobsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListJobsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *AdRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *AdRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ListAdsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListAdsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ListContextIDsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListContextIDsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ContextIDRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ContextIDRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *FindContextIDsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *FindContextIDsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *RetrievalStatsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *RetrievalStatsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ListRetrievalStatsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListRetrievalStatsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
 func respond(w http.ResponseWriter, statusCode int, body io.WriterTo) {
 	w.WriteHeader(statusCode)
 	// Attempt to serialize body as JSON
@@ -0,0 +1,71 @@
+package adminserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type jobHandler struct {
+	jobs *jobManager
+}
+
+func (h *jobHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	j, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	resp := j.info()
+	respond(w, http.StatusOK, &resp)
+}
+
+func (h *jobHandler) handleList(w http.ResponseWriter, _ *http.Request) {
+	resp := &ListJobsRes{
+		Jobs: h.jobs.list(),
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+// handleCancel requests the cancellation of a job. Cancellation is asynchronous; the job status
+// changes once the job stops. Cancelling a finished job has no effect.
+func (h *jobHandler) handleCancel(w http.ResponseWriter, r *http.Request) {
+	j, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	log.Infow("cancelling job", "job", j.info().ID)
+	j.cancel()
+	resp := j.info()
+	respond(w, http.StatusAccepted, &resp)
+}
+
+func (h *jobHandler) lookup(w http.ResponseWriter, r *http.Request) (*job, bool) {
+	id := mux.Vars(r)["id"]
+	j := h.jobs.get(id)
+	if j == nil {
+		http.Error(w, fmt.Sprintf("no job found with ID %s", id), http.StatusNotFound)
+		return nil, false
+	}
+	return j, true
+}
+
+// isAsync checks whether the given request asks for the operation to be performed as a job in
+// the background, in which case the job is returned immediately instead of waiting for the
+// operation to complete.
+func isAsync(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("async")
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// respondJobAccepted responds with the given job, pointing at the location at which its status
+// can be polled.
+func respondJobAccepted(w http.ResponseWriter, j *job) {
+	resp := j.info()
+	w.Header().Set("Location", "/admin/jobs/"+resp.ID)
+	respond(w, http.StatusAccepted, &resp)
+}
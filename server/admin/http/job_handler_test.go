@@ -0,0 +1,161 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/index-provider/cardatatransfer"
+	"github.com/filecoin-project/index-provider/metadata"
+	mock_provider "github.com/filecoin-project/index-provider/mock"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_asyncImportCarCreatesJob(t *testing.T) {
+	wantKey := []byte("lobster")
+	wantTp, err := cardatatransfer.TransportFromContextID(wantKey)
+	require.NoError(t, err)
+	wantMetadata := metadata.New(wantTp)
+
+	mc := gomock.NewController(t)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	cs := supplier.NewCarSupplier(mockEng, dssync.MutexWrap(datastore.NewMapDatastore()))
+	router := newJobsTestRouter(cs)
+
+	// Block the advertisement until the job is cancelled.
+	mockEng.
+		EXPECT().
+		NotifyPut(gomock.Any(), gomock.Eq(wantKey), gomock.Eq(wantMetadata)).
+		DoAndReturn(func(ctx context.Context, _ []byte, _ metadata.Metadata) (cid.Cid, error) {
+			<-ctx.Done()
+			return cid.Undef, ctx.Err()
+		})
+
+	rr := serveImportCar(t, router, "/admin/import/car?async=true", wantKey, wantMetadata)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	var started JobRes
+	_, err = started.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, JobKindImportCar, started.Kind)
+	require.Equal(t, JobStatusRunning, started.Status)
+	require.Equal(t, "/admin/jobs/"+started.ID, rr.Header().Get("Location"))
+
+	rr = serveJobsReq(t, router, http.MethodGet, "/admin/jobs")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var listed ListJobsRes
+	_, err = listed.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Len(t, listed.Jobs, 1)
+	require.Equal(t, started.ID, listed.Jobs[0].ID)
+
+	rr = serveJobsReq(t, router, http.MethodPost, "/admin/jobs/"+started.ID+"/cancel")
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	got := requireJobFinished(t, router, started.ID)
+	require.Equal(t, JobStatusCancelled, got.Status)
+	require.Equal(t, context.Canceled.Error(), got.Error)
+
+	// The cancelled import leaves no CAR behind.
+	paths, err := cs.List(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, paths)
+}
+
+func Test_asyncImportCarJobSucceeds(t *testing.T) {
+	wantKey := []byte("lobster")
+	wantTp, err := cardatatransfer.TransportFromContextID(wantKey)
+	require.NoError(t, err)
+	wantMetadata := metadata.New(wantTp)
+	wantCid, err := cid.Decode("bafkqaaa")
+	require.NoError(t, err)
+
+	mc := gomock.NewController(t)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	cs := supplier.NewCarSupplier(mockEng, dssync.MutexWrap(datastore.NewMapDatastore()))
+	router := newJobsTestRouter(cs)
+
+	mockEng.
+		EXPECT().
+		NotifyPut(gomock.Any(), gomock.Eq(wantKey), gomock.Eq(wantMetadata)).
+		Return(wantCid, nil)
+
+	rr := serveImportCar(t, router, "/admin/import/car?async=true", wantKey, wantMetadata)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	var started JobRes
+	_, err = started.ReadFrom(rr.Body)
+	require.NoError(t, err)
+
+	got := requireJobFinished(t, router, started.ID)
+	require.Equal(t, JobStatusSucceeded, got.Status)
+	require.Equal(t, wantCid, got.AdvId)
+	require.Equal(t, wantKey, got.Key)
+	require.Empty(t, got.Error)
+}
+
+func Test_getUnknownJobIsNotFound(t *testing.T) {
+	router := newJobsTestRouter(nil)
+	rr := serveJobsReq(t, router, http.MethodGet, "/admin/jobs/fish")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	rr = serveJobsReq(t, router, http.MethodPost, "/admin/jobs/fish/cancel")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func newJobsTestRouter(cs *supplier.CarSupplier) *mux.Router {
+	jobs := newJobManager()
+	cHandler := &carHandler{cs: cs, jobs: jobs}
+	jHandler := &jobHandler{jobs}
+	r := mux.NewRouter()
+	r.HandleFunc("/admin/import/car", cHandler.handleImport).Methods(http.MethodPost)
+	r.HandleFunc("/admin/jobs", jHandler.handleList).Methods(http.MethodGet)
+	r.HandleFunc("/admin/jobs/{id}", jHandler.handleGet).Methods(http.MethodGet)
+	r.HandleFunc("/admin/jobs/{id}/cancel", jHandler.handleCancel).Methods(http.MethodPost)
+	return r
+}
+
+func serveImportCar(t *testing.T, router *mux.Router, url string, key []byte, md metadata.Metadata) *httptest.ResponseRecorder {
+	mdBytes, err := md.MarshalBinary()
+	require.NoError(t, err)
+	jsonReq, err := json.Marshal(&ImportCarReq{
+		Path:     testCarPath(t),
+		Key:      key,
+		Metadata: mdBytes,
+	})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonReq))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func serveJobsReq(t *testing.T, router *mux.Router, method, url string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func requireJobFinished(t *testing.T, router *mux.Router, id string) JobRes {
+	var got JobRes
+	require.Eventually(t, func() bool {
+		rr := serveJobsReq(t, router, http.MethodGet, "/admin/jobs/"+id)
+		require.Equal(t, http.StatusOK, rr.Code)
+		_, err := got.ReadFrom(rr.Body)
+		require.NoError(t, err)
+		return got.Finished != nil
+	}, 10*time.Second, 10*time.Millisecond)
+	return got
+}
@@ -0,0 +1,225 @@
+package adminserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+const (
+	// JobKindImportCar is the kind of jobs that import a CAR file.
+	JobKindImportCar = "import-car"
+	// JobKindRemoveCar is the kind of jobs that remove a CAR file.
+	JobKindRemoveCar = "remove-car"
+	// JobKindRebuildReverseIndex is the kind of jobs that rebuild the reverse index of the engine.
+	JobKindRebuildReverseIndex = "rebuild-reverse-index"
+	// JobKindDrain is the kind of jobs that remove all the content advertised by the engine.
+	JobKindDrain = "drain"
+)
+
+const (
+	// JobStatusRunning signals that the job is in progress.
+	JobStatusRunning JobStatus = "running"
+	// JobStatusSucceeded signals that the job has completed successfully.
+	JobStatusSucceeded JobStatus = "succeeded"
+	// JobStatusFailed signals that the job has completed with an error.
+	JobStatusFailed JobStatus = "failed"
+	// JobStatusCancelled signals that the job was cancelled before completion.
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+// maxFinishedJobs is the maximum number of finished jobs retained; the oldest finished jobs are
+// discarded first.
+const maxFinishedJobs = 1000
+
+// JobStatus represents the status of an admin job.
+type JobStatus string
+
+type (
+	// jobManager tracks the long-running operations performed by the admin server, such as
+	// importing and removing CAR files. Jobs are kept in memory only.
+	jobManager struct {
+		lk   sync.Mutex
+		jobs map[string]*job
+	}
+
+	job struct {
+		lk     sync.Mutex
+		res    JobRes
+		err    error
+		cancel context.CancelFunc
+		done   chan struct{}
+	}
+
+	// jobFunc is the operation performed by a job, returning the CID of the advertisement it
+	// generated. The given context is cancelled when the job is cancelled, and may be used to
+	// report progress via setJobProgress.
+	jobFunc func(ctx context.Context) (cid.Cid, error)
+
+	jobContextKey struct{}
+)
+
+func newJobManager() *jobManager {
+	return &jobManager{
+		jobs: make(map[string]*job),
+	}
+}
+
+// start starts a job of the given kind that runs the given function in the background.
+func (m *jobManager) start(kind string, key []byte, path string, fn jobFunc) (*job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		res: JobRes{
+			ID:      id,
+			Kind:    kind,
+			Status:  JobStatusRunning,
+			Key:     key,
+			Path:    path,
+			Created: time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	ctx = context.WithValue(ctx, jobContextKey{}, j)
+
+	m.lk.Lock()
+	m.jobs[id] = j
+	m.lk.Unlock()
+
+	log := log.With("job", id, "kind", kind)
+	log.Info("started job")
+	go func() {
+		defer cancel()
+		advID, err := fn(ctx)
+		j.finish(advID, err, ctx.Err() != nil)
+		if err != nil {
+			log.Infow("job finished with error", "err", err)
+		} else {
+			log.Infow("job finished successfully", "advertisement", advID)
+		}
+		m.evict()
+	}()
+	return j, nil
+}
+
+// get returns the job with the given ID, or nil if no such job exists.
+func (m *jobManager) get(id string) *job {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.jobs[id]
+}
+
+// list lists all jobs in order of creation.
+func (m *jobManager) list() []JobRes {
+	m.lk.Lock()
+	jobs := make([]JobRes, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j.info())
+	}
+	m.lk.Unlock()
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Created.Before(jobs[k].Created) })
+	return jobs
+}
+
+// cancelAll cancels all running jobs and waits for them to finish.
+func (m *jobManager) cancelAll() {
+	m.lk.Lock()
+	jobs := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	m.lk.Unlock()
+	for _, j := range jobs {
+		j.cancel()
+		<-j.done
+	}
+}
+
+// evict discards the oldest finished jobs once there are more than maxFinishedJobs of them.
+func (m *jobManager) evict() {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	var finished []JobRes
+	for _, j := range m.jobs {
+		if info := j.info(); info.Finished != nil {
+			finished = append(finished, info)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, k int) bool { return finished[i].Finished.Before(*finished[k].Finished) })
+	for _, info := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, info.ID)
+	}
+}
+
+// setJobProgress sets the progress of the job running with the given context, if any.
+func setJobProgress(ctx context.Context, done, total int) {
+	j, ok := ctx.Value(jobContextKey{}).(*job)
+	if !ok {
+		return
+	}
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	j.res.Progress = &JobProgress{Done: done, Total: total}
+}
+
+func (j *job) finish(advID cid.Cid, err error, cancelled bool) {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	now := time.Now()
+	j.res.Finished = &now
+	j.res.AdvId = advID
+	j.err = err
+	switch {
+	case err == nil:
+		j.res.Status = JobStatusSucceeded
+	case cancelled && errors.Is(err, context.Canceled):
+		j.res.Status = JobStatusCancelled
+		j.res.Error = err.Error()
+	default:
+		j.res.Status = JobStatusFailed
+		j.res.Error = err.Error()
+	}
+	close(j.done)
+}
+
+// wait waits for the job to finish and returns its result. The job is cancelled if the given
+// context is done before the job finishes.
+func (j *job) wait(ctx context.Context) (cid.Cid, error) {
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+		j.cancel()
+		<-j.done
+	}
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	return j.res.AdvId, j.err
+}
+
+func (j *job) info() JobRes {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	return j.res
+}
+
+func newJobID() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,116 @@
+package adminserver
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// ManifestContentTypeJson is the content type of manifests encoded as a JSON array of
+	// ImportCarReq.
+	ManifestContentTypeJson = "application/json"
+	// ManifestContentTypeCsv is the content type of manifests encoded as CSV.
+	// The first record must be a header that names the columns, one of which must be "path".
+	// The optional "key" column holds base64 encoded values, and the optional "metadata" column
+	// holds metadata in either JSON or base64 encoded binary form.
+	// See: ParseMetadata.
+	ManifestContentTypeCsv = "text/csv"
+)
+
+const (
+	manifestCsvPathColumn     = "path"
+	manifestCsvKeyColumn      = "key"
+	manifestCsvMetadataColumn = "metadata"
+)
+
+// ReadManifest reads the rows of a manifest encoded with the given content type.
+// See: ManifestContentTypeJson, ManifestContentTypeCsv.
+func ReadManifest(r io.Reader, contentType string) ([]ImportCarReq, error) {
+	var rows []ImportCarReq
+	switch contentType {
+	case ManifestContentTypeJson:
+		if err := json.NewDecoder(r).Decode(&rows); err != nil {
+			return nil, err
+		}
+	case ManifestContentTypeCsv:
+		var err error
+		if rows, err = readCsvManifest(r); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported manifest content type: %s", contentType)
+	}
+	if err := validateManifestRows(rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func validateManifestRows(rows []ImportCarReq) error {
+	if len(rows) == 0 {
+		return errors.New("manifest has no rows")
+	}
+	for i, row := range rows {
+		if row.Path == "" {
+			return fmt.Errorf("path must be specified at row %d", i)
+		}
+	}
+	return nil
+}
+
+func readCsvManifest(r io.Reader) ([]ImportCarReq, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.New("manifest has no header")
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case manifestCsvPathColumn, manifestCsvKeyColumn, manifestCsvMetadataColumn:
+		default:
+			return nil, fmt.Errorf("unknown manifest column: %s", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("duplicate manifest column: %s", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns[manifestCsvPathColumn]; !ok {
+		return nil, fmt.Errorf("manifest must have a %s column", manifestCsvPathColumn)
+	}
+
+	var rows []ImportCarReq
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := ImportCarReq{Path: record[columns[manifestCsvPathColumn]]}
+		if i, ok := columns[manifestCsvKeyColumn]; ok && record[i] != "" {
+			if row.Key, err = base64.StdEncoding.DecodeString(record[i]); err != nil {
+				return nil, fmt.Errorf("key is not a valid base64 encoded string at row %d", len(rows))
+			}
+		}
+		if i, ok := columns[manifestCsvMetadataColumn]; ok && record[i] != "" {
+			if row.Metadata, err = ParseMetadata(record[i]); err != nil {
+				return nil, fmt.Errorf("invalid metadata at row %d: %w", len(rows), err)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
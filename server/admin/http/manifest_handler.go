@@ -0,0 +1,162 @@
+package adminserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/filecoin-project/index-provider/cardatatransfer"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/supplier"
+)
+
+const (
+	defaultImportConcurrency = 4
+	maxImportConcurrency     = 64
+)
+
+type manifestHandler struct {
+	cs *supplier.CarSupplier
+	e  *engine.Engine
+}
+
+// handleImport imports the CARs listed in a manifest with bounded concurrency, and streams the
+// result of importing each row back as newline-delimited JSON ImportManifestRes.
+//
+// The advertisements generated by the import are announced once all rows are imported.
+// Note that the response stream is subject to the write timeout of the admin server.
+func (h *manifestHandler) handleImport(w http.ResponseWriter, r *http.Request) {
+	log.Info("received import manifest request")
+
+	concurrency := defaultImportConcurrency
+	if v := r.URL.Query().Get("concurrency"); v != "" {
+		var err error
+		concurrency, err = strconv.Atoi(v)
+		if err != nil || concurrency < 1 || concurrency > maxImportConcurrency {
+			msg := fmt.Sprintf("concurrency must be an integer between 1 and %d", maxImportConcurrency)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		msg := fmt.Sprintf("invalid content type: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	rows, err := ReadManifest(r.Body, contentType)
+	if err != nil {
+		msg := fmt.Sprintf("failed to read manifest: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	results := h.importRows(rows, concurrency)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for res := range results {
+		// Keep importing even if the client has gone away; the imports are not cancelled.
+		if err := enc.Encode(res); err != nil {
+			log.Debugw("failed to write import manifest progress", "err", err)
+			continue
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// importRows imports the given manifest rows with bounded concurrency in the background, and sends
+// the result of importing each row on the returned channel in order of completion.
+//
+// The advertisements generated by the import are announced once all rows are imported, after which
+// the channel is closed. The channel must be drained.
+func (h *manifestHandler) importRows(rows []ImportCarReq, concurrency int) <-chan *ImportManifestRes {
+	log.Infow("importing manifest", "rows", len(rows), "concurrency", concurrency)
+
+	// Only publish advertisements locally while importing, and announce the latest once done.
+	ctx := engine.WithDeferredAnnounce(context.Background())
+	results := make(chan *ImportManifestRes)
+	go func() {
+		defer close(results)
+		var wg sync.WaitGroup
+		var imported int32
+		sem := make(chan struct{}, concurrency)
+		for i := range rows {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(i int) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				res := h.importRow(ctx, i, rows[i])
+				if res.Error == "" {
+					atomic.AddInt32(&imported, 1)
+				}
+				results <- res
+			}(i)
+		}
+		wg.Wait()
+		log.Infow("imported manifest", "rows", len(rows), "imported", imported)
+
+		if imported != 0 {
+			if err := h.e.PublishLatest(context.Background()); err != nil {
+				log.Errorw("failed to announce latest advertisement after manifest import", "err", err)
+			}
+		}
+	}()
+	return results
+}
+
+func (h *manifestHandler) importRow(ctx context.Context, i int, row ImportCarReq) *ImportManifestRes {
+	res := &ImportManifestRes{
+		Row:  i,
+		Path: row.Path,
+		Key:  row.Key,
+	}
+	log := log.With("row", i, "path", row.Path)
+
+	if len(res.Key) == 0 {
+		sum := sha256.Sum256([]byte(row.Path))
+		res.Key = sum[:]
+	}
+	var md metadata.Metadata
+	if len(row.Metadata) == 0 {
+		// If no metadata is set, generate metadata that is compatible for Filecoin retrieval based
+		// on the context ID, consistent with the CLI.
+		tp, err := cardatatransfer.TransportFromContextID(res.Key)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		md = metadata.New(tp)
+	} else if err := md.UnmarshalBinary(row.Metadata); err != nil {
+		res.Error = fmt.Sprintf("failed to unmarshal metadata: %v", err)
+		return res
+	} else if err := md.ValidateKnown(); err != nil {
+		res.Error = fmt.Sprintf("invalid metadata: %v", err)
+		return res
+	}
+
+	advID, err := h.cs.Put(ctx, res.Key, row.Path, md)
+	if err != nil {
+		log.Errorw("failed to import CAR from manifest", "err", err)
+		res.Error = err.Error()
+		return res
+	}
+	log.Infow("imported CAR from manifest", "advID", advID)
+	res.AdvId = advID
+	return res
+}
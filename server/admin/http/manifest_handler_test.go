@@ -0,0 +1,172 @@
+package adminserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_importManifestHandler(t *testing.T) {
+	testdata := filepath.Join(testutil.ThisDir(t), "../../../testdata")
+	v1Path := filepath.Join(testdata, "sample-v1.car")
+	v2Path := filepath.Join(testdata, "sample-wrapped-v2.car")
+	missingPath := filepath.Join(t.TempDir(), "missing.car")
+	md := metadata.New(metadata.Bitswap{})
+	bitswapMd, err := md.MarshalBinary()
+	require.NoError(t, err)
+
+	jsonManifest, err := json.Marshal([]ImportCarReq{
+		{Path: v1Path},
+		{Path: v2Path, Key: []byte("lobster"), Metadata: bitswapMd},
+		{Path: missingPath},
+	})
+	require.NoError(t, err)
+	csvManifest := "path,key,metadata\n" +
+		v1Path + ",,\n" +
+		v2Path + "," + base64.StdEncoding.EncodeToString([]byte("lobster")) + "," + base64.StdEncoding.EncodeToString(bitswapMd) + "\n" +
+		missingPath + ",,\n"
+
+	tests := []struct {
+		name        string
+		contentType string
+		manifest    []byte
+	}{
+		{
+			name:        "json",
+			contentType: ManifestContentTypeJson,
+			manifest:    jsonManifest,
+		},
+		{
+			name:        "csv",
+			contentType: ManifestContentTypeCsv,
+			manifest:    []byte(csvManifest),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			t.Cleanup(cancel)
+			eng, err := engine.New()
+			require.NoError(t, err)
+			require.NoError(t, eng.Start(ctx))
+			t.Cleanup(func() { eng.Shutdown() })
+			cs := supplier.NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+			subject := &manifestHandler{cs, eng}
+
+			req, err := http.NewRequest(http.MethodPost, "/admin/import/manifest?concurrency=2", bytes.NewReader(tt.manifest))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(subject.handleImport).ServeHTTP(rr, req)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			var results []ImportManifestRes
+			scanner := bufio.NewScanner(rr.Body)
+			for scanner.Scan() {
+				var res ImportManifestRes
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &res))
+				results = append(results, res)
+			}
+			require.NoError(t, scanner.Err())
+			require.Len(t, results, 3)
+			sort.Slice(results, func(i, j int) bool { return results[i].Row < results[j].Row })
+
+			require.Empty(t, results[0].Error)
+			require.NotEqual(t, cid.Undef, results[0].AdvId)
+			require.Empty(t, results[1].Error)
+			require.Equal(t, []byte("lobster"), results[1].Key)
+			require.NotEqual(t, cid.Undef, results[1].AdvId)
+			require.Equal(t, missingPath, results[2].Path)
+			require.NotEmpty(t, results[2].Error)
+			require.Equal(t, cid.Undef, results[2].AdvId)
+
+			paths, err := cs.List(ctx)
+			require.NoError(t, err)
+			require.ElementsMatch(t, []string{v1Path, v2Path}, paths)
+
+			ad, err := eng.GetAdv(ctx, results[1].AdvId)
+			require.NoError(t, err)
+			require.Equal(t, []byte("lobster"), ad.ContextID)
+		})
+	}
+}
+
+func Test_importManifestHandler_InvalidManifestIsBadRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		manifest    string
+	}{
+		{
+			name:        "empty json",
+			contentType: ManifestContentTypeJson,
+			manifest:    "[]",
+		},
+		{
+			name:        "json without path",
+			contentType: ManifestContentTypeJson,
+			manifest:    `[{"key":"ZmlzaA=="}]`,
+		},
+		{
+			name:        "csv without path column",
+			contentType: ManifestContentTypeCsv,
+			manifest:    "key\nZmlzaA==\n",
+		},
+		{
+			name:        "csv with unknown column",
+			contentType: ManifestContentTypeCsv,
+			manifest:    "path,fish\nlobster,barreleye\n",
+		},
+		{
+			name:        "csv with invalid key",
+			contentType: ManifestContentTypeCsv,
+			manifest:    "path,key\nlobster,not-base64\n",
+		},
+		{
+			name:        "unknown content type",
+			contentType: "text/plain",
+			manifest:    "lobster",
+		},
+		{
+			name:        "invalid concurrency",
+			url:         "/admin/import/manifest?concurrency=0",
+			contentType: ManifestContentTypeCsv,
+			manifest:    "path\nlobster\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := tt.url
+			if url == "" {
+				url = "/admin/import/manifest"
+			}
+			req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(tt.manifest))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", tt.contentType)
+
+			subject := &manifestHandler{}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(subject.handleImport).ServeHTTP(rr, req)
+			require.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/multiformats/go-multicodec"
+)
+
+var _ json.Unmarshaler = (*MetadataBytes)(nil)
+
+// MetadataBytes is binary encoded metadata.Metadata.
+//
+// It is always represented in JSON as a base64 encoded string, like []byte, so that its
+// representation does not depend on whether the metadata can be decoded. The JSON representation
+// of metadata.Metadata, e.g. {"bitswap": {}}, is also accepted when unmarshalling, so that clients
+// can send metadata in readable form. Responses that carry metadata provide its decoded form in
+// a separate field.
+type MetadataBytes []byte
+
+// ParseMetadata parses metadata in its JSON representation, or as a base64 encoded string of
+// binary metadata. Like MetadataBytes.UnmarshalJSON, metadata in JSON representation must be
+// valid, whereas binary metadata is validated only once it is decoded.
+func ParseMetadata(s string) (MetadataBytes, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") {
+		var md metadata.Metadata
+		if err := json.Unmarshal([]byte(s), &md); err != nil {
+			return nil, err
+		}
+		return md.MarshalBinary()
+	}
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("metadata is neither JSON nor a valid base64 encoded string")
+	}
+	return decoded, nil
+}
+
+// String returns the JSON representation of the metadata, or its base64 encoding if it cannot be
+// decoded.
+func (mb MetadataBytes) String() string {
+	var md metadata.Metadata
+	if err := md.UnmarshalBinary(mb); err == nil {
+		if j, err := json.Marshal(md); err == nil {
+			return string(j)
+		}
+	}
+	return base64.StdEncoding.EncodeToString(mb)
+}
+
+// decode returns the decoded metadata, or nil if it cannot be decoded.
+func (mb MetadataBytes) decode() *metadata.Metadata {
+	if len(mb) == 0 {
+		return nil
+	}
+	var md metadata.Metadata
+	if err := md.UnmarshalBinary(mb); err != nil {
+		return nil
+	}
+	return &md
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (mb *MetadataBytes) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*mb = nil
+		return nil
+	case bytes.HasPrefix(data, []byte(`"`)):
+		var decoded []byte
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return err
+		}
+		*mb = decoded
+		return nil
+	default:
+		var md metadata.Metadata
+		if err := json.Unmarshal(data, &md); err != nil {
+			return err
+		}
+		encoded, err := md.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		*mb = encoded
+		return nil
+	}
+}
+
+// metadataUpdate is the change to the advertised metadata of a CAR requested by an
+// UpdateMetadataReq.
+type metadataUpdate struct {
+	md     metadata.Metadata
+	merge  bool
+	remove []multicodec.Code
+}
+
+// newMetadataUpdate decodes the metadata update requested by the given request.
+func newMetadataUpdate(req *UpdateMetadataReq) (*metadataUpdate, error) {
+	u := &metadataUpdate{merge: req.Merge || len(req.Remove) != 0}
+	for _, name := range req.Remove {
+		code, err := metadata.ProtocolCode(name)
+		if err != nil {
+			return nil, err
+		}
+		u.remove = append(u.remove, code)
+	}
+	if u.merge && len(req.Metadata) == 0 {
+		return u, nil
+	}
+	if err := u.md.UnmarshalBinary(req.Metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+	return u, nil
+}
+
+// apply returns the metadata with which to advertise the given context ID as a result of the
+// update. When merging, the protocols of the update are merged into the metadata currently
+// advertised by the given engine, in which case provider.ErrContextIDNotFound is returned if the
+// context ID is not advertised. Callers must serialize updates until the returned metadata is
+// advertised, so that concurrent merges do not lose each other's protocols.
+//
+// The returned metadata is not validated, since removing protocols may leave none.
+func (u *metadataUpdate) apply(ctx context.Context, e *engine.Engine, contextID []byte) (metadata.Metadata, error) {
+	if !u.merge {
+		return u.md, nil
+	}
+	info, err := e.GetContextID(ctx, contextID)
+	if err != nil {
+		return metadata.Metadata{}, err
+	}
+	return info.Metadata.With(u.md.Protocols()...).Without(u.remove...), nil
+}
@@ -0,0 +1,62 @@
+package adminserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataBytes_JSON(t *testing.T) {
+	md := metadata.New(metadata.Bitswap{})
+	mdBytes, err := md.MarshalBinary()
+	require.NoError(t, err)
+
+	// Metadata is represented as base64 encoded bytes.
+	data, err := json.Marshal(ImportCarReq{Path: "fish", Metadata: mdBytes})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"path": "fish", "key": null, "metadata": "`+base64.StdEncoding.EncodeToString(mdBytes)+`"}`, string(data))
+	var got ImportCarReq
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.Equal(t, MetadataBytes(mdBytes), got.Metadata)
+
+	// Metadata in JSON form is accepted.
+	got = ImportCarReq{}
+	require.NoError(t, json.Unmarshal([]byte(`{"path": "fish", "metadata": {"bitswap": {}}}`), &got))
+	require.Equal(t, MetadataBytes(mdBytes), got.Metadata)
+
+	// Metadata that cannot be decoded is represented the same way.
+	data, err = json.Marshal(MetadataBytes{0x80})
+	require.NoError(t, err)
+	require.Equal(t, `"gA=="`, string(data))
+
+	// Absent metadata is represented as null.
+	data, err = json.Marshal(MetadataBytes(nil))
+	require.NoError(t, err)
+	require.Equal(t, "null", string(data))
+
+	// Invalid metadata in JSON form is rejected.
+	require.Error(t, json.Unmarshal([]byte(`{"path": "fish", "metadata": {"fish": {}}}`), &got))
+}
+
+func TestParseMetadata(t *testing.T) {
+	md := metadata.New(metadata.Bitswap{})
+	mdBytes, err := md.MarshalBinary()
+	require.NoError(t, err)
+
+	got, err := ParseMetadata(` {"bitswap": {}} `)
+	require.NoError(t, err)
+	require.Equal(t, MetadataBytes(mdBytes), got)
+	require.Equal(t, `{"bitswap":{}}`, got.String())
+
+	got, err = ParseMetadata(base64.StdEncoding.EncodeToString(mdBytes))
+	require.NoError(t, err)
+	require.Equal(t, MetadataBytes(mdBytes), got)
+
+	_, err = ParseMetadata("!fish!")
+	require.EqualError(t, err, "metadata is neither JSON nor a valid base64 encoded string")
+	_, err = ParseMetadata(`{"bitswap": {"fish": true}}`)
+	require.Error(t, err)
+}
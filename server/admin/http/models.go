@@ -1,6 +1,9 @@
 package adminserver
 
 import (
+	"time"
+
+	"github.com/filecoin-project/index-provider/metadata"
 	"github.com/ipfs/go-cid"
 )
 
@@ -22,7 +25,7 @@ type (
 		// The optional key associated to the CAR. If not provided, one will be generated.
 		Key []byte `json:"key"`
 		// The optional metadata.
-		Metadata []byte `json:"metadata"`
+		Metadata MetadataBytes `json:"metadata"`
 	}
 	// ImportCarRes represents the response to an ImportCarReq.
 	ImportCarRes struct {
@@ -33,6 +36,22 @@ type (
 	}
 )
 
+// ImportManifestRes represents the result of importing a row of a manifest.
+// The results of a manifest import are streamed as newline-delimited JSON, in the order in which
+// the imports complete.
+type ImportManifestRes struct {
+	// The zero-based index of the manifest row.
+	Row int `json:"row"`
+	// The path to the CAR file.
+	Path string `json:"path"`
+	// The lookup key associated to the CAR.
+	Key []byte `json:"key,omitempty"`
+	// The CID of the advertisement generated as a result of import, if successful.
+	AdvId cid.Cid `json:"adv_id"`
+	// The cause of import failure, if unsuccessful.
+	Error string `json:"error,omitempty"`
+}
+
 type (
 	// RemoveCarReq represents a request for removing a CAR file.
 	RemoveCarReq struct {
@@ -46,6 +65,31 @@ type (
 	}
 )
 
+type (
+	// UpdateMetadataReq represents a request for updating the metadata of an imported CAR file.
+	UpdateMetadataReq struct {
+		// The key associated to the CAR.
+		Key []byte `json:"key"`
+		// The new metadata.
+		Metadata MetadataBytes `json:"metadata"`
+		// Whether to merge the new metadata into the currently advertised metadata, replacing only
+		// the protocols present in the new metadata, instead of replacing the advertised metadata
+		// wholesale. The new metadata is optional when merging.
+		Merge bool `json:"merge,omitempty"`
+		// The names of the protocols to remove from the currently advertised metadata, e.g.
+		// bitswap. Removing protocols implies merge.
+		// See: metadata.ProtocolName.
+		Remove []string `json:"remove,omitempty"`
+	}
+	// UpdateMetadataRes represents the response to an UpdateMetadataReq.
+	UpdateMetadataRes struct {
+		// The key associated to the CAR.
+		Key []byte `json:"key"`
+		// The CID of the advertisement generated as a result of the update.
+		AdvId cid.Cid `json:"adv_id"`
+	}
+)
+
 type (
 	// ListCarRes represents the response to list cars.
 	ListCarRes struct {
@@ -53,3 +97,140 @@ type (
 		Paths []string `json:"paths"`
 	}
 )
+
+type (
+	// JobRes represents the state of an admin job, such as importing or removing a CAR.
+	JobRes struct {
+		// The ID of the job.
+		ID string `json:"id"`
+		// The kind of job, e.g. JobKindImportCar.
+		Kind string `json:"kind"`
+		// The status of the job.
+		Status JobStatus `json:"status"`
+		// The key associated to the CAR.
+		Key []byte `json:"key,omitempty"`
+		// The path to the CAR file, if known.
+		Path string `json:"path,omitempty"`
+		// The CID of the advertisement generated as a result of the job, if successful.
+		AdvId cid.Cid `json:"adv_id"`
+		// The cause of failure, if the job failed or was cancelled.
+		Error string `json:"error,omitempty"`
+		// The time at which the job was created.
+		Created time.Time `json:"created"`
+		// The time at which the job finished, or nil if the job is still running.
+		Finished *time.Time `json:"finished,omitempty"`
+		// The progress of the job, if reported by its kind of job.
+		Progress *JobProgress `json:"progress,omitempty"`
+	}
+	// JobProgress represents the progress of a job.
+	JobProgress struct {
+		// The number of items processed so far.
+		Done int `json:"done"`
+		// The total number of items to process.
+		Total int `json:"total"`
+	}
+	// ListJobsRes represents the response to list jobs.
+	ListJobsRes struct {
+		// The jobs in order of creation.
+		Jobs []JobRes `json:"jobs"`
+	}
+)
+
+type (
+	// AdRes represents an advertisement published by the provider.
+	AdRes struct {
+		// The CID of the advertisement.
+		ID cid.Cid `json:"id"`
+		// The CID of the previous advertisement in the chain, if any.
+		PreviousID cid.Cid `json:"previous_id"`
+		// The ID of the provider that published the advertisement.
+		Provider string `json:"provider"`
+		// The addresses at which the advertised content is retrievable.
+		Addresses []string `json:"addresses"`
+		// The CID of the advertised chain of multihash entries.
+		Entries cid.Cid `json:"entries"`
+		// The advertised context ID.
+		ContextID []byte `json:"context_id"`
+		// The advertised metadata.
+		Metadata MetadataBytes `json:"metadata"`
+		// The advertised metadata in decoded form, or nil if it cannot be decoded.
+		DecodedMetadata *metadata.Metadata `json:"decoded_metadata,omitempty"`
+		// Whether the advertisement removes the content associated to its context ID.
+		IsRm bool `json:"is_rm"`
+	}
+	// ListAdsRes represents a page of the advertisement chain, starting from the latest.
+	ListAdsRes struct {
+		// The advertisements in the page, each followed by its previous advertisement.
+		Ads []AdRes `json:"ads"`
+		// The CID of the advertisement at which the next page starts, or undefined if the page
+		// reaches the end of the chain.
+		Next cid.Cid `json:"next"`
+	}
+)
+
+type (
+	// ListContextIDsRes represents a page of the context IDs advertised by the provider.
+	ListContextIDsRes struct {
+		// The advertised context IDs in lexicographic order.
+		ContextIDs [][]byte `json:"context_ids"`
+		// The offset at which the next page starts, or zero if there are no more context IDs.
+		NextOffset int `json:"next_offset,omitempty"`
+	}
+	// ContextIDRes represents the state of a context ID advertised by the provider.
+	ContextIDRes struct {
+		// The advertised context ID.
+		ContextID []byte `json:"context_id"`
+		// The CID of the advertised chain of multihash entries.
+		Entries cid.Cid `json:"entries"`
+		// The advertised metadata.
+		Metadata MetadataBytes `json:"metadata"`
+		// The advertised metadata in decoded form, or nil if there is no metadata.
+		DecodedMetadata *metadata.Metadata `json:"decoded_metadata,omitempty"`
+	}
+	// FindContextIDsRes represents the context IDs that contain a multihash.
+	FindContextIDsRes struct {
+		// The advertised context IDs that contain the multihash.
+		ContextIDs [][]byte `json:"context_ids"`
+	}
+)
+
+type (
+	// RetrievalStatsRes represents the retrieval stats of a context ID.
+	RetrievalStatsRes struct {
+		// The context ID of the retrieved content.
+		ContextID []byte `json:"context_id"`
+		// The number of transfers that sent all the content selected.
+		Completed uint64 `json:"completed"`
+		// The number of transfers that ended due to an error.
+		Failed uint64 `json:"failed"`
+		// The number of transfers cancelled by either end.
+		Cancelled uint64 `json:"cancelled"`
+		// The total number of bytes sent over all transfers.
+		BytesSent uint64 `json:"bytes_sent"`
+		// The total duration of all transfers.
+		Duration time.Duration `json:"duration"`
+		// The time at which the latest transfer ended.
+		LastRetrieved time.Time `json:"last_retrieved"`
+		// The most recent transfers, latest first. Only populated when getting the stats of a
+		// single context ID.
+		Transfers []RetrievalTransferRes `json:"transfers,omitempty"`
+	}
+	// RetrievalTransferRes represents a transfer of the content of a context ID.
+	RetrievalTransferRes struct {
+		// The ID of the peer to which the content was sent.
+		Peer string `json:"peer"`
+		// The number of bytes sent.
+		BytesSent uint64 `json:"bytes_sent"`
+		// The time at which the transfer was accepted.
+		Started time.Time `json:"started"`
+		// The time from the acceptance of the transfer to its end.
+		Duration time.Duration `json:"duration"`
+		// The way in which the transfer ended, i.e. completed, failed or cancelled.
+		Outcome string `json:"outcome"`
+	}
+	// ListRetrievalStatsRes represents the retrieval stats of all retrieved context IDs.
+	ListRetrievalStatsRes struct {
+		// The stats in descending order of number of transfers.
+		Stats []RetrievalStatsRes `json:"stats"`
+	}
+)
@@ -1,6 +1,13 @@
 package adminserver
 
-import "time"
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/index-provider/cardatatransfer"
+)
 
 type (
 	// Option captures a configurable parameter in admin HTTP server.
@@ -10,6 +17,9 @@ type (
 		listenAddr   string
 		readTimeout  time.Duration
 		writeTimeout time.Duration
+		tokens       []bearerToken
+		tlsConfig    *tls.Config
+		stats        *cardatatransfer.RetrievalStats
 	}
 )
 
@@ -54,3 +64,45 @@ func WithWriteTimeout(t time.Duration) Option {
 		return nil
 	}
 }
+
+// WithBearerToken adds a bearer token that grants access to admin endpoints with the given scope.
+// The option may be specified multiple times to add several tokens.
+// If no tokens are added, requests to the admin server are not authenticated.
+func WithBearerToken(token string, scope Scope) Option {
+	return func(o *options) error {
+		if token == "" {
+			return errors.New("bearer token cannot be empty")
+		}
+		switch scope {
+		case ScopeReadOnly, ScopeReadWrite:
+		default:
+			return fmt.Errorf("unknown scope: %s", scope)
+		}
+		o.tokens = append(o.tokens, bearerToken{token: []byte(token), scope: scope})
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to serve the admin HTTP server over HTTPS.
+// The configuration must have at least one certificate. Mutual TLS can be enforced by setting
+// tls.Config.ClientAuth and tls.Config.ClientCAs.
+// If unset, the admin HTTP server is served over plain HTTP.
+func WithTLSConfig(c *tls.Config) Option {
+	return func(o *options) error {
+		if c == nil || (len(c.Certificates) == 0 && c.GetCertificate == nil) {
+			return errors.New("TLS config must have at least one certificate")
+		}
+		o.tlsConfig = c
+		return nil
+	}
+}
+
+// WithRetrievalStats sets the retrieval stats exposed by the admin HTTP server, which should be the
+// stats in which the car data transfer server records transfers.
+// If unset, requests for retrieval stats fail.
+func WithRetrievalStats(s *cardatatransfer.RetrievalStats) Option {
+	return func(o *options) error {
+		o.stats = s
+		return nil
+	}
+}
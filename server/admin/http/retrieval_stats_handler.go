@@ -0,0 +1,113 @@
+package adminserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/filecoin-project/index-provider/cardatatransfer"
+	"github.com/gorilla/mux"
+)
+
+// errRetrievalStatsDisabled signals that the server was not given retrieval stats to expose.
+var errRetrievalStatsDisabled = errors.New("retrieval stats are disabled")
+
+type retrievalStatsHandler struct {
+	stats *cardatatransfer.RetrievalStats
+}
+
+// handleList responds with the retrieval stats of all retrieved context IDs.
+func (h *retrievalStatsHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.list(r.Context())
+	if err != nil {
+		if errors.Is(err, errRetrievalStatsDisabled) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		msg := fmt.Sprintf("failed to list retrieval stats: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+// handleGet responds with the retrieval stats and most recent transfers of the context ID in the
+// request path, encoded as URL-safe base64.
+func (h *retrievalStatsHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	contextID, err := decodeContextID(mux.Vars(r)["contextid"])
+	if err != nil {
+		http.Error(w, "context ID must be URL-safe base64 encoded", http.StatusBadRequest)
+		return
+	}
+	resp, err := h.get(r.Context(), contextID)
+	if err != nil {
+		switch {
+		case errors.Is(err, cardatatransfer.ErrNoRetrievals):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, errRetrievalStatsDisabled):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			msg := fmt.Sprintf("failed to get retrieval stats: %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusInternalServerError)
+		}
+		return
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+func (h *retrievalStatsHandler) list(ctx context.Context) (*ListRetrievalStatsRes, error) {
+	if h.stats == nil {
+		return nil, errRetrievalStatsDisabled
+	}
+	list, err := h.stats.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &ListRetrievalStatsRes{
+		Stats: make([]RetrievalStatsRes, 0, len(list)),
+	}
+	for i := range list {
+		resp.Stats = append(resp.Stats, *toRetrievalStatsRes(&list[i]))
+	}
+	return resp, nil
+}
+
+func (h *retrievalStatsHandler) get(ctx context.Context, contextID []byte) (*RetrievalStatsRes, error) {
+	if h.stats == nil {
+		return nil, errRetrievalStatsDisabled
+	}
+	counters, err := h.stats.Get(ctx, contextID)
+	if err != nil {
+		return nil, err
+	}
+	records, err := h.stats.Records(ctx, contextID)
+	if err != nil {
+		return nil, err
+	}
+	resp := toRetrievalStatsRes(counters)
+	for _, r := range records {
+		resp.Transfers = append(resp.Transfers, RetrievalTransferRes{
+			Peer:      r.Peer.String(),
+			BytesSent: r.BytesSent,
+			Started:   r.Started,
+			Duration:  r.Duration,
+			Outcome:   string(r.Outcome),
+		})
+	}
+	return resp, nil
+}
+
+func toRetrievalStatsRes(c *cardatatransfer.RetrievalCounters) *RetrievalStatsRes {
+	return &RetrievalStatsRes{
+		ContextID:     c.ContextID,
+		Completed:     c.Completed,
+		Failed:        c.Failed,
+		Cancelled:     c.Cancelled,
+		BytesSent:     c.BytesSent,
+		Duration:      c.Duration,
+		LastRetrieved: c.LastRetrieved,
+	}
+}
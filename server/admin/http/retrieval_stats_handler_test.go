@@ -0,0 +1,87 @@
+package adminserver
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/index-provider/cardatatransfer"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p-core/test"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_retrievalStatsHandler(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	stats := cardatatransfer.NewRetrievalStats(dssync.MutexWrap(datastore.NewMapDatastore()))
+	subject := startTestServer(t, WithRetrievalStats(stats))
+	baseURL := "http://" + subject.Addr().String()
+
+	var list ListRetrievalStatsRes
+	requireGetOk(t, baseURL+"/admin/stats/retrievals", &list)
+	require.Empty(t, list.Stats)
+
+	client := test.RandPeerIDFatal(t)
+	started := time.Now().UTC().Truncate(time.Second)
+	for i, outcome := range []cardatatransfer.RetrievalOutcome{cardatatransfer.RetrievalCompleted, cardatatransfer.RetrievalFailed} {
+		require.NoError(t, stats.Record(ctx, &cardatatransfer.RetrievalRecord{
+			Peer:      client,
+			ContextID: []byte("fish"),
+			BytesSent: 1413,
+			Started:   started.Add(time.Duration(i) * time.Minute),
+			Duration:  time.Second,
+			Outcome:   outcome,
+		}))
+	}
+	require.NoError(t, stats.Record(ctx, &cardatatransfer.RetrievalRecord{
+		Peer:      client,
+		ContextID: []byte("lobster"),
+		Started:   started,
+		Outcome:   cardatatransfer.RetrievalCancelled,
+	}))
+
+	list = ListRetrievalStatsRes{}
+	requireGetOk(t, baseURL+"/admin/stats/retrievals", &list)
+	require.Len(t, list.Stats, 2)
+	require.Equal(t, []byte("fish"), list.Stats[0].ContextID)
+	require.Equal(t, uint64(1), list.Stats[0].Completed)
+	require.Equal(t, uint64(1), list.Stats[0].Failed)
+	require.Equal(t, uint64(2*1413), list.Stats[0].BytesSent)
+	require.Empty(t, list.Stats[0].Transfers)
+	require.Equal(t, []byte("lobster"), list.Stats[1].ContextID)
+	require.Equal(t, uint64(1), list.Stats[1].Cancelled)
+
+	var got RetrievalStatsRes
+	requireGetOk(t, baseURL+"/admin/stats/retrievals/"+base64.RawURLEncoding.EncodeToString([]byte("fish")), &got)
+	require.Equal(t, []byte("fish"), got.ContextID)
+	require.Equal(t, 2*time.Second, got.Duration)
+	require.Equal(t, started.Add(time.Minute+time.Second), got.LastRetrieved)
+	require.Equal(t, []RetrievalTransferRes{
+		{
+			Peer:      client.String(),
+			BytesSent: 1413,
+			Started:   started.Add(time.Minute),
+			Duration:  time.Second,
+			Outcome:   "failed",
+		},
+		{
+			Peer:      client.String(),
+			BytesSent: 1413,
+			Started:   started,
+			Duration:  time.Second,
+			Outcome:   "completed",
+		},
+	}, got.Transfers)
+
+	resp := httpGet(t, baseURL+"/admin/stats/retrievals/"+base64.RawURLEncoding.EncodeToString([]byte("undadasea")))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = httpGet(t, baseURL+"/admin/stats/retrievals/!fish!")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Retrieval stats are unavailable unless the server is given them.
+	disabled := startTestServer(t)
+	resp = httpGet(t, "http://"+disabled.Addr().String()+"/admin/stats/retrievals")
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+}
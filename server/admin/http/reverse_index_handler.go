@@ -0,0 +1,57 @@
+package adminserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/ipfs/go-cid"
+)
+
+type reverseIndexHandler struct {
+	e    *engine.Engine
+	jobs *jobManager
+}
+
+// handleRebuild rebuilds the reverse index of the engine as a job, and responds with the job once
+// complete. The job is returned immediately if the request asks for the rebuild to run in the
+// background.
+func (h *reverseIndexHandler) handleRebuild(w http.ResponseWriter, r *http.Request) {
+	log.Info("received rebuild reverse index request")
+
+	async, err := isAsync(r)
+	if err != nil {
+		http.Error(w, "async must be a boolean", http.StatusBadRequest)
+		return
+	}
+
+	j, err := h.jobs.start(JobKindRebuildReverseIndex, nil, "", func(ctx context.Context) (cid.Cid, error) {
+		return cid.Undef, h.e.RebuildReverseIndex(ctx)
+	})
+	if err != nil {
+		msg := fmt.Sprintf("failed to start rebuild job: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if async {
+		respondJobAccepted(w, j)
+		return
+	}
+	// Wait for the rebuild to complete, cancelling it if the request is cancelled.
+	if _, err := j.wait(r.Context()); err != nil {
+		if errors.Is(err, engine.ErrReverseIndexDisabled) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		msg := fmt.Sprintf("failed to rebuild reverse index: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	log.Info("rebuilt reverse index successfully")
+	resp := j.info()
+	respond(w, http.StatusOK, &resp)
+}
@@ -0,0 +1,44 @@
+package adminserver
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_reverseIndexHandler_Rebuild(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	baseURL, _, cs := startTestEngineServer(t, engine.WithReverseIndex(true))
+
+	carPath := filepath.Join(testutil.ThisDir(t), "../../../testdata/sample-v1.car")
+	_, err := cs.Put(ctx, []byte("fish"), carPath, metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	resp, err := http.Post(baseURL+"/admin/reverseindex/rebuild", "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var job JobRes
+	_, err = job.ReadFrom(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, JobKindRebuildReverseIndex, job.Kind)
+	require.Equal(t, JobStatusSucceeded, job.Status)
+
+	var found FindContextIDsRes
+	requireGetOk(t, baseURL+"/admin/multihashes/"+carRoot(t, carPath).String()+"/contextids", &found)
+	require.Equal(t, [][]byte{[]byte("fish")}, found.ContextIDs)
+}
+
+func Test_reverseIndexHandler_RebuildWhenDisabledIsConflict(t *testing.T) {
+	baseURL, _, _ := startTestEngineServer(t)
+
+	resp, err := http.Post(baseURL+"/admin/reverseindex/rebuild", "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+}
@@ -0,0 +1,165 @@
+package adminserver
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/multiformats/go-multihash"
+)
+
+// RPCVersion is the version of JSON-RPC protocol implemented by the admin RPC service.
+const RPCVersion = "2.0"
+
+// The methods of the admin RPC service.
+const (
+	// RPCMethodAnnounce announces the latest advertisement. It takes no params and returns an
+	// AnnounceRes.
+	RPCMethodAnnounce = "Admin.Announce"
+	// RPCMethodConnect connects to a peer. It takes ConnectReq params and returns a ConnectRes.
+	RPCMethodConnect = "Admin.Connect"
+	// RPCMethodImportCar imports a CAR file and waits for the import to complete. It takes
+	// ImportCarReq params and returns an ImportCarRes.
+	RPCMethodImportCar = "Admin.ImportCar"
+	// RPCMethodImportCarAsync starts importing a CAR file as a job. It takes ImportCarReq params and
+	// returns the JobRes of the started job.
+	RPCMethodImportCarAsync = "Admin.ImportCarAsync"
+	// RPCMethodImportManifest imports the CARs listed in a manifest. It takes ImportManifestReq
+	// params, streams the progress of import as RPCMethodImportProgress notifications, and returns
+	// an ImportManifestSummaryRes once all rows are imported.
+	RPCMethodImportManifest = "Admin.ImportManifest"
+	// RPCMethodImportProgress is the method of notifications that report the result of importing a
+	// manifest row, with ImportManifestRes params.
+	RPCMethodImportProgress = "Admin.ImportProgress"
+	// RPCMethodRemoveCar removes a CAR file and waits for the removal to complete. It takes
+	// RemoveCarReq params and returns a RemoveCarRes.
+	RPCMethodRemoveCar = "Admin.RemoveCar"
+	// RPCMethodRemoveCarAsync starts removing a CAR file as a job. It takes RemoveCarReq params and
+	// returns the JobRes of the started job.
+	RPCMethodRemoveCarAsync = "Admin.RemoveCarAsync"
+	// RPCMethodUpdateMetadata updates the metadata of an imported CAR file. It takes
+	// UpdateMetadataReq params and returns an UpdateMetadataRes.
+	RPCMethodUpdateMetadata = "Admin.UpdateMetadata"
+	// RPCMethodListCars lists the paths of imported CAR files. It takes no params and returns a
+	// ListCarRes.
+	RPCMethodListCars = "Admin.ListCars"
+	// RPCMethodListJobs lists the jobs. It takes no params and returns a ListJobsRes.
+	RPCMethodListJobs = "Admin.ListJobs"
+	// RPCMethodGetJob gets a job. It takes JobReq params and returns a JobRes.
+	RPCMethodGetJob = "Admin.GetJob"
+	// RPCMethodCancelJob requests the cancellation of a job. It takes JobReq params and returns a
+	// JobRes.
+	RPCMethodCancelJob = "Admin.CancelJob"
+	// RPCMethodFindContextIDs finds the advertised context IDs that contain a multihash. It takes
+	// FindContextIDsReq params and returns a FindContextIDsRes.
+	RPCMethodFindContextIDs = "Admin.FindContextIDs"
+	// RPCMethodRebuildReverseIndex rebuilds the reverse index of the engine. It takes
+	// RebuildReverseIndexReq params and returns the JobRes of the rebuild job.
+	RPCMethodRebuildReverseIndex = "Admin.RebuildReverseIndex"
+	// RPCMethodDrain removes all the content advertised by the provider. It takes DrainReq params
+	// and returns the JobRes of the drain job once started, which runs in the background.
+	RPCMethodDrain = "Admin.Drain"
+	// RPCMethodListRetrievalStats lists the retrieval stats of all retrieved context IDs. It takes no
+	// params and returns a ListRetrievalStatsRes.
+	RPCMethodListRetrievalStats = "Admin.ListRetrievalStats"
+	// RPCMethodGetRetrievalStats gets the retrieval stats of a context ID, along with its most
+	// recent transfers. It takes RetrievalStatsReq params and returns a RetrievalStatsRes.
+	RPCMethodGetRetrievalStats = "Admin.GetRetrievalStats"
+)
+
+// The error codes of the admin RPC service, in addition to the error codes defined by the JSON-RPC
+// specification.
+const (
+	RPCCodeParseError     = -32700
+	RPCCodeInvalidRequest = -32600
+	RPCCodeMethodNotFound = -32601
+	RPCCodeInvalidParams  = -32602
+	RPCCodeInternalError  = -32603
+	// RPCCodeNotFound signals that the entity targeted by a request, such as a CAR or a job, does
+	// not exist.
+	RPCCodeNotFound = -32001
+	// RPCCodeConflict signals that a request conflicts with the state of the provider, for example
+	// importing a CAR that is already advertised.
+	RPCCodeConflict = -32002
+	// RPCCodeForbidden signals that the bearer token of a request does not grant the scope
+	// required by its method.
+	RPCCodeForbidden = -32003
+)
+
+type (
+	// RPCRequest represents a JSON-RPC request to the admin RPC service.
+	// Batch requests are not supported.
+	RPCRequest struct {
+		JsonRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id,omitempty"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params,omitempty"`
+	}
+	// RPCMessage represents a JSON-RPC message sent by the admin RPC service, which is either a
+	// response to a request or a notification.
+	//
+	// Methods that stream progress respond with zero or more newline-delimited notifications,
+	// followed by the response to the request.
+	RPCMessage struct {
+		JsonRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id,omitempty"`
+		Result  json.RawMessage `json:"result,omitempty"`
+		Error   *RPCError       `json:"error,omitempty"`
+		// The method and params of notifications.
+		Method string          `json:"method,omitempty"`
+		Params json.RawMessage `json:"params,omitempty"`
+	}
+	// RPCError represents the error of a failed JSON-RPC request.
+	RPCError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+)
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+type (
+	// AnnounceRes represents the response to an announce request.
+	AnnounceRes struct { // Empty placeholder used to return an empty JSON object in body.
+	}
+	// ImportManifestReq represents a request to import the CARs listed in a manifest.
+	ImportManifestReq struct {
+		// The manifest rows.
+		Rows []ImportCarReq `json:"rows"`
+		// The maximum number of CARs to import concurrently. Defaults to 4 if unset.
+		Concurrency int `json:"concurrency,omitempty"`
+	}
+	// ImportManifestSummaryRes represents the outcome of importing a manifest.
+	ImportManifestSummaryRes struct {
+		// The number of manifest rows.
+		Rows int `json:"rows"`
+		// The number of rows imported successfully.
+		Imported int `json:"imported"`
+	}
+	// JobReq represents a request that targets a job.
+	JobReq struct {
+		// The ID of the job.
+		ID string `json:"id"`
+	}
+	// FindContextIDsReq represents a request to find the context IDs that contain a multihash.
+	FindContextIDsReq struct {
+		// The multihash to look up.
+		Multihash multihash.Multihash `json:"multihash"`
+	}
+	// DrainReq represents a request to remove all the content advertised by the provider.
+	DrainReq struct {
+		// The number of context IDs to read at a time. Defaults to 1000 if unset.
+		BatchSize int `json:"batch_size,omitempty"`
+	}
+	// RetrievalStatsReq represents a request for the retrieval stats of a context ID.
+	RetrievalStatsReq struct {
+		// The context ID of the retrieved content.
+		ContextID []byte `json:"context_id"`
+	}
+	// RebuildReverseIndexReq represents a request to rebuild the reverse index.
+	RebuildReverseIndexReq struct {
+		// Whether to respond once the rebuild job has started instead of once it has completed.
+		Async bool `json:"async,omitempty"`
+	}
+)
@@ -0,0 +1,477 @@
+package adminserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/cardatatransfer"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+)
+
+type (
+	rpcHandler struct {
+		s         *Server
+		cs        *supplier.CarSupplier
+		cars      *carHandler
+		manifests *manifestHandler
+		drains    *drainHandler
+		stats     *retrievalStatsHandler
+		methods   map[string]rpcMethod
+	}
+	rpcMethod struct {
+		// scope is the scope required to call the method.
+		scope Scope
+		// streaming signals whether the method sends notifications before its response.
+		streaming bool
+		call      rpcFunc
+	}
+	// rpcFunc calls a method with the given params, and returns its result. Streaming methods send
+	// notifications via the given notify function.
+	rpcFunc func(ctx context.Context, params json.RawMessage, notify rpcNotifyFunc) (interface{}, error)
+	// rpcNotifyFunc sends a notification with the given method and params.
+	rpcNotifyFunc func(method string, params interface{})
+)
+
+func newRPCHandler(s *Server, cs *supplier.CarSupplier, cars *carHandler, manifests *manifestHandler, drains *drainHandler, stats *retrievalStatsHandler) *rpcHandler {
+	h := &rpcHandler{
+		s:         s,
+		cs:        cs,
+		cars:      cars,
+		manifests: manifests,
+		drains:    drains,
+		stats:     stats,
+	}
+	h.methods = map[string]rpcMethod{
+		RPCMethodAnnounce:            {scope: ScopeReadWrite, call: h.announce},
+		RPCMethodConnect:             {scope: ScopeReadWrite, call: h.connect},
+		RPCMethodImportCar:           {scope: ScopeReadWrite, call: h.importCar},
+		RPCMethodImportCarAsync:      {scope: ScopeReadWrite, call: h.importCarAsync},
+		RPCMethodImportManifest:      {scope: ScopeReadWrite, streaming: true, call: h.importManifest},
+		RPCMethodRemoveCar:           {scope: ScopeReadWrite, call: h.removeCar},
+		RPCMethodRemoveCarAsync:      {scope: ScopeReadWrite, call: h.removeCarAsync},
+		RPCMethodUpdateMetadata:      {scope: ScopeReadWrite, call: h.updateMetadata},
+		RPCMethodListCars:            {scope: ScopeReadOnly, call: h.listCars},
+		RPCMethodListJobs:            {scope: ScopeReadOnly, call: h.listJobs},
+		RPCMethodGetJob:              {scope: ScopeReadOnly, call: h.getJob},
+		RPCMethodCancelJob:           {scope: ScopeReadWrite, call: h.cancelJob},
+		RPCMethodFindContextIDs:      {scope: ScopeReadOnly, call: h.findContextIDs},
+		RPCMethodRebuildReverseIndex: {scope: ScopeReadWrite, call: h.rebuildReverseIndex},
+		RPCMethodDrain:               {scope: ScopeReadWrite, call: h.drain},
+		RPCMethodListRetrievalStats:  {scope: ScopeReadOnly, call: h.listRetrievalStats},
+		RPCMethodGetRetrievalStats:   {scope: ScopeReadOnly, call: h.getRetrievalStats},
+	}
+	return h
+}
+
+// handle serves a JSON-RPC request. Errors are reported as JSON-RPC errors in a response with
+// status OK, as per the JSON-RPC over HTTP convention.
+func (h *rpcHandler) handle(w http.ResponseWriter, r *http.Request) {
+	var req RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respond(w, nil, nil, &RPCError{RPCCodeParseError, fmt.Sprintf("failed to parse request: %v", err)})
+		return
+	}
+	if req.JsonRPC != RPCVersion || req.Method == "" {
+		h.respond(w, req.ID, nil, &RPCError{RPCCodeInvalidRequest, "invalid JSON-RPC 2.0 request"})
+		return
+	}
+	m, ok := h.methods[req.Method]
+	if !ok {
+		h.respond(w, req.ID, nil, &RPCError{RPCCodeMethodNotFound, "unknown method: " + req.Method})
+		return
+	}
+	if scope := h.s.scopeOf(r); !scope.allows(m.scope) {
+		h.respond(w, req.ID, nil, &RPCError{RPCCodeForbidden, "bearer token does not grant " + string(m.scope) + " access"})
+		return
+	}
+
+	log := log.With("method", req.Method)
+	log.Info("received RPC request")
+	var notify rpcNotifyFunc
+	if m.streaming {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		notify = func(method string, params interface{}) {
+			msg := &RPCMessage{JsonRPC: RPCVersion, Method: method}
+			var err error
+			if msg.Params, err = json.Marshal(params); err == nil {
+				err = enc.Encode(msg)
+			}
+			// Keep going even if the client has gone away.
+			if err != nil {
+				log.Debugw("failed to write RPC notification", "err", err)
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	result, err := m.call(r.Context(), req.Params, notify)
+	if err != nil {
+		rpcErr := toRPCError(err)
+		if rpcErr.Code == RPCCodeInternalError {
+			log.Errorw("RPC request failed", "err", err)
+		} else {
+			log.Infow("RPC request failed", "err", err)
+		}
+		h.respond(w, req.ID, nil, rpcErr)
+		return
+	}
+	h.respond(w, req.ID, result, nil)
+}
+
+func (h *rpcHandler) respond(w http.ResponseWriter, id json.RawMessage, result interface{}, rpcErr *RPCError) {
+	msg := &RPCMessage{
+		JsonRPC: RPCVersion,
+		ID:      id,
+		Error:   rpcErr,
+	}
+	if msg.ID == nil {
+		msg.ID = json.RawMessage("null")
+	}
+	if rpcErr == nil {
+		var err error
+		if msg.Result, err = json.Marshal(result); err != nil {
+			msg.Error = &RPCError{RPCCodeInternalError, fmt.Sprintf("failed to encode result: %v", err)}
+		}
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		log.Errorw("failed to write RPC response", "err", err)
+	}
+}
+
+// toRPCError converts the given error to the RPCError reported to clients.
+func toRPCError(err error) *RPCError {
+	var rpcErr *RPCError
+	switch {
+	case errors.As(err, &rpcErr):
+		return rpcErr
+	case errors.Is(err, provider.ErrAlreadyAdvertised):
+		return &RPCError{RPCCodeConflict, "CAR already advertised"}
+	case errors.Is(err, engine.ErrReverseIndexDisabled), errors.Is(err, errRetrievalStatsDisabled):
+		return &RPCError{RPCCodeConflict, err.Error()}
+	case errors.Is(err, supplier.ErrNotFound), errors.Is(err, provider.ErrContextIDNotFound),
+		errors.Is(err, cardatatransfer.ErrNoRetrievals):
+		return &RPCError{RPCCodeNotFound, err.Error()}
+	case errors.Is(err, supplier.ErrInvalidCar), errors.Is(err, fs.ErrNotExist):
+		return &RPCError{RPCCodeInvalidParams, err.Error()}
+	default:
+		return &RPCError{RPCCodeInternalError, err.Error()}
+	}
+}
+
+func decodeParams(params json.RawMessage, dst interface{}) error {
+	if len(params) == 0 {
+		return &RPCError{RPCCodeInvalidParams, "params must be specified"}
+	}
+	if err := json.Unmarshal(params, dst); err != nil {
+		return &RPCError{RPCCodeInvalidParams, fmt.Sprintf("failed to unmarshal params: %v", err)}
+	}
+	return nil
+}
+
+func (h *rpcHandler) announce(ctx context.Context, _ json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	if err := h.s.e.PublishLatest(ctx); err != nil {
+		return nil, err
+	}
+	return &AnnounceRes{}, nil
+}
+
+func (h *rpcHandler) connect(ctx context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	var req ConnectReq
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	maddr, err := ma.NewMultiaddr(req.Maddr)
+	if err != nil {
+		return nil, &RPCError{RPCCodeInvalidParams, fmt.Sprintf("failed to parse multiaddr: %v", err)}
+	}
+	addrInfo, err := peer.AddrInfoFromP2pAddr(maddr)
+	if err != nil {
+		return nil, &RPCError{RPCCodeInvalidParams, fmt.Sprintf("failed to create addrInfo from multiaddr: %v", err)}
+	}
+	if err := h.s.h.Connect(ctx, *addrInfo); err != nil {
+		return nil, fmt.Errorf("failed to connect to peer: %w", err)
+	}
+	log.Infow("Connected to peer successfully", "addrInfo", addrInfo)
+	return &ConnectRes{}, nil
+}
+
+func (h *rpcHandler) startImportCar(params json.RawMessage) (*job, *ImportCarReq, error) {
+	var req ImportCarReq
+	if err := decodeParams(params, &req); err != nil {
+		return nil, nil, err
+	}
+	var md metadata.Metadata
+	if err := md.UnmarshalBinary(req.Metadata); err != nil {
+		return nil, nil, &RPCError{RPCCodeInvalidParams, fmt.Sprintf("failed to unmarshal metadata: %v", err)}
+	}
+	if err := md.ValidateKnown(); err != nil {
+		return nil, nil, &RPCError{RPCCodeInvalidParams, fmt.Sprintf("invalid metadata: %v", err)}
+	}
+	j, err := h.s.jobs.start(JobKindImportCar, req.Key, req.Path, func(ctx context.Context) (cid.Cid, error) {
+		return h.cs.Put(ctx, req.Key, req.Path, md)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start import job: %w", err)
+	}
+	return j, &req, nil
+}
+
+func (h *rpcHandler) importCar(ctx context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	j, req, err := h.startImportCar(params)
+	if err != nil {
+		return nil, err
+	}
+	// Wait for the import to complete, cancelling it if the request is cancelled.
+	advID, err := j.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Infow("imported CAR successfully", "path", req.Path)
+	return &ImportCarRes{req.Key, advID}, nil
+}
+
+func (h *rpcHandler) importCarAsync(_ context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	j, _, err := h.startImportCar(params)
+	if err != nil {
+		return nil, err
+	}
+	res := j.info()
+	return &res, nil
+}
+
+func (h *rpcHandler) importManifest(_ context.Context, params json.RawMessage, notify rpcNotifyFunc) (interface{}, error) {
+	var req ImportManifestReq
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if err := validateManifestRows(req.Rows); err != nil {
+		return nil, &RPCError{RPCCodeInvalidParams, err.Error()}
+	}
+	concurrency := req.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultImportConcurrency
+	}
+	if concurrency < 1 || concurrency > maxImportConcurrency {
+		return nil, &RPCError{RPCCodeInvalidParams, fmt.Sprintf("concurrency must be between 1 and %d", maxImportConcurrency)}
+	}
+
+	res := &ImportManifestSummaryRes{Rows: len(req.Rows)}
+	for rowRes := range h.manifests.importRows(req.Rows, concurrency) {
+		if rowRes.Error == "" {
+			res.Imported++
+		}
+		notify(RPCMethodImportProgress, rowRes)
+	}
+	return res, nil
+}
+
+func (h *rpcHandler) startRemoveCar(params json.RawMessage) (*job, error) {
+	var req RemoveCarReq
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Key) == 0 {
+		return nil, &RPCError{RPCCodeInvalidParams, "key must be specified"}
+	}
+	j, err := h.s.jobs.start(JobKindRemoveCar, req.Key, "", func(ctx context.Context) (cid.Cid, error) {
+		return h.cs.Remove(ctx, req.Key)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start remove job: %w", err)
+	}
+	return j, nil
+}
+
+func (h *rpcHandler) removeCar(ctx context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	j, err := h.startRemoveCar(params)
+	if err != nil {
+		return nil, err
+	}
+	// Wait for the removal to complete, cancelling it if the request is cancelled.
+	advID, err := j.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoveCarRes{AdvId: advID}, nil
+}
+
+func (h *rpcHandler) removeCarAsync(_ context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	j, err := h.startRemoveCar(params)
+	if err != nil {
+		return nil, err
+	}
+	res := j.info()
+	return &res, nil
+}
+
+func (h *rpcHandler) updateMetadata(ctx context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	var req UpdateMetadataReq
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Key) == 0 {
+		return nil, &RPCError{RPCCodeInvalidParams, "key must be specified"}
+	}
+	update, err := newMetadataUpdate(&req)
+	if err != nil {
+		return nil, &RPCError{RPCCodeInvalidParams, err.Error()}
+	}
+	// Share the lock of the REST endpoint, so that updates via either are serialized.
+	h.cars.updateLk.Lock()
+	defer h.cars.updateLk.Unlock()
+	md, err := update.apply(ctx, h.s.e, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if err := md.ValidateKnown(); err != nil {
+		return nil, &RPCError{RPCCodeInvalidParams, fmt.Sprintf("invalid metadata: %v", err)}
+	}
+	advID, err := h.cs.UpdateMetadata(ctx, req.Key, md)
+	if err != nil {
+		if err == provider.ErrAlreadyAdvertised {
+			return nil, &RPCError{RPCCodeConflict, "CAR already advertised with the given metadata"}
+		}
+		return nil, err
+	}
+	return &UpdateMetadataRes{req.Key, advID}, nil
+}
+
+func (h *rpcHandler) listCars(ctx context.Context, _ json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	paths, err := h.cs.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CARs: %w", err)
+	}
+	return &ListCarRes{Paths: paths}, nil
+}
+
+func (h *rpcHandler) listJobs(context.Context, json.RawMessage, rpcNotifyFunc) (interface{}, error) {
+	return &ListJobsRes{Jobs: h.s.jobs.list()}, nil
+}
+
+func (h *rpcHandler) lookupJob(params json.RawMessage) (*job, error) {
+	var req JobReq
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	j := h.s.jobs.get(req.ID)
+	if j == nil {
+		return nil, &RPCError{RPCCodeNotFound, fmt.Sprintf("no job found with ID %s", req.ID)}
+	}
+	return j, nil
+}
+
+func (h *rpcHandler) getJob(_ context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	j, err := h.lookupJob(params)
+	if err != nil {
+		return nil, err
+	}
+	res := j.info()
+	return &res, nil
+}
+
+func (h *rpcHandler) cancelJob(_ context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	j, err := h.lookupJob(params)
+	if err != nil {
+		return nil, err
+	}
+	log.Infow("cancelling job", "job", j.info().ID)
+	j.cancel()
+	res := j.info()
+	return &res, nil
+}
+
+func (h *rpcHandler) findContextIDs(ctx context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	var req FindContextIDsReq
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if _, err := multihash.Decode(req.Multihash); err != nil {
+		return nil, &RPCError{RPCCodeInvalidParams, fmt.Sprintf("invalid multihash: %v", err)}
+	}
+	contextIDs, err := h.s.e.FindContextIDs(ctx, req.Multihash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find context IDs: %w", err)
+	}
+	res := &FindContextIDsRes{ContextIDs: [][]byte{}}
+	res.ContextIDs = append(res.ContextIDs, contextIDs...)
+	return res, nil
+}
+
+func (h *rpcHandler) rebuildReverseIndex(ctx context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	var req RebuildReverseIndexReq
+	if len(params) != 0 {
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+	}
+	j, err := h.s.jobs.start(JobKindRebuildReverseIndex, nil, "", func(ctx context.Context) (cid.Cid, error) {
+		return cid.Undef, h.s.e.RebuildReverseIndex(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start rebuild job: %w", err)
+	}
+	if !req.Async {
+		if _, err := j.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	res := j.info()
+	return &res, nil
+}
+
+func (h *rpcHandler) drain(_ context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	var req DrainReq
+	if len(params) != 0 {
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+	}
+	batchSize := req.BatchSize
+	if batchSize == 0 {
+		batchSize = engine.DefaultDrainBatchSize
+	}
+	if batchSize < 1 || batchSize > maxDrainBatchSize {
+		return nil, &RPCError{RPCCodeInvalidParams, fmt.Sprintf("batch size must be between 1 and %d", maxDrainBatchSize)}
+	}
+	j, err := h.drains.start(batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start drain job: %w", err)
+	}
+	// Draining is not awaited, since it may take longer than the server write timeout.
+	res := j.info()
+	return &res, nil
+}
+
+func (h *rpcHandler) listRetrievalStats(ctx context.Context, _ json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	return h.stats.list(ctx)
+}
+
+func (h *rpcHandler) getRetrievalStats(ctx context.Context, params json.RawMessage, _ rpcNotifyFunc) (interface{}, error) {
+	var req RetrievalStatsReq
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if len(req.ContextID) == 0 {
+		return nil, &RPCError{RPCCodeInvalidParams, "context ID must be specified"}
+	}
+	return h.stats.get(ctx, req.ContextID)
+}
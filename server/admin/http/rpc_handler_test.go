@@ -0,0 +1,61 @@
+package adminserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_rpcHandler_Errors(t *testing.T) {
+	roToken := "read-only-token"
+	subject := startTestServer(t, WithBearerToken("read-write-token", ScopeReadWrite), WithBearerToken(roToken, ScopeReadOnly))
+	url := "http://" + subject.Addr().String() + "/admin/rpc"
+
+	call := func(t *testing.T, body string) *RPCMessage {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+roToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var msg RPCMessage
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&msg))
+		require.Equal(t, RPCVersion, msg.JsonRPC)
+		return &msg
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"malformed", `{"jsonrpc":`, RPCCodeParseError},
+		{"wrong version", `{"jsonrpc":"1.0","id":1,"method":"Admin.ListCars"}`, RPCCodeInvalidRequest},
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"Admin.Fish"}`, RPCCodeMethodNotFound},
+		{"invalid params", `{"jsonrpc":"2.0","id":1,"method":"Admin.GetJob","params":"fish"}`, RPCCodeInvalidParams},
+		{"unknown job", `{"jsonrpc":"2.0","id":1,"method":"Admin.GetJob","params":{"id":"fish"}}`, RPCCodeNotFound},
+		{"insufficient scope", `{"jsonrpc":"2.0","id":1,"method":"Admin.Announce"}`, RPCCodeForbidden},
+		{"retrieval stats disabled", `{"jsonrpc":"2.0","id":1,"method":"Admin.ListRetrievalStats"}`, RPCCodeConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := call(t, tt.body)
+			require.NotNil(t, msg.Error)
+			require.Equal(t, tt.wantCode, msg.Error.Code)
+			require.Empty(t, msg.Result)
+		})
+	}
+
+	t.Run("read-only method", func(t *testing.T) {
+		msg := call(t, `{"jsonrpc":"2.0","id":"abc","method":"Admin.ListJobs"}`)
+		require.Nil(t, msg.Error)
+		require.JSONEq(t, `"abc"`, string(msg.ID))
+		var res ListJobsRes
+		require.NoError(t, json.Unmarshal(msg.Result, &res))
+		require.Empty(t, res.Jobs)
+	})
+}
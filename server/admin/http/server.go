@@ -2,6 +2,7 @@ package adminserver
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 
@@ -19,6 +20,10 @@ type Server struct {
 	l      net.Listener
 	h      host.Host
 	e      *engine.Engine
+	jobs   *jobManager
+	tokens []bearerToken
+	// closing is closed on shutdown to end long-lived requests, i.e. event streams.
+	closing chan struct{}
 }
 
 func New(h host.Host, e *engine.Engine, cs *supplier.CarSupplier, o ...Option) (*Server, error) {
@@ -37,32 +42,103 @@ func New(h host.Host, e *engine.Engine, cs *supplier.CarSupplier, o ...Option) (
 		Handler:      r,
 		ReadTimeout:  opts.readTimeout,
 		WriteTimeout: opts.writeTimeout,
+		ConnContext:  withConn,
 	}
-	s := &Server{server, l, h, e}
+	if opts.tlsConfig != nil {
+		server.TLSConfig = opts.tlsConfig
+		l = tls.NewListener(l, opts.tlsConfig)
+	}
+	s := &Server{server, l, h, e, newJobManager(), opts.tokens, make(chan struct{})}
 
 	// Set protocol handlers
-	r.HandleFunc("/admin/announce", s.announceHandler).
+	r.HandleFunc("/admin/announce", s.authorize(ScopeReadWrite, s.announceHandler)).
 		Methods(http.MethodPost)
 
-	r.HandleFunc("/admin/connect", s.connectHandler).
+	r.HandleFunc("/admin/connect", s.authorize(ScopeReadWrite, s.connectHandler)).
 		Methods(http.MethodPost).
 		Headers("Content-Type", "application/json")
 
-	cHandler := &carHandler{cs}
-	r.HandleFunc("/admin/import/car", cHandler.handleImport).
+	cHandler := &carHandler{cs: cs, jobs: s.jobs, e: e}
+	r.HandleFunc("/admin/import/car", s.authorize(ScopeReadWrite, cHandler.handleImport)).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+
+	mHandler := &manifestHandler{cs, e}
+	r.HandleFunc("/admin/import/manifest", s.authorize(ScopeReadWrite, mHandler.handleImport)).
+		Methods(http.MethodPost)
+
+	r.HandleFunc("/admin/remove/car", s.authorize(ScopeReadWrite, cHandler.handleRemove)).
 		Methods(http.MethodPost).
 		Headers("Content-Type", "application/json")
 
-	r.HandleFunc("/admin/remove/car", cHandler.handleRemove).
+	r.HandleFunc("/admin/update/metadata", s.authorize(ScopeReadWrite, cHandler.handleUpdateMetadata)).
 		Methods(http.MethodPost).
 		Headers("Content-Type", "application/json")
 
-	r.HandleFunc("/admin/list/car", cHandler.handleList).
+	r.HandleFunc("/admin/list/car", s.authorize(ScopeReadOnly, cHandler.handleList)).
+		Methods(http.MethodGet)
+
+	jHandler := &jobHandler{s.jobs}
+	r.HandleFunc("/admin/jobs", s.authorize(ScopeReadOnly, jHandler.handleList)).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/admin/jobs/{id}", s.authorize(ScopeReadOnly, jHandler.handleGet)).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/admin/jobs/{id}/cancel", s.authorize(ScopeReadWrite, jHandler.handleCancel)).
+		Methods(http.MethodPost)
+
+	aHandler := &adHandler{e}
+	r.HandleFunc("/admin/ads", s.authorize(ScopeReadOnly, aHandler.handleList)).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/admin/ads/head", s.authorize(ScopeReadOnly, aHandler.handleGetHead)).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/admin/ads/{cid}", s.authorize(ScopeReadOnly, aHandler.handleGet)).
+		Methods(http.MethodGet)
+
+	ctxHandler := &contextIDHandler{e}
+	r.HandleFunc("/admin/contextids", s.authorize(ScopeReadOnly, ctxHandler.handleList)).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/admin/contextids/{contextid}", s.authorize(ScopeReadOnly, ctxHandler.handleGet)).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/admin/multihashes/{multihash}/contextids", s.authorize(ScopeReadOnly, ctxHandler.handleFind)).
 		Methods(http.MethodGet)
 
+	riHandler := &reverseIndexHandler{e, s.jobs}
+	r.HandleFunc("/admin/reverseindex/rebuild", s.authorize(ScopeReadWrite, riHandler.handleRebuild)).
+		Methods(http.MethodPost)
+
+	dHandler := &drainHandler{e, cs, s.jobs}
+	r.HandleFunc("/admin/drain", s.authorize(ScopeReadWrite, dHandler.handleDrain)).
+		Methods(http.MethodPost)
+
+	eHandler := &eventsHandler{e: e, closing: s.closing, writeTimeout: opts.writeTimeout}
+	r.HandleFunc("/admin/events", s.authorize(ScopeReadOnly, eHandler.handleStream)).
+		Methods(http.MethodGet)
+
+	rsHandler := &retrievalStatsHandler{opts.stats}
+	r.HandleFunc("/admin/stats/retrievals", s.authorize(ScopeReadOnly, rsHandler.handleList)).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/admin/stats/retrievals/{contextid}", s.authorize(ScopeReadOnly, rsHandler.handleGet)).
+		Methods(http.MethodGet)
+
+	rHandler := newRPCHandler(s, cs, cHandler, mHandler, dHandler, rsHandler)
+	r.HandleFunc("/admin/rpc", s.authorize(ScopeReadOnly, rHandler.handle)).
+		Methods(http.MethodPost)
+
 	return s, nil
 }
 
+// Addr returns the address on which the server listens.
+func (s *Server) Addr() net.Addr {
+	return s.l.Addr()
+}
+
 func (s *Server) Start() error {
 	log.Infow("admin http server listening", "addr", s.l.Addr())
 	return s.server.Serve(s.l)
@@ -70,5 +146,8 @@ func (s *Server) Start() error {
 
 func (s *Server) Shutdown(ctx context.Context) error {
 	log.Info("admin http server shutdown")
+	// Stop any jobs that are still running, which also unblocks requests waiting for them.
+	s.jobs.cancelAll()
+	close(s.closing)
 	return s.server.Shutdown(ctx)
 }
@@ -0,0 +1,112 @@
+package bitswapserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/filecoin-project/index-provider/supplier"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/multiformats/go-multihash"
+)
+
+var (
+	_ bstore.Blockstore = (*routingBlockstore)(nil)
+
+	errReadOnly = errors.New("blockstore is read-only")
+)
+
+// ContextIDFinder finds the context IDs of the advertised content that contains a multihash.
+// engine.Engine implements this interface.
+type ContextIDFinder interface {
+	FindContextIDs(ctx context.Context, mh multihash.Multihash) ([][]byte, error)
+}
+
+// routingBlockstore is a read-only blockstore that routes reads to the blockstores of the
+// advertised content that contains the requested block.
+type routingBlockstore struct {
+	finder ContextIDFinder
+	stores *openBlockstores
+}
+
+// withBlockstore calls fn with the blockstore of each advertised content that contains the given
+// CID, until fn returns true.
+func (rb *routingBlockstore) withBlockstore(ctx context.Context, c cid.Cid, fn func(bstore.Blockstore) (bool, error)) error {
+	contextIDs, err := rb.finder.FindContextIDs(ctx, c.Hash())
+	if err != nil {
+		return err
+	}
+	for _, contextID := range contextIDs {
+		obs, err := rb.stores.acquire(contextID)
+		if err != nil {
+			if errors.Is(err, supplier.ErrNotFound) {
+				// The content was removed since the context IDs were found.
+				continue
+			}
+			return err
+		}
+		done, err := fn(obs)
+		rb.stores.release(obs)
+		if err != nil || done {
+			return err
+		}
+	}
+	return bstore.ErrNotFound
+}
+
+func (rb *routingBlockstore) Has(ctx context.Context, c cid.Cid) (bool, error) {
+	err := rb.withBlockstore(ctx, c, func(bs bstore.Blockstore) (bool, error) {
+		return bs.Has(ctx, c)
+	})
+	if errors.Is(err, bstore.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (rb *routingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	var blk blocks.Block
+	err := rb.withBlockstore(ctx, c, func(bs bstore.Blockstore) (bool, error) {
+		var err error
+		blk, err = bs.Get(ctx, c)
+		if errors.Is(err, bstore.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	return blk, err
+}
+
+func (rb *routingBlockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	var size int
+	err := rb.withBlockstore(ctx, c, func(bs bstore.Blockstore) (bool, error) {
+		var err error
+		size, err = bs.GetSize(ctx, c)
+		if errors.Is(err, bstore.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	return size, err
+}
+
+func (rb *routingBlockstore) DeleteBlock(context.Context, cid.Cid) error {
+	return errReadOnly
+}
+
+func (rb *routingBlockstore) Put(context.Context, blocks.Block) error {
+	return errReadOnly
+}
+
+func (rb *routingBlockstore) PutMany(context.Context, []blocks.Block) error {
+	return errReadOnly
+}
+
+// AllKeysChan is not supported, since listing all the blocks of all advertised content is
+// prohibitively expensive.
+func (rb *routingBlockstore) AllKeysChan(context.Context) (<-chan cid.Cid, error) {
+	return nil, errors.New("listing all keys is not supported")
+}
+
+func (rb *routingBlockstore) HashOnRead(bool) {}
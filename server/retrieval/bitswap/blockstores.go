@@ -0,0 +1,88 @@
+package bitswapserver
+
+import (
+	"sync"
+
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/golang/groupcache/lru"
+)
+
+// openBlockstores caches the blockstores opened by a BlockStoreSupplier, keyed by context ID.
+//
+// Blockstores are reference counted, so that a blockstore evicted from the cache is only closed
+// once it is no longer in use.
+type openBlockstores struct {
+	bss BlockStoreSupplier
+	lk  sync.Mutex
+	lru *lru.Cache
+}
+
+type openBlockstore struct {
+	supplier.ClosableBlockstore
+	contextID []byte
+	refs      int
+	evicted   bool
+}
+
+func newOpenBlockstores(bss BlockStoreSupplier, capacity int) *openBlockstores {
+	s := &openBlockstores{
+		bss: bss,
+		lru: lru.New(capacity),
+	}
+	s.lru.OnEvicted = func(_ lru.Key, v interface{}) {
+		obs := v.(*openBlockstore)
+		obs.evicted = true
+		if obs.refs == 0 {
+			obs.close()
+		}
+	}
+	return s
+}
+
+// acquire returns the open blockstore of the given context ID, opening it if necessary. The
+// returned blockstore must be released once no longer in use.
+func (s *openBlockstores) acquire(contextID []byte) (*openBlockstore, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if v, ok := s.lru.Get(string(contextID)); ok {
+		obs := v.(*openBlockstore)
+		obs.refs++
+		return obs, nil
+	}
+	bs, err := s.bss.ReadOnlyBlockstore(contextID)
+	if err != nil {
+		return nil, err
+	}
+	obs := &openBlockstore{ClosableBlockstore: bs, contextID: contextID, refs: 1}
+	s.lru.Add(string(contextID), obs)
+	return obs, nil
+}
+
+func (s *openBlockstores) release(obs *openBlockstore) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	obs.refs--
+	if obs.evicted && obs.refs == 0 {
+		obs.close()
+	}
+}
+
+// evict closes the open blockstore of the given context ID, if any, once no longer in use.
+func (s *openBlockstores) evict(contextID []byte) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.lru.Remove(string(contextID))
+}
+
+// evictAll closes all the open blockstores once no longer in use.
+func (s *openBlockstores) evictAll() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.lru.Clear()
+}
+
+func (obs *openBlockstore) close() {
+	if err := obs.Close(); err != nil {
+		log.Warnw("failed to close blockstore", "contextID", obs.contextID, "err", err)
+	}
+}
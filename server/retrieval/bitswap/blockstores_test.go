@@ -0,0 +1,84 @@
+package bitswapserver
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/ipfs/go-datastore"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/require"
+)
+
+type countingSupplier struct {
+	lk     sync.Mutex
+	opened map[string]int
+	closed map[string]int
+}
+
+type countingBlockstore struct {
+	bstore.Blockstore
+	contextID string
+	s         *countingSupplier
+}
+
+func (s *countingSupplier) ReadOnlyBlockstore(contextID []byte) (supplier.ClosableBlockstore, error) {
+	if string(contextID) == "missing" {
+		return nil, supplier.ErrNotFound
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.opened[string(contextID)]++
+	return &countingBlockstore{
+		Blockstore: bstore.NewBlockstore(datastore.NewMapDatastore()),
+		contextID:  string(contextID),
+		s:          s,
+	}, nil
+}
+
+func (b *countingBlockstore) Close() error {
+	b.s.lk.Lock()
+	defer b.s.lk.Unlock()
+	b.s.closed[b.contextID]++
+	return nil
+}
+
+func TestOpenBlockstores(t *testing.T) {
+	bss := &countingSupplier{opened: map[string]int{}, closed: map[string]int{}}
+	subject := newOpenBlockstores(bss, 2)
+
+	_, err := subject.acquire([]byte("missing"))
+	require.Equal(t, supplier.ErrNotFound, err)
+
+	// Open blockstores are reused.
+	fish, err := subject.acquire([]byte("fish"))
+	require.NoError(t, err)
+	subject.release(fish)
+	fish, err = subject.acquire([]byte("fish"))
+	require.NoError(t, err)
+	require.Equal(t, 1, bss.opened["fish"])
+
+	// Evicted blockstores are closed once released.
+	lobster, err := subject.acquire([]byte("lobster"))
+	require.NoError(t, err)
+	subject.release(lobster)
+	crab, err := subject.acquire([]byte("crab"))
+	require.NoError(t, err)
+	subject.release(crab)
+	require.Equal(t, 0, bss.closed["fish"])
+	subject.release(fish)
+	require.Equal(t, 1, bss.closed["fish"])
+	require.Equal(t, 0, bss.closed["lobster"])
+
+	// Explicitly evicted blockstores are reopened on next use.
+	subject.evict([]byte("crab"))
+	require.Equal(t, 1, bss.closed["crab"])
+	crab, err = subject.acquire([]byte("crab"))
+	require.NoError(t, err)
+	subject.release(crab)
+	require.Equal(t, 2, bss.opened["crab"])
+
+	subject.evictAll()
+	require.Equal(t, 1, bss.closed["lobster"])
+	require.Equal(t, 2, bss.closed["crab"])
+}
@@ -0,0 +1,9 @@
+// Package bitswapserver provides a bitswap server that serves the blocks of the content
+// advertised by the provider.
+//
+// Blocks are looked up across all the content supplied by a BlockStoreSupplier, e.g. all CAR files
+// imported by supplier.CarSupplier. The context IDs of the content that contains a requested block
+// are found via the reverse index of the engine, which must therefore be enabled; otherwise any peer
+// could make the provider list the multihashes of all advertised content. See
+// engine.WithReverseIndex.
+package bitswapserver
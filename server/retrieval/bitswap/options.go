@@ -0,0 +1,39 @@
+package bitswapserver
+
+import "fmt"
+
+type (
+	// Option captures a configurable parameter in bitswap server.
+	Option func(*options) error
+
+	options struct {
+		maxOpenBlockstores int
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{
+		maxOpenBlockstores: 64,
+	}
+
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// WithMaxOpenBlockstores sets the maximum number of blockstores that are kept open in order to
+// serve subsequent requests for blocks of the same content. The least recently used blockstore is
+// closed once the limit is reached.
+// If unset, up to 64 blockstores are kept open.
+func WithMaxOpenBlockstores(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return fmt.Errorf("max open blockstores must be at least 1; got %d", n)
+		}
+		o.maxOpenBlockstores = n
+		return nil
+	}
+}
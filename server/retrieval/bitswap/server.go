@@ -0,0 +1,124 @@
+package bitswapserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/ipfs/go-bitswap"
+	bsnet "github.com/ipfs/go-bitswap/network"
+	"github.com/ipfs/go-cid"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+var log = logging.Logger("bitswapserver")
+
+type (
+	// BlockStoreSupplier supplies the blocks of the content associated to a context ID.
+	// supplier.CarSupplier implements this interface.
+	BlockStoreSupplier interface {
+		ReadOnlyBlockstore(contextID []byte) (supplier.ClosableBlockstore, error)
+	}
+
+	// Server serves the blocks of advertised content over bitswap.
+	Server struct {
+		h      host.Host
+		e      *engine.Engine
+		stores *openBlockstores
+		bs     *routingBlockstore
+
+		bswap      exchange.Interface
+		cancel     context.CancelFunc
+		eventsDone chan struct{}
+	}
+)
+
+// New instantiates a new Server that serves the content supplied by the given BlockStoreSupplier
+// to peers of the given host. The context IDs of the content that contains requested blocks are
+// found via the given engine.
+func New(h host.Host, e *engine.Engine, bss BlockStoreSupplier, o ...Option) (*Server, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	// Without the reverse index, every block requested by any peer would list the multihashes of
+	// all advertised content.
+	if !e.ReverseIndexEnabled() {
+		return nil, fmt.Errorf("bitswap server requires the engine reverse index: %w", engine.ErrReverseIndexDisabled)
+	}
+	stores := newOpenBlockstores(bss, opts.maxOpenBlockstores)
+	return &Server{
+		h:      h,
+		e:      e,
+		stores: stores,
+		bs:     &routingBlockstore{finder: e, stores: stores},
+	}, nil
+}
+
+// Start starts serving blocks over bitswap.
+func (s *Server) Start(ctx context.Context) error {
+	if s.bswap != nil {
+		return errors.New("already started")
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	// Close the blockstores of content that is removed or updated, since the content may no
+	// longer exist or may have moved.
+	events, cancelSub := s.e.Subscribe()
+	s.eventsDone = make(chan struct{})
+	go func() {
+		defer close(s.eventsDone)
+		defer cancelSub()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				if published, ok := ev.(engine.AdPublished); ok {
+					s.stores.evict(published.ContextID)
+				}
+			}
+		}
+	}()
+
+	// Blocks are never fetched from other peers; routing is therefore never used.
+	network := bsnet.NewFromIpfsHost(s.h, nullRouting{})
+	s.bswap = bitswap.New(ctx, network, s.bs, bitswap.ProvideEnabled(false))
+	log.Infow("bitswap server started", "peerID", s.h.ID())
+	return nil
+}
+
+// Shutdown stops serving blocks and closes all open blockstores.
+func (s *Server) Shutdown() error {
+	log.Info("bitswap server shutdown")
+	if s.bswap == nil {
+		return nil
+	}
+	err := s.bswap.Close()
+	s.cancel()
+	<-s.eventsDone
+	s.stores.evictAll()
+	s.bswap = nil
+	return err
+}
+
+// nullRouting is a routing.ContentRouting that neither provides nor finds content.
+type nullRouting struct{}
+
+func (nullRouting) Provide(context.Context, cid.Cid, bool) error {
+	return nil
+}
+
+func (nullRouting) FindProvidersAsync(context.Context, cid.Cid, int) <-chan peer.AddrInfo {
+	ch := make(chan peer.AddrInfo)
+	close(ch)
+	return ch
+}
@@ -0,0 +1,88 @@
+package bitswapserver
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-bitswap"
+	bsnet "github.com/ipfs/go-bitswap/network"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ServesBlocksOfImportedCars(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	t.Cleanup(cancel)
+
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	eng, err := engine.New(engine.WithHost(h), engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	cs := supplier.NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	testdata := filepath.Join(testutil.ThisDir(t), "../../../testdata")
+	md := metadata.New(metadata.Bitswap{})
+	_, err = cs.Put(ctx, []byte("fish"), filepath.Join(testdata, "sample-v1.car"), md)
+	require.NoError(t, err)
+	_, err = cs.Put(ctx, []byte("lobster"), filepath.Join(testdata, "sample-v1-2.car"), md)
+	require.NoError(t, err)
+
+	subject, err := New(h, eng, cs)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, subject.Shutdown()) })
+
+	// Fetch a block of each CAR from a bitswap client connected to the server.
+	clientHost, err := libp2p.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { clientHost.Close() })
+	clientBs := bstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	client := bitswap.New(ctx, bsnet.NewFromIpfsHost(clientHost, nullRouting{}), clientBs, bitswap.ProvideEnabled(false))
+	t.Cleanup(func() { client.Close() })
+	// Connect once the client is started, so that it sends its wants to the server.
+	require.NoError(t, clientHost.Connect(ctx, peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()}))
+
+	for _, name := range []string{"sample-v1.car", "sample-v1-2.car"} {
+		carBs := testutil.OpenSampleCar(t, name)
+		roots, err := carBs.Roots()
+		require.NoError(t, err)
+		want, err := carBs.Get(ctx, roots[0])
+		require.NoError(t, err)
+
+		got, err := client.GetBlock(ctx, roots[0])
+		require.NoError(t, err)
+		require.Equal(t, want.RawData(), got.RawData())
+	}
+
+	// Blocks of removed CARs are no longer served.
+	carBs := testutil.OpenSampleCar(t, "sample-v1.car")
+	roots, err := carBs.Roots()
+	require.NoError(t, err)
+	_, err = cs.Remove(ctx, []byte("fish"))
+	require.NoError(t, err)
+	has, err := subject.bs.Has(ctx, roots[0])
+	require.NoError(t, err)
+	require.False(t, has)
+	_, err = subject.bs.Get(ctx, roots[0])
+	require.Equal(t, bstore.ErrNotFound, err)
+}
+
+func TestNew_RequiresReverseIndex(t *testing.T) {
+	eng, err := engine.New()
+	require.NoError(t, err)
+	_, err = New(nil, eng, nil)
+	require.ErrorIs(t, err, engine.ErrReverseIndexDisabled)
+}
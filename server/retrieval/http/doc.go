@@ -0,0 +1,10 @@
+// Package retrievalserver provides a HTTP server that serves the content advertised by the
+// provider following the IPFS trustless gateway specification.
+//
+// Blocks are served at /ipfs/{cid} as raw blocks or as CARv1 files containing the entire DAG
+// rooted at the CID, depending on the requested format. The format is specified either via the
+// format query parameter, i.e. "raw" or "car", or via the Accept header, i.e.
+// "application/vnd.ipld.raw" or "application/vnd.ipld.car".
+//
+// See: https://specs.ipfs.tech/http-gateways/trustless-gateway/
+package retrievalserver
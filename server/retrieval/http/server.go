@@ -0,0 +1,240 @@
+package retrievalserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/gorilla/mux"
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipld/go-car/v2"
+	_ "github.com/ipld/go-codec-dagpb"
+	"github.com/ipld/go-ipld-prime"
+	_ "github.com/ipld/go-ipld-prime/codec/raw"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	"github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("retrievalserver")
+
+const (
+	mimeTypeRaw = "application/vnd.ipld.raw"
+	mimeTypeCar = "application/vnd.ipld.car"
+)
+
+type (
+	// ContextIDFinder finds the context IDs of the advertised content that contains a multihash.
+	// engine.Engine implements this interface.
+	ContextIDFinder interface {
+		FindContextIDs(ctx context.Context, mh multihash.Multihash) ([][]byte, error)
+	}
+
+	// reverseIndexer is implemented by finders that may find context IDs without an index, such as
+	// engine.Engine, in which case they report whether their index is enabled.
+	reverseIndexer interface {
+		ReverseIndexEnabled() bool
+	}
+
+	// BlockStoreSupplier supplies the blocks of the content associated to a context ID.
+	// supplier.CarSupplier implements this interface.
+	BlockStoreSupplier interface {
+		ReadOnlyBlockstore(contextID []byte) (supplier.ClosableBlockstore, error)
+	}
+
+	// Server serves advertised content over HTTP.
+	Server struct {
+		server *http.Server
+		l      net.Listener
+		finder ContextIDFinder
+		bss    BlockStoreSupplier
+	}
+)
+
+// New instantiates a new Server that serves the content supplied by the given BlockStoreSupplier,
+// looking up the context ID of requested CIDs via the given ContextIDFinder. When the finder is an
+// engine.Engine, its reverse index must be enabled, since requests are not authenticated and
+// finding context IDs otherwise lists the multihashes of all advertised content.
+func New(finder ContextIDFinder, bss BlockStoreSupplier, o ...Option) (*Server, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	if ri, ok := finder.(reverseIndexer); ok && !ri.ReverseIndexEnabled() {
+		return nil, errReverseIndexDisabled
+	}
+
+	l, err := net.Listen("tcp", opts.listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := mux.NewRouter().StrictSlash(true)
+	server := &http.Server{
+		Handler:      r,
+		ReadTimeout:  opts.readTimeout,
+		WriteTimeout: opts.writeTimeout,
+	}
+	s := &Server{server, l, finder, bss}
+
+	r.HandleFunc("/ipfs/{cid}", s.handleGet).
+		Methods(http.MethodGet, http.MethodHead)
+	return s, nil
+}
+
+// Addr returns the address on which the server listens.
+func (s *Server) Addr() net.Addr {
+	return s.l.Addr()
+}
+
+func (s *Server) Start() error {
+	log.Infow("http retrieval server listening", "addr", s.l.Addr())
+	return s.server.Serve(s.l)
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Info("http retrieval server shutdown")
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	c, err := cid.Decode(mux.Vars(r)["cid"])
+	if err != nil {
+		http.Error(w, "invalid cid: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := responseFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	bs, err := s.openBlockstore(r.Context(), c)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Errorw("failed to find blockstore", "cid", c, "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	defer bs.Close()
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	w.Header().Set("Etag", fmt.Sprintf(`"%s.%s"`, c, format))
+	switch format {
+	case "raw":
+		s.serveRaw(w, r, bs, c)
+	case "car":
+		s.serveCar(w, r, bs, c)
+	}
+}
+
+var (
+	errNotFound             = errors.New("content not found")
+	errReverseIndexDisabled = errors.New("http retrieval server requires the reverse index of the context ID finder")
+)
+
+// openBlockstore opens the blockstore of the first advertised content that contains the given
+// CID.
+func (s *Server) openBlockstore(ctx context.Context, c cid.Cid) (supplier.ClosableBlockstore, error) {
+	contextIDs, err := s.finder.FindContextIDs(ctx, c.Hash())
+	if err != nil {
+		return nil, err
+	}
+	for _, contextID := range contextIDs {
+		bs, err := s.bss.ReadOnlyBlockstore(contextID)
+		if err != nil {
+			if errors.Is(err, supplier.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		has, err := bs.Has(ctx, c)
+		if err != nil || !has {
+			bs.Close()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return bs, nil
+	}
+	return nil, fmt.Errorf("%w: %s", errNotFound, c)
+}
+
+func (s *Server) serveRaw(w http.ResponseWriter, r *http.Request, bs bstore.Blockstore, c cid.Cid) {
+	blk, err := bs.Get(r.Context(), c)
+	if err != nil {
+		log.Errorw("failed to get block", "cid", c, "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", mimeTypeRaw)
+	w.Header().Set("Content-Length", fmt.Sprint(len(blk.RawData())))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(blk.RawData()); err != nil {
+		log.Debugw("failed to write block", "cid", c, "err", err)
+	}
+}
+
+// serveCar writes a CARv1 containing the DAG rooted at the given CID. Because the response is
+// streamed, failures that occur once the response has started are only logged; the client
+// observes a truncated CAR.
+func (s *Server) serveCar(w http.ResponseWriter, r *http.Request, bs bstore.Blockstore, c cid.Cid) {
+	w.Header().Set("Content-Type", mimeTypeCar+"; version=1")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	ls := cidlink.DefaultLinkSystem()
+	ls.StorageReadOpener = func(lctx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		blk, err := bs.Get(lctx.Ctx, lnk.(cidlink.Link).Cid)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(blk.RawData()), nil
+	}
+	if _, err := car.TraverseV1(r.Context(), &ls, c, selectorparse.CommonSelector_ExploreAllRecursively, w); err != nil {
+		log.Errorw("failed to write CAR", "cid", c, "err", err)
+	}
+}
+
+// responseFormat determines the requested response format, i.e. "raw" or "car", from the format
+// query parameter or otherwise the Accept header.
+func responseFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "raw", "car":
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case mimeTypeRaw:
+			return "raw", nil
+		case mimeTypeCar:
+			return "car", nil
+		}
+	}
+	return "", fmt.Errorf("either %s or %s response must be requested", mimeTypeRaw, mimeTypeCar)
+}
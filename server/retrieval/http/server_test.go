@@ -0,0 +1,112 @@
+package retrievalserver_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	retrievalserver "github.com/filecoin-project/index-provider/server/retrieval/http"
+	"github.com/filecoin-project/index-provider/supplier"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-car/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	eng, err := engine.New(engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	cs := supplier.NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	carPath := filepath.Join(testutil.ThisDir(t), "../../../testdata/sample-v1.car")
+	_, err = cs.Put(ctx, []byte("fish"), carPath, metadata.New(metadata.IpfsGatewayHttp{}))
+	require.NoError(t, err)
+	bs := testutil.OpenSampleCar(t, "sample-v1.car")
+	roots, err := bs.Roots()
+	require.NoError(t, err)
+	root := roots[0]
+
+	subject, err := retrievalserver.New(eng, cs, retrievalserver.WithListenAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+	go subject.Start()
+	t.Cleanup(func() { subject.Shutdown(ctx) })
+	baseURL := "http://" + subject.Addr().String()
+
+	get := func(path, accept string) *http.Response {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+		require.NoError(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// Raw blocks are served when requested via either the format or the Accept header.
+	wantBlk, err := bs.Get(ctx, root)
+	require.NoError(t, err)
+	for _, resp := range []*http.Response{
+		get("/ipfs/"+root.String()+"?format=raw", ""),
+		get("/ipfs/"+root.String(), "application/vnd.ipld.raw"),
+	} {
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/vnd.ipld.raw", resp.Header.Get("Content-Type"))
+		gotData, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, wantBlk.RawData(), gotData)
+	}
+
+	// CAR responses contain the DAG rooted at the requested CID.
+	resp := get("/ipfs/"+root.String(), "application/vnd.ipld.car")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/vnd.ipld.car; version=1", resp.Header.Get("Content-Type"))
+	br, err := car.NewBlockReader(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, []cid.Cid{root}, br.Roots)
+	var gotCount int
+	for {
+		blk, err := br.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if gotCount == 0 {
+			require.Equal(t, root, blk.Cid())
+		}
+		wantBlk, err := bs.Get(ctx, blk.Cid())
+		require.NoError(t, err)
+		require.Equal(t, wantBlk.RawData(), blk.RawData())
+		gotCount++
+	}
+	require.NotZero(t, gotCount)
+
+	// Unknown CIDs are not found, and invalid requests are rejected.
+	unknown := testutil.RandomCids(t, rand.New(rand.NewSource(1413)), 1)[0]
+	require.Equal(t, http.StatusNotFound, get("/ipfs/"+unknown.String()+"?format=raw", "").StatusCode)
+	require.Equal(t, http.StatusBadRequest, get("/ipfs/fish?format=raw", "").StatusCode)
+	require.Equal(t, http.StatusNotAcceptable, get("/ipfs/"+root.String(), "").StatusCode)
+	require.Equal(t, http.StatusNotAcceptable, get("/ipfs/"+root.String()+"?format=tar", "").StatusCode)
+}
+
+func TestNew_RequiresReverseIndex(t *testing.T) {
+	eng, err := engine.New()
+	require.NoError(t, err)
+	_, err = retrievalserver.New(eng, nil, retrievalserver.WithListenAddr("127.0.0.1:0"))
+	require.EqualError(t, err, "http retrieval server requires the reverse index of the context ID finder")
+}
@@ -0,0 +1,328 @@
+package supplier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	dagpb "github.com/ipld/go-codec-dagpb"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/raw"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/linking"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/ipld/go-ipld-prime/traversal"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	bsSupplierDatastorePrefix = "blockstore_supplier://"
+	bsDagDatastoreKeyPrefix   = bsSupplierDatastorePrefix + "dag/"
+)
+
+// dagMhIteratorBuffer is the number of multihashes a DAG traversal may visit ahead of the
+// iterator that lists them.
+const dagMhIteratorBuffer = 64
+
+var (
+	_ provider.MultihashIterator = (*dagMhIterator)(nil)
+
+	errDagMhIteratorStopped = errors.New("multihash iterator is no longer used")
+)
+
+type (
+	// BlockstoreSupplier supplies multihashes of DAGs stored in a blockstore to an implementation
+	// of provider.Interface via provider.MultihashLister. It allows the users to advertise addition
+	// and removal of DAGs by simply calling BlockstoreSupplier.Put and BlockstoreSupplier.Remove.
+	//
+	// Each context ID maps to the root CID of a DAG, and the multihashes are listed by traversing
+	// the DAG from its root using a selector. The DAGs may be stored in any blockstore, such as
+	// the flatfs-backed blockstore of an IPFS repo.
+	//
+	// See: NewBlockstoreSupplier, BlockstoreSupplier.Put, BlockstoreSupplier.Remove.
+	BlockstoreSupplier struct {
+		eng provider.Interface
+		ds  datastore.Datastore
+		bs  bstore.Blockstore
+	}
+
+	// dagEntry is the persisted DAG that corresponds to a context ID.
+	dagEntry struct {
+		Root cid.Cid `json:"root"`
+		// Selector is the DAG-CBOR encoded selector used to traverse the DAG.
+		Selector []byte `json:"selector,omitempty"`
+	}
+
+	// nopCloserBlockstore wraps a shared blockstore so that closing it has no effect.
+	nopCloserBlockstore struct {
+		bstore.Blockstore
+	}
+
+	// dagMhIterator iterates over the multihashes of a DAG as they are visited by its traversal,
+	// which runs in the background. The traversal stops once the iterator is exhausted, the context
+	// of the traversal is done, or the iterator is garbage collected.
+	dagMhIterator struct {
+		results <-chan dagMhResult
+		stop    chan struct{}
+	}
+
+	dagMhResult struct {
+		mh  multihash.Multihash
+		err error
+	}
+)
+
+// NewBlockstoreSupplier instantiates a new BlockstoreSupplier that supplies DAGs stored in the
+// given blockstore, and registers it as the provider.MultihashLister of the given
+// provider.Interface. The given datastore is used to persist the mapping of context IDs to DAGs.
+func NewBlockstoreSupplier(eng provider.Interface, ds datastore.Datastore, bs bstore.Blockstore) *BlockstoreSupplier {
+	bss := &BlockstoreSupplier{
+		eng: eng,
+		ds:  ds,
+		bs:  bs,
+	}
+	eng.RegisterMultihashLister(bss.ListMultihashes)
+	return bss
+}
+
+// Put makes the DAG with the given root, and identified by the given context ID, suppliable by
+// this supplier. The multihashes of the DAG are listed by traversing it from the root with the
+// given selector. If the selector is nil, the entire DAG is traversed.
+//
+// Note that blocks of the DAG must be present in the blockstore when the advertisement is
+// generated.
+func (bss *BlockstoreSupplier) Put(ctx context.Context, contextID []byte, root cid.Cid, sel ipld.Node, md metadata.Metadata) (cid.Cid, error) {
+	if !root.Defined() {
+		return cid.Undef, fmt.Errorf("root CID must be defined")
+	}
+	entry := dagEntry{Root: root}
+	if sel != nil {
+		// Check that the selector is valid before persisting it.
+		if _, err := selector.CompileSelector(sel); err != nil {
+			return cid.Undef, fmt.Errorf("invalid selector: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := dagcbor.Encode(sel, &buf); err != nil {
+			return cid.Undef, err
+		}
+		entry.Selector = buf.Bytes()
+	}
+	b, err := json.Marshal(&entry)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err := bss.ds.Put(ctx, toDagKey(contextID), b); err != nil {
+		return cid.Undef, err
+	}
+	return bss.eng.NotifyPut(ctx, contextID, md)
+}
+
+// Remove removes the DAG identified by the given context ID from the list of suppliable DAGs.
+// ErrNotFound is returned if no DAG is known for the given context ID.
+func (bss *BlockstoreSupplier) Remove(ctx context.Context, contextID []byte) (cid.Cid, error) {
+	key := toDagKey(contextID)
+	has, err := bss.ds.Has(ctx, key)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if !has {
+		return cid.Undef, ErrNotFound
+	}
+	if err := bss.ds.Delete(ctx, key); err != nil {
+		return cid.Undef, err
+	}
+	return bss.eng.NotifyRemove(ctx, contextID)
+}
+
+// List lists the root CIDs of DAGs that are supplied by this supplier.
+//
+// See: BlockstoreSupplier.Put
+func (bss *BlockstoreSupplier) List(ctx context.Context) ([]cid.Cid, error) {
+	results, err := bss.ds.Query(ctx, query.Query{Prefix: bsDagDatastoreKeyPrefix})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var roots []cid.Cid
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var entry dagEntry
+		if err := json.Unmarshal(r.Value, &entry); err != nil {
+			return nil, err
+		}
+		roots = append(roots, entry.Root)
+	}
+	return roots, nil
+}
+
+// ListMultihashes supplies an iterator over multihashes of the DAG that corresponds to the given
+// context ID, in the order in which they are visited by the DAG traversal. The DAG is traversed as
+// the iterator is consumed, so that its multihashes are never all held in memory. Blocks that are
+// visited more than once are only listed once.
+//
+// An error is returned if no DAG is found for the context ID, or if the root of the DAG is missing
+// from the blockstore. Errors that occur later during traversal, e.g. due to missing blocks, are
+// returned by the iterator. Callers that stop iterating early should cancel the given context in
+// order to stop the traversal promptly.
+func (bss *BlockstoreSupplier) ListMultihashes(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+	entry, err := bss.getDag(ctx, contextID)
+	if err != nil {
+		return nil, err
+	}
+
+	sel := selectorparse.CommonSelector_ExploreAllRecursively
+	if len(entry.Selector) != 0 {
+		nb := basicnode.Prototype.Any.NewBuilder()
+		if err := dagcbor.Decode(nb, bytes.NewReader(entry.Selector)); err != nil {
+			return nil, fmt.Errorf("failed to decode selector: %w", err)
+		}
+		sel = nb.Build()
+	}
+	compiled, err := selector.CompileSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(chan dagMhResult, dagMhIteratorBuffer)
+	stop := make(chan struct{})
+	send := func(r dagMhResult) error {
+		select {
+		case results <- r:
+			return nil
+		case <-stop:
+			return errDagMhIteratorStopped
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// Only the multihashes of visited blocks are kept in order to list each block once.
+	seen := make(map[string]struct{})
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageReadOpener = func(lctx linking.LinkContext, lnk datamodel.Link) (io.Reader, error) {
+		c := lnk.(cidlink.Link).Cid
+		blk, err := bss.bs.Get(lctx.Ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[string(c.Hash())]; !ok {
+			seen[string(c.Hash())] = struct{}{}
+			if err := send(dagMhResult{mh: c.Hash()}); err != nil {
+				return nil, err
+			}
+		}
+		return bytes.NewReader(blk.RawData()), nil
+	}
+	protoChooser := dagpb.AddSupportToChooser(func(datamodel.Link, linking.LinkContext) (datamodel.NodePrototype, error) {
+		return basicnode.Prototype.Any, nil
+	})
+
+	rootLnk := cidlink.Link{Cid: entry.Root}
+	proto, err := protoChooser(rootLnk, linking.LinkContext{Ctx: ctx})
+	if err != nil {
+		return nil, err
+	}
+	nd, err := lsys.Load(linking.LinkContext{Ctx: ctx}, rootLnk, proto)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load root of DAG: %w", err)
+	}
+
+	go func() {
+		defer close(results)
+		err := traversal.Progress{
+			Cfg: &traversal.Config{
+				Ctx:                            ctx,
+				LinkSystem:                     lsys,
+				LinkTargetNodePrototypeChooser: protoChooser,
+			},
+		}.WalkAdv(nd, compiled, func(traversal.Progress, datamodel.Node, traversal.VisitReason) error { return nil })
+		if err == nil || errors.Is(err, errDagMhIteratorStopped) {
+			return
+		}
+		// Deliver the error even if the context is done, so that the listing is never mistaken
+		// for a complete one.
+		select {
+		case results <- dagMhResult{err: fmt.Errorf("failed to traverse DAG: %w", err)}:
+		case <-stop:
+		}
+	}()
+
+	it := &dagMhIterator{results: results, stop: stop}
+	// Stop the traversal if the iterator is abandoned before it is exhausted. Note that the
+	// traversal must not reference the iterator itself for it to be garbage collected.
+	runtime.SetFinalizer(it, func(it *dagMhIterator) { close(it.stop) })
+	return it, nil
+}
+
+// ReadOnlyBlockstore returns the blockstore from which the DAG identified by the given context ID
+// can be read. Closing the returned blockstore has no effect on the underlying blockstore.
+//
+// This allows BlockstoreSupplier to be used with cardatatransfer in order to serve the DAGs it
+// supplies over graphsync.
+func (bss *BlockstoreSupplier) ReadOnlyBlockstore(contextID []byte) (ClosableBlockstore, error) {
+	if _, err := bss.getDag(context.TODO(), contextID); err != nil {
+		return nil, err
+	}
+	return nopCloserBlockstore{bss.bs}, nil
+}
+
+func (bss *BlockstoreSupplier) getDag(ctx context.Context, contextID []byte) (*dagEntry, error) {
+	b, err := bss.ds.Get(ctx, toDagKey(contextID))
+	if err != nil {
+		if err == datastore.ErrNotFound {
+			err = ErrNotFound
+		}
+		return nil, err
+	}
+	var entry dagEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func toDagKey(contextID []byte) datastore.Key {
+	return datastore.NewKey(bsDagDatastoreKeyPrefix + string(contextID))
+}
+
+// Close permanently closes this supplier.
+// After calling Close this supplier is no longer usable.
+//
+// Note that the blockstore from which DAGs are supplied is not closed.
+func (bss *BlockstoreSupplier) Close() error {
+	return bss.ds.Close()
+}
+
+func (nopCloserBlockstore) Close() error {
+	return nil
+}
+
+// Next returns the next multihash visited by the DAG traversal, or io.EOF once the traversal is
+// complete. If the traversal fails, its error is returned instead.
+func (d *dagMhIterator) Next() (multihash.Multihash, error) {
+	r, ok := <-d.results
+	if !ok {
+		return nil, io.EOF
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.mh, nil
+}
@@ -0,0 +1,149 @@
+package supplier
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	mock_provider "github.com/filecoin-project/index-provider/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipld/go-car/v2/blockstore"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockstoreSupplierListsDagMultihashes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+
+	carBs, err := blockstore.OpenReadOnly("../testdata/sample-v1-2.car", blockstore.UseWholeCIDs(true))
+	require.NoError(t, err)
+	t.Cleanup(func() { carBs.Close() })
+	roots, err := carBs.Roots()
+	require.NoError(t, err)
+	require.Len(t, roots, 1)
+
+	// Copy the CAR content into a non-CAR blockstore.
+	bs := bstore.NewBlockstore(datastore.NewMapDatastore())
+	keys, err := carBs.AllKeysChan(ctx)
+	require.NoError(t, err)
+	var wantCount int
+	for k := range keys {
+		blk, err := carBs.Get(ctx, k)
+		require.NoError(t, err)
+		require.NoError(t, bs.Put(ctx, blk))
+		wantCount++
+	}
+
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	ds := datastore.NewMapDatastore()
+	subject := NewBlockstoreSupplier(mockEng, ds, bs)
+	t.Cleanup(func() { require.NoError(t, subject.Close()) })
+
+	md := metadata.New(metadata.Bitswap{})
+	allCtxID := []byte("all")
+	rootOnlyCtxID := []byte("root-only")
+	mockEng.EXPECT().NotifyPut(ctx, allCtxID, md).Return(generateCidV1(t, rng), nil)
+	mockEng.EXPECT().NotifyPut(ctx, rootOnlyCtxID, md).Return(generateCidV1(t, rng), nil)
+
+	_, err = subject.Put(ctx, allCtxID, roots[0], nil, md)
+	require.NoError(t, err)
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
+	_, err = subject.Put(ctx, rootOnlyCtxID, roots[0], ssb.Matcher().Node(), md)
+	require.NoError(t, err)
+
+	gotRoots, err := subject.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, gotRoots, 2)
+
+	it, err := subject.ListMultihashes(ctx, allCtxID)
+	require.NoError(t, err)
+	seen := make(map[string]bool)
+	for {
+		mh, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.False(t, seen[string(mh)])
+		seen[string(mh)] = true
+		// The blockstore is keyed by multihash; the CID codec is irrelevant.
+		has, err := bs.Has(ctx, cid.NewCidV1(cid.Raw, mh))
+		require.NoError(t, err)
+		require.True(t, has)
+	}
+	require.Equal(t, wantCount, len(seen))
+
+	it, err = subject.ListMultihashes(ctx, rootOnlyCtxID)
+	require.NoError(t, err)
+	mh, err := it.Next()
+	require.NoError(t, err)
+	require.Equal(t, roots[0].Hash(), mh)
+	_, err = it.Next()
+	require.Equal(t, io.EOF, err)
+
+	rbs, err := subject.ReadOnlyBlockstore(allCtxID)
+	require.NoError(t, err)
+	require.NoError(t, rbs.Close())
+	has, err := bs.Has(ctx, roots[0])
+	require.NoError(t, err)
+	require.True(t, has)
+
+	mockEng.EXPECT().NotifyRemove(ctx, allCtxID).Return(generateCidV1(t, rng), nil)
+	_, err = subject.Remove(ctx, allCtxID)
+	require.NoError(t, err)
+	_, err = subject.Remove(ctx, allCtxID)
+	require.Equal(t, ErrNotFound, err)
+	_, err = subject.ListMultihashes(ctx, allCtxID)
+	require.Equal(t, ErrNotFound, err)
+	_, err = subject.ReadOnlyBlockstore(allCtxID)
+	require.Equal(t, ErrNotFound, err)
+}
+
+func TestBlockstoreSupplierIteratorFailsOnMissingBlocks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+
+	carBs, err := blockstore.OpenReadOnly("../testdata/sample-v1-2.car", blockstore.UseWholeCIDs(true))
+	require.NoError(t, err)
+	t.Cleanup(func() { carBs.Close() })
+	roots, err := carBs.Roots()
+	require.NoError(t, err)
+
+	// Copy the CAR content into a non-CAR blockstore, except for the blocks other than the root.
+	bs := bstore.NewBlockstore(datastore.NewMapDatastore())
+	blk, err := carBs.Get(ctx, roots[0])
+	require.NoError(t, err)
+	require.NoError(t, bs.Put(ctx, blk))
+
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	subject := NewBlockstoreSupplier(mockEng, datastore.NewMapDatastore(), bs)
+	t.Cleanup(func() { require.NoError(t, subject.Close()) })
+
+	md := metadata.New(metadata.Bitswap{})
+	contextID := []byte("fish")
+	mockEng.EXPECT().NotifyPut(ctx, contextID, md).Return(generateCidV1(t, rng), nil)
+	_, err = subject.Put(ctx, contextID, roots[0], nil, md)
+	require.NoError(t, err)
+
+	// The root is listed before the traversal fails on the first missing block.
+	it, err := subject.ListMultihashes(ctx, contextID)
+	require.NoError(t, err)
+	mh, err := it.Next()
+	require.NoError(t, err)
+	require.Equal(t, roots[0].Hash(), mh)
+	_, err = it.Next()
+	require.ErrorIs(t, err, bstore.ErrNotFound)
+}
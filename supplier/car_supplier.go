@@ -3,7 +3,10 @@ package supplier
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"os"
 	"path/filepath"
 
 	"github.com/filecoin-project/index-provider"
@@ -24,8 +27,15 @@ const (
 	carIdDatastoreKeyPrefix    = carSupplierDatastorePrefix + "car_id/"
 )
 
-// ErrNotFound signals that CidIteratorSupplier has no iterator corresponding to the given key.
-var ErrNotFound = errors.New("no CID iterator found for given key")
+var (
+	// ErrNotFound signals that CidIteratorSupplier has no iterator corresponding to the given key.
+	ErrNotFound = errors.New("no CID iterator found for given key")
+	// ErrInvalidCar signals that the CAR file given to CarSupplier.Put is malformed.
+	ErrInvalidCar = errors.New("invalid CAR")
+	// ErrEmptyCar signals that the CAR file given to CarSupplier.Put contains no blocks.
+	// Note that ErrEmptyCar is also an ErrInvalidCar.
+	ErrEmptyCar = fmt.Errorf("%w: CAR contains no blocks", ErrInvalidCar)
+)
 
 var log = logging.Logger("provider/carsupplier")
 
@@ -35,21 +45,45 @@ var log = logging.Logger("provider/carsupplier")
 //
 // CarSupplier accepts both CARv1 and CARv2, and will automatically generate an index if one is not
 // present or the index codec and characteristics are not sufficient for provider.Interface purposes.
+// Generated indexes may optionally be persisted in a sidecar directory to avoid rescanning the CAR
+// file on every lookup.
 //
-// See: engine.New, CarSupplier.Put, CarSupplier.Remove.
+// See: engine.New, CarSupplier.Put, CarSupplier.Remove, WithIndexCacheDir.
 type CarSupplier struct {
-	eng  provider.Interface
-	ds   datastore.Datastore
-	opts []car.ReadOption
+	eng        provider.Interface
+	ds         datastore.Datastore
+	opts       []car.ReadOption
+	idxDir     *indexCache
+	httpClient *http.Client
+	s3Endpoint string
+	pages      *pageCache
+	// validateHashes specifies whether to check the hash of every block when a CAR is put.
+	validateHashes bool
 }
 
-// NewCarSupplier instantiates a new CarSupplier and registers it as the provider.MultihashLister of the
-// given provider.Interface.
+// NewCarSupplier instantiates a new CarSupplier that reads CAR files with the given options, and
+// registers it as the provider.MultihashLister of the given provider.Interface.
+//
+// See: NewCarSupplierWithOptions.
 func NewCarSupplier(eng provider.Interface, ds datastore.Datastore, opts ...car.ReadOption) *CarSupplier {
+	return NewCarSupplierWithOptions(eng, ds, WithCarReadOptions(opts...))
+}
+
+// NewCarSupplierWithOptions instantiates a new CarSupplier with the given options, and registers it
+// as the provider.MultihashLister of the given provider.Interface.
+func NewCarSupplierWithOptions(eng provider.Interface, ds datastore.Datastore, o ...Option) *CarSupplier {
+	opts := newOptions(o...)
 	cs := &CarSupplier{
-		eng:  eng,
-		ds:   ds,
-		opts: opts,
+		eng:            eng,
+		ds:             ds,
+		opts:           opts.readOpts,
+		httpClient:     opts.httpClient,
+		s3Endpoint:     opts.s3Endpoint,
+		pages:          newPageCache(opts.remotePageSize, opts.remoteCachePages),
+		validateHashes: opts.validateHashes,
+	}
+	if opts.indexDir != "" {
+		cs.idxDir = &indexCache{dir: opts.indexDir}
 	}
 	eng.RegisterMultihashLister(cs.ListMultihashes)
 	return cs
@@ -59,21 +93,149 @@ func NewCarSupplier(eng provider.Interface, ds datastore.Datastore, opts ...car.
 // suppliable by this supplier. The return CID can then be used via Supply to
 // get an iterator over CIDs that belong to the CAR.
 //
-// This function accepts both CARv1 and CARv2 formats.
+// The path may either be a local file path or a remote URL with http, https or s3 scheme.
+// Remote CAR files are read using HTTP range requests, and should be in CARv2 format with an
+// index in order to avoid reading the entire file when listing its multihashes. CAR files with s3
+// scheme are read with unsigned requests, and so must be publicly readable unless a signing HTTP
+// client is set.
+// See: WithS3Endpoint, WithHttpClient.
+//
+// This function accepts both CARv1 and CARv2 formats. The CAR header, version and index, if any,
+// are validated before the CAR is advertised, and ErrInvalidCar is returned if the CAR is malformed.
+// ErrEmptyCar is returned if the CAR contains no blocks. The hash of every block is additionally
+// checked if block hash validation is enabled. See: WithBlockHashValidation.
+//
+// The mapping of context ID to path is left unchanged if the advertisement fails.
 func (cs *CarSupplier) Put(ctx context.Context, contextID []byte, path string, metadata metadata.Metadata) (cid.Cid, error) {
 	// Clean path to CAR.
-	path = filepath.Clean(path)
+	if !isRemote(path) {
+		path = filepath.Clean(path)
+	}
 
-	// Store mapping of CAR ID to path, used to instantiate CID iterator.
+	if err := cs.validateCar(ctx, path); err != nil {
+		return cid.Undef, err
+	}
+
+	// Remember any previous mapping in order to restore it if advertisement fails.
 	carIdKey := toCarIdKey(contextID)
-	err := cs.ds.Put(ctx, carIdKey, []byte(path))
+	prevPath, err := cs.ds.Get(ctx, carIdKey)
+	if err != nil && err != datastore.ErrNotFound {
+		return cid.Undef, err
+	}
+
+	// Store mapping of CAR ID to path, used to instantiate CID iterator.
+	err = cs.ds.Put(ctx, carIdKey, []byte(path))
 	if err != nil {
 		return cid.Undef, err
 	}
 
+	adCid, err := cs.eng.NotifyPut(ctx, contextID, metadata)
+	if err != nil {
+		var rbErr error
+		if prevPath != nil {
+			rbErr = cs.ds.Put(ctx, carIdKey, prevPath)
+		} else {
+			rbErr = cs.ds.Delete(ctx, carIdKey)
+		}
+		if rbErr != nil {
+			log.Errorw("Failed to roll back CAR path mapping", "err", rbErr, "contextID", contextID)
+		}
+		return cid.Undef, err
+	}
+	return adCid, nil
+}
+
+// UpdateMetadata publishes an advertisement that changes the metadata of the CAR previously put
+// with the given context ID, without changing its path or regenerating its entries.
+// ErrNotFound is returned if no CAR is known with the given context ID, and
+// provider.ErrAlreadyAdvertised if the CAR is already advertised with the given metadata.
+//
+// See: CarSupplier.Put
+func (cs *CarSupplier) UpdateMetadata(ctx context.Context, contextID []byte, metadata metadata.Metadata) (cid.Cid, error) {
+	has, err := cs.ds.Has(ctx, toCarIdKey(contextID))
+	if err != nil {
+		return cid.Undef, err
+	}
+	if !has {
+		return cid.Undef, ErrNotFound
+	}
 	return cs.eng.NotifyPut(ctx, contextID, metadata)
 }
 
+// validateCar checks that the CAR at the given path is well-formed and contains at least one
+// block.
+func (cs *CarSupplier) validateCar(ctx context.Context, path string) error {
+	var cr *car.Reader
+	if isRemote(path) {
+		rc, err := cs.openRemote(ctx, path)
+		if err != nil {
+			return err
+		}
+		if cr, err = car.NewReader(rc, cs.opts...); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidCar, err)
+		}
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if cr, err = car.NewReader(f, cs.opts...); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidCar, err)
+		}
+	}
+
+	// Check the index, if present, can be decoded.
+	if idxReader := cr.IndexReader(); idxReader != nil {
+		if _, err := index.ReadFrom(idxReader); err != nil {
+			return fmt.Errorf("%w: failed to read index: %v", ErrInvalidCar, err)
+		}
+	}
+
+	// Reading blocks also checks the CARv1 header and the hash of each block read.
+	br, err := car.NewBlockReader(cr.DataReader(), cs.opts...)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCar, err)
+	}
+	for count := 0; ; count++ {
+		if _, err := br.Next(); err != nil {
+			if err == io.EOF {
+				if count == 0 {
+					return ErrEmptyCar
+				}
+				return nil
+			}
+			return fmt.Errorf("%w: %v", ErrInvalidCar, err)
+		}
+		if !cs.validateHashes {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// ctxReadSeeker is an io.ReadSeeker that fails once its context is done.
+type ctxReadSeeker struct {
+	ctx context.Context
+	rs  io.ReadSeeker
+}
+
+func (r *ctxReadSeeker) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.rs.Read(p)
+}
+
+func (r *ctxReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.rs.Seek(offset, whence)
+}
+
 func toCarIdKey(contextID []byte) datastore.Key {
 	return datastore.NewKey(carIdDatastoreKeyPrefix + string(contextID))
 }
@@ -98,10 +260,39 @@ func (cs *CarSupplier) Remove(ctx context.Context, contextID []byte) (cid.Cid, e
 		// See what we can do to opportunistically heal the datastore.
 		return cid.Undef, err
 	}
+	if cs.idxDir != nil {
+		if err := cs.idxDir.remove(contextID); err != nil {
+			log.Warnw("Failed to remove cached index", "err", err)
+		}
+	}
 
 	return cs.eng.NotifyRemove(ctx, contextID)
 }
 
+// Forget deletes the mapping of the given context ID to the path of a CAR file, along with any
+// index cached for it, without publishing an advertisement. It is intended for context IDs whose
+// removal has already been advertised by other means, e.g. by draining the engine.
+// ErrNotFound is returned if no CAR is known with the given context ID.
+func (cs *CarSupplier) Forget(ctx context.Context, contextID []byte) error {
+	carIdKey := toCarIdKey(contextID)
+	has, err := cs.ds.Has(ctx, carIdKey)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return ErrNotFound
+	}
+	if err := cs.ds.Delete(ctx, carIdKey); err != nil {
+		return err
+	}
+	if cs.idxDir != nil {
+		if err := cs.idxDir.remove(contextID); err != nil {
+			log.Warnw("Failed to remove cached index", "err", err)
+		}
+	}
+	return nil
+}
+
 // List lists the CAR paths that are supplied by this supplier.
 //
 // See: CarSupplier.Put
@@ -132,6 +323,9 @@ func (cs *CarSupplier) ListMultihashes(ctx context.Context, contextID []byte) (p
 	if err != nil {
 		return nil, err
 	}
+	// The iterator holds the records of the index, which is closed once they are read in order to
+	// unmap any cached index.
+	defer closeIndex(idx)
 	return provider.CarMultihashIterator(idx)
 }
 
@@ -143,11 +337,60 @@ type ClosableBlockstore interface {
 
 // ReadOnlyBlockstore returns a CAR blockstore interface for the given blockstore key
 func (cs *CarSupplier) ReadOnlyBlockstore(contextID []byte) (ClosableBlockstore, error) {
-	path, err := cs.getPath(context.TODO(), contextID)
+	ctx := context.TODO()
+	path, err := cs.getPath(ctx, contextID)
+	if err != nil {
+		return nil, err
+	}
+	if !isRemote(path) {
+		return blockstore.OpenReadOnly(path, cs.opts...)
+	}
+	rc, err := cs.openRemote(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	// Reuse the iterable index to avoid regenerating it when the remote CAR has no index.
+	idx, err := cs.iterableIndex(ctx, contextID, path, rc)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := blockstore.NewReadOnly(rc, idx, cs.opts...)
+	if err != nil {
+		closeIndex(idx)
+		return nil, err
+	}
+	return &indexClosingBlockstore{ReadOnly: bs, idx: idx}, nil
+}
+
+// indexClosingBlockstore closes the index of a blockstore along with the blockstore, in order to
+// unmap any cached index.
+type indexClosingBlockstore struct {
+	*blockstore.ReadOnly
+	idx index.Index
+}
+
+func (b *indexClosingBlockstore) Close() error {
+	err := b.ReadOnly.Close()
+	closeIndex(b.idx)
+	return err
+}
+
+// closeIndex closes the given index if it is backed by resources that must be released, i.e. if it
+// is a memory-mapped cached index.
+func closeIndex(idx index.Index) {
+	if c, ok := idx.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Warnw("Failed to close index", "err", err)
+		}
+	}
+}
+
+func (cs *CarSupplier) openRemote(ctx context.Context, path string) (*remoteCar, error) {
+	url, err := toHttpURL(path, cs.s3Endpoint)
 	if err != nil {
 		return nil, err
 	}
-	return blockstore.OpenReadOnly(path, cs.opts...)
+	return openRemoteCar(ctx, cs.httpClient, url, cs.pages)
 }
 
 func (cs *CarSupplier) getPath(ctx context.Context, contextID []byte) (path string, err error) {
@@ -162,22 +405,68 @@ func (cs *CarSupplier) getPath(ctx context.Context, contextID []byte) (path stri
 }
 
 func (cs *CarSupplier) lookupIterableIndex(ctx context.Context, contextID []byte) (index.IterableIndex, error) {
-	log := log.With("contextID", contextID)
-
 	path, err := cs.getPath(ctx, contextID)
 	if err != nil {
 		return nil, err
 	}
+	var rc *remoteCar
+	if isRemote(path) {
+		if rc, err = cs.openRemote(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+	return cs.iterableIndex(ctx, contextID, path, rc)
+}
 
-	cr, err := car.OpenReader(path, cs.opts...)
+// iterableIndex returns the iterable index of the CAR at the given path, which is read from the
+// given remote CAR if it is remote, or nil otherwise.
+func (cs *CarSupplier) iterableIndex(ctx context.Context, contextID []byte, path string, rc *remoteCar) (index.IterableIndex, error) {
+	log := log.With("contextID", contextID)
+
+	// Look up previously generated index if index cache is enabled. Indexes of remote CARs without
+	// a version are not cached, since changes to such CARs cannot be detected.
+	var fp *fingerprint
+	if cs.idxDir != nil {
+		switch {
+		case rc == nil:
+			localFp, err := newFingerprint(path)
+			if err != nil {
+				return nil, err
+			}
+			fp = &localFp
+		case rc.version != "":
+			remoteFp := rc.fingerprint()
+			fp = &remoteFp
+		default:
+			log.Debugw("Remote CAR has no version; not caching its index.")
+		}
+	}
+	if fp != nil {
+		idx, err := cs.idxDir.get(contextID, *fp)
+		if err != nil {
+			log.Warnw("Failed to read cached index; ignoring cached index.", "err", err)
+		} else if idx != nil {
+			log.Debugw("Found cached index.")
+			return idx, nil
+		}
+	}
+
+	var cr *car.Reader
+	var err error
+	if rc != nil {
+		cr, err = car.NewReader(rc, cs.opts...)
+	} else {
+		cr, err = car.OpenReader(path, cs.opts...)
+	}
 	if err != nil {
 		return nil, err
 	}
+	defer cr.Close()
 	idxReader := cr.IndexReader()
 	if idxReader == nil {
 		// Missing index; generate it.
 		log.Debugw("CAR has no index; generating.")
-		return cs.generateIterableIndex(cr)
+		return cs.generateIterableIndex(ctx, contextID, fp, cr)
 	}
 	idx, err := index.ReadFrom(idxReader)
 	if err != nil {
@@ -187,7 +476,7 @@ func (cs *CarSupplier) lookupIterableIndex(ctx context.Context, contextID []byte
 	log = log.With("codec", codec)
 	if codec != multicodec.CarMultihashIndexSorted {
 		log.Debugw("CAR index not iterable; regenerating index.")
-		return cs.generateIterableIndex(cr)
+		return cs.generateIterableIndex(ctx, contextID, fp, cr)
 	}
 	itIdx, ok := idx.(index.IterableIndex)
 	if !ok {
@@ -196,16 +485,26 @@ func (cs *CarSupplier) lookupIterableIndex(ctx context.Context, contextID []byte
 		// Regardless, defensively check this and re-generate as needed in case go-car library
 		// changes this expectation.
 		log.Warnw("expected CAR index to implement index.IterableIndex interface; regenerating index.")
-		return cs.generateIterableIndex(cr)
+		return cs.generateIterableIndex(ctx, contextID, fp, cr)
 	}
 	return itIdx, nil
 }
 
-func (cs *CarSupplier) generateIterableIndex(cr *car.Reader) (index.IterableIndex, error) {
+// generateIterableIndex generates the iterable index of the given CAR, and caches it under the
+// given fingerprint unless it is nil.
+func (cs *CarSupplier) generateIterableIndex(ctx context.Context, contextID []byte, fp *fingerprint, cr *car.Reader) (index.IterableIndex, error) {
 	idx := index.NewMultihashSorted()
-	if err := car.LoadIndex(idx, cr.DataReader(), cs.opts...); err != nil {
+	// Stop reading the CAR as soon as the context is done, since generating the index of large CAR
+	// files may take a long time.
+	if err := car.LoadIndex(idx, &ctxReadSeeker{ctx, cr.DataReader()}, cs.opts...); err != nil {
 		return nil, err
 	}
+	if fp != nil {
+		// Failure to cache the index is not critical; the index is simply regenerated next time.
+		if err := cs.idxDir.put(contextID, *fp, idx); err != nil {
+			log.Warnw("Failed to cache generated index", "err", err, "contextID", contextID)
+		}
+	}
 	return idx, nil
 }
 
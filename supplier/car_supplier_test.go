@@ -3,19 +3,23 @@ package supplier
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
 
+	provider "github.com/filecoin-project/index-provider"
 	"github.com/filecoin-project/index-provider/metadata"
 	mock_provider "github.com/filecoin-project/index-provider/mock"
 	"github.com/golang/mock/gomock"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/blockstore"
 	"github.com/multiformats/go-multihash"
 	"github.com/stretchr/testify/require"
 )
@@ -193,3 +197,272 @@ func generateCidV1(t *testing.T, rng *rand.Rand) cid.Cid {
 	require.NoError(t, err)
 	return cid.NewCidV1(cid.Raw, mh)
 }
+
+func TestGeneratedIndexIsCached(t *testing.T) {
+	path := "../testdata/sample-v1.car"
+	rng := rand.New(rand.NewSource(1413))
+
+	ctx := context.Background()
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+	ds := datastore.NewMapDatastore()
+
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	idxDir := t.TempDir()
+	subject := NewCarSupplierWithOptions(mockEng, ds, WithIndexCacheDir(idxDir))
+	t.Cleanup(func() { require.NoError(t, subject.Close()) })
+
+	md := metadata.New(metadata.Bitswap{})
+	contextID := []byte("fish")
+	mockEng.
+		EXPECT().
+		NotifyPut(ctx, contextID, md).
+		Return(generateCidV1(t, rng), nil)
+	_, err := subject.Put(ctx, contextID, path, md)
+	require.NoError(t, err)
+
+	wantMhs := drainMultihashes(t, subject, contextID)
+	cached, err := os.ReadDir(idxDir)
+	require.NoError(t, err)
+	require.Len(t, cached, 1)
+
+	// Subsequent lookups are served from the cached index.
+	gotMhs := drainMultihashes(t, subject, contextID)
+	require.Equal(t, wantMhs, gotMhs)
+	cachedAgain, err := os.ReadDir(idxDir)
+	require.NoError(t, err)
+	require.Equal(t, cached[0].Name(), cachedAgain[0].Name())
+
+	mockEng.
+		EXPECT().
+		NotifyRemove(ctx, contextID).
+		Return(generateCidV1(t, rng), nil)
+	_, err = subject.Remove(ctx, contextID)
+	require.NoError(t, err)
+	cached, err = os.ReadDir(idxDir)
+	require.NoError(t, err)
+	require.Empty(t, cached)
+}
+
+func TestCachedIndexIsUnmappedOnceListed(t *testing.T) {
+	maps, err := os.ReadFile("/proc/self/maps")
+	if err != nil {
+		t.Skip("memory mappings of the process cannot be inspected:", err)
+	}
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	idxDir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	subject := NewCarSupplierWithOptions(mockEng, datastore.NewMapDatastore(), WithIndexCacheDir(idxDir))
+	t.Cleanup(func() { require.NoError(t, subject.Close()) })
+
+	md := metadata.New(metadata.Bitswap{})
+	contextID := []byte("fish")
+	mockEng.EXPECT().NotifyPut(ctx, contextID, md).Return(generateCidV1(t, rng), nil)
+	_, err = subject.Put(ctx, contextID, "../testdata/sample-v1.car", md)
+	require.NoError(t, err)
+
+	// The first listing caches the index, and the following ones are served from it.
+	for i := 0; i < 5; i++ {
+		require.NotEmpty(t, drainMultihashes(t, subject, contextID))
+	}
+	maps, err = os.ReadFile("/proc/self/maps")
+	require.NoError(t, err)
+	require.NotContains(t, string(maps), idxDir)
+}
+
+func drainMultihashes(t *testing.T, cs *CarSupplier, contextID []byte) []multihash.Multihash {
+	it, err := cs.ListMultihashes(context.Background(), contextID)
+	require.NoError(t, err)
+	var mhs []multihash.Multihash
+	for {
+		mh, err := it.Next()
+		if err == io.EOF {
+			return mhs
+		}
+		require.NoError(t, err)
+		mhs = append(mhs, mh)
+	}
+}
+
+func TestPutRejectsInvalidCars(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+	tmp := t.TempDir()
+
+	// Corrupt the last block of an otherwise valid CAR.
+	corruptPath := filepath.Join(tmp, "corrupt.car")
+	data, err := os.ReadFile("../testdata/sample-v1.car")
+	require.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(corruptPath, data, 0644))
+
+	garbagePath := filepath.Join(tmp, "garbage.car")
+	require.NoError(t, os.WriteFile(garbagePath, []byte("not a CAR"), 0644))
+
+	emptyPath := filepath.Join(tmp, "empty.car")
+	emptyBs, err := blockstore.OpenReadWrite(emptyPath, []cid.Cid{generateCidV1(t, rng)})
+	require.NoError(t, err)
+	require.NoError(t, emptyBs.Finalize())
+
+	tests := []struct {
+		name    string
+		path    string
+		opts    []Option
+		wantErr error
+	}{
+		{
+			name:    "missing",
+			path:    filepath.Join(tmp, "missing.car"),
+			wantErr: fs.ErrNotExist,
+		},
+		{
+			name:    "garbage",
+			path:    garbagePath,
+			wantErr: ErrInvalidCar,
+		},
+		{
+			name:    "empty",
+			path:    emptyPath,
+			wantErr: ErrEmptyCar,
+		},
+		{
+			name:    "corrupt",
+			path:    corruptPath,
+			opts:    []Option{WithBlockHashValidation(true)},
+			wantErr: ErrInvalidCar,
+		},
+		{
+			name: "corrupt without block hash validation",
+			path: corruptPath,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			t.Cleanup(mc.Finish)
+			mockEng := mock_provider.NewMockInterface(mc)
+			mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+			subject := NewCarSupplierWithOptions(mockEng, datastore.NewMapDatastore(), tt.opts...)
+
+			md := metadata.New(metadata.Bitswap{})
+			contextID := []byte("fish")
+			if tt.wantErr == nil {
+				mockEng.EXPECT().NotifyPut(ctx, contextID, md).Return(generateCidV1(t, rng), nil)
+			}
+			_, err := subject.Put(ctx, contextID, tt.path, md)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+			paths, err := subject.List(ctx)
+			require.NoError(t, err)
+			require.Empty(t, paths)
+		})
+	}
+}
+
+func TestFailedPutRollsBackPathMapping(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	subject := NewCarSupplier(mockEng, datastore.NewMapDatastore())
+
+	md := metadata.New(metadata.Bitswap{})
+	contextID := []byte("fish")
+	firstPath := filepath.Clean("../testdata/sample-v1.car")
+	secondPath := filepath.Clean("../testdata/sample-wrapped-v2.car")
+
+	mockEng.EXPECT().NotifyPut(ctx, contextID, md).Return(cid.Undef, errors.New("lobster"))
+	_, err := subject.Put(ctx, contextID, firstPath, md)
+	require.EqualError(t, err, "lobster")
+	paths, err := subject.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, paths)
+
+	mockEng.EXPECT().NotifyPut(ctx, contextID, md).Return(generateCidV1(t, rng), nil)
+	_, err = subject.Put(ctx, contextID, firstPath, md)
+	require.NoError(t, err)
+
+	// A failed put of a different path for the same context ID restores the previous path.
+	mockEng.EXPECT().NotifyPut(ctx, contextID, md).Return(cid.Undef, errors.New("lobster"))
+	_, err = subject.Put(ctx, contextID, secondPath, md)
+	require.EqualError(t, err, "lobster")
+	paths, err = subject.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{firstPath}, paths)
+}
+
+func TestUpdateMetadataKeepsPathMapping(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	subject := NewCarSupplier(mockEng, datastore.NewMapDatastore())
+
+	contextID := []byte("fish")
+	path := filepath.Clean("../testdata/sample-v1.car")
+	bitswapMd := metadata.New(metadata.Bitswap{})
+	gsMd := metadata.New(&metadata.GraphsyncFilecoinV1{PieceCID: generateCidV1(t, rng), FastRetrieval: true})
+
+	_, err := subject.UpdateMetadata(ctx, contextID, gsMd)
+	require.Equal(t, ErrNotFound, err)
+
+	mockEng.EXPECT().NotifyPut(ctx, contextID, bitswapMd).Return(generateCidV1(t, rng), nil)
+	_, err = subject.Put(ctx, contextID, path, bitswapMd)
+	require.NoError(t, err)
+
+	wantAd := generateCidV1(t, rng)
+	mockEng.EXPECT().NotifyPut(ctx, contextID, gsMd).Return(wantAd, nil)
+	gotAd, err := subject.UpdateMetadata(ctx, contextID, gsMd)
+	require.NoError(t, err)
+	require.Equal(t, wantAd, gotAd)
+
+	mockEng.EXPECT().NotifyPut(ctx, contextID, gsMd).Return(cid.Undef, provider.ErrAlreadyAdvertised)
+	_, err = subject.UpdateMetadata(ctx, contextID, gsMd)
+	require.Equal(t, provider.ErrAlreadyAdvertised, err)
+
+	paths, err := subject.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{path}, paths)
+}
+
+func TestForgetDoesNotNotifyEngine(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	subject := NewCarSupplier(mockEng, datastore.NewMapDatastore())
+
+	contextID := []byte("fish")
+	md := metadata.New(metadata.Bitswap{})
+	require.Equal(t, ErrNotFound, subject.Forget(ctx, contextID))
+
+	mockEng.EXPECT().NotifyPut(ctx, contextID, md).Return(generateCidV1(t, rng), nil)
+	_, err := subject.Put(ctx, contextID, "../testdata/sample-v1.car", md)
+	require.NoError(t, err)
+
+	require.NoError(t, subject.Forget(ctx, contextID))
+	paths, err := subject.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, paths)
+	_, err = subject.ListMultihashes(ctx, contextID)
+	require.Equal(t, ErrNotFound, err)
+}
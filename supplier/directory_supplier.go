@@ -0,0 +1,367 @@
+package supplier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+const (
+	dirSupplierDatastorePrefix = "directory_supplier://"
+	dirEntryDatastoreKeyPrefix = dirSupplierDatastorePrefix + "entry/"
+)
+
+type (
+	// DirectoryOption captures a configurable parameter of DirectorySupplier.
+	DirectoryOption func(*dirOptions) error
+
+	// MetadataFunc generates the metadata with which the CAR identified by the given context ID
+	// is advertised.
+	MetadataFunc func(contextID []byte) (metadata.Metadata, error)
+
+	dirOptions struct {
+		pollInterval time.Duration
+		extensions   []string
+		mdFunc       MetadataFunc
+	}
+
+	// dirEntry is the persisted state of a CAR file that is known to DirectorySupplier.
+	dirEntry struct {
+		Path    string    `json:"path"`
+		Size    int64     `json:"size"`
+		ModTime time.Time `json:"modTime"`
+	}
+)
+
+func newDirOptions(o ...DirectoryOption) (*dirOptions, error) {
+	opts := &dirOptions{
+		pollInterval: 10 * time.Second,
+		extensions:   []string{".car"},
+		mdFunc: func([]byte) (metadata.Metadata, error) {
+			return metadata.New(metadata.Bitswap{}), nil
+		},
+	}
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// WithPollInterval sets the interval at which watched directories are scanned for changes.
+// If unset, the default of 10 seconds is used.
+func WithPollInterval(i time.Duration) DirectoryOption {
+	return func(o *dirOptions) error {
+		if i <= 0 {
+			return fmt.Errorf("poll interval must be greater than zero; got %s", i)
+		}
+		o.pollInterval = i
+		return nil
+	}
+}
+
+// WithFileExtensions sets the file extensions, e.g. ".car", that are considered as CAR files.
+// Files with any other extension are ignored. The match is case-insensitive.
+// If unset, only files with ".car" extension are imported.
+func WithFileExtensions(ext ...string) DirectoryOption {
+	return func(o *dirOptions) error {
+		o.extensions = ext
+		return nil
+	}
+}
+
+// WithMetadataFunc sets the function used to generate the metadata of imported CAR files.
+// If unset, CAR files are advertised with metadata.Bitswap metadata.
+func WithMetadataFunc(f MetadataFunc) DirectoryOption {
+	return func(o *dirOptions) error {
+		o.mdFunc = f
+		return nil
+	}
+}
+
+// DirectorySupplier watches a set of directories and keeps the CAR files within them advertised
+// via a CarSupplier. New CAR files are imported via CarSupplier.Put and deleted CAR files are
+// removed via CarSupplier.Remove.
+//
+// The directories are watched by polling at a configurable interval. A CAR file is only imported
+// once its size and modification time remain unchanged between two consecutive scans, so that
+// partially written files are not advertised. A CAR file that is modified after it has been
+// imported is removed and then re-imported.
+//
+// The context ID of each CAR file is deterministically derived as the SHA-256 hash of its absolute
+// path. This is the context ID generated by the "provider import car" command when it is given the
+// same absolute path; since the command hashes the path as given, relative or otherwise different
+// paths to the same file result in different context IDs. The state of imported files is persisted
+// in the given datastore so that restarts do not re-announce CAR files that are already advertised.
+//
+// See: NewDirectorySupplier, CarSupplier.
+type DirectorySupplier struct {
+	*dirOptions
+	cs   *CarSupplier
+	ds   datastore.Datastore
+	dirs []string
+
+	// pending tracks files seen during the last scan that are not yet imported, used to determine
+	// whether a file has settled.
+	pending map[string]dirEntry
+	// syncLk serializes scans.
+	syncLk sync.Mutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewDirectorySupplier instantiates a new DirectorySupplier that watches the given directories
+// and supplies the CAR files found in them via the given CarSupplier.
+//
+// The directories are not scanned until DirectorySupplier.Start or DirectorySupplier.Sync is called.
+func NewDirectorySupplier(cs *CarSupplier, ds datastore.Datastore, dirs []string, o ...DirectoryOption) (*DirectorySupplier, error) {
+	opts, err := newDirOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, errors.New("at least one directory must be specified")
+	}
+	cleanDirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		cleanDirs = append(cleanDirs, abs)
+	}
+	return &DirectorySupplier{
+		dirOptions: opts,
+		cs:         cs,
+		ds:         ds,
+		dirs:       cleanDirs,
+		pending:    make(map[string]dirEntry),
+	}, nil
+}
+
+// DirectoryContextID returns the context ID with which the CAR file at the given absolute path is
+// supplied by DirectorySupplier, i.e. the SHA-256 hash of the path as is, the same way the
+// "provider import car" command derives context IDs.
+func DirectoryContextID(path string) []byte {
+	h := sha256.Sum256([]byte(path))
+	return h[:]
+}
+
+// Start starts watching the directories in the background. The directories are scanned once
+// immediately, then periodically at the configured poll interval until DirectorySupplier.Close
+// is called or the given context is cancelled.
+func (d *DirectorySupplier) Start(ctx context.Context) {
+	ctx, d.cancel = context.WithCancel(ctx)
+	d.done = make(chan struct{})
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(d.pollInterval)
+		defer ticker.Stop()
+		for {
+			if err := d.Sync(ctx); err != nil && ctx.Err() == nil {
+				log.Errorw("Failed to sync watched directories", "err", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Sync scans the watched directories once, importing new CAR files and removing the ones that
+// are deleted since the last scan. Errors that occur while importing or removing individual files
+// are logged, and the files are retried during the next scan.
+//
+// A directory that cannot be scanned, e.g. because it is missing or unreadable, is logged and
+// skipped without affecting the other directories. The CAR files previously imported from it are
+// kept as they are until it can be scanned again.
+func (d *DirectorySupplier) Sync(ctx context.Context) error {
+	d.syncLk.Lock()
+	defer d.syncLk.Unlock()
+
+	known, err := d.listEntries(ctx)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]dirEntry)
+	var unscanned []string
+	for _, dir := range d.dirs {
+		if err := filepath.WalkDir(dir, func(path string, de fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if de.IsDir() || !d.isCar(path) {
+				return nil
+			}
+			info, err := de.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					// File was deleted during the walk.
+					return nil
+				}
+				return err
+			}
+			found[path] = dirEntry{Path: path, Size: info.Size(), ModTime: info.ModTime().UTC()}
+			return nil
+		}); err != nil {
+			log.Errorw("Failed to scan directory; keeping the CAR files imported from it", "dir", dir, "err", err)
+			unscanned = append(unscanned, dir)
+		}
+	}
+
+	for path, entry := range known {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		current, ok := found[path]
+		if ok && current.equal(entry) {
+			delete(found, path)
+			continue
+		}
+		if !ok && isInAnyDir(path, unscanned) {
+			// File may well exist; its directory could not be scanned.
+			continue
+		}
+		// File is either deleted or modified since it was imported; remove it.
+		if err := d.remove(ctx, entry); err != nil {
+			log.Errorw("Failed to remove CAR", "path", path, "err", err)
+			// Do not attempt to re-import a modified file until its removal succeeds.
+			delete(found, path)
+		}
+	}
+
+	pending := make(map[string]dirEntry)
+	for path, entry := range found {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if prev, ok := d.pending[path]; !ok || !prev.equal(entry) {
+			// File is either new or still changing; wait for it to settle.
+			pending[path] = entry
+			continue
+		}
+		if err := d.put(ctx, entry); err != nil {
+			log.Errorw("Failed to import CAR", "path", path, "err", err)
+			pending[path] = entry
+		}
+	}
+	d.pending = pending
+	return nil
+}
+
+// isInAnyDir checks whether the given path is within any of the given directories.
+func isInAnyDir(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DirectorySupplier) put(ctx context.Context, entry dirEntry) error {
+	contextID := DirectoryContextID(entry.Path)
+	md, err := d.mdFunc(contextID)
+	if err != nil {
+		return err
+	}
+	adCid, err := d.cs.Put(ctx, contextID, entry.Path, md)
+	switch err {
+	case nil:
+		log.Infow("Imported CAR from watched directory", "path", entry.Path, "adCid", adCid)
+	case provider.ErrAlreadyAdvertised:
+		log.Infow("CAR from watched directory is already advertised", "path", entry.Path)
+	default:
+		return err
+	}
+	return d.putEntry(ctx, contextID, entry)
+}
+
+func (d *DirectorySupplier) remove(ctx context.Context, entry dirEntry) error {
+	contextID := DirectoryContextID(entry.Path)
+	adCid, err := d.cs.Remove(ctx, contextID)
+	switch err {
+	case nil:
+		log.Infow("Removed CAR from watched directory", "path", entry.Path, "adCid", adCid)
+	case ErrNotFound, provider.ErrContextIDNotFound:
+		log.Infow("CAR from watched directory is already removed", "path", entry.Path)
+	default:
+		return err
+	}
+	return d.ds.Delete(ctx, toDirEntryKey(contextID))
+}
+
+func (d *DirectorySupplier) isCar(path string) bool {
+	ext := filepath.Ext(path)
+	for _, want := range d.extensions {
+		if strings.EqualFold(ext, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DirectorySupplier) putEntry(ctx context.Context, contextID []byte, entry dirEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return d.ds.Put(ctx, toDirEntryKey(contextID), b)
+}
+
+func (d *DirectorySupplier) listEntries(ctx context.Context) (map[string]dirEntry, error) {
+	results, err := d.ds.Query(ctx, query.Query{Prefix: dirEntryDatastoreKeyPrefix})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	entries := make(map[string]dirEntry)
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var entry dirEntry
+		if err := json.Unmarshal(r.Value, &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode directory entry %s: %w", r.Key, err)
+		}
+		entries[entry.Path] = entry
+	}
+	return entries, nil
+}
+
+func toDirEntryKey(contextID []byte) datastore.Key {
+	return datastore.NewKey(dirEntryDatastoreKeyPrefix + base64.RawURLEncoding.EncodeToString(contextID))
+}
+
+func (e dirEntry) equal(other dirEntry) bool {
+	return e.Path == other.Path && e.Size == other.Size && e.ModTime.Equal(other.ModTime)
+}
+
+// Close stops watching the directories and blocks until any in-progress scan is finished.
+// Note that closing DirectorySupplier does not close the underlying CarSupplier.
+func (d *DirectorySupplier) Close() error {
+	if d.cancel != nil {
+		d.cancel()
+		<-d.done
+	}
+	return nil
+}
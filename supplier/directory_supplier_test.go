@@ -0,0 +1,145 @@
+package supplier
+
+import (
+	"context"
+	"crypto/sha256"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	mock_provider "github.com/filecoin-project/index-provider/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectorySupplierImportsAndRemovesCars(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+	ds := datastore.NewMapDatastore()
+
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	cs := NewCarSupplier(mockEng, ds)
+
+	dir := t.TempDir()
+	carPath := filepath.Join(dir, "sample.car")
+	copyFile(t, "../testdata/sample-wrapped-v2.car", carPath)
+	// Non-CAR files must be ignored.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("fish"), 0666))
+
+	md := metadata.New(metadata.Bitswap{})
+	subject, err := NewDirectorySupplier(cs, ds, []string{dir})
+	require.NoError(t, err)
+
+	// First scan only observes the file; it is imported once it has settled.
+	require.NoError(t, subject.Sync(ctx))
+
+	// The context ID is derived the same way as the "provider import car" command does, given the
+	// absolute path of the CAR file.
+	sum := sha256.Sum256([]byte(carPath))
+	wantContextID := sum[:]
+	require.Equal(t, wantContextID, DirectoryContextID(carPath))
+	mockEng.
+		EXPECT().
+		NotifyPut(ctx, wantContextID, md).
+		Return(generateCidV1(t, rng), nil)
+	require.NoError(t, subject.Sync(ctx))
+
+	paths, err := cs.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{carPath}, paths)
+
+	// Already imported files are not re-announced after restart.
+	restarted, err := NewDirectorySupplier(cs, ds, []string{dir})
+	require.NoError(t, err)
+	require.NoError(t, restarted.Sync(ctx))
+	require.NoError(t, restarted.Sync(ctx))
+
+	require.NoError(t, os.Remove(carPath))
+	mockEng.
+		EXPECT().
+		NotifyRemove(ctx, wantContextID).
+		Return(generateCidV1(t, rng), nil)
+	require.NoError(t, restarted.Sync(ctx))
+
+	paths, err = cs.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, paths)
+	require.NoError(t, restarted.Sync(ctx))
+}
+
+func TestDirectorySupplierSkipsMissingDirectory(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+	ds := datastore.NewMapDatastore()
+
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	cs := NewCarSupplier(mockEng, ds)
+
+	root := t.TempDir()
+	fishDir := filepath.Join(root, "fish")
+	lobsterDir := filepath.Join(root, "lobster")
+	require.NoError(t, os.Mkdir(fishDir, 0777))
+	require.NoError(t, os.Mkdir(lobsterDir, 0777))
+	fishPath := filepath.Join(fishDir, "fish.car")
+	copyFile(t, "../testdata/sample-wrapped-v2.car", fishPath)
+
+	md := metadata.New(metadata.Bitswap{})
+	subject, err := NewDirectorySupplier(cs, ds, []string{fishDir, lobsterDir})
+	require.NoError(t, err)
+	mockEng.
+		EXPECT().
+		NotifyPut(ctx, DirectoryContextID(fishPath), md).
+		Return(generateCidV1(t, rng), nil)
+	require.NoError(t, subject.Sync(ctx))
+	require.NoError(t, subject.Sync(ctx))
+
+	// While one directory is missing, e.g. unmounted, the others are still scanned and the CAR
+	// files imported from the missing one are not removed.
+	require.NoError(t, os.Rename(fishDir, fishDir+".unmounted"))
+	lobsterPath := filepath.Join(lobsterDir, "lobster.car")
+	copyFile(t, "../testdata/sample-v1.car", lobsterPath)
+	mockEng.
+		EXPECT().
+		NotifyPut(ctx, DirectoryContextID(lobsterPath), md).
+		Return(generateCidV1(t, rng), nil)
+	require.NoError(t, subject.Sync(ctx))
+	require.NoError(t, subject.Sync(ctx))
+	paths, err := cs.List(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{fishPath, lobsterPath}, paths)
+
+	// Once back, the directory is scanned as before without re-importing its CAR files.
+	require.NoError(t, os.Rename(fishDir+".unmounted", fishDir))
+	require.NoError(t, subject.Sync(ctx))
+	paths, err = cs.List(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{fishPath, lobsterPath}, paths)
+}
+
+func TestNewDirectorySupplierRequiresDirectory(t *testing.T) {
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	ds := datastore.NewMapDatastore()
+	cs := NewCarSupplier(mockEng, ds)
+
+	_, err := NewDirectorySupplier(cs, ds, nil)
+	require.EqualError(t, err, "at least one directory must be specified")
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	data, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(dst, data, 0666))
+}
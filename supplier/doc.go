@@ -1,5 +1,8 @@
 // Package supplier provides mechanisms to supply mulithashes to an index-provider engine via
 // provider.MultihashLister
-// The only implemented mechanism is CarSupplier, that in conjunction with an engine allows a user
-// to advertise multihashes by simply providing CAR files.
+// The main mechanism is CarSupplier, that in conjunction with an engine allows a user to advertise
+// multihashes by simply providing CAR files, either on the local file system or at remote HTTP or
+// S3 URLs. DirectorySupplier builds on CarSupplier to
+// automatically advertise the CAR files within a set of watched directories, and
+// BlockstoreSupplier advertises DAGs that are already stored in a blockstore.
 package supplier
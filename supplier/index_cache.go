@@ -0,0 +1,130 @@
+package supplier
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ipld/go-car/v2/index"
+)
+
+const indexCacheFileExt = ".idx"
+
+// indexCache persists generated CAR indexes in a directory.
+//
+// Each index is stored in a file named after the hash of its context ID followed by the hash of
+// the CAR file fingerprint. This allows finding and deleting all cached indexes for a context ID
+// without having to know the fingerprint of the CAR file from which they were generated.
+type indexCache struct {
+	dir string
+}
+
+// fingerprint uniquely identifies the state of a CAR file at a given path.
+type fingerprint struct {
+	path string
+	size int64
+	// version identifies the revision of the CAR file, e.g. its modification time.
+	version string
+}
+
+func newFingerprint(path string) (fingerprint, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fingerprint{}, err
+	}
+	return fingerprint{
+		path:    path,
+		size:    info.Size(),
+		version: strconv.FormatInt(info.ModTime().UnixNano(), 10),
+	}, nil
+}
+
+func (f fingerprint) hash() string {
+	h := sha256.New()
+	h.Write([]byte(f.path))
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(f.size))
+	h.Write(buf[:])
+	h.Write([]byte(f.version))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func contextIDHash(contextID []byte) string {
+	h := sha256.Sum256(contextID)
+	return hex.EncodeToString(h[:])
+}
+
+func (c *indexCache) filePath(contextID []byte, fp fingerprint) string {
+	return filepath.Join(c.dir, contextIDHash(contextID)+"-"+fp.hash()+indexCacheFileExt)
+}
+
+// get memory-maps the cached index of the CAR file with the given context ID and fingerprint.
+// The records of the index are read from the mapped file on demand rather than loaded into memory.
+// A nil index is returned if no such index is cached.
+func (c *indexCache) get(contextID []byte, fp fingerprint) (index.IterableIndex, error) {
+	idx, err := openMmapIndex(c.filePath(contextID, fp))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cached index: %w", err)
+	}
+	return idx, nil
+}
+
+// put persists the given index of the CAR file with the given context ID and fingerprint,
+// replacing any index previously cached for the same context ID.
+func (c *indexCache) put(contextID []byte, fp fingerprint, idx index.Index) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first, then rename, so that partially written indexes are never
+	// read back.
+	f, err := ioutil.TempFile(c.dir, "tmp-*"+indexCacheFileExt)
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+	if _, err := index.WriteTo(idx, f); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := c.remove(contextID); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, c.filePath(contextID, fp))
+}
+
+// remove deletes all the indexes cached for the given context ID.
+func (c *indexCache) remove(contextID []byte) error {
+	prefix := contextIDHash(contextID) + "-"
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) {
+			if err := os.Remove(filepath.Join(c.dir, e.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package supplier
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car/v2/index"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/multiformats/go-varint"
+	"golang.org/x/exp/mmap"
+)
+
+// mmapIndexReadBatch is the number of records read from a memory-mapped index at a time when
+// iterating over it.
+const mmapIndexReadBatch = 4096
+
+var (
+	_ index.IterableIndex = (*mmapIndex)(nil)
+
+	errReadOnlyIndex = errors.New("memory-mapped index is read-only")
+)
+
+// mmapIndex is a read-only index.IterableIndex of codec multicodec.CarMultihashIndexSorted, the
+// records of which are read on demand from a memory-mapped file instead of being decoded onto the
+// heap. The file is unmapped once the index is closed or garbage collected.
+type mmapIndex struct {
+	r *mmap.ReaderAt
+	// start is the offset at which the index follows its codec.
+	start int64
+	// buckets are the sets of sorted records of the same multihash code and width, in the order in
+	// which they are stored, i.e. by code then width.
+	buckets []mmapIndexBucket
+}
+
+type mmapIndexBucket struct {
+	code uint64
+	// width is the width of each record, i.e. the length of the digest plus 8 bytes of offset.
+	width int64
+	// offset is the offset of the first record.
+	offset int64
+	count  int64
+}
+
+// openMmapIndex memory-maps the index at the given path, and reads the layout of its records.
+func openMmapIndex(path string) (*mmapIndex, error) {
+	r, err := mmap.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	idx := &mmapIndex{r: r}
+	if err := idx.readLayout(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (m *mmapIndex) readLayout() error {
+	size := int64(m.r.Len())
+	head := make([]byte, varint.MaxLenUvarint63)
+	n, err := m.r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	codec, codecLen, err := varint.FromUvarint(head[:n])
+	if err != nil {
+		return err
+	}
+	if multicodec.Code(codec) != multicodec.CarMultihashIndexSorted {
+		return fmt.Errorf("unexpected index codec: %s", multicodec.Code(codec))
+	}
+	m.start = int64(codecLen)
+
+	sr := io.NewSectionReader(m.r, m.start, size-m.start)
+	var codes int32
+	if err := binary.Read(sr, binary.LittleEndian, &codes); err != nil {
+		return err
+	}
+	for i := int32(0); i < codes; i++ {
+		var code uint64
+		if err := binary.Read(sr, binary.LittleEndian, &code); err != nil {
+			return err
+		}
+		var widths int32
+		if err := binary.Read(sr, binary.LittleEndian, &widths); err != nil {
+			return err
+		}
+		for j := int32(0); j < widths; j++ {
+			var width uint32
+			if err := binary.Read(sr, binary.LittleEndian, &width); err != nil {
+				return err
+			}
+			var length int64
+			if err := binary.Read(sr, binary.LittleEndian, &length); err != nil {
+				return err
+			}
+			offset, _ := sr.Seek(0, io.SeekCurrent)
+			offset += m.start
+			if width <= 8 || length < 0 || length%int64(width) != 0 || offset+length > size {
+				return errors.New("malformed index")
+			}
+			m.buckets = append(m.buckets, mmapIndexBucket{
+				code:   code,
+				width:  int64(width),
+				offset: offset,
+				count:  length / int64(width),
+			})
+			if _, err := sr.Seek(length, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Codec returns multicodec.CarMultihashIndexSorted.
+func (m *mmapIndex) Codec() multicodec.Code {
+	return multicodec.CarMultihashIndexSorted
+}
+
+// Marshal copies the memory-mapped index, excluding its codec, to w.
+func (m *mmapIndex) Marshal(w io.Writer) (uint64, error) {
+	n, err := io.Copy(w, io.NewSectionReader(m.r, m.start, int64(m.r.Len())-m.start))
+	return uint64(n), err
+}
+
+// Unmarshal is not supported, since the index is read-only.
+func (m *mmapIndex) Unmarshal(io.Reader) error {
+	return errReadOnlyIndex
+}
+
+// Load is not supported, since the index is read-only.
+func (m *mmapIndex) Load([]index.Record) error {
+	return errReadOnlyIndex
+}
+
+// GetAll calls f with the offsets of the given CID, by binary search over the records of the
+// bucket that matches the code and digest length of its multihash.
+func (m *mmapIndex) GetAll(c cid.Cid, f func(uint64) bool) error {
+	dmh, err := multihash.Decode(c.Hash())
+	if err != nil {
+		return err
+	}
+	for _, b := range m.buckets {
+		if b.code != dmh.Code || b.width != int64(len(dmh.Digest))+8 {
+			continue
+		}
+		record := make([]byte, b.width)
+		var readErr error
+		digestAt := func(i int64) []byte {
+			if _, err := m.r.ReadAt(record, b.offset+i*b.width); err != nil {
+				readErr = err
+			}
+			return record[:b.width-8]
+		}
+		i := int64(sort.Search(int(b.count), func(i int) bool {
+			return bytes.Compare(dmh.Digest, digestAt(int64(i))) <= 0
+		}))
+		var found bool
+		for ; i < b.count && readErr == nil; i++ {
+			if !bytes.Equal(dmh.Digest, digestAt(i)) {
+				break
+			}
+			found = true
+			if !f(binary.LittleEndian.Uint64(record[b.width-8:])) {
+				break
+			}
+		}
+		if readErr != nil {
+			return readErr
+		}
+		if found {
+			return nil
+		}
+	}
+	return index.ErrNotFound
+}
+
+// ForEach calls f for every multihash and its offset, reading the records in batches.
+func (m *mmapIndex) ForEach(f func(multihash.Multihash, uint64) error) error {
+	for _, b := range m.buckets {
+		buf := make([]byte, b.width*mmapIndexReadBatch)
+		for i := int64(0); i < b.count; i += mmapIndexReadBatch {
+			batch := buf
+			if remaining := b.count - i; remaining < mmapIndexReadBatch {
+				batch = buf[:remaining*b.width]
+			}
+			if _, err := m.r.ReadAt(batch, b.offset+i*b.width); err != nil {
+				return err
+			}
+			for len(batch) != 0 {
+				record := batch[:b.width]
+				batch = batch[b.width:]
+				mh, err := multihash.Encode(record[:b.width-8], b.code)
+				if err != nil {
+					return err
+				}
+				if err := f(mh, binary.LittleEndian.Uint64(record[b.width-8:])); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Close unmaps the index.
+func (m *mmapIndex) Close() error {
+	return m.r.Close()
+}
@@ -0,0 +1,85 @@
+package supplier
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/index"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMmapIndex(t *testing.T) {
+	f, err := os.Open("../testdata/sample-v1.car")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, f.Close()) })
+	want := index.NewMultihashSorted()
+	require.NoError(t, car.LoadIndex(want, f))
+
+	path := filepath.Join(t.TempDir(), "sample-v1.idx")
+	var wantBytes bytes.Buffer
+	_, err = index.WriteTo(want, &wantBytes)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, wantBytes.Bytes(), 0644))
+
+	subject, err := openMmapIndex(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, subject.Close()) })
+
+	// Records are iterated in the same order as the decoded index.
+	type record struct {
+		mh     multihash.Multihash
+		offset uint64
+	}
+	var wantRecords, gotRecords []record
+	require.NoError(t, want.ForEach(func(mh multihash.Multihash, offset uint64) error {
+		wantRecords = append(wantRecords, record{mh, offset})
+		return nil
+	}))
+	require.NoError(t, subject.ForEach(func(mh multihash.Multihash, offset uint64) error {
+		gotRecords = append(gotRecords, record{mh, offset})
+		return nil
+	}))
+	require.NotEmpty(t, gotRecords)
+	require.Equal(t, wantRecords, gotRecords)
+
+	// Every record is found by its CID.
+	for _, r := range wantRecords {
+		var offsets []uint64
+		require.NoError(t, subject.GetAll(cid.NewCidV1(cid.Raw, r.mh), func(o uint64) bool {
+			offsets = append(offsets, o)
+			return true
+		}))
+		require.Equal(t, []uint64{r.offset}, offsets)
+	}
+	missing, err := multihash.Sum([]byte("fish"), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	err = subject.GetAll(cid.NewCidV1(cid.Raw, missing), func(uint64) bool { return true })
+	require.Equal(t, index.ErrNotFound, err)
+
+	// The index is marshalled unchanged.
+	var gotBytes bytes.Buffer
+	_, err = index.WriteTo(subject, &gotBytes)
+	require.NoError(t, err)
+	require.Equal(t, wantBytes.Bytes(), gotBytes.Bytes())
+
+	// Indexes of other codecs are rejected.
+	sorted, err := index.New(multicodec.CarIndexSorted)
+	require.NoError(t, err)
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	require.NoError(t, car.LoadIndex(sorted, f))
+	otherPath := filepath.Join(t.TempDir(), "sorted.idx")
+	var otherBytes bytes.Buffer
+	_, err = index.WriteTo(sorted, &otherBytes)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(otherPath, otherBytes.Bytes(), 0644))
+	_, err = openMmapIndex(otherPath)
+	require.EqualError(t, err, "unexpected index codec: car-index-sorted")
+}
@@ -0,0 +1,100 @@
+package supplier
+
+import (
+	"net/http"
+
+	"github.com/ipld/go-car/v2"
+)
+
+type (
+	// Option captures a configurable parameter of CarSupplier.
+	Option func(*options)
+
+	options struct {
+		readOpts         []car.ReadOption
+		indexDir         string
+		httpClient       *http.Client
+		s3Endpoint       string
+		remotePageSize   int64
+		remoteCachePages int
+		validateHashes   bool
+	}
+)
+
+func newOptions(o ...Option) *options {
+	opts := &options{
+		httpClient:       http.DefaultClient,
+		remotePageSize:   1 << 20,
+		remoteCachePages: 64,
+	}
+	for _, apply := range o {
+		apply(opts)
+	}
+	return opts
+}
+
+// WithCarReadOptions sets the options used when reading CAR files.
+// If unset, the go-car/v2 defaults are used.
+func WithCarReadOptions(ro ...car.ReadOption) Option {
+	return func(o *options) {
+		o.readOpts = ro
+	}
+}
+
+// WithIndexCacheDir sets the sidecar directory in which the indexes generated for CAR files are
+// persisted. An index is generated for a CAR file when it has no index or when its index is not
+// iterable. Once persisted, later lookups memory-map the index from the directory instead of
+// rescanning the CAR file.
+//
+// Cached indexes are keyed by context ID and the fingerprint of the CAR file, i.e. its path, size
+// and modification time, or ETag for remote CAR files; a changed CAR file therefore invalidates
+// its cached index.
+// If unset, generated indexes are not persisted.
+func WithIndexCacheDir(dir string) Option {
+	return func(o *options) {
+		o.indexDir = dir
+	}
+}
+
+// WithHttpClient sets the HTTP client used to read remote CAR files.
+// Custom clients may be used to authenticate requests, for example by signing them with a custom
+// http.RoundTripper.
+// If unset, http.DefaultClient is used.
+func WithHttpClient(c *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = c
+	}
+}
+
+// WithS3Endpoint sets the endpoint of an S3-compatible object storage, e.g. a MinIO server, at
+// which CAR files with s3://<bucket>/<key> URLs are read using path-style requests.
+// If unset, such CAR files are read from AWS S3 using virtual-hosted–style requests.
+//
+// Requests are not signed, so only publicly readable objects can be read unless the HTTP client
+// signs them. See: WithHttpClient.
+func WithS3Endpoint(endpoint string) Option {
+	return func(o *options) {
+		o.s3Endpoint = endpoint
+	}
+}
+
+// WithRemoteReadCache sets the size of pages in which remote CAR files are read using range
+// requests, and the maximum number of pages to cache in memory across all remote CAR files.
+// Pages of remote CAR files for which the server reports neither ETag nor Last-Modified are not
+// cached, since a replaced file could not be detected.
+// If unset, pages of 1MiB are read with up to 64 pages cached.
+func WithRemoteReadCache(pageSize int64, pages int) Option {
+	return func(o *options) {
+		o.remotePageSize = pageSize
+		o.remoteCachePages = pages
+	}
+}
+
+// WithBlockHashValidation sets whether to check the hash of every block in a CAR file before it is
+// advertised by CarSupplier.Put. Enabling validation requires reading the entire CAR file on put.
+// If unset, only the first block is checked.
+func WithBlockHashValidation(enable bool) Option {
+	return func(o *options) {
+		o.validateHashes = enable
+	}
+}
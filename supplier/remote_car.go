@@ -0,0 +1,225 @@
+package supplier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/groupcache/lru"
+)
+
+var _ io.ReaderAt = (*remoteCar)(nil)
+
+// remoteCar is an io.ReaderAt over a CAR file stored at a remote URL.
+//
+// Reads are served using HTTP range requests in fixed-size pages. The pages are cached in a
+// pageCache shared across all remote CARs of a CarSupplier, unless the remote server reports no
+// version of the CAR, in which case cached pages could not be told apart from those of a replaced
+// CAR and every page is requested when it is read.
+type remoteCar struct {
+	// ctx is the context with which the CAR was opened, which bounds the requests of its reads.
+	ctx    context.Context
+	client *http.Client
+	url    string
+	size   int64
+	// version is the ETag or last modification time reported by the remote server, used to
+	// invalidate cached pages and indexes when the remote CAR changes. It is empty if the server
+	// reports neither, in which case pages and indexes are not cached.
+	version string
+	cache   *pageCache
+}
+
+// pageCache is an LRU cache of pages read from remote CARs.
+type pageCache struct {
+	pageSize int64
+	lk       sync.Mutex
+	pages    *lru.Cache
+}
+
+type pageKey struct {
+	url     string
+	version string
+	page    int64
+}
+
+func newPageCache(pageSize int64, pages int) *pageCache {
+	return &pageCache{
+		pageSize: pageSize,
+		pages:    lru.New(pages),
+	}
+}
+
+func (c *pageCache) get(key pageKey) ([]byte, bool) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	v, ok := c.pages.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.([]byte), true
+}
+
+func (c *pageCache) add(key pageKey, page []byte) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.pages.Add(key, page)
+}
+
+// isRemote checks whether the given CAR path is a remote URL supported by CarSupplier.
+func isRemote(path string) bool {
+	u, err := url.Parse(path)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "s3":
+		return true
+	default:
+		return false
+	}
+}
+
+// toHttpURL converts the given remote CAR URL to an HTTP URL. URLs with s3 scheme, e.g.
+// s3://bucket/key, are converted to path-style URLs at the given S3 endpoint if one is specified,
+// and to virtual-hosted–style URLs at AWS S3 otherwise.
+//
+// Note that the converted URLs are requested as is, i.e. without AWS signatures, and so only
+// publicly readable objects can be read unless the HTTP client signs its requests.
+func toHttpURL(remote string, s3Endpoint string) (string, error) {
+	u, err := url.Parse(remote)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "s3" {
+		return remote, nil
+	}
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return "", fmt.Errorf("s3 URL must specify both bucket and key: %s", remote)
+	}
+	if s3Endpoint == "" {
+		return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", bucket, key), nil
+	}
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s3Endpoint, "/"), bucket, key), nil
+}
+
+// openRemoteCar opens the CAR at the given HTTP URL. The remote server must support range
+// requests. The reads of the returned CAR fail once the given context is done.
+func openRemoteCar(ctx context.Context, client *http.Client, url string, cache *pageCache) (*remoteCar, error) {
+	// Request the first byte to both learn the total size and check that range requests are
+	// supported.
+	resp, err := doRangeRequest(ctx, client, url, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contentRange := resp.Header.Get("Content-Range")
+	i := strings.LastIndex(contentRange, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid content range in response from %s: %q", url, contentRange)
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unknown size of remote CAR %s: %w", url, err)
+	}
+	version := resp.Header.Get("ETag")
+	if version == "" {
+		version = resp.Header.Get("Last-Modified")
+	}
+	return &remoteCar{
+		ctx:     ctx,
+		client:  client,
+		url:     url,
+		size:    size,
+		version: version,
+		cache:   cache,
+	}, nil
+}
+
+func doRangeRequest(ctx context.Context, client *http.Client, url string, from, to int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("remote CAR not found: %s", url)
+		}
+		return nil, fmt.Errorf("expected partial content response from %s but got: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// ReadAt reads len(p) bytes starting at offset off of the remote CAR.
+func (r *remoteCar) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset: %d", off)
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	var read int
+	for read < len(p) && off < r.size {
+		pageNum := off / r.cache.pageSize
+		page, err := r.page(pageNum)
+		if err != nil {
+			return read, err
+		}
+		n := copy(p[read:], page[off-pageNum*r.cache.pageSize:])
+		read += n
+		off += int64(n)
+	}
+	if read < len(p) {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+func (r *remoteCar) page(pageNum int64) ([]byte, error) {
+	key := pageKey{url: r.url, version: r.version, page: pageNum}
+	cacheable := r.version != ""
+	if cacheable {
+		if page, ok := r.cache.get(key); ok {
+			return page, nil
+		}
+	}
+	from := pageNum * r.cache.pageSize
+	to := from + r.cache.pageSize - 1
+	if to >= r.size {
+		to = r.size - 1
+	}
+	resp, err := doRangeRequest(r.ctx, r.client, r.url, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	page := make([]byte, to-from+1)
+	if _, err := io.ReadFull(resp.Body, page); err != nil {
+		return nil, fmt.Errorf("failed to read range %d-%d of remote CAR %s: %w", from, to, r.url, err)
+	}
+	if cacheable {
+		r.cache.add(key, page)
+	}
+	return page, nil
+}
+
+func (r *remoteCar) fingerprint() fingerprint {
+	return fingerprint{
+		path:    r.url,
+		size:    r.size,
+		version: r.version,
+	}
+}
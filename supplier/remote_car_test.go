@@ -0,0 +1,234 @@
+package supplier
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	mock_provider "github.com/filecoin-project/index-provider/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-car/v2/blockstore"
+	"github.com/stretchr/testify/require"
+)
+
+// remoteCarServer serves the CAR files in testdata under /fish/, counting the requests it
+// receives. The files are served without a version, i.e. modification time, unless versioned.
+type remoteCarServer struct {
+	*httptest.Server
+	versioned bool
+	// rangeReqs, fullReqs and opens count the range requests, the requests for entire files, and
+	// the requests for the first byte, i.e. those that open a remote CAR.
+	rangeReqs, fullReqs, opens int32
+}
+
+func newRemoteCarServer(t *testing.T, versioned bool) *remoteCarServer {
+	s := &remoteCarServer{versioned: versioned}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/fish/")
+		if name == r.URL.Path {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Header.Get("Range") {
+		case "":
+			atomic.AddInt32(&s.fullReqs, 1)
+		case "bytes=0-0":
+			atomic.AddInt32(&s.opens, 1)
+			fallthrough
+		default:
+			atomic.AddInt32(&s.rangeReqs, 1)
+		}
+		f, err := os.Open(filepath.Join("../testdata", name))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		stat, err := f.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var modTime time.Time
+		if s.versioned {
+			modTime = stat.ModTime()
+		}
+		http.ServeContent(w, r, stat.Name(), modTime, f)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestRemoteCarIsSuppliedWithRangeRequests(t *testing.T) {
+	server := newRemoteCarServer(t, true)
+
+	tests := []struct {
+		name    string
+		carPath string
+		url     string
+		opts    []Option
+	}{
+		{
+			name:    "http",
+			carPath: "../testdata/sample-v1.car",
+			url:     server.URL + "/fish/sample-v1.car",
+		},
+		{
+			name:    "s3",
+			carPath: "../testdata/sample-wrapped-v2.car",
+			url:     "s3://fish/sample-wrapped-v2.car",
+			opts:    []Option{WithS3Endpoint(server.URL)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1413))
+			ctx := context.Background()
+			mc := gomock.NewController(t)
+			t.Cleanup(mc.Finish)
+			mockEng := mock_provider.NewMockInterface(mc)
+			mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+			opts := append(tt.opts, WithRemoteReadCache(512, 16))
+			subject := NewCarSupplierWithOptions(mockEng, datastore.NewMapDatastore(), opts...)
+
+			md := metadata.New(metadata.Bitswap{})
+			contextID := []byte(tt.name)
+			mockEng.EXPECT().NotifyPut(ctx, contextID, md).Return(generateCidV1(t, rng), nil)
+			_, err := subject.Put(ctx, contextID, tt.url, md)
+			require.NoError(t, err)
+
+			paths, err := subject.List(ctx)
+			require.NoError(t, err)
+			require.Equal(t, []string{tt.url}, paths)
+
+			// Compare against the same CAR supplied from local file system.
+			mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+			local := NewCarSupplier(mockEng, datastore.NewMapDatastore())
+			mockEng.EXPECT().NotifyPut(ctx, contextID, md).Return(generateCidV1(t, rng), nil)
+			_, err = local.Put(ctx, contextID, tt.carPath, md)
+			require.NoError(t, err)
+			require.Equal(t, drainMultihashes(t, local, contextID), drainMultihashes(t, subject, contextID))
+
+			wantBs, err := blockstore.OpenReadOnly(tt.carPath)
+			require.NoError(t, err)
+			t.Cleanup(func() { wantBs.Close() })
+			wantRoots, err := wantBs.Roots()
+			require.NoError(t, err)
+
+			opens := atomic.LoadInt32(&server.opens)
+			gotBs, err := subject.ReadOnlyBlockstore(contextID)
+			require.NoError(t, err)
+			// The remote CAR is opened once for both its index and its blocks.
+			require.Equal(t, opens+1, atomic.LoadInt32(&server.opens))
+			t.Cleanup(func() { gotBs.Close() })
+			wantBlk, err := wantBs.Get(ctx, wantRoots[0])
+			require.NoError(t, err)
+			gotBlk, err := gotBs.Get(ctx, wantRoots[0])
+			require.NoError(t, err)
+			require.Equal(t, wantBlk.RawData(), gotBlk.RawData())
+
+			require.NotZero(t, atomic.LoadInt32(&server.rangeReqs))
+			require.Zero(t, atomic.LoadInt32(&server.fullReqs))
+		})
+	}
+}
+
+func TestRemoteCarNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	subject := NewCarSupplier(mockEng, datastore.NewMapDatastore())
+
+	ctx := context.Background()
+	contextID := []byte("fish")
+	url := server.URL + "/missing.car"
+	require.NoError(t, subject.ds.Put(ctx, toCarIdKey(contextID), []byte(url)))
+	_, err := subject.ListMultihashes(ctx, contextID)
+	require.EqualError(t, err, "remote CAR not found: "+url)
+}
+
+func TestRemoteCarReadsFailOnceContextIsDone(t *testing.T) {
+	server := newRemoteCarServer(t, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	rc, err := openRemoteCar(ctx, http.DefaultClient, server.URL+"/fish/sample-v1.car", newPageCache(512, 16))
+	require.NoError(t, err)
+
+	buf := make([]byte, 10)
+	_, err = rc.ReadAt(buf, 0)
+	require.NoError(t, err)
+	cancel()
+	_, err = rc.ReadAt(buf, 1024)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRemoteCarWithoutVersionIndexIsNotCached(t *testing.T) {
+	server := newRemoteCarServer(t, false)
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	idxDir := t.TempDir()
+	subject := NewCarSupplierWithOptions(mockEng, datastore.NewMapDatastore(), WithIndexCacheDir(idxDir))
+
+	md := metadata.New(metadata.Bitswap{})
+	contextID := []byte("fish")
+	mockEng.EXPECT().NotifyPut(ctx, contextID, md).Return(generateCidV1(t, rng), nil)
+	_, err := subject.Put(ctx, contextID, server.URL+"/fish/sample-v1.car", md)
+	require.NoError(t, err)
+
+	// The index of the CARv1 is generated, but not cached since changes to the CAR cannot be
+	// detected.
+	require.NotEmpty(t, drainMultihashes(t, subject, contextID))
+	cached, err := os.ReadDir(idxDir)
+	require.NoError(t, err)
+	require.Empty(t, cached)
+}
+
+func TestRemoteCarWithoutVersionPagesAreNotCached(t *testing.T) {
+	// The server reports neither ETag nor last modification time of its body, which is replaced
+	// by another of the same size.
+	var lk sync.Mutex
+	body := bytes.Repeat([]byte("a"), 64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lk.Lock()
+		b := body
+		lk.Unlock()
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	cache := newPageCache(16, 16)
+	rc, err := openRemoteCar(ctx, http.DefaultClient, server.URL, cache)
+	require.NoError(t, err)
+	got := make([]byte, len(body))
+	_, err = rc.ReadAt(got, 0)
+	require.NoError(t, err)
+	require.Equal(t, body, got)
+
+	lk.Lock()
+	body = bytes.Repeat([]byte("b"), 64)
+	lk.Unlock()
+	rc, err = openRemoteCar(ctx, http.DefaultClient, server.URL, cache)
+	require.NoError(t, err)
+	_, err = rc.ReadAt(got, 0)
+	require.NoError(t, err)
+	require.Equal(t, body, got)
+}
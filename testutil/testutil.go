@@ -21,6 +21,7 @@ import (
 	"github.com/ipld/go-car/v2/blockstore"
 	"github.com/ipld/go-ipld-prime"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/multiformats/go-multicodec"
 	"github.com/multiformats/go-multihash"
 	"github.com/stretchr/testify/require"
 )
@@ -53,6 +54,22 @@ func RandomMultihashes(t *testing.T, rng *rand.Rand, n int) []multihash.Multihas
 	return mhashes
 }
 
+// RandomPieceCids generates n random CIDs of piece commitments, i.e. with fil-commitment-unsealed
+// codec and sha2-256-trunc254-padded multihash.
+func RandomPieceCids(t testing.TB, rng *rand.Rand, n int) []cid.Cid {
+	cids := make([]cid.Cid, n)
+	for i := 0; i < n; i++ {
+		digest := make([]byte, 32)
+		rng.Read(digest)
+		// Zero the two most significant bits of the last byte, as truncated padded hashes do.
+		digest[31] &= 0b00111111
+		mh, err := multihash.Encode(digest, uint64(multicodec.Sha2_256Trunc254Padded))
+		require.NoError(t, err)
+		cids[i] = cid.NewCidV1(uint64(multicodec.FilCommitmentUnsealed), mh)
+	}
+	return cids
+}
+
 // ThisDir gets the current directory of the source file its called in
 func ThisDir(t *testing.T) string {
 	_, fname, _, ok := runtime.Caller(1)